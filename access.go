@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// accessRecordSize is the size of an encoded access statistic:
+// key hash, hits and last access time in unix nanoseconds
+const accessRecordSize = 8 + 8 + 8
+
+// AccessStat is the sampled access statistic of a single key
+type AccessStat struct {
+	Key        uint64 // hashed key
+	Hits       uint64
+	LastAccess time.Time
+}
+
+type accessStats struct {
+	threshold uint64 // keys hashing below the threshold are sampled
+	stats     map[uint64]*AccessStat
+	mu        *sync.Mutex
+}
+
+func newAccessStats(rate float64) *accessStats {
+	threshold := uint64(math.MaxUint64)
+	if rate < 1 {
+		threshold = uint64(rate * float64(math.MaxUint64))
+	}
+
+	return &accessStats{
+		threshold: threshold,
+		stats:     make(map[uint64]*AccessStat),
+		mu:        &sync.Mutex{},
+	}
+}
+
+// record will count a hit for the key if the key is sampled
+func (a *accessStats) record(key uint64, now time.Time) {
+	if key > a.threshold {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stat, ok := a.stats[key]
+	if !ok {
+		stat = &AccessStat{Key: key}
+		a.stats[key] = stat
+	}
+	stat.Hits++
+	stat.LastAccess = now
+}
+
+// AccessStats will return the sampled access statistics of the cache.
+// Keys are sampled by their hash when AccessSampleRate is configured,
+// so that the same keys are always sampled.
+func (t *Cache) AccessStats() []AccessStat {
+	if t.access == nil {
+		return nil
+	}
+
+	t.access.mu.Lock()
+	defer t.access.mu.Unlock()
+
+	stats := make([]AccessStat, 0, len(t.access.stats))
+	for _, stat := range t.access.stats {
+		stats = append(stats, *stat)
+	}
+
+	return stats
+}
+
+// SaveAccessStats will write the sampled access statistics
+// of the cache to a file of the given name.
+func (t *Cache) SaveAccessStats(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteAccessStats(f, t.AccessStats())
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WriteAccessStats will write access statistics in a compact
+// fixed-size binary format that can be read with ReadAccessStats.
+func WriteAccessStats(w io.Writer, stats []AccessStat) error {
+	bw := bufio.NewWriter(w)
+	var record [accessRecordSize]byte
+	for _, stat := range stats {
+		binary.BigEndian.PutUint64(record[0:], stat.Key)
+		binary.BigEndian.PutUint64(record[8:], stat.Hits)
+		binary.BigEndian.PutUint64(record[16:], uint64(stat.LastAccess.UnixNano()))
+
+		_, err := bw.Write(record[:])
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadAccessStats will read access statistics
+// written with WriteAccessStats or SaveAccessStats.
+func ReadAccessStats(r io.Reader) ([]AccessStat, error) {
+	br := bufio.NewReader(r)
+	var stats []AccessStat
+	var record [accessRecordSize]byte
+	for {
+		_, err := io.ReadFull(br, record[:])
+		if err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, AccessStat{
+			Key:        binary.BigEndian.Uint64(record[0:]),
+			Hits:       binary.BigEndian.Uint64(record[8:]),
+			LastAccess: time.Unix(0, int64(binary.BigEndian.Uint64(record[16:]))).UTC(),
+		})
+	}
+}
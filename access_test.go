@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheAccessStats(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		AccessSampleRate: 1,
+	})
+
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = cache.Get("key")
+		if err != nil {
+			t.Errorf("error while getting key: %+v", err)
+		}
+	}
+
+	stats := cache.AccessStats()
+	if len(stats) != 1 {
+		t.Fatalf("recorded access stats for %d keys", len(stats))
+	}
+
+	if stats[0].Hits != 3 {
+		t.Errorf("recorded %d hits", stats[0].Hits)
+	}
+
+	filename := filepath.Join(t.TempDir(), "access.stats")
+	err = cache.SaveAccessStats(filename)
+	if err != nil {
+		t.Fatalf("error while saving access stats: %+v", err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("error opening access stats: %+v", err)
+	}
+	defer f.Close()
+
+	read, err := ReadAccessStats(f)
+	if err != nil {
+		t.Errorf("error while reading access stats: %+v", err)
+	}
+
+	if len(read) != 1 || read[0].Key != stats[0].Key || read[0].Hits != 3 || !read[0].LastAccess.Equal(stats[0].LastAccess) {
+		t.Errorf("read access stats %+v did not match %+v", read, stats)
+	}
+}
+
+func TestCacheAccessStatsDisabled(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	_, err = cache.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if len(cache.AccessStats()) != 0 {
+		t.Error("access stats were recorded without sampling")
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import "time"
+
+// AddNX will add the item at the key if the key holds no unexpired item,
+// returning true, or otherwise return false along with the item already
+// stored, so that the caller does not need a racy Get after ErrCollision.
+// An error is returned if the key collides with a different key or the
+// item cannot be stored, and ErrRejected if the Admission policy rejects
+// it, as with Add.
+func (t *Cache) AddNX(key string, item interface{}, expiresIn time.Duration, opts ...AddOption) (bool, interface{}, error) {
+	o := newAddOptions(opts)
+	err := t.enterAdd(&o)
+	if err != nil {
+		return false, nil, err
+	}
+	defer t.exitAdd(&o)
+
+	var existing interface{}
+	var added bool
+	err = t.write(func() error {
+		hashedKey := t.hash(key)
+
+		if idx, ok := t.live(hashedKey); ok {
+			slot := t.slots[idx]
+			if slot.name != key {
+				return t.collision()
+			}
+
+			if !t.now().After(slot.ExpiresAt) {
+				existing = slot.Item
+				return nil
+			}
+		}
+
+		err := t.admit(hashedKey, key, item, o)
+		if err != nil {
+			return err
+		}
+
+		expiresIn = t.ttl(expiresIn)
+		tx := t.beginStore(hashedKey, key)
+		err = t.place(hashedKey, key, item, t.expiration(t.jitter(expiresIn)), o)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+		t.added(key, expiresIn, o)
+		added = true
+
+		return nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	return added, existing, nil
+}
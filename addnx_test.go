@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAddNX(t *testing.T) {
+	cache := NewCache(nil)
+
+	added, existing, err := cache.AddNX("key", "first", 10*time.Minute)
+	if err != nil || !added || existing != nil {
+		t.Errorf("expected the item to be added, got %v %v: %+v", added, existing, err)
+	}
+
+	added, existing, err = cache.AddNX("key", "second", 10*time.Minute)
+	if err != nil || added || existing != "first" {
+		t.Errorf("expected the existing item, got %v %v: %+v", added, existing, err)
+	}
+
+	// expired and soft deleted items are replaced
+	cache.Add("expired", 1, time.Millisecond)
+	cache.Add("hidden", 1, 10*time.Minute)
+	cache.SoftDelete("hidden")
+	time.Sleep(5 * time.Millisecond)
+
+	for _, key := range []string{"expired", "hidden"} {
+		added, _, err := cache.AddNX(key, 2, 10*time.Minute)
+		if err != nil || !added {
+			t.Errorf("expected %s to be replaced, got %v: %+v", key, added, err)
+		}
+
+		if item, _ := cache.Get(key); item != 2 {
+			t.Errorf("unexpected item %v for %s", item, key)
+		}
+	}
+}
+
+func TestAddNXConcurrent(t *testing.T) {
+	cache := NewCache(nil)
+
+	var winners int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			added, existing, err := cache.AddNX("key", i, 10*time.Minute)
+			if err != nil {
+				t.Errorf("AddNX error: %+v", err)
+			} else if added {
+				atomic.AddInt64(&winners, 1)
+			} else if existing == nil {
+				t.Errorf("expected the existing item to be returned")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if winners != 1 {
+		t.Errorf("expected one caller to add the item, got %d", winners)
+	}
+}
+
+func TestAddNXAdmissionAndTrace(t *testing.T) {
+	trace := NewTraceRecorder(10)
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     2,
+		EvictionPolicy: EvictLRU,
+		Admission:      AdmitByCost,
+		Trace:          trace,
+	})
+	defer cache.Close()
+
+	cache.AddNX("expensive", 1, time.Hour, WithCost(100))
+	cache.AddNX("moderate", 2, time.Hour, WithCost(10))
+
+	if _, _, err := cache.AddNX("cheap", 3, time.Hour, WithCost(1)); err != ErrRejected {
+		t.Errorf("expected the cheap item to be rejected, got %+v", err)
+	}
+
+	if !cache.Deduplicate("delivery", time.Hour) {
+		t.Errorf("expected a rejected key to be reported as unseen")
+	}
+
+	if item, err := cache.Pop("moderate"); err != nil || item != 2 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	if !cache.Deduplicate("delivery", time.Hour) || cache.Deduplicate("delivery", time.Hour) {
+		t.Errorf("expected the key to be marked once there is room")
+	}
+
+	var ops []TraceOp
+	for _, e := range trace.Events() {
+		ops = append(ops, e.Op)
+	}
+
+	want := []TraceOp{TraceAdd, TraceAdd, TraceGet, TraceDelete, TraceAdd}
+	if len(ops) != len(want) {
+		t.Fatalf("expected the events %v, got %v", want, ops)
+	}
+
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("expected the events %v, got %v", want, ops)
+			break
+		}
+	}
+}
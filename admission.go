@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrRejected is returned when the Admission policy of a full
+// cache rejects an item in favor of the item it would evict
+var ErrRejected = errors.New("item rejected by admission policy")
+
+// Candidate describes an item competing for a place in a full cache
+type Candidate struct {
+	Key  string
+	Cost int64 // cost of recomputing the item, given with WithCost
+	Size int64 // size of the item measured by the Sizer
+}
+
+// Admission is a policy that decides whether an item added to a full
+// cache is admitted in place of the victim, the first item the eviction
+// policy would evict for it. A rejected item is not added and Add
+// returns ErrRejected, keeping the victim in the cache.
+type Admission func(candidate, victim Candidate) bool
+
+// AdmitByCost admits an item only if it is at least as costly to
+// recompute as the victim, so that expensive items are retained and
+// cheap items are rejected once the cache is full
+func AdmitByCost(candidate, victim Candidate) bool {
+	return candidate.Cost >= victim.Cost
+}
+
+// AdmitByCostDensity admits an item only if its cost per byte is
+// at least that of the victim, preferring small expensive items
+func AdmitByCostDensity(candidate, victim Candidate) bool {
+	return density(candidate) >= density(victim)
+}
+
+func density(c Candidate) float64 {
+	if c.Size <= 0 {
+		return float64(c.Cost)
+	}
+
+	return float64(c.Cost) / float64(c.Size)
+}
+
+// WithCost will record the cost of recomputing the added item, such as
+// the milliseconds taken to load it, for the Admission policy
+func WithCost(cost int64) AddOption {
+	return addOptionFunc(func(o *addOptions) {
+		o.cost = cost
+	})
+}
+
+// admit will decide with the Admission policy whether the item may be
+// added when adding it would evict another item, returning ErrRejected
+// if it may not. The lock must be held.
+func (t *Cache) admit(key uint64, name string, item interface{}, o addOptions) error {
+	if t.config.Admission == nil {
+		return nil
+	}
+
+	if _, ok := t.keys[key]; ok {
+		return nil
+	}
+
+	size := t.measure(item)
+	full := t.config.MaxEntries > 0 && len(t.keys)+1 > t.config.MaxEntries
+	if t.config.MaxBytes > 0 && t.bytes+size > t.config.MaxBytes {
+		full = true
+	}
+	if !full {
+		return nil
+	}
+
+	victim, ok := t.victim(t.unpinned(func(key uint64) bool {
+		return false
+	}))
+	if !ok {
+		return nil
+	}
+
+	idx, ok := t.keys[victim]
+	if !ok {
+		return nil
+	}
+
+	slot := t.slots[idx]
+	candidate := Candidate{Key: name, Cost: o.cost, Size: size}
+	if t.config.Admission(candidate, Candidate{Key: slot.name, Cost: slot.cost, Size: slot.size}) {
+		return nil
+	}
+	atomic.AddUint64(&t.counters.rejections, 1)
+
+	return ErrRejected
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdmission(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     2,
+		EvictionPolicy: EvictLRU,
+		Admission:      AdmitByCost,
+	})
+	defer cache.Close()
+
+	cache.Add("expensive", 1, time.Hour, WithCost(100))
+	cache.Add("moderate", 2, time.Hour, WithCost(10))
+
+	if err := cache.Add("cheap", 3, time.Hour, WithCost(1)); err != ErrRejected {
+		t.Errorf("expected the cheap item to be rejected, got %+v", err)
+	}
+
+	if _, err := cache.Get("cheap"); err != ErrDNE {
+		t.Errorf("expected the rejected item not to be added, got %+v", err)
+	}
+
+	// the least recently used item is the victim
+	cache.Get("expensive")
+	if err := cache.Add("costly", 4, time.Hour, WithCost(50)); err != nil {
+		t.Errorf("expected the costly item to be admitted, got %+v", err)
+	}
+
+	if _, err := cache.Get("moderate"); err != ErrDNE {
+		t.Errorf("expected the victim to be evicted, got %+v", err)
+	}
+
+	if _, err := cache.Get("expensive"); err != nil {
+		t.Errorf("expected the expensive item to be retained, got %+v", err)
+	}
+
+	if err := cache.Set("expensive", 5, time.Hour); err != nil {
+		t.Errorf("expected replacing an item not to be subject to admission, got %+v", err)
+	}
+
+	if stats := cache.Stats(); stats.Rejections != 1 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAdmitByCostDensity(t *testing.T) {
+	small := Candidate{Cost: 10, Size: 10}
+	large := Candidate{Cost: 50, Size: 100}
+
+	if !AdmitByCostDensity(small, large) || AdmitByCostDensity(large, small) {
+		t.Errorf("expected items with a higher cost per byte to be admitted")
+	}
+
+	cache := NewCache(&CacheConfig{MaxBytes: 10, Admission: AdmitByCostDensity})
+	defer cache.Close()
+
+	bucket := cache.Bucket("bucket")
+	bucket.Add("a", "0123456789", time.Hour, WithCost(10))
+	if err := bucket.Add("b", "01234", time.Hour, WithCost(1)); err != ErrRejected {
+		t.Errorf("expected the bucket item to be rejected, got %+v", err)
+	}
+}
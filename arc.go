@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// arcEvictor is an Adaptive Replacement Cache policy. Resident keys are
+// split between a recency list (t1, seen once) and a frequency list (t2,
+// seen again). Evicted keys are remembered in ghost lists (b1, b2), and
+// hits on ghosts adapt the target size p of the recency list.
+type arcEvictor struct {
+	t1, t2, b1, b2 *list.List // most recent at the front
+	items          map[uint64]*arcEntry
+	p              int    // target size of t1
+	victim         uint64 // last key returned by Victim
+	hasVictim      bool
+	mu             *sync.Mutex
+}
+
+type arcEntry struct {
+	list *list.List
+	el   *list.Element
+}
+
+func newARCEvictor() *arcEvictor {
+	return &arcEvictor{
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		items: make(map[uint64]*arcEntry),
+		mu:    &sync.Mutex{},
+	}
+}
+
+func (e *arcEvictor) Add(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entry, ok := e.items[key]
+	if !ok {
+		e.move(key, e.t1)
+		return
+	}
+
+	switch entry.list {
+	case e.b1:
+		e.p = minInt(e.p+maxInt(e.b2.Len()/maxInt(e.b1.Len(), 1), 1), e.capacity())
+		e.move(key, e.t2)
+	case e.b2:
+		e.p = maxInt(e.p-maxInt(e.b1.Len()/maxInt(e.b2.Len(), 1), 1), 0)
+		e.move(key, e.t2)
+	default:
+		e.move(key, e.t2)
+	}
+}
+
+func (e *arcEvictor) Access(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if entry, ok := e.items[key]; ok && (entry.list == e.t1 || entry.list == e.t2) {
+		e.move(key, e.t2)
+	}
+}
+
+func (e *arcEvictor) Remove(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	entry, ok := e.items[key]
+	if !ok {
+		return
+	}
+
+	// only evicted keys are remembered as ghosts, deleted keys are forgotten
+	if e.hasVictim && e.victim == key {
+		e.hasVictim = false
+		switch entry.list {
+		case e.t1:
+			e.move(key, e.b1)
+		case e.t2:
+			e.move(key, e.b2)
+		}
+		e.trimGhosts()
+		return
+	}
+
+	entry.list.Remove(entry.el)
+	delete(e.items, key)
+}
+
+func (e *arcEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	first, second := e.t2, e.t1
+	if e.t1.Len() > 0 && e.t1.Len() > e.p {
+		first, second = e.t1, e.t2
+	}
+
+	for _, l := range []*list.List{first, second} {
+		for el := l.Back(); el != nil; el = el.Prev() {
+			key := el.Value.(uint64)
+			if !skip(key) {
+				e.victim = key
+				e.hasVictim = true
+				return key, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// capacity is the number of resident keys
+func (e *arcEvictor) capacity() int {
+	return e.t1.Len() + e.t2.Len()
+}
+
+// move will put the key at the front of the list
+func (e *arcEvictor) move(key uint64, to *list.List) {
+	if entry, ok := e.items[key]; ok {
+		entry.list.Remove(entry.el)
+	}
+
+	e.items[key] = &arcEntry{
+		list: to,
+		el:   to.PushFront(key),
+	}
+}
+
+// trimGhosts will keep each ghost list no longer than the resident keys
+func (e *arcEvictor) trimGhosts() {
+	for _, l := range []*list.List{e.b1, e.b2} {
+		for l.Len() > maxInt(e.capacity(), 1) {
+			el := l.Back()
+			l.Remove(el)
+			delete(e.items, el.Value.(uint64))
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvictARC(t *testing.T) {
+	testEvictionPolicy(t, EvictARC, "b")
+}
+
+func TestEvictARCGhosts(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     2,
+		EvictionPolicy: EvictARC,
+	})
+	arc := cache.evictor.(*arcEvictor)
+
+	for _, key := range []string{"a", "b", "c"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	if arc.b1.Len() != 1 {
+		t.Errorf("evicted key was not remembered as a ghost: %d ghosts", arc.b1.Len())
+	}
+
+	err := cache.Delete("c")
+	if err != nil {
+		t.Errorf("error while deleting key: %+v", err)
+	}
+
+	if arc.b1.Len() != 1 || arc.t1.Len() != 1 {
+		t.Errorf("deleted key was remembered as a ghost: %d ghosts", arc.b1.Len())
+	}
+
+	// re-adding the ghost should grow the recency target
+	err = cache.Add("a", "a", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	if arc.p == 0 || arc.t2.Len() != 1 {
+		t.Errorf("ghost hit did not adapt the policy: p=%d", arc.p)
+	}
+}
+
+func BenchmarkHitRatioARC(b *testing.B) {
+	benchmarkHitRatio(b, EvictARC)
+}
+
+func TestEvictARCChurn(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     10,
+		EvictionPolicy: EvictARC,
+	})
+	arc := cache.evictor.(*arcEvictor)
+
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i % 37)
+		if _, err := cache.Get(key); err == ErrDNE {
+			cache.Add(key, i, 10*time.Minute)
+		}
+	}
+
+	if arc.capacity() != len(cache.keys) {
+		t.Errorf("policy tracked %d keys for %d cached keys", arc.capacity(), len(cache.keys))
+	}
+
+	if arc.b1.Len()+arc.b2.Len() > 2*arc.capacity() {
+		t.Errorf("ghost lists grew to %d keys", arc.b1.Len()+arc.b2.Len())
+	}
+}
@@ -0,0 +1,373 @@
+package cache
+
+import (
+	"bytes"
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var defaultPageSize = 1 << 20
+
+// BytesConfig is used to configure a BytesCache
+type BytesConfig struct {
+	PageSize int     // size of the pages values are packed into, defaults to 1MB
+	MaxBytes int64   // size of the pages beyond which the oldest page and its values are dropped, 0 is unbounded
+	Clock    Clock   // source of time for expirations, defaults to the system clock
+	Storage  Storage // provides the memory of the pages, defaults to pooled pages on the Go heap
+}
+
+// BytesCache is a cache of byte slices for caches holding millions of
+// small values. Rather than each value being its own allocation behind
+// an interface{}, keys and values are copied into large pooled pages
+// and indexed by a map without pointers, which the garbage collector
+// does not need to scan. GC time therefore grows with the number of
+// pages instead of the number of values.
+//
+// Space freed by deleting or replacing a value is reclaimed once every
+// value in its page is gone, or when the page is dropped for MaxBytes.
+// With an MmapStorage the pages are kept outside of the Go heap entirely,
+// leaving only the index on it.
+type BytesCache struct {
+	config  *BytesConfig
+	seed    maphash.Seed
+	index   map[uint64]arenaEntry
+	pages   map[uint32]*arenaPage
+	order   []*arenaPage // pages in order of creation, oldest first
+	current *arenaPage   // page new values are appended to
+	nextID  uint32
+	bytes   int64
+	storage Storage
+	mu      *sync.RWMutex
+}
+
+// arenaEntry locates a key and its value within a page
+type arenaEntry struct {
+	page    uint32
+	off     uint32
+	keyLen  uint32
+	valLen  uint32
+	expires int64 // unix nanoseconds, 0 never expires
+}
+
+// arenaPage is a block of memory values are appended to. A page is
+// returned to the storage once it holds no values and none of its values
+// are being viewed.
+type arenaPage struct {
+	id      uint32
+	buf     []byte
+	used    int
+	live    int      // values stored in the page that have not been deleted
+	hashes  []uint64 // keys of the values stored in the page
+	refs    int32    // calls to View reading the page
+	dropped bool     // the page has been removed from the cache
+}
+
+// NewBytesCache will create and return a pointer to a new BytesCache
+func NewBytesCache(config *BytesConfig) *BytesCache {
+	if config == nil {
+		config = &BytesConfig{}
+	}
+
+	if config.PageSize <= 0 {
+		config.PageSize = defaultPageSize
+	}
+
+	if config.MaxBytes > 0 && int64(config.PageSize) > config.MaxBytes {
+		config.PageSize = int(config.MaxBytes)
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	if config.Storage == nil {
+		config.Storage = newHeapStorage(config.PageSize)
+	}
+
+	return &BytesCache{
+		config:  config,
+		seed:    maphash.MakeSeed(),
+		index:   make(map[uint64]arenaEntry),
+		pages:   make(map[uint32]*arenaPage),
+		storage: config.Storage,
+		mu:      &sync.RWMutex{},
+	}
+}
+
+// Add will copy the key and value into the cache, replacing the value
+// if the key already exists. An expiresIn of DefaultExpiration or
+// NoExpiration never expires the value. It will return ErrCollision
+// if another key with the same hash is in the cache, and ErrTooLarge
+// if the key and value do not fit within MaxBytes. Errors allocating
+// a page are returned from the Storage.
+func (c *BytesCache) Add(key string, value []byte, expiresIn time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	size := len(key) + len(value)
+	if c.config.MaxBytes > 0 && int64(size) > c.config.MaxBytes {
+		return ErrTooLarge
+	}
+
+	hk := c.hash(key)
+	now := c.config.Clock.Now()
+	if e, ok := c.index[hk]; ok {
+		if !c.expired(e, now) && c.key(e) != key {
+			return ErrCollision
+		}
+		c.free(hk, e)
+	}
+
+	var expires int64
+	if expiresIn != DefaultExpiration && expiresIn != NoExpiration {
+		expires = now.Add(expiresIn).UnixNano()
+	}
+
+	p, err := c.alloc(size)
+	if err != nil {
+		return err
+	}
+
+	off := p.used
+	copy(p.buf[off:], key)
+	copy(p.buf[off+len(key):], value)
+	p.used += size
+	p.live++
+	p.hashes = append(p.hashes, hk)
+
+	c.index[hk] = arenaEntry{
+		page:    p.id,
+		off:     uint32(off),
+		keyLen:  uint32(len(key)),
+		valLen:  uint32(len(value)),
+		expires: expires,
+	}
+
+	return nil
+}
+
+// Get will return a copy of the value stored at the key
+func (c *BytesCache) Get(key string) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	p, e, err := c.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+
+	value := make([]byte, e.valLen)
+	copy(value, c.value(p, e))
+
+	return value, nil
+}
+
+// View will call fn with the value stored at the key without copying
+// it. The value must not be modified or retained after fn returns.
+// The lock is not held while fn runs, so fn may use the cache.
+func (c *BytesCache) View(key string, fn func(value []byte)) error {
+	c.mu.RLock()
+	p, e, err := c.lookup(key)
+	if err != nil {
+		c.mu.RUnlock()
+		return err
+	}
+	atomic.AddInt32(&p.refs, 1)
+	value := c.value(p, e)
+	c.mu.RUnlock()
+
+	defer c.release(p)
+	fn(value)
+
+	return nil
+}
+
+// Delete will remove the value stored at the key
+func (c *BytesCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hk := c.hash(key)
+	e, ok := c.index[hk]
+	if !ok || c.key(e) != key {
+		return ErrDNE
+	}
+
+	c.free(hk, e)
+	return nil
+}
+
+// Len will return the number of values in the cache,
+// including expired values that have not been reclaimed
+func (c *BytesCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.index)
+}
+
+// Bytes will return the size of the pages held by the cache
+func (c *BytesCache) Bytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.bytes
+}
+
+// Close will remove every value and release the memory held by the
+// cache's Storage. It must not be called while a View is in progress.
+func (c *BytesCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.order) > 0 {
+		c.drop(c.order[0])
+	}
+
+	return c.storage.Close()
+}
+
+func (c *BytesCache) hash(key string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(c.seed)
+	h.WriteString(key)
+	return h.Sum64()
+}
+
+// lookup will return the page and entry of the live value at the key.
+// The lock must be held.
+func (c *BytesCache) lookup(key string) (*arenaPage, arenaEntry, error) {
+	e, ok := c.index[c.hash(key)]
+	if !ok || c.expired(e, c.config.Clock.Now()) {
+		return nil, e, ErrDNE
+	}
+
+	p := c.pages[e.page]
+	if !bytes.Equal(p.buf[e.off:e.off+e.keyLen], []byte(key)) {
+		return nil, e, ErrDNE
+	}
+
+	return p, e, nil
+}
+
+func (c *BytesCache) expired(e arenaEntry, now time.Time) bool {
+	return e.expires != 0 && now.UnixNano() > e.expires
+}
+
+func (c *BytesCache) key(e arenaEntry) string {
+	p := c.pages[e.page]
+	return string(p.buf[e.off : e.off+e.keyLen])
+}
+
+func (c *BytesCache) value(p *arenaPage, e arenaEntry) []byte {
+	start := e.off + e.keyLen
+	return p.buf[start : start+e.valLen : start+e.valLen]
+}
+
+// free will remove the entry at the hashed key, dropping its
+// page once it holds no other values. The lock must be held.
+func (c *BytesCache) free(hk uint64, e arenaEntry) {
+	delete(c.index, hk)
+
+	p := c.pages[e.page]
+	p.live--
+	if p.live == 0 && p != c.current {
+		c.drop(p)
+	}
+}
+
+// alloc will return a page with room for size bytes, starting a new
+// page and dropping the oldest pages beyond MaxBytes if needed.
+// Values larger than a page are given a page of their own.
+// The lock must be held.
+func (c *BytesCache) alloc(size int) (*arenaPage, error) {
+	if c.current != nil && c.current.used+size <= len(c.current.buf) {
+		return c.current, nil
+	}
+
+	if c.current != nil && c.current.live == 0 {
+		c.drop(c.current)
+	}
+
+	pageSize := c.config.PageSize
+	if size > pageSize {
+		pageSize = size
+	}
+
+	for c.config.MaxBytes > 0 && c.bytes+int64(pageSize) > c.config.MaxBytes && len(c.order) > 0 {
+		c.drop(c.order[0])
+	}
+
+	buf, err := c.storage.Alloc(pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	c.nextID++
+	p := &arenaPage{id: c.nextID, buf: buf}
+	c.pages[p.id] = p
+	c.order = append(c.order, p)
+	c.bytes += int64(len(buf))
+
+	if size <= c.config.PageSize {
+		c.current = p
+	}
+
+	return p, nil
+}
+
+// drop will remove the page and any values still stored in it,
+// recycling it once it is no longer viewed. The lock must be held.
+func (c *BytesCache) drop(p *arenaPage) {
+	for _, hk := range p.hashes {
+		if e, ok := c.index[hk]; ok && e.page == p.id {
+			delete(c.index, hk)
+		}
+	}
+
+	delete(c.pages, p.id)
+	for i, o := range c.order {
+		if o == p {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+
+	if c.current == p {
+		c.current = nil
+	}
+
+	c.bytes -= int64(len(p.buf))
+	p.dropped = true
+	if atomic.LoadInt32(&p.refs) == 0 {
+		c.recycle(p)
+	}
+}
+
+// release will end a View of the page, recycling it
+// if it was dropped while being viewed
+func (c *BytesCache) release(p *arenaPage) {
+	if atomic.AddInt32(&p.refs, -1) > 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p.dropped && atomic.LoadInt32(&p.refs) == 0 {
+		c.recycle(p)
+	}
+}
+
+// recycle will return the page's memory to the storage. The lock must be held.
+func (c *BytesCache) recycle(p *arenaPage) {
+	if p.buf == nil {
+		return
+	}
+
+	c.storage.Free(p.buf)
+	p.buf = nil
+	p.hashes = nil
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBytesCache(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	c := NewBytesCache(&BytesConfig{PageSize: 64, Clock: clock})
+
+	err := c.Add("key", []byte("value"), time.Minute)
+	if err != nil {
+		t.Fatalf("Add error: %+v", err)
+	}
+
+	value, err := c.Get("key")
+	if err != nil || string(value) != "value" {
+		t.Errorf("expected the value, got %q %+v", value, err)
+	}
+
+	value[0] = 'V'
+	if value, _ := c.Get("key"); string(value) != "value" {
+		t.Errorf("expected Get to return a copy, got %q", value)
+	}
+
+	c.Add("key", []byte("replaced"), NoExpiration)
+	err = c.View("key", func(value []byte) {
+		if string(value) != "replaced" {
+			t.Errorf("expected the replaced value, got %q", value)
+		}
+	})
+	if err != nil {
+		t.Errorf("View error: %+v", err)
+	}
+
+	c.Add("short", []byte("lived"), time.Minute)
+	clock.Advance(2 * time.Minute)
+	if _, err := c.Get("short"); err != ErrDNE {
+		t.Errorf("expected the value to expire, got %+v", err)
+	}
+
+	if _, err := c.Get("key"); err != nil {
+		t.Errorf("expected the value to never expire, got %+v", err)
+	}
+
+	if err := c.Delete("key"); err != nil {
+		t.Errorf("Delete error: %+v", err)
+	}
+
+	if err := c.Delete("key"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	large := bytes.Repeat([]byte("x"), 100)
+	c.Add("large", large, 0)
+	if value, _ := c.Get("large"); !bytes.Equal(value, large) {
+		t.Errorf("expected a value larger than a page to be stored")
+	}
+}
+
+func TestBytesCacheReclaim(t *testing.T) {
+	c := NewBytesCache(&BytesConfig{PageSize: 32})
+
+	for i := 0; i < 8; i++ {
+		c.Add(fmt.Sprintf("key-%d", i), []byte("0123456789"), 0)
+	}
+
+	held := c.Bytes()
+	for i := 0; i < 8; i++ {
+		c.Delete(fmt.Sprintf("key-%d", i))
+	}
+
+	if c.Bytes() >= held || c.Len() != 0 {
+		t.Errorf("expected emptied pages to be reclaimed, held %d of %d", c.Bytes(), held)
+	}
+}
+
+func TestBytesCacheMaxBytes(t *testing.T) {
+	c := NewBytesCache(&BytesConfig{PageSize: 32, MaxBytes: 64})
+
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("key-%d", i), []byte("0123456789"), 0)
+	}
+
+	if c.Bytes() > 64 {
+		t.Errorf("expected at most 64 bytes of pages, got %d", c.Bytes())
+	}
+
+	if _, err := c.Get("key-0"); err != ErrDNE {
+		t.Errorf("expected the oldest values to be dropped, got %+v", err)
+	}
+
+	if _, err := c.Get("key-9"); err != nil {
+		t.Errorf("expected the newest value to be kept, got %+v", err)
+	}
+
+	if err := c.Add("huge", make([]byte, 100), 0); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %+v", err)
+	}
+}
+
+func TestBytesCacheViewDuringDrop(t *testing.T) {
+	c := NewBytesCache(&BytesConfig{PageSize: 32, MaxBytes: 32})
+	c.Add("key", []byte("value"), 0)
+
+	err := c.View("key", func(value []byte) {
+		// drops the page being viewed
+		c.Add("other", bytes.Repeat([]byte("x"), 25), 0)
+
+		if string(value) != "value" {
+			t.Errorf("expected the viewed value to stay intact, got %q", value)
+		}
+	})
+	if err != nil {
+		t.Errorf("View error: %+v", err)
+	}
+
+	if _, err := c.Get("key"); err != ErrDNE {
+		t.Errorf("expected the dropped value to be gone, got %+v", err)
+	}
+}
+
+const gcBenchValues = 200000
+
+// benchmarkGC will time full collections with the values held in memory,
+// reporting the average stop-the-world pause of each collection
+func benchmarkGC(b *testing.B, add func(key string, value []byte)) {
+	value := make([]byte, 64)
+	for i := 0; i < gcBenchValues; i++ {
+		add(fmt.Sprintf("key-%d", i), value)
+	}
+	runtime.GC()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	if n := after.NumGC - before.NumGC; n > 0 {
+		b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(n), "pause-ns/gc")
+	}
+}
+
+func BenchmarkGCCache(b *testing.B) {
+	c := NewCache(&CacheConfig{MaxBytes: -1})
+	defer c.Close()
+
+	benchmarkGC(b, func(key string, value []byte) {
+		v := make([]byte, len(value))
+		copy(v, value)
+		c.Add(key, v, NoExpiration)
+	})
+	runtime.KeepAlive(c)
+}
+
+func BenchmarkGCBytesCache(b *testing.B) {
+	c := NewBytesCache(nil)
+
+	benchmarkGC(b, func(key string, value []byte) {
+		c.Add(key, value, NoExpiration)
+	})
+	runtime.KeepAlive(c)
+}
@@ -0,0 +1,173 @@
+// Package bench simulates workloads against a cache to compare its
+// eviction policies. A Workload generates the keys of a sequence of
+// requests, and Simulate replays them against a cache bounded by
+// MaxEntries, reading each key and adding it on a miss, to report the
+// hit ratio and the allocations made by the cache.
+//
+// Workloads are seeded, so that a simulation run twice requests the
+// same keys. The hit ratio is then the same on every run, except for
+// EvictRandom and EvictLFU, which choose their victims from a random
+// sample of the keys.
+package bench
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+// Policies are the eviction policies compared by default
+var Policies = []cache.EvictionPolicy{
+	cache.EvictLRU,
+	cache.EvictLFU,
+	cache.EvictCLOCK,
+	cache.EvictARC,
+	cache.EvictRandom,
+	cache.EvictTTL,
+}
+
+// Workload generates the keys of a sequence of requests
+type Workload interface {
+	// Next will return the key of the next request
+	Next() string
+}
+
+// WorkloadFunc adapts a function to a Workload
+type WorkloadFunc func() string
+
+// Next will call the function
+func (f WorkloadFunc) Next() string {
+	return f()
+}
+
+func key(n uint64) string {
+	return "key-" + strconv.FormatUint(n, 10)
+}
+
+// Zipf will return a workload requesting keys in [0, keys) following
+// a zipfian distribution with the exponent s, which must be greater
+// than 1. The larger s is, the more requests go to the hottest keys.
+func Zipf(seed int64, s float64, keys uint64) Workload {
+	z := rand.NewZipf(rand.New(rand.NewSource(seed)), s, 1, keys-1)
+	return WorkloadFunc(func() string {
+		return key(z.Uint64())
+	})
+}
+
+// Loop will return a workload requesting the keys in [0, keys) in
+// order over and over. A loop larger than the cache is the worst
+// case for LRU, which evicts every key just before it is requested.
+func Loop(keys uint64) Workload {
+	var n uint64
+	return WorkloadFunc(func() string {
+		k := n % keys
+		n++
+		return key(k)
+	})
+}
+
+// Scan will return a workload interrupting the requests of the base
+// workload with a scan of length keys every interval requests. Each
+// scan requests keys that are never requested again, which pushes
+// the hot keys of the base workload out of caches that do not
+// resist scans.
+func Scan(base Workload, interval, length int) Workload {
+	var n, scanned uint64
+	return WorkloadFunc(func() string {
+		n++
+		if interval > 0 && int(n%uint64(interval+length)) >= interval {
+			scanned++
+			return "scan-" + strconv.FormatUint(scanned, 10)
+		}
+
+		return base.Next()
+	})
+}
+
+// Result is the outcome of a simulation
+type Result struct {
+	Policy   cache.EvictionPolicy
+	Requests int
+	Hits     int
+	Misses   int
+	HitRatio float64       // share of the requests that were hits
+	Allocs   uint64        // heap allocations made by the cache
+	Bytes    uint64        // heap bytes allocated by the cache
+	Duration time.Duration // time taken to replay the requests
+}
+
+// AllocsPerOp will return the allocations made by the cache per request
+func (r Result) AllocsPerOp() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+
+	return float64(r.Allocs) / float64(r.Requests)
+}
+
+// Simulate will replay the requests of the workload against a cache of
+// the capacity with the eviction policy. The keys are generated before
+// the replay, so that only the allocations of the cache are counted.
+func Simulate(policy cache.EvictionPolicy, capacity, requests int, workload Workload) Result {
+	keys := make([]string, requests)
+	for i := range keys {
+		keys[i] = workload.Next()
+	}
+
+	c := cache.NewCache(&cache.CacheConfig{
+		MaxEntries:     capacity,
+		MaxBytes:       -1,
+		EvictionPolicy: policy,
+		DisableCleaner: true,
+	})
+	defer c.Close()
+
+	r := Result{
+		Policy:   policy,
+		Requests: requests,
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for _, k := range keys {
+		if _, err := c.Get(k); err == nil {
+			r.Hits++
+			continue
+		}
+
+		r.Misses++
+		c.Add(k, struct{}{}, cache.NoExpiration)
+	}
+
+	r.Duration = time.Since(start)
+	runtime.ReadMemStats(&after)
+	r.Allocs = after.Mallocs - before.Mallocs
+	r.Bytes = after.TotalAlloc - before.TotalAlloc
+	if requests > 0 {
+		r.HitRatio = float64(r.Hits) / float64(requests)
+	}
+
+	return r
+}
+
+// Compare will simulate the workload against each policy, or against
+// Policies if none are given. The workload is created anew for every
+// policy, so that each replays the same requests.
+func Compare(capacity, requests int, workload func() Workload, policies ...cache.EvictionPolicy) []Result {
+	if len(policies) == 0 {
+		policies = Policies
+	}
+
+	results := make([]Result, 0, len(policies))
+	for _, p := range policies {
+		results = append(results, Simulate(p, capacity, requests, workload()))
+	}
+
+	return results
+}
@@ -0,0 +1,85 @@
+package bench
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JKhawaja/cache"
+)
+
+func TestWorkloads(t *testing.T) {
+	a, b := Zipf(1, 1.1, 1000), Zipf(1, 1.1, 1000)
+	for i := 0; i < 100; i++ {
+		if ka, kb := a.Next(), b.Next(); ka != kb {
+			t.Fatalf("expected seeded workloads to match, got %s and %s", ka, kb)
+		}
+	}
+
+	loop := Loop(3)
+	var keys []string
+	for i := 0; i < 4; i++ {
+		keys = append(keys, loop.Next())
+	}
+	if fmt.Sprint(keys) != "[key-0 key-1 key-2 key-0]" {
+		t.Errorf("expected the loop to repeat, got %v", keys)
+	}
+
+	scan := Scan(Loop(1), 2, 2)
+	keys = nil
+	for i := 0; i < 6; i++ {
+		keys = append(keys, scan.Next())
+	}
+	if fmt.Sprint(keys) != "[key-0 scan-1 scan-2 key-0 key-0 scan-3]" {
+		t.Errorf("expected scans between the base requests, got %v", keys)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	r := Simulate(cache.EvictLRU, 10, 1000, Loop(11))
+	if r.Hits != 0 || r.Misses != 1000 {
+		t.Errorf("expected LRU to miss every request of a larger loop, got %+v", r)
+	}
+
+	r = Simulate(cache.EvictLRU, 10, 1000, Loop(10))
+	if r.Misses != 10 || r.HitRatio != 0.99 {
+		t.Errorf("expected only the first pass of a loop that fits to miss, got %+v", r)
+	}
+
+	results := Compare(100, 10000, func() Workload {
+		return Zipf(1, 1.2, 10000)
+	}, cache.EvictLRU, cache.EvictCLOCK, cache.EvictARC)
+	again := Compare(100, 10000, func() Workload {
+		return Zipf(1, 1.2, 10000)
+	}, cache.EvictLRU, cache.EvictCLOCK, cache.EvictARC)
+
+	for i, r := range results {
+		if r.Hits == 0 || r.Hits != again[i].Hits {
+			t.Errorf("expected %s to hit the same requests on each run, got %d and %d", r.Policy, r.Hits, again[i].Hits)
+		}
+
+		if r.Allocs == 0 {
+			t.Errorf("expected the allocations of %s to be counted", r.Policy)
+		}
+	}
+}
+
+func BenchmarkPolicies(b *testing.B) {
+	workloads := map[string]func() Workload{
+		"zipf": func() Workload { return Zipf(1, 1.1, 100000) },
+		"loop": func() Workload { return Loop(1500) },
+		"scan": func() Workload { return Scan(Zipf(1, 1.1, 100000), 5000, 2000) },
+	}
+
+	for name, workload := range workloads {
+		for _, p := range Policies {
+			b.Run(name+"/"+p.String(), func(b *testing.B) {
+				var r Result
+				for i := 0; i < b.N; i++ {
+					r = Simulate(p, 1000, 100000, workload())
+				}
+				b.ReportMetric(r.HitRatio, "hit-ratio")
+				b.ReportMetric(r.AllocsPerOp(), "allocs/request")
+			})
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+var defaultBloomFPRate = 0.01
+
+// bloom is a counting bloom filter of the keys in the cache, which
+// can tell that a key is absent without taking the cache lock. Its
+// saturating 8-bit counters are packed four to a word and updated
+// atomically, so that lookups can run alongside writes. The filter is
+// written with the cache lock held.
+type bloom struct {
+	words  []uint32
+	size   uint64 // number of counters
+	hashes int
+}
+
+// newBloom will size a filter for the number of keys at the false positive rate
+func newBloom(capacity int, fpRate float64) *bloom {
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = defaultBloomFPRate
+	}
+
+	n := float64(capacity)
+	size := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+
+	hashes := int(math.Round(float64(size) / n * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &bloom{
+		words:  make([]uint32, (size+3)/4),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+// locations will derive the counters of the key by double hashing
+// an FNV-1a hash, which is independent of the cache's own hasher
+func (b *bloom) locations(key string, fn func(word int, shift uint)) {
+	h1 := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h1 ^= uint64(key[i])
+		h1 *= 1099511628211
+	}
+
+	h2 := h1 ^ h1>>33
+	h2 *= 0xff51afd7ed558ccd
+	h2 ^= h2 >> 33
+	h2 |= 1
+
+	for i := 0; i < b.hashes; i++ {
+		c := (h1 + uint64(i)*h2) % b.size
+		fn(int(c/4), uint(c%4)*8)
+	}
+}
+
+func (b *bloom) add(key string) {
+	b.locations(key, func(word int, shift uint) {
+		b.update(word, shift, 1)
+	})
+}
+
+func (b *bloom) remove(key string) {
+	b.locations(key, func(word int, shift uint) {
+		b.update(word, shift, -1)
+	})
+}
+
+// update will move the counter by delta, leaving saturated counters
+// in place since the keys they count are no longer known
+func (b *bloom) update(word int, shift uint, delta int) {
+	for {
+		old := atomic.LoadUint32(&b.words[word])
+		c := int(old >> shift & 0xff)
+		if c == 0xff || c+delta < 0 {
+			return
+		}
+
+		next := old&^(0xff<<shift) | uint32(c+delta)<<shift
+		if atomic.CompareAndSwapUint32(&b.words[word], old, next) {
+			return
+		}
+	}
+}
+
+// contains reports whether the key may be in the cache.
+// False means it is definitely absent.
+func (b *bloom) contains(key string) bool {
+	found := true
+	b.locations(key, func(word int, shift uint) {
+		if found && atomic.LoadUint32(&b.words[word])>>shift&0xff == 0 {
+			found = false
+		}
+	})
+
+	return found
+}
+
+func (b *bloom) reset() {
+	for i := range b.words {
+		atomic.StoreUint32(&b.words[i], 0)
+	}
+}
+
+// absent reports whether the bloom filter rules out the key, counting
+// a miss if it does. The shadow is still consulted on every lookup,
+// so the filter is bypassed when there is one.
+func (t *Cache) absent(key string) bool {
+	if t.bloom == nil || t.shadow != nil || t.bloom.contains(key) {
+		return false
+	}
+
+	if t.spill != nil && t.spill.has(key) {
+		return false
+	}
+
+	t.countMiss()
+	return true
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := newBloom(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		b.add("key" + strconv.Itoa(i))
+	}
+
+	for i := 0; i < 10000; i++ {
+		if !b.contains("key" + strconv.Itoa(i)) {
+			t.Fatalf("false negative for key%d", i)
+		}
+	}
+
+	var positives int
+	for i := 0; i < 10000; i++ {
+		if b.contains("other" + strconv.Itoa(i)) {
+			positives++
+		}
+	}
+
+	if positives > 300 {
+		t.Errorf("expected about 1%% false positives, got %d in 10000", positives)
+	}
+
+	for i := 0; i < 10000; i++ {
+		b.remove("key" + strconv.Itoa(i))
+	}
+
+	for i, w := range b.words {
+		if w != 0 {
+			t.Fatalf("expected every counter to return to zero, word %d is %x", i, w)
+		}
+	}
+}
+
+func TestBloomCache(t *testing.T) {
+	cache := NewCache(&CacheConfig{BloomCapacity: 1000})
+
+	cache.Add("a", 1, 10*time.Minute)
+	cache.Bucket("bucket").Add("b", 2, 10*time.Minute)
+
+	if item, err := cache.Get("a"); err != nil || item != 1 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	if item, err := cache.Bucket("bucket").Get("b"); err != nil || item != 2 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	// the filter answers for absent keys without taking the lock
+	cache.mu.Lock()
+	_, err := cache.Get("missing")
+	cache.mu.Unlock()
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	if stats := cache.Stats(); stats.Misses != 1 {
+		t.Errorf("expected the filtered lookup to count as a miss, got %d", stats.Misses)
+	}
+
+	cache.Delete("a")
+	if cache.bloom.contains("a") {
+		t.Errorf("expected the deleted key to be removed from the filter")
+	}
+
+	cache.Flush()
+	if cache.bloom.contains("bucket") {
+		t.Errorf("expected the filter to be cleared by Flush")
+	}
+}
+
+func TestBloomConcurrent(t *testing.T) {
+	cache := NewCache(&CacheConfig{BloomCapacity: 1000})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := strconv.Itoa(i) + ":" + strconv.Itoa(j)
+				cache.Add(key, j, 10*time.Minute)
+				if _, err := cache.Get(key); err != nil {
+					t.Errorf("expected %s to be found, got %+v", key, err)
+				}
+				cache.Get("missing")
+				cache.Delete(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
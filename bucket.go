@@ -1,16 +1,42 @@
 package cache
 
 import (
-	"hash/fnv"
+	"sort"
+	"sync"
 	"time"
 )
 
 // Bucket indexes a group of keys in cache
 // and should be used to manage them
 type Bucket struct {
-	name  string
-	list  []uint64
-	cache *Cache
+	name     string
+	list     []uint64
+	cache    *Cache
+	config   *BucketConfig
+	counters *counters
+	loadMu   *sync.Mutex
+	loads    map[string]*loadCall
+}
+
+// BucketConfig is used to configure a bucket
+type BucketConfig struct {
+	Loader       Loader        // loads items that are missing from the bucket in GetOrLoad
+	LoadTTL      time.Duration // expiration duration used for loaded items, defaults to DefaultTTL
+	RefreshAhead time.Duration // reloads items in the background when they are this close to expiring
+	DefaultTTL   time.Duration // expiration of items added with DefaultExpiration, defaults to the cache's DefaultTTL
+	Priority     Priority      // priority of the items added to the bucket without WithPriority
+	OnExpire     OnRemoval     // called with each item of the bucket whose ttl was reached, before the cache's OnExpire
+	OnEvict      OnRemoval     // called with each item of the bucket evicted or flushed, before the cache's OnEvict
+}
+
+// Loader is a function that will load the item
+// for a key that is missing from a bucket.
+type Loader func(key string) (interface{}, error)
+
+type loadCall struct {
+	wg   sync.WaitGroup
+	item interface{}
+	err  error
 }
 
 type bucketIterator struct {
@@ -24,113 +50,230 @@ type bucketIterator struct {
 // It will create and return a new bucket by the name
 // if the bucket does not already exist.
 func (c *Cache) Bucket(name string) *Bucket {
-	obj, err := c.Get(name)
-	if err == ErrDNE {
-		b := &Bucket{
-			name:  name,
-			list:  make([]uint64, 0),
-			cache: c,
-		}
+	return c.BucketWithConfig(name, nil)
+}
 
-		err := c.Add(name, b, 0)
-		if err != nil {
-			return nil
-		}
+// BucketWithConfig will return the bucket by the name,
+// creating it if it does not already exist. A non-nil
+// config replaces the configuration of an existing bucket.
+func (c *Cache) BucketWithConfig(name string, config *BucketConfig) *Bucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		return b
-	} else if err != nil {
+	b, err := c.bucket(name, config)
+	if err != nil {
 		return nil
 	}
 
-	return obj.(*Bucket)
+	return b
 }
 
-// Add will add an item to the bucket.
-func (b *Bucket) Add(key string, item interface{}, expiresIn time.Duration) error {
-	b.cache.Lock()
-	defer b.cache.Unlock()
+// Buckets will return the names of the buckets in the cache, in order
+func (c *Cache) Buckets() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	pk := b.name + "-" + key
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(pk))
+	names := make([]string, 0)
+	for _, slot := range c.slots {
+		if b, ok := slot.Item.(*Bucket); ok && !slot.empty {
+			names = append(names, b.name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Add will add an item to the bucket. An expiresIn of DefaultExpiration
+// uses the bucket's DefaultTTL, then the cache's, and otherwise never
+// expires the item. Use NoExpiration to never expire the item.
+func (b *Bucket) Add(key string, item interface{}, expiresIn time.Duration, opts ...AddOption) error {
+	o := newAddOptions(opts)
+	err := b.cache.enterAdd(&o)
 	if err != nil {
 		return err
 	}
-	hk := hasher.Sum64()
+	defer b.cache.exitAdd(&o)
 
-	var exists bool
-	for _, k := range b.list {
-		if k == hk {
-			exists = true
-			break
+	return b.cache.write(func() error {
+		pk := b.key(key)
+		hk := b.cache.hash(pk)
+
+		err := b.cache.admit(hk, pk, item, o)
+		if err != nil {
+			return err
+		}
+
+		expiresIn = b.ttl(expiresIn)
+		tx := b.cache.beginStore(hk, pk)
+		expiresAt := b.cache.expiration(b.cache.jitter(expiresIn))
+		err = b.cache.place(hk, pk, item, expiresAt, o)
+		if err != nil {
+			return err
+		}
+
+		b.cache.persist(tx, hk, pk)
+		b.revalidateBucketItem(key)
+		if o.priority == nil && b.config.Priority != PriorityNormal {
+			p := b.config.Priority
+			o.priority = &p
 		}
+		b.cache.added(pk, expiresIn, o)
+
+		return nil
+	})
+}
+
+// AddSized will add an item to the bucket like Add and return its
+// size in bytes encoded with gob, as Cache.AddSized does.
+func (b *Bucket) AddSized(key string, item interface{}, expiresIn time.Duration, opts ...AddOption) (int64, error) {
+	size, err := serializedSize(item)
+	if err != nil {
+		return 0, err
 	}
 
-	if !exists {
-		b.list = append(b.list, hk)
+	err = b.Add(key, item, expiresIn, opts...)
+	if err != nil {
+		return 0, err
 	}
 
-	expiresAt := time.Now().UTC().Add(expiresIn)
-	return b.cache.add(hk, item, expiresAt)
+	return size, nil
 }
 
 // Delete will remove an item from the bucket
 func (b *Bucket) Delete(key string) error {
-	b.cache.Lock()
-	defer b.cache.Unlock()
+	return b.cache.write(func() error {
+		pk := b.key(key)
+		hk := b.cache.hash(pk)
 
-	pk := b.name + "-" + key
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(pk))
+		return b.delete(hk, pk)
+	})
+}
+
+// delete will remove the item at the hashed key from the
+// cache and the bucket. The cache lock must be held.
+func (b *Bucket) delete(hk uint64, pk string) error {
+	tx := b.cache.beginStore(hk, pk)
+	err := b.cache.delete(hk)
 	if err != nil {
 		return err
 	}
-	hk := hasher.Sum64()
+	b.cache.trace(TraceDelete, pk, 0, 0)
+	b.cache.persist(tx, hk, pk)
+
+	return nil
+}
+
+// list will add the hashed key of an item added to the cache to the
+// list of its bucket, if it is in one. The lock must be held.
+func (t *Cache) list(key uint64, name string) {
+	b, _ := t.bucketOf(name)
+	if b == nil || b.cache != t {
+		return
+	}
+
+	for _, k := range b.list {
+		if k == key {
+			return
+		}
+	}
+
+	b.list = append(b.list, key)
+}
+
+// unlist will drop the hashed key of an item removed from the cache
+// from the list of its bucket, if it is in one. The lock must be held.
+func (t *Cache) unlist(key uint64, name string) {
+	b, _ := t.bucketOf(name)
+	if b == nil || b.cache != t {
+		return
+	}
 
 	for i, k := range b.list {
-		if k == hk {
+		if k == key {
 			b.list = append(b.list[:i], b.list[i+1:]...)
-			break
+			return
 		}
 	}
-
-	return b.cache.delete(hk)
 }
 
 // Get will get an item from the bucket.
-func (b *Bucket) Get(key string) (interface{}, error) {
-	b.cache.Lock()
-	defer b.cache.Unlock()
+// With SpillDir set, an item that was evicted to disk is moved back into memory.
+func (b *Bucket) Get(key string, opts ...GetOption) (interface{}, error) {
+	pk := b.key(key)
+	b.cache.trace(TraceGet, pk, 0, 0)
+	if b.cache.absent(pk) {
+		b.countGet(ErrDNE)
+		return nil, ErrDNE
+	}
 
-	pk := b.name + "-" + key
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(pk))
-	if err != nil {
+	item, err := b.cache.getKey(pk, newGetOptions(opts))
+	if err == ErrDNE && b.cache.spill != nil {
+		item, err = b.cache.fault(pk)
+	}
+	b.countGet(err)
+
+	return item, err
+}
+
+// GetOrLoad will get an item from the bucket, using the
+// bucket's Loader to load and add the item if it is missing.
+// Concurrent loads of the same key share a single Loader call.
+// If RefreshAhead is configured then items close to expiring
+// are reloaded in the background while the current item is returned.
+func (b *Bucket) GetOrLoad(key string) (interface{}, error) {
+	pk := b.key(key)
+	b.cache.trace(TraceGet, pk, 0, 0)
+	b.cache.readLock()
+	hk := b.cache.hash(pk)
+
+	item, err := b.cache.get(hk)
+	var expiresAt time.Time
+	if err == nil {
+		expiresAt = b.cache.slots[b.cache.keys[hk]].ExpiresAt
+	}
+	b.cache.readUnlock()
+
+	if err == ErrDNE {
+		if b.cache.spill != nil {
+			if item, err := b.cache.fault(pk); err == nil {
+				b.countGet(nil)
+				return item, nil
+			}
+		}
+		b.countGet(ErrDNE)
+		return b.load(key)
+	} else if err != nil {
 		return nil, err
 	}
-	hk := hasher.Sum64()
+	b.countGet(nil)
+
+	b.loadMu.Lock()
+	ahead := b.config.RefreshAhead
+	b.loadMu.Unlock()
 
-	return b.cache.get(hk)
+	if ahead > 0 && b.cache.now().Add(ahead).After(expiresAt) {
+		go b.load(key)
+	}
+
+	return item, nil
 }
 
 // Extend will extend an item from the bucket.
 func (b *Bucket) Extend(key string, extend time.Duration) error {
-	b.cache.Lock()
-	defer b.cache.Unlock()
+	b.cache.mu.Lock()
+	defer b.cache.mu.Unlock()
 
-	pk := b.name + "-" + key
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(pk))
-	if err != nil {
-		return err
-	}
-	hk := hasher.Sum64()
+	pk := b.key(key)
+	hk := b.cache.hash(pk)
 
 	return b.cache.extend(hk, extend)
 }
 
 // Iterator will return an iterator to iterate
 // over the items in the bucket.
+//
+// Deprecated: with Go 1.23 or later, range over All instead.
 func (b *Bucket) Iterator() *bucketIterator {
 	return &bucketIterator{
 		bucket: b,
@@ -139,23 +282,138 @@ func (b *Bucket) Iterator() *bucketIterator {
 
 // Len returns the number of items in the bucket
 func (b *Bucket) Len() int {
+	b.cache.mu.RLock()
+	defer b.cache.mu.RUnlock()
+
 	return len(b.list)
 }
 
+// Touch will reset the expiration of an item in the bucket
+// to the specified duration from now, resolving DefaultExpiration
+// to the DefaultTTL of the bucket.
+func (b *Bucket) Touch(key string, newTTL time.Duration) error {
+	b.cache.mu.Lock()
+	defer b.cache.mu.Unlock()
+
+	pk := b.key(key)
+	hk := b.cache.hash(pk)
+
+	return b.cache.touch(hk, b.cache.expiration(b.ttl(newTTL)))
+}
+
 // Update will update the item in the bucket
 func (b *Bucket) Update(key string, item interface{}) error {
-	b.cache.Lock()
-	defer b.cache.Unlock()
+	return b.cache.write(func() error {
+		pk := b.key(key)
+		hk := b.cache.hash(pk)
+
+		tx := b.cache.beginStore(hk, pk)
+		err := b.cache.update(hk, item)
+		if err != nil {
+			return err
+		}
 
-	pk := b.name + "-" + key
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(pk))
+		b.cache.persist(tx, hk, pk)
+
+		return nil
+	})
+}
+
+// bucket will return the bucket stored at the name, creating it if it
+// does not already exist. The cache lock must be held by the caller.
+func (c *Cache) bucket(name string, config *BucketConfig) (*Bucket, error) {
+	hk := c.hash(name)
+
+	idx, ok := c.keys[hk]
+	if !ok {
+		if config == nil {
+			config = &BucketConfig{}
+		}
+
+		b := &Bucket{
+			name:     name,
+			list:     make([]uint64, 0),
+			cache:    c,
+			config:   config,
+			counters: &counters{},
+			loadMu:   &sync.Mutex{},
+			loads:    make(map[string]*loadCall),
+		}
+
+		return b, c.add(hk, name, b, c.expiration(0))
+	}
+
+	b, ok := c.slots[idx].Item.(*Bucket)
+	if !ok {
+		return nil, ErrCollision
+	}
+
+	if config != nil {
+		b.loadMu.Lock()
+		b.config = config
+		b.loadMu.Unlock()
+	}
+
+	return b, nil
+}
+
+// load will call the bucket's Loader for the key and store the result,
+// waiting on the in-flight call instead if the key is already loading.
+func (b *Bucket) load(key string) (interface{}, error) {
+	b.loadMu.Lock()
+	if call, ok := b.loads[key]; ok {
+		b.loadMu.Unlock()
+		call.wg.Wait()
+		return call.item, call.err
+	}
+
+	config := b.config
+	if config.Loader == nil {
+		b.loadMu.Unlock()
+		return nil, ErrNoLoader
+	}
+
+	call := &loadCall{}
+	call.wg.Add(1)
+	b.loads[key] = call
+	b.loadMu.Unlock()
+
+	done := b.cache.observeLoad(b.name, key)
+	call.item, call.err = config.Loader(key)
+	done(call.err)
+	if call.err == nil {
+		b.cache.mu.Lock()
+		call.err = b.set(key, call.item, config.LoadTTL)
+		b.cache.mu.Unlock()
+	}
+	call.wg.Done()
+
+	b.loadMu.Lock()
+	delete(b.loads, key)
+	b.loadMu.Unlock()
+
+	return call.item, call.err
+}
+
+// set will add or replace the item at the key in the bucket.
+// The cache lock must be held by the caller.
+func (b *Bucket) set(key string, item interface{}, expiresIn time.Duration) error {
+	pk := b.key(key)
+	hk := b.cache.hash(pk)
+
+	expiresAt := b.cache.expiration(b.ttl(expiresIn))
+	_, replaced := b.cache.live(hk)
+	err := b.cache.set(hk, pk, item, expiresAt)
 	if err != nil {
 		return err
 	}
-	hk := hasher.Sum64()
 
-	return b.cache.update(hk, item)
+	if !replaced && b.config.Priority != PriorityNormal {
+		b.cache.prioritize(b.cache.keys[hk], b.config.Priority)
+	}
+
+	b.revalidateBucketItem(key)
+	return nil
 }
 
 /*  bucket iterator */
@@ -169,6 +427,9 @@ func (b *bucketIterator) Item() interface{} {
 // Next will return false when there
 // are no items remaining to iterate
 func (b *bucketIterator) Next() bool {
+	b.bucket.cache.readLock()
+	defer b.bucket.cache.readUnlock()
+
 	if b.position < len(b.bucket.list) {
 		key := b.bucket.list[b.position]
 		item, _ := b.bucket.cache.get(key)
@@ -187,8 +448,8 @@ func (b *bucketIterator) Next() bool {
 // which can be checked with the `Item()` method,
 // with the provided item object in the argument.
 func (b *bucketIterator) Update(item interface{}) error {
-	b.bucket.cache.Lock()
-	defer b.bucket.cache.Unlock()
+	b.bucket.cache.mu.Lock()
+	defer b.bucket.cache.mu.Unlock()
 
 	return b.bucket.cache.update(b.key, item)
 }
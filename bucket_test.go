@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -202,3 +204,127 @@ func TestBucketIterator(t *testing.T) {
 		t.Errorf("value was not updated: %s", v)
 	}
 }
+
+func TestBucketGetOrLoad(t *testing.T) {
+	cache := NewCache(nil)
+
+	b := cache.Bucket("my-bucket")
+	if b == nil {
+		t.Error("bucket was nil")
+	}
+
+	_, err := b.GetOrLoad("key")
+	if err != ErrNoLoader {
+		t.Errorf("should have returned ErrNoLoader but returned %+v", err)
+	}
+
+	var calls int32
+	b = cache.BucketWithConfig("my-bucket", &BucketConfig{
+		Loader: func(key string) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(10 * time.Millisecond)
+			return "loaded-" + key, nil
+		},
+		LoadTTL: 10 * time.Minute,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := b.GetOrLoad("key")
+			if err != nil {
+				t.Errorf("error while loading key: %+v", err)
+			}
+
+			if value != "loaded-key" {
+				t.Errorf("returned value was %+v", value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader was called %d times", n)
+	}
+
+	value, err := b.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value != "loaded-key" {
+		t.Errorf("loaded value was not stored: %+v", value)
+	}
+}
+
+func TestBucketRefreshAhead(t *testing.T) {
+	cache := NewCache(nil)
+
+	loaded := make(chan string, 1)
+	b := cache.BucketWithConfig("my-bucket", &BucketConfig{
+		Loader: func(key string) (interface{}, error) {
+			loaded <- key
+			return "new-value", nil
+		},
+		LoadTTL:      10 * time.Minute,
+		RefreshAhead: 1 * time.Minute,
+	})
+
+	err := b.Add("key", "value", 30*time.Second)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	value, err := b.GetOrLoad("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value != "value" {
+		t.Errorf("current value was not returned: %+v", value)
+	}
+
+	select {
+	case <-loaded:
+	case <-time.After(time.Second):
+		t.Fatal("item was not refreshed ahead of expiration")
+	}
+}
+
+func TestBucketTouch(t *testing.T) {
+	cache := NewCache(nil)
+
+	b := cache.Bucket("my-bucket")
+	if b == nil {
+		t.Error("bucket was nil")
+	}
+
+	err := b.Touch("key", 1*time.Minute)
+	if err != ErrDNE {
+		t.Errorf("touching key that does not exist did not fail: %+v", err)
+	}
+
+	err = b.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = b.Touch("key", 1*time.Minute)
+	if err != nil {
+		t.Errorf("error while touching key: %+v", err)
+	}
+}
+
+func TestCacheBuckets(t *testing.T) {
+	cache := NewCache(nil)
+	cache.Bucket("b")
+	cache.Bucket("a")
+	cache.Add("c", 1, time.Minute)
+
+	names := cache.Buckets()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("unexpected bucket names: %v", names)
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"encoding/gob"
 	"errors"
-	"hash/fnv"
+	"hash/maphash"
 	"io/ioutil"
-	"math"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,39 +17,185 @@ var (
 	ErrCollision = errors.New("hash collision")
 	// ErrDNE is a "does not exist" error
 	ErrDNE = errors.New("does not exist")
+	// ErrIncomparable is returned when comparing an item whose type cannot be compared
+	ErrIncomparable = errors.New("item is not comparable")
+	// ErrTooLarge is returned when an item is larger than the cache's MaxBytes
+	ErrTooLarge = errors.New("item too large")
+	// ErrNoLoader is returned when loading an item without a configured loader
+	ErrNoLoader = errors.New("no loader configured")
 
 	defaultConfig = &CacheConfig{
 		CleanDuration: defaultCleanDuration,
 	}
 	defaultCleanDuration   = 10 * time.Second
 	defaultRefreshDuration = 1 * time.Second
+
+	// neverExpires is the expiration time of items that never expire.
+	// time.Unix(math.MaxInt64, 0) overflows, and compares as already past.
+	neverExpires = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
 )
 
 // Cache is a generic in-memory cache
 type Cache struct {
-	slots   []Slot
-	keys    map[uint64]int
-	nextExp time.Time
-	config  *CacheConfig
-	*sync.Mutex
+	slots       []Slot
+	free        []int // indexes of empty slots available for reuse
+	keys        map[uint64]int
+	nextExp     time.Time
+	bytes       int64
+	version     uint64 // last version given to a written item
+	gen         uint64 // changes whenever an item is written or removed
+	pins        int    // pinned items
+	prioritized int    // items with a priority other than PriorityNormal
+	config      *CacheConfig
+
+	seed       *maphash.Seed
+	collisions uint64
+	floodStart time.Time
+	floodCount int
+
+	access     *accessStats
+	hot        *hotKeys
+	evictor    Evictor
+	counters   *counters
+	shadow     *Cache
+	revalidate *revalidator
+	reload     *reloader
+	expirer    *expirer
+	expiry     *expiryIndex
+	writeBack  *writeBack
+	invalidate *invalidator
+	done       chan struct{}
+	closeOnce  *sync.Once
+	lanes      *laneGate
+	cleanTimer Timer // pending clean cycle on the TimerWheel
+	cleanMu    *sync.Mutex
+	pauses     int32 // calls to PauseCleaning that have not been resumed
+
+	writeBackDone chan struct{}
+	tags          map[string]map[string]struct{} // keys carrying each tag
+	deps          *dependencies
+	sorted        *skipList      // keys in order, when SortedKeys is enabled
+	bloom         *bloom         // filter of the keys, when BloomCapacity is set
+	spill         *spill         // log of the evicted items, when SpillDir is set
+	pressure      *MemoryWatcher // sheds items over HeapLimit, when it is set
+	snapshots     *snapshots     // entries shared by calls to Snapshot, when CopyOnWrite is set
+	storeMu       *sync.Mutex    // held while changes are written through to the Store
+	pending       []pendingWrite // write-throughs of the change being made
+	tombstones    *tombstones    // keys removed since the cache was saved, for SaveDelta
+
+	mu *sync.RWMutex
 }
 
 // CacheConfig is used to configure a cache
 type CacheConfig struct {
-	OnExpires       OnExpires
-	Refresh         bool // extends key's expiration time on usage (for lru-like behavior)
-	RefreshDuration time.Duration
-	CleanDuration   time.Duration
+	OnExpires        OnExpires
+	Clock            Clock          // source of time for expirations and the cleaner, defaults to the system clock
+	OnExpiresBatch   OnExpiresBatch // called once per clean cycle with every expired item
+	Refresh          bool           // extends key's expiration time on usage (for lru-like behavior)
+	RefreshDuration  time.Duration
+	CleanDuration    time.Duration
+	DefaultTTL       time.Duration  // expiration of items added with DefaultExpiration, 0 never expires them
+	DisableCleaner   bool           // stops the background cleaner, expired items are then only removed by DeleteExpired
+	ExpireOnFlush    bool           // invokes the expiration callbacks for items removed by Flush
+	OnExpire         OnRemoval      // called with each item whose ttl was reached
+	OnEvict          OnRemoval      // called with each item evicted for capacity or memory pressure, or removed by Flush or a reseed
+	MaxBytes         int64          // evicts the items closest to expiring beyond this size, 0 or negative is unbounded
+	MemoryFraction   float64        // derives MaxBytes as this fraction of the container memory limit when it is 0
+	Sizer            Sizer          // measures the size of items, defaults to walking the values they reference when MaxBytes is set
+	FloodThreshold   int            // hash collisions within FloodWindow treated as hash flooding, 0 disables detection
+	FloodWindow      time.Duration  // window over which hash collisions are counted
+	OnHashFlood      OnHashFlood    // alarm raised when hash flooding is detected
+	AutoReseed       bool           // switches to a randomly seeded hasher when hash flooding is detected
+	AccessSampleRate float64        // fraction of keys whose hits and last access are recorded for AccessStats, 0 disables
+	MaxEntries       int            // evicts items beyond this number of keys, 0 is unbounded
+	EvictionPolicy   EvictionPolicy // selects the items evicted when MaxEntries or MaxBytes is reached
+	Evictor          Evictor        // custom eviction policy, overrides EvictionPolicy
+	Admission        Admission      // decides whether items added to a full cache may evict another item, nil admits every item
+	Shadow           *CacheConfig   // mirrors all operations into a cache with this configuration, without serving from it
+	StaleGrace       time.Duration  // serves expired items for this long while their Refresher reloads them, 0 disables
+	ReloadAhead      float64        // fraction of the ttl before expiry at which AddWithRefresher reloads items, defaults to 0.1
+	ReloadJitter     time.Duration  // random amount by which reloads are brought forward
+	MaxReloads       int            // reloads run at once by AddWithRefresher, 0 is unbounded
+	TTLJitter        float64        // randomizes the ttl of added items by up to this fraction either way, e.g. 0.1 for ±10%
+	ExpireWorkers    int            // expiration callbacks run at once, defaults to 1
+	ExpireTimeout    time.Duration  // abandons expiration callbacks that run for longer, 0 waits for them
+	OnError          OnError        // called with errors from background work, such as panicking callbacks
+	Observer         Observer       // notified of hits, misses, evictions, expirations and loads, for instrumentation
+	Store            Store          // backing store that Add, Set, Update and Delete are written through to
+	WriteBack        bool           // batches changes and flushes them to the Store asynchronously instead of writing through
+	WriteInterval    time.Duration  // interval at which changes are flushed to the Store, defaults to 1 second
+	WriteRetries     int            // flushes at which a failed write-back is retried before being reported to OnError, defaults to 3
+	Invalidator      Invalidator    // publishes the keys changed in this cache and drops the keys changed in other caches
+	InvalidateQueue  int            // invalidations held while publishing fails, replayed once it succeeds, defaults to 1024
+	DependencyDepth  int            // longest chain of keys depending on each other through AddDependency, defaults to 16
+	SortedKeys       bool           // keeps an index of the keys in order for RangeKeys
+	CopyOnWrite      bool           // shares the entries returned by Snapshot between calls until the cache is written
+	BloomCapacity    int            // keys a bloom filter answering lookups of absent keys without the lock is sized for, 0 disables it
+	BloomFPRate      float64        // false positive rate of the bloom filter at BloomCapacity keys, defaults to 0.01
+	Hasher           Hasher         // hashes keys, with a Hasher128 a key is placed under either 64-bit half of its hash, defaults to FNV-1a
+	HeapLimit        int64          // sheds a share of the items when the Go heap grows beyond this many bytes, 0 disables
+	PressureInterval time.Duration  // interval at which the heap is compared with HeapLimit, defaults to 1 second
+	SpillDir         string         // spills evicted items to a log in this directory and faults them back in on Get, "" disables
+	SaveOnShutdown   string         // file the cache is saved to by Shutdown, "" does not save it
+	AccessStatsFile  string         // file the AccessStats are written to by Shutdown, "" does not write them
+	Keyring          *Keyring       // encrypts the files written by Save with AES-GCM and decrypts them in Load, nil leaves them unencrypted
+	Histograms       bool           // adds histograms of the ages and remaining ttls of the items to Stats, which then visits every item
+	HotKeySampleRate float64        // fraction of hits counted to find the keys read most often for HotKeys, 0 disables
+	HotKeyWindow     time.Duration  // window over which HotKeys counts hits, defaults to 1 minute
+	Trace            *TraceRecorder // records the gets, adds and deletes of the cache with hashed keys, nil disables
+	TimerWheel       *TimerWheel    // runs the cleaner as a timer of the wheel rather than a goroutine of the cache, nil starts a goroutine
 }
 
 // OnExpires is a function that will act on the item object
 // of an expired Slot.
 type OnExpires func(item interface{})
 
+// OnExpiresBatch is a function that will act on all of the
+// items expired during a single clean of the cache.
+type OnExpiresBatch func(items []Expired)
+
+// Expired is an item that has been expired from the cache
+type Expired struct {
+	Key       string
+	Item      interface{}
+	ExpiresAt time.Time
+	Deleted   bool // the item had been soft deleted
+}
+
+type gobCache struct {
+	Entries []gobEntry
+	Buckets []gobBucket
+}
+
+type gobEntry struct {
+	Key       string
+	Item      interface{}
+	ExpiresAt time.Time
+	Meta      map[string]string
+}
+
+type gobBucket struct {
+	Name string
+	Keys []string
+}
+
 // Slot is a slot in a cache
 type Slot struct {
 	Item      interface{}
 	ExpiresAt time.Time
+	key       uint64
+	name      string // original key, used to tell hash collisions from duplicate adds
+	size      int64
+	version   uint64    // changes whenever the item is written
+	deleted   bool      // soft deleted, hidden until it expires or is restored
+	pinned    bool      // passed over when choosing items to evict
+	priority  Priority  // evicted after all items of lower priority
+	cost      int64     // cost of recomputing the item, for the Admission policy
+	held      time.Time // expiration of a pinned item, held until it is unpinned
+	modified  time.Time // last write of the item or its expiration, for SaveDelta
+	added     time.Time // time the item was added, for the AgeHistogram
+	tags      []string
+	meta      map[string]string
 	empty     bool
 }
 
@@ -56,36 +203,155 @@ type Slot struct {
 // Renewable sets whether
 func NewCache(config *CacheConfig) *Cache {
 	if config == nil {
-		config = defaultConfig
+		c := *defaultConfig
+		config = &c
 	}
 
 	if config.CleanDuration == 0 {
 		config.CleanDuration = defaultCleanDuration
 	}
 
+	if config.PressureInterval == 0 {
+		config.PressureInterval = defaultPressureInterval
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	if config.Hasher == nil {
+		config.Hasher = FNVHasher{}
+	}
+
 	if config.Refresh {
 		if config.RefreshDuration == 0 {
 			config.RefreshDuration = defaultRefreshDuration
 		}
 	}
 
+	if config.MaxBytes == 0 && config.MemoryFraction > 0 {
+		config.MaxBytes = defaultMaxBytes(config.MemoryFraction)
+	}
+
+	// items are only measured by default when there is a limit
+	if config.Sizer == nil && config.MaxBytes > 0 {
+		config.Sizer = defaultSizer
+	}
+
+	if config.HotKeyWindow <= 0 {
+		config.HotKeyWindow = defaultHotKeyWindow
+	}
+
+	if config.ReloadAhead <= 0 || config.ReloadAhead > 1 {
+		config.ReloadAhead = defaultReloadAhead
+	}
+
+	if config.ExpireWorkers <= 0 {
+		config.ExpireWorkers = defaultExpireWorkers
+	}
+
+	if config.WriteInterval <= 0 {
+		config.WriteInterval = defaultWriteInterval
+	}
+
+	if config.WriteRetries == 0 {
+		config.WriteRetries = defaultWriteRetries
+	}
+
+	if config.DependencyDepth <= 0 {
+		config.DependencyDepth = defaultDependencyDepth
+	}
+
+	if config.InvalidateQueue <= 0 {
+		config.InvalidateQueue = defaultInvalidateQueue
+	}
+
+	if config.FloodThreshold > 0 && config.FloodWindow == 0 {
+		config.FloodWindow = defaultFloodWindow
+	}
+
 	t := &Cache{
 		slots:  make([]Slot, 0),
 		keys:   make(map[uint64]int),
 		config: config,
-		Mutex:  &sync.Mutex{},
+		mu:     &sync.RWMutex{},
 	}
 
-	go func(t *Cache) {
-		for {
-			time.Sleep(t.config.CleanDuration)
-			if time.Now().UTC().After(t.nextExp) {
-				for _, exp := range t.clean() {
-					t.config.OnExpires(exp.Item)
-				}
-			}
+	t.counters = &counters{}
+	t.lanes = newLaneGate()
+	t.revalidate = newRevalidator()
+	t.deps = newDependencies()
+	t.expiry = newExpiryIndex()
+	t.tombstones = newTombstones()
+	if config.SortedKeys {
+		t.sorted = newSkipList()
+	}
+
+	if config.CopyOnWrite {
+		t.snapshots = &snapshots{mu: &sync.Mutex{}}
+	}
+	if config.BloomCapacity > 0 {
+		t.bloom = newBloom(config.BloomCapacity, config.BloomFPRate)
+	}
+	t.reload = newReloader(config.MaxReloads)
+	t.expirer = newExpirer(config.ExpireWorkers, config.ExpireTimeout, config.OnError)
+	if config.SpillDir != "" {
+		s, err := newSpill(config.SpillDir)
+		if err != nil {
+			t.expirer.report(err)
+		} else {
+			t.spill = s
 		}
-	}(t)
+	}
+	t.done = make(chan struct{})
+	t.closeOnce = &sync.Once{}
+	t.cleanMu = &sync.Mutex{}
+	t.storeMu = &sync.Mutex{}
+	t.shadow = newShadow(config.Shadow)
+
+	t.evictor = config.Evictor
+	if t.evictor == nil {
+		t.evictor = newEvictor(config.EvictionPolicy, t)
+	}
+
+	if config.AccessSampleRate > 0 {
+		t.access = newAccessStats(config.AccessSampleRate)
+	}
+
+	if config.HotKeySampleRate > 0 {
+		t.hot = newHotKeys(config.HotKeySampleRate, config.HotKeyWindow)
+	}
+
+	if config.Store != nil && config.WriteBack {
+		t.writeBack = newWriteBack(config.Store, config.WriteRetries)
+		t.writeBackDone = make(chan struct{})
+		go t.writeBacker()
+	}
+
+	if config.Invalidator != nil {
+		t.invalidate = newInvalidator(config.Invalidator, config.InvalidateQueue)
+		go t.invalidatePublisher()
+
+		err := config.Invalidator.Subscribe(t.invalidated)
+		if err != nil {
+			t.expirer.report(err)
+		}
+	}
+
+	if !config.DisableCleaner && config.TimerWheel != nil {
+		t.scheduleClean()
+	} else if !config.DisableCleaner {
+		go t.cleaner()
+	}
+
+	if config.HeapLimit > 0 {
+		t.pressure = NewMemoryWatcher(&MemoryWatcherConfig{
+			SoftLimit: config.HeapLimit,
+			Interval:  config.PressureInterval,
+			Clock:     config.Clock,
+		})
+		t.pressure.Watch(t)
+	}
 
 	return t
 }
@@ -93,227 +359,831 @@ func NewCache(config *CacheConfig) *Cache {
 // Add will add a key, value, and expiration duration to the cache.
 // If the key already exists in the collision (i.e. if a collision occurs) then an
 // ErrCollision value will be returned.
-// If you use an expiresIn time of `0` then the item will never be expired from the cache.
-func (t *Cache) Add(key string, item interface{}, expiresIn time.Duration) error {
-	t.Lock()
-	defer t.Unlock()
-
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(key))
+// An expiresIn of DefaultExpiration (0) expires the item after the cache's
+// DefaultTTL, or never if it has none. Use NoExpiration to never expire the item.
+// ErrRejected is returned if the cache is full and its Admission policy rejects the item.
+func (t *Cache) Add(key string, item interface{}, expiresIn time.Duration, opts ...AddOption) error {
+	o := newAddOptions(opts)
+	err := t.enterAdd(&o)
 	if err != nil {
 		return err
 	}
-	hashedKey := hasher.Sum64()
+	defer t.exitAdd(&o)
 
-	var expiresAt time.Time
-	if expiresIn == 0 {
-		expiresAt = time.Unix(math.MaxInt64, 0)
-	} else {
-		expiresAt = time.Now().UTC().Add(expiresIn)
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		err := t.admit(hashedKey, key, item, o)
+		if err != nil {
+			return err
+		}
+
+		expiresIn = t.ttl(expiresIn)
+		tx := t.beginStore(hashedKey, key)
+		err = t.place(hashedKey, key, item, t.expiration(t.jitter(expiresIn)), o)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+		t.added(key, expiresIn, o)
+
+		return nil
+	})
+}
+
+// Close will stop the cleaner, the background reloads, and the
+// expiration workers once their queued callbacks have run. The cache
+// can still be used after it is closed, but expired items are left in
+// place, unseen by Get, until the cache is flushed. With WriteBack it
+// waits for the pending writes to drain, see FlushWrites.
+func (t *Cache) Close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.stopClean()
+		t.stopReloads()
+		if t.pressure != nil {
+			t.pressure.Stop()
+		}
+		t.expirer.close()
+
+		if t.writeBack != nil {
+			<-t.writeBackDone
+		}
+
+		if t.shadow != nil {
+			t.shadow.Close()
+		}
+
+		if t.spill != nil {
+			t.spill.close()
+		}
+	})
+}
+
+// CompareAndSwap will replace the item at the key with the new item
+// only if the current item is equal to the old item, and reports whether
+// the swap took place. It will return ErrDNE if the key does not exist.
+func (t *Cache) CompareAndSwap(key string, old, new interface{}) (bool, error) {
+	var swapped bool
+	err := t.write(func() error {
+		hashedKey := t.hash(key)
+
+		idx, ok := t.live(hashedKey)
+		if !ok {
+			return ErrDNE
+		}
+
+		cur := t.slots[idx].Item
+		if cur != nil && !reflect.TypeOf(cur).Comparable() {
+			return ErrIncomparable
+		}
+
+		if cur != old {
+			return nil
+		}
+
+		tx := t.beginStore(hashedKey, key)
+		t.replace(idx, new)
+		t.evict(hashedKey)
+
+		t.mirror(func(shadow *Cache) {
+			shadow.update(hashedKey, new)
+		})
+
+		t.persist(tx, hashedKey, key)
+		swapped = true
+
+		return nil
+	})
+	if err != nil {
+		return false, err
 	}
 
-	return t.add(hashedKey, item, expiresAt)
+	return swapped, nil
 }
 
 // Delete will delete a key from the cache.
 // It will return ErrDNE if the key does not exist.
 func (t *Cache) Delete(key string) error {
-	t.Lock()
-	defer t.Unlock()
+	return t.write(func() error {
+		hashedKey := t.hash(key)
 
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(key))
-	if err != nil {
-		return err
-	}
-	hashedKey := hasher.Sum64()
+		tx := t.beginStore(hashedKey, key)
+		err := t.delete(hashedKey)
+		if err != nil {
+			return err
+		}
+		t.trace(TraceDelete, key, 0, 0)
+
+		t.persist(tx, hashedKey, key)
 
-	return t.delete(hashedKey)
+		return nil
+	})
 }
 
 // Extend will extend the time until expiration for the specified key by the specified duration.
 func (t *Cache) Extend(key string, extend time.Duration) error {
-	t.Lock()
-	defer t.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(key))
-	if err != nil {
-		return err
-	}
-	hashedKey := hasher.Sum64()
+	hashedKey := t.hash(key)
 
 	return t.extend(hashedKey, extend)
 }
 
+// Flush will remove all entries from the cache, including buckets,
+// and release the slot storage. If ExpireOnFlush is set then the
+// expiration callbacks are invoked for every removed item, and the
+// OnEvict callbacks are invoked with ReasonFlushed.
+func (t *Cache) Flush() {
+	t.mu.Lock()
+	var flushed, items []Slot
+	for _, slot := range t.slots {
+		if slot.empty {
+			continue
+		}
+
+		if b, ok := slot.Item.(*Bucket); ok {
+			b.list = make([]uint64, 0)
+			continue
+		}
+		t.evictor.Remove(slot.key)
+		items = append(items, slot)
+
+		if t.config.ExpireOnFlush {
+			flushed = append(flushed, slot)
+		}
+	}
+	removed := t.removals(items, ReasonFlushed)
+
+	t.slots = make([]Slot, 0)
+	t.gen++
+	t.pins = 0
+	t.prioritized = 0
+	t.free = nil
+	t.keys = make(map[uint64]int)
+	t.tags = nil
+	t.deps = newDependencies()
+	t.expiry = newExpiryIndex()
+	if t.sorted != nil {
+		t.sorted = newSkipList()
+	}
+	if t.bloom != nil {
+		t.bloom.reset()
+	}
+	if t.spill != nil {
+		t.spill.reset()
+	}
+	t.revalidate.refreshers = make(map[string]func())
+	t.stopReloads()
+	t.nextExp = time.Time{}
+	t.bytes = 0
+	t.tombstones.flush(t.now())
+	t.mu.Unlock()
+
+	if t.shadow != nil {
+		t.shadow.Flush()
+	}
+
+	t.expire(flushed, true)
+	t.notify(removed, ReasonFlushed, true)
+}
+
 // Get will return the value stored at the key.
 // It will return an ErrDNE value if key is not in cache.
-func (t *Cache) Get(key string) (interface{}, error) {
-	t.Lock()
-	defer t.Unlock()
+// Concurrent calls only share a read lock unless Refresh is enabled.
+// With SpillDir set, an item that was evicted to disk is moved back into memory.
+func (t *Cache) Get(key string, opts ...GetOption) (interface{}, error) {
+	t.trace(TraceGet, key, 0, 0)
+	if t.absent(key) {
+		return nil, ErrDNE
+	}
+
+	item, err := t.getKey(key, newGetOptions(opts))
+	if err == ErrDNE && t.spill != nil {
+		return t.fault(key)
+	}
+
+	return item, err
+}
+
+func (t *Cache) getKey(key string, o getOptions) (interface{}, error) {
+	t.lockGet(&o)
+	defer t.unlockGet(&o)
+
+	hashedKey := t.hash(key)
+
+	return t.getWithOptions(hashedKey, o)
+}
+
+// GetAndTouch will return the value stored at the key and
+// reset its expiration to the specified duration from now,
+// with DefaultExpiration and NoExpiration as in Add.
+// It will return an ErrDNE value if key is not in cache.
+func (t *Cache) GetAndTouch(key string, newTTL time.Duration) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
 
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(key))
+	item, err := t.get(hashedKey)
 	if err != nil {
 		return nil, err
 	}
-	hashedKey := hasher.Sum64()
 
-	return t.get(hashedKey)
+	return item, t.touch(hashedKey, t.expiration(t.ttl(newTTL)))
+}
+
+// GobDecode will add the entries and buckets of a gob encoded
+// cache to the cache, which must have been created with NewCache.
+func (c *Cache) GobDecode(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gobDecode(data)
+}
+
+// GobEncode will encode the entries of the cache and the keys held
+// by its buckets, so that a cache can be gob encoded directly.
+// Items stored as interface values must be registered with gob.Register.
+func (c *Cache) GobEncode() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.gobEncode()
+}
+
+// Keys will return the keys of the items in the cache, in order,
+// excluding buckets and expired items. Items in buckets are listed
+// by their composite keys.
+func (t *Cache) Keys() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+	keys := make([]string, 0, len(t.keys))
+	for _, slot := range t.slots {
+		if slot.empty || slot.deleted || now.After(slot.ExpiresAt) {
+			continue
+		}
+
+		if _, ok := slot.Item.(*Bucket); ok {
+			continue
+		}
+
+		keys = append(keys, slot.name)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
 // Load will load an empty cache with the data from
 // the given file. File should contain a gob encoded
 // cached object created via the `Save()` method.
+// A file encrypted with a Keyring is decrypted with the
+// cache's Keyring, which rejects unencrypted files with
+// ErrUnencrypted unless its AllowPlaintext is set.
+// A file that was truncated or corrupted returns ErrCorrupt
+// and leaves the cache as it was.
 func (c *Cache) Load(filename string) error {
-	data, err := ioutil.ReadFile(filename)
+	data, err := c.readFile(filename)
 	if err != nil {
 		return err
 	}
 
-	return c.gobDecode(data)
+	// files saved before the envelope are plain gob
+	count := -1
+	if enveloped(data) {
+		data, count, err = openEnvelope(envelopeMagic, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	gc, err := decodeGob(data)
+	if err != nil {
+		if count >= 0 {
+			return ErrCorrupt
+		}
+		return err
+	}
+
+	if count >= 0 && count != len(gc.Entries) {
+		return ErrCorrupt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.restore(gc)
 }
 
 // Save will gob-encode and persist the cache
 // in its current state to a file of the given name.
+// The file is wrapped in an envelope recording its format
+// version, number of items and checksum for Load to verify.
+// With a Keyring the file is encrypted with AES-GCM.
 func (c *Cache) Save(filename string) error {
-	data, err := c.gobEncode()
+	c.mu.RLock()
+	gc := c.encode()
+	now := c.now()
+	c.mu.RUnlock()
+
+	data, err := encodeGob(gc)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(filename, data, 0777)
-}
 
-// Update updates the value at the key to the new supplied value
-func (t *Cache) Update(key string, item interface{}) error {
-	hasher := fnv.New64a()
-	_, err := hasher.Write([]byte(key))
+	err = c.writeFile(filename, envelope(envelopeMagic, data, len(gc.Entries)))
 	if err != nil {
 		return err
 	}
-	hashedKey := hasher.Sum64()
 
-	return t.update(hashedKey, item)
+	// the removals before the save are reflected in the file
+	c.tombstones.prune(now)
+
+	return nil
 }
 
-func (t *Cache) add(key uint64, item interface{}, expiresAt time.Time) error {
-	_, ok := t.keys[key]
-	if ok {
-		return ErrCollision
+// readFile will read a file written by writeFile, decrypting it
+func (c *Cache) readFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !encrypted(data) {
+		if c.config.Keyring != nil && !c.config.Keyring.AllowPlaintext {
+			return nil, ErrUnencrypted
+		}
+		return data, nil
+	}
+
+	if c.config.Keyring == nil {
+		return nil, ErrEncrypted
+	}
+
+	return c.config.Keyring.Open(data)
+}
+
+// writeFile will write the data to the file, encrypting it with the Keyring
+func (c *Cache) writeFile(filename string, data []byte) error {
+	var err error
+	if c.config.Keyring != nil {
+		data, err = c.config.Keyring.Seal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// Set will add a key, value, and expiration duration to the cache,
+// or replace the value and expiration if the key already exists.
+func (t *Cache) Set(key string, item interface{}, expiresIn time.Duration) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.set(hashedKey, key, item, t.expiration(t.jitter(t.ttl(expiresIn))))
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
+
+// Touch will reset the time until expiration for the specified key
+// to the specified duration from now. Unlike Extend, the current
+// expiration time is replaced rather than added to. DefaultExpiration
+// and NoExpiration are resolved as in Add.
+func (t *Cache) Touch(key string, newTTL time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	return t.touch(hashedKey, t.expiration(t.ttl(newTTL)))
+}
+
+// TTL will return the time until the item at the key expires,
+// or 0 if it never expires. It will return ErrDNE if the key
+// does not exist or the item has expired.
+func (t *Cache) TTL(key string) (time.Duration, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	hashedKey := t.hash(key)
+
+	idx, ok := t.live(hashedKey)
+	if !ok {
+		return 0, ErrDNE
+	}
+
+	expiresAt := t.slots[idx].ExpiresAt
+	if expiresAt.Equal(neverExpires) {
+		return 0, nil
 	}
 
-	ts := Slot{
+	ttl := expiresAt.Sub(t.now())
+	if ttl <= 0 {
+		return 0, ErrDNE
+	}
+
+	return ttl, nil
+}
+
+// Update updates the value at the key to the new supplied value
+func (t *Cache) Update(key string, item interface{}) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.update(hashedKey, item)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
+
+// UpdateIf will call fn with the current item at the key while holding
+// the cache lock, and replace the item with the returned one if fn returns true.
+// It will return ErrDNE if the key does not exist.
+func (t *Cache) UpdateIf(key string, fn func(cur interface{}) (interface{}, bool)) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.updateIf(hashedKey, fn)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
+
+func (t *Cache) add(key uint64, name string, item interface{}, expiresAt time.Time) error {
+	// an expired item is gone, whether or not the cleaner has removed it
+	if idx, ok := t.keys[key]; ok && t.expired(t.slots[idx], t.now()) {
+		t.dropExpired(idx)
+	}
+
+	if idx, ok := t.keys[key]; ok {
+		if t.slots[idx].name != name {
+			return t.collision()
+		} else if !t.slots[idx].deleted {
+			return ErrCollision
+		}
+		t.remove(idx)
+	}
+
+	size := t.measure(item)
+	if t.config.MaxBytes > 0 && size > t.config.MaxBytes {
+		return ErrTooLarge
+	}
+
+	t.version++
+	idx := t.insert(Slot{
 		Item:      item,
 		ExpiresAt: expiresAt,
+		key:       key,
+		name:      name,
+		size:      size,
+		version:   t.version,
+		added:     t.now(),
 		empty:     false,
-	}
+	})
+	t.expireAt(idx, expiresAt)
+	t.evict(key)
+
+	t.mirror(func(shadow *Cache) {
+		shadow.add(key, name, item, expiresAt)
+	})
 
+	return nil
+}
+
+// insert will place the slot in a free slot, or at the end of the
+// slots, and index its key. The lock must be held.
+func (t *Cache) insert(ts Slot) int {
 	var idx int
-	var fit bool
-	for i, c := range t.slots {
-		if c.empty {
-			t.slots[i] = ts
-			fit = true
-			idx = i
-		}
-	}
-	if !fit {
+	if n := len(t.free); n > 0 {
+		idx = t.free[n-1]
+		t.free = t.free[:n-1]
+		t.slots[idx] = ts
+	} else {
 		idx = len(t.slots)
 		t.slots = append(t.slots, ts)
 	}
 
-	if t.nextExp.After(expiresAt) || len(t.slots) == 1 {
-		t.nextExp = expiresAt
+	t.keys[ts.key] = idx
+	t.list(ts.key, ts.name)
+	t.bytes += ts.size
+	if t.sorted != nil {
+		t.sorted.insert(ts.name)
+	}
+	if t.bloom != nil {
+		t.bloom.add(ts.name)
+	}
+	if t.spill != nil {
+		t.spill.remove(ts.name)
+	}
+	t.tombstones.drop(ts.name)
+	if _, ok := ts.Item.(*Bucket); !ok {
+		t.evictor.Add(ts.key)
 	}
 
-	t.keys[key] = idx
-
-	return nil
+	return idx
 }
 
-func (t *Cache) clean() []Slot {
-	t.Lock()
-	defer t.Unlock()
+func (t *Cache) clean() ([]Slot, []removal) {
+	t.lanes.enter(LaneBackground)
+	defer t.lanes.exit(LaneBackground)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
+	now := t.now()
 	var expired []Slot
-	var nearestExp time.Time
-	firstNonEmpty := true
-	for i, object := range t.slots {
-		if !object.empty {
-			if time.Now().UTC().After(object.ExpiresAt) {
-				expired = append(expired, object)
-				t.slots[i].empty = true
-			} else {
-				if firstNonEmpty {
-					nearestExp = object.ExpiresAt
-					firstNonEmpty = false
-				}
+	var held []expiryEntry
+	for {
+		e, ok := t.expiry.next()
+		if !ok || !now.After(e.at) {
+			break
+		}
 
-				if nearestExp.After(object.ExpiresAt) {
-					nearestExp = object.ExpiresAt
-				}
-			}
+		object := t.slots[e.idx]
+		if !t.expired(object, now) {
+			// held while it can be served stale
+			held = append(held, e)
+			t.expiry.remove(e.idx)
+			continue
 		}
+
+		expired = append(expired, object)
+		dependents := t.dependents(object.name)
+		t.remove(e.idx)
+		t.cascade(dependents)
+		t.countExpiration()
 	}
 
-	t.nextExp = nearestExp
+	for _, e := range held {
+		if !t.slots[e.idx].empty {
+			t.expiry.set(e.idx, e.at)
+		}
+	}
 
-	return expired
+	t.nextExp = time.Time{}
+	if e, ok := t.expiry.next(); ok {
+		t.nextExp = e.at
+	}
+
+	return expired, t.removals(expired, ReasonExpired)
+}
+
+// dropExpired will remove the expired item in the slot as the cleaner
+// would, along with the items depending on it, running its expiration
+// callbacks once the lock is released. The lock must be held.
+func (t *Cache) dropExpired(idx int) {
+	expired := []Slot{t.slots[idx]}
+	dependents := t.dependents(t.slots[idx].name)
+	t.remove(idx)
+	t.cascade(dependents)
+	t.countExpiration()
+
+	removed := t.removals(expired, ReasonExpired)
+	go func() {
+		t.expire(expired, false)
+		t.notify(removed, ReasonExpired, false)
+	}()
+}
+
+// cleaner will clean the cache every CleanDuration until it is closed
+func (t *Cache) cleaner() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-t.config.Clock.After(t.config.CleanDuration):
+		}
+
+		t.cleanCycle()
+	}
+}
+
+// cleanCycle will clean the cache if an item is due to expire,
+// unless cleaning is paused
+func (t *Cache) cleanCycle() {
+	if t.paused() {
+		return
+	}
+
+	t.cleanDue()
+}
+
+// cleanDue will clean the cache if an item is due to expire, returning
+// the number of items removed. A panic is reported to OnError so that
+// one bad cycle cannot stop the cleaner.
+func (t *Cache) cleanDue() (n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.expirer.report(&CleanerPanic{Value: r})
+		}
+	}()
+
+	t.mu.RLock()
+	due := t.now().After(t.nextExp)
+	t.mu.RUnlock()
+
+	if due {
+		expired, removed := t.clean()
+		t.expire(expired, false)
+		t.notify(removed, ReasonExpired, false)
+		n = len(expired)
+	}
+
+	return n
 }
 
 func (t *Cache) delete(key uint64) error {
 	idx, ok := t.keys[key]
 	if !ok {
+		if t.spill != nil && t.spill.removeKey(key) {
+			return nil
+		}
 		return ErrDNE
 	}
 
-	t.slots[idx].empty = true
-	delete(t.keys, key)
+	dependents := t.dependents(t.slots[idx].name)
+	t.remove(idx)
+	t.cascade(dependents)
+
+	t.mirror(func(shadow *Cache) {
+		shadow.delete(key)
+	})
 
 	return nil
 }
 
+// expire will run the expiration callbacks for the slots on the
+// expiration workers, waiting for them to finish if wait is set.
+func (t *Cache) expire(slots []Slot, wait bool) {
+	if len(slots) == 0 {
+		return
+	}
+
+	var callbacks []func()
+	if onBatch := t.config.OnExpiresBatch; onBatch != nil {
+		expired := make([]Expired, len(slots))
+		for i, slot := range slots {
+			expired[i] = Expired{
+				Key:       slot.name,
+				Item:      slot.Item,
+				ExpiresAt: slot.ExpiresAt,
+				Deleted:   slot.deleted,
+			}
+		}
+		callbacks = append(callbacks, func() {
+			onBatch(expired)
+		})
+	}
+
+	if onExpires := t.config.OnExpires; onExpires != nil {
+		for _, slot := range slots {
+			item := slot.Item
+			callbacks = append(callbacks, func() {
+				onExpires(item)
+			})
+		}
+	}
+
+	t.expirer.dispatch(callbacks, wait)
+}
+
 func (t *Cache) extend(key uint64, extend time.Duration) error {
-	idx, ok := t.keys[key]
+	idx, ok := t.live(key)
 	if !ok {
 		return ErrDNE
 	}
 
-	t.slots[idx].ExpiresAt = t.slots[idx].ExpiresAt.Add(extend)
+	t.extendSlot(idx, extend)
 
-	if t.nextExp.After(t.slots[idx].ExpiresAt) {
-		t.nextExp = t.slots[idx].ExpiresAt
-	}
+	t.mirror(func(shadow *Cache) {
+		shadow.extend(key, extend)
+	})
 
 	return nil
 }
 
+func (t *Cache) extendSlot(idx int, extend time.Duration) {
+	t.expireAt(idx, t.slots[idx].ExpiresAt.Add(extend))
+}
+
+// expiration will return the expiration time for an item added
+// with the given duration, where 0 means the item never expires.
+func (t *Cache) expiration(expiresIn time.Duration) time.Time {
+	if expiresIn == 0 || expiresIn == NoExpiration {
+		return neverExpires
+	}
+
+	return t.now().Add(expiresIn)
+}
+
 func (t *Cache) get(key uint64) (interface{}, error) {
-	idx, ok := t.keys[key]
+	return t.getWithOptions(key, getOptions{})
+}
+
+func (t *Cache) getWithOptions(key uint64, o getOptions) (interface{}, error) {
+	t.mirror(func(shadow *Cache) {
+		shadow.get(key)
+	})
+
+	idx, ok := t.present(key)
 	if !ok {
+		t.countMiss()
 		return nil, ErrDNE
 	}
 
-	item := t.slots[idx]
-	if item.empty {
-		delete(t.keys, key)
+	// expired items are missing unless they can be served stale
+	if now := t.now(); now.After(t.slots[idx].ExpiresAt) && !t.stale(t.slots[idx], now) && !o.allowStale {
+		t.countMiss()
 		return nil, ErrDNE
 	}
+	t.countHit()
 
-	if t.config.Refresh {
-		err := t.extend(key, t.config.RefreshDuration)
-		if err != nil {
-			return nil, err
-		}
+	if t.access != nil {
+		t.access.record(key, t.now())
+	}
+	if t.hot != nil {
+		t.hot.record(key, t.slots[idx].name, t.now())
+	}
+	t.evictor.Access(key)
+
+	if t.config.Refresh && !o.noRefresh {
+		t.extendSlot(idx, t.config.RefreshDuration)
 	}
 
-	return item.Item, nil
+	return t.slots[idx].Item, nil
 }
 
 func (c *Cache) gobEncode() ([]byte, error) {
+	return encodeGob(c.encode())
+}
+
+// encode will copy the entries of the cache and the keys
+// held by its buckets for saving. The lock must be held.
+func (c *Cache) encode() gobCache {
+	var gc gobCache
+	for _, slot := range c.slots {
+		if slot.empty || slot.deleted {
+			continue
+		}
+
+		if b, ok := slot.Item.(*Bucket); ok {
+			gb := gobBucket{Name: b.name}
+			for _, k := range b.list {
+				if idx, ok := c.keys[k]; ok {
+					gb.Keys = append(gb.Keys, c.slots[idx].name)
+				}
+			}
+			gc.Buckets = append(gc.Buckets, gb)
+			continue
+		}
+
+		// pins are not saved, so neither are held expirations
+		expiresAt := slot.ExpiresAt
+		if !slot.held.IsZero() {
+			expiresAt = slot.held
+		}
+
+		gc.Entries = append(gc.Entries, gobEntry{
+			Key:       slot.name,
+			Item:      slot.Item,
+			ExpiresAt: expiresAt,
+			Meta:      slot.meta,
+		})
+	}
+
+	return gc
+}
+
+func encodeGob(gc gobCache) ([]byte, error) {
 	var buff bytes.Buffer
 	e := gob.NewEncoder(&buff)
-	err := e.Encode(c)
+	err := e.Encode(gc)
 	if err != nil {
 		return nil, err
 	}
@@ -321,24 +1191,177 @@ func (c *Cache) gobEncode() ([]byte, error) {
 	return buff.Bytes(), nil
 }
 
+func decodeGob(data []byte) (gobCache, error) {
+	var gc gobCache
+	d := gob.NewDecoder(bytes.NewReader(data))
+	err := d.Decode(&gc)
+	return gc, err
+}
+
 func (c *Cache) gobDecode(data []byte) error {
-	var buf bytes.Buffer
-	_, err := buf.Write(data)
+	gc, err := decodeGob(data)
 	if err != nil {
 		return err
 	}
 
-	d := gob.NewDecoder(&buf)
-	return d.Decode(c)
+	return c.restore(gc)
 }
 
-func (t *Cache) update(key uint64, item interface{}) error {
+// restore will add the saved entries and buckets to the cache.
+// The lock must be held.
+func (c *Cache) restore(gc gobCache) error {
+	for _, entry := range gc.Entries {
+		hk := c.hash(entry.Key)
+		err := c.add(hk, entry.Key, entry.Item, entry.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		if idx, ok := c.keys[hk]; ok {
+			c.slots[idx].meta = entry.Meta
+		}
+	}
+
+	for _, gb := range gc.Buckets {
+		_, err := c.bucket(gb.Name, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range gb.Keys {
+			hk := c.hash(name)
+			if _, ok := c.keys[hk]; ok {
+				c.list(hk, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// readLock will take the lock needed by get, which is only
+// a read lock when get does not refresh expiration times.
+func (t *Cache) readLock() {
+	if t.config.Refresh {
+		t.mu.Lock()
+		return
+	}
+	t.mu.RLock()
+}
+
+// readUnlock will release the lock taken by readLock.
+func (t *Cache) readUnlock() {
+	if t.config.Refresh {
+		t.mu.Unlock()
+		return
+	}
+	t.mu.RUnlock()
+}
+
+// remove will empty the slot at idx, drop its key
+// and make the slot available for reuse.
+func (t *Cache) remove(idx int) {
+	t.evictor.Remove(t.slots[idx].key)
+	t.expiry.remove(idx)
+	delete(t.keys, t.slots[idx].key)
+	t.unlist(t.slots[idx].key, t.slots[idx].name)
+	t.untag(idx)
+	t.deps.unlink(t.slots[idx].name)
+	if t.sorted != nil {
+		t.sorted.remove(t.slots[idx].name)
+	}
+	if t.bloom != nil {
+		t.bloom.remove(t.slots[idx].name)
+	}
+	delete(t.revalidate.refreshers, t.slots[idx].name)
+	t.stopReload(t.slots[idx].name)
+	t.bytes -= t.slots[idx].size
+	if t.slots[idx].pinned {
+		t.pins--
+	}
+	if t.slots[idx].priority != PriorityNormal {
+		t.prioritized--
+	}
+	if _, ok := t.slots[idx].Item.(*Bucket); !ok {
+		t.tombstones.add(t.slots[idx].name, t.now())
+	}
+	t.slots[idx] = Slot{empty: true}
+	t.free = append(t.free, idx)
+	t.gen++
+}
+
+func (t *Cache) set(key uint64, name string, item interface{}, expiresAt time.Time) error {
+	if idx, ok := t.keys[key]; ok && t.expired(t.slots[idx], t.now()) {
+		t.dropExpired(idx)
+	}
+
 	idx, ok := t.keys[key]
+	if !ok {
+		return t.add(key, name, item, expiresAt)
+	}
+
+	if t.slots[idx].name != name {
+		return t.collision()
+	}
+
+	t.replace(idx, item)
+	t.expireAt(idx, expiresAt)
+	t.slots[idx].deleted = false
+	t.evict(key)
+
+	t.mirror(func(shadow *Cache) {
+		shadow.set(key, name, item, expiresAt)
+	})
+
+	return nil
+}
+
+func (t *Cache) touch(key uint64, expiresAt time.Time) error {
+	idx, ok := t.live(key)
+	if !ok {
+		return ErrDNE
+	}
+
+	t.expireAt(idx, expiresAt)
+
+	t.mirror(func(shadow *Cache) {
+		shadow.touch(key, expiresAt)
+	})
+
+	return nil
+}
+
+func (t *Cache) update(key uint64, item interface{}) error {
+	idx, ok := t.live(key)
 	if !ok {
 		return ErrDNE
 	}
 
-	t.slots[idx].Item = item
+	t.replace(idx, item)
+	t.evict(key)
+
+	t.mirror(func(shadow *Cache) {
+		shadow.update(key, item)
+	})
+
+	return nil
+}
+
+func (t *Cache) updateIf(key uint64, fn func(cur interface{}) (interface{}, bool)) error {
+	idx, ok := t.live(key)
+	if !ok {
+		return ErrDNE
+	}
+
+	item, ok := fn(t.slots[idx].Item)
+	if ok {
+		t.replace(idx, item)
+		t.evict(key)
+
+		t.mirror(func(shadow *Cache) {
+			shadow.update(key, item)
+		})
+	}
 
 	return nil
 }
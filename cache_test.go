@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -111,3 +113,530 @@ func TestCacheAdd(t *testing.T) {
 		t.Errorf("did not return collision error when adding existing key: %+v", err)
 	}
 }
+
+func TestCacheCompareAndSwap(t *testing.T) {
+	cache := NewCache(nil)
+	_, err := cache.CompareAndSwap("key", "value", "new-value")
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	err = cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	swapped, err := cache.CompareAndSwap("key", "other-value", "new-value")
+	if err != nil {
+		t.Errorf("error while swapping key: %+v", err)
+	}
+
+	if swapped {
+		t.Error("swapped item that did not match old item")
+	}
+
+	swapped, err = cache.CompareAndSwap("key", "value", "new-value")
+	if err != nil {
+		t.Errorf("error while swapping key: %+v", err)
+	}
+
+	if !swapped {
+		t.Error("did not swap item that matched old item")
+	}
+
+	value, err := cache.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "new-value" {
+		t.Error("value was not swapped for key properly")
+	}
+
+	err = cache.Add("slice", []string{"value"}, 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	_, err = cache.CompareAndSwap("slice", []string{"value"}, "new-value")
+	if err != ErrIncomparable {
+		t.Errorf("should have returned ErrIncomparable but returned %+v", err)
+	}
+}
+
+func TestCacheUpdateIf(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.UpdateIf("key", func(cur interface{}) (interface{}, bool) {
+		return cur, true
+	})
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	err = cache.Add("key", 1, 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.UpdateIf("key", func(cur interface{}) (interface{}, bool) {
+		return cur.(int) + 1, true
+	})
+	if err != nil {
+		t.Errorf("error while updating key: %+v", err)
+	}
+
+	err = cache.UpdateIf("key", func(cur interface{}) (interface{}, bool) {
+		return 100, false
+	})
+	if err != nil {
+		t.Errorf("error while updating key: %+v", err)
+	}
+
+	value, err := cache.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(int) != 2 {
+		t.Errorf("value was not updated for key properly: %d", value)
+	}
+}
+
+func TestCacheTouch(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Touch("key", 1*time.Minute)
+	if err != ErrDNE {
+		t.Errorf("touching key that does not exist did not fail: %+v", err)
+	}
+
+	err = cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Touch("key", 1*time.Minute)
+	if err != nil {
+		t.Errorf("error while touching key: %+v", err)
+	}
+
+	expiresAt := cache.slots[0].ExpiresAt
+	if expiresAt.After(time.Now().UTC().Add(1 * time.Minute)) {
+		t.Errorf("expiration was not reset: %s", expiresAt)
+	}
+}
+
+func TestCacheGetAndTouch(t *testing.T) {
+	cache := NewCache(nil)
+	_, err := cache.GetAndTouch("key", 1*time.Minute)
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	err = cache.Add("key", "value", 1*time.Second)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	value, err := cache.GetAndTouch("key", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "value" {
+		t.Errorf("returned value was %s", value)
+	}
+
+	expiresAt := cache.slots[0].ExpiresAt
+	if expiresAt.Before(time.Now().UTC().Add(9 * time.Minute)) {
+		t.Errorf("expiration was not reset: %s", expiresAt)
+	}
+}
+
+func TestCacheOnExpiresBatch(t *testing.T) {
+	batches := make(chan []Expired, 1)
+	cache := NewCache(&CacheConfig{
+		OnExpiresBatch: func(items []Expired) {
+			batches <- items
+		},
+		CleanDuration: 10 * time.Millisecond,
+	})
+
+	for _, key := range []string{"a", "b", "c"} {
+		err := cache.Add(key, key, 1*time.Millisecond)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	select {
+	case items := <-batches:
+		if len(items) != 3 {
+			t.Errorf("batch contained %d items", len(items))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expired items were not batched")
+	}
+}
+
+func TestCacheFlush(t *testing.T) {
+	var expired int
+	cache := NewCache(&CacheConfig{
+		OnExpires: func(item interface{}) {
+			expired++
+		},
+		ExpireOnFlush: true,
+	})
+
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	b := cache.Bucket("my-bucket")
+	err = b.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	cache.Flush()
+
+	if expired != 2 {
+		t.Errorf("expiration callback was called %d times", expired)
+	}
+
+	if len(cache.slots) != 0 || len(cache.keys) != 0 {
+		t.Error("slot storage was not released")
+	}
+
+	if b.Len() != 0 {
+		t.Errorf("length of bucket list is %d", b.Len())
+	}
+
+	_, err = cache.Get("key")
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+}
+
+func BenchmarkCacheAdd(b *testing.B) {
+	cache := NewCache(nil)
+	keys := make([]string, b.N)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := cache.Add(keys[i], i, 10*time.Minute)
+		if err != nil {
+			b.Fatalf("error adding key: %+v", err)
+		}
+	}
+}
+
+func BenchmarkCacheAddDelete(b *testing.B) {
+	cache := NewCache(nil)
+	for i := 0; i < 10000; i++ {
+		err := cache.Add(strconv.Itoa(i), i, 10*time.Minute)
+		if err != nil {
+			b.Fatalf("error adding key: %+v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := strconv.Itoa(i % 10000)
+		err := cache.Delete(key)
+		if err != nil {
+			b.Fatalf("error deleting key: %+v", err)
+		}
+
+		err = cache.Add(key, i, 10*time.Minute)
+		if err != nil {
+			b.Fatalf("error adding key: %+v", err)
+		}
+	}
+}
+
+func TestCacheSlotReuse(t *testing.T) {
+	cache := NewCache(nil)
+	for _, key := range []string{"a", "b", "c"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	for _, key := range []string{"a", "b"} {
+		err := cache.Delete(key)
+		if err != nil {
+			t.Errorf("error while deleting key: %+v", err)
+		}
+	}
+
+	err := cache.Add("d", "d", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	if len(cache.slots) != 3 {
+		t.Errorf("slots were not reused: %d slots", len(cache.slots))
+	}
+
+	var live int
+	for _, slot := range cache.slots {
+		if !slot.empty {
+			live++
+		}
+	}
+
+	if live != 2 {
+		t.Errorf("cache had %d live slots", live)
+	}
+}
+
+func TestCacheConcurrentGet(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	// a held read lock must not block readers
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := cache.Get("key")
+		if err != nil {
+			t.Errorf("error while getting key: %+v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("concurrent get was blocked by a reader")
+	}
+}
+
+func BenchmarkCacheGetParallel(b *testing.B) {
+	cache := NewCache(nil)
+	for i := 0; i < 1000; i++ {
+		err := cache.Add(strconv.Itoa(i), i, 10*time.Minute)
+		if err != nil {
+			b.Fatalf("error adding key: %+v", err)
+		}
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			_, err := cache.Get(strconv.Itoa(i % 1000))
+			if err != nil {
+				b.Fatalf("error while getting key: %+v", err)
+			}
+			i++
+		}
+	})
+}
+
+func TestCacheSaveLoad(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Bucket("my-bucket").Add("key", "bucket-value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "cache.gob")
+	err = cache.Save(filename)
+	if err != nil {
+		t.Fatalf("error while saving cache: %+v", err)
+	}
+
+	loaded := NewCache(nil)
+	err = loaded.Load(filename)
+	if err != nil {
+		t.Fatalf("error while loading cache: %+v", err)
+	}
+
+	value, err := loaded.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "value" {
+		t.Errorf("returned value was %s", value)
+	}
+
+	b := loaded.Bucket("my-bucket")
+	if b.Len() != 1 {
+		t.Errorf("length of bucket list is %d", b.Len())
+	}
+
+	value, err = b.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "bucket-value" {
+		t.Errorf("returned value was %s", value)
+	}
+}
+
+func TestCacheSet(t *testing.T) {
+	cache := NewCache(nil)
+
+	err := cache.Set("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	err = cache.Set("key", "new", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error setting existing key: %+v", err)
+	}
+
+	value, err := cache.Get("key")
+	if err != nil || value != "new" {
+		t.Errorf("unexpected value %v: %+v", value, err)
+	}
+}
+
+func TestCacheNeverExpires(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+	})
+
+	err := cache.Add("key", "value", 0)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+	b := cache.Bucket("bucket")
+
+	// add an item that expires so that the cleaner runs
+	err = cache.Add("soon", "value", time.Millisecond)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = cache.Get("key")
+	if err != nil {
+		t.Errorf("item without an expiration expired: %+v", err)
+	}
+
+	if cache.Bucket("bucket") != b {
+		t.Errorf("bucket was removed by the cleaner")
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	cache := NewCache(nil)
+
+	cache.Add("b", 1, 10*time.Minute)
+	cache.Add("a", 2, 0)
+	cache.Add("expired", 3, time.Nanosecond)
+	cache.Bucket("bucket").Add("c", 4, 10*time.Minute)
+	time.Sleep(time.Millisecond)
+
+	keys := cache.Keys()
+	expected := []string{"a", "b", "bucket:c"}
+	if len(keys) != len(expected) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("unexpected keys: %v", keys)
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := NewCache(nil)
+
+	cache.Add("a", 1, 10*time.Minute)
+	cache.Add("b", 2, 0)
+
+	ttl, err := cache.TTL("a")
+	if err != nil || ttl <= 9*time.Minute || ttl > 10*time.Minute {
+		t.Errorf("unexpected ttl %v: %+v", ttl, err)
+	}
+
+	ttl, err = cache.TTL("b")
+	if err != nil || ttl != 0 {
+		t.Errorf("unexpected ttl for an item that never expires %v: %+v", ttl, err)
+	}
+
+	_, err = cache.TTL("missing")
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+}
+
+func TestCacheExpiredItems(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	var expired removalLog
+	cache := NewCache(&CacheConfig{
+		Clock:          clock,
+		DisableCleaner: true,
+		OnExpire:       expired.record,
+	})
+	defer cache.Close()
+
+	for _, key := range []string{"add", "touch", "update", "cas"} {
+		err := cache.Add(key, "old", time.Minute)
+		if err != nil {
+			t.Errorf("error adding %s: %+v", key, err)
+		}
+	}
+	cache.AddTagged("tagged", "old", time.Minute, "tag")
+	clock.Advance(2 * time.Minute)
+
+	err := cache.Add("add", "new", time.Hour)
+	if err != nil {
+		t.Errorf("adding over an expired item should succeed: %+v", err)
+	}
+
+	value, err := cache.Get("add")
+	if err != nil || value.(string) != "new" {
+		t.Errorf("expected the new item, got %v: %+v", value, err)
+	}
+
+	if !waitFor(func() bool { return len(expired.take()) > 0 }) {
+		t.Error("expired item replaced by Add was not passed to OnExpire")
+	}
+
+	err = cache.Touch("touch", time.Hour)
+	if err != ErrDNE {
+		t.Errorf("touching an expired item should return ErrDNE: %+v", err)
+	}
+
+	err = cache.Update("update", "new")
+	if err != ErrDNE {
+		t.Errorf("updating an expired item should return ErrDNE: %+v", err)
+	}
+
+	swapped, err := cache.CompareAndSwap("cas", "old", "new")
+	if swapped || err != ErrDNE {
+		t.Errorf("swapping an expired item should return ErrDNE: %v %+v", swapped, err)
+	}
+
+	err = cache.Set("tagged", "new", time.Hour)
+	if err != nil {
+		t.Errorf("error setting over an expired item: %+v", err)
+	}
+
+	if n := cache.InvalidateTag("tag"); n != 0 {
+		t.Errorf("item set over an expired item kept its tags, %d invalidated", n)
+	}
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strconv"
+)
+
+var (
+	// ErrCorruptChunk is returned when a chunked value fails its integrity check
+	ErrCorruptChunk = errors.New("corrupt chunked value")
+
+	defaultChunkSize = 512 * 1024
+)
+
+const (
+	chunkInline   byte = 0 // the value follows the header byte
+	chunkManifest byte = 1 // the value is split across chunk keys
+
+	manifestSize = 1 + 4 + 8 + 4 // header, chunk count, value length, crc32
+)
+
+// Chunk is a key and the stored data for one part of a chunked value
+type Chunk struct {
+	Key  string
+	Data []byte
+}
+
+// Chunker splits values that are larger than Size into chunks
+// for storage backends with item size limits (e.g. memcached's 1MB),
+// and reassembles them with an integrity check.
+type Chunker struct {
+	Size int // maximum size of a stored chunk, defaults to 512KB
+}
+
+// Split will return the chunks to store for the value. The first chunk is
+// always stored at the key itself, holding either the value inline or a
+// manifest describing the remaining chunks.
+func (c *Chunker) Split(key string, value []byte) []Chunk {
+	size := c.Size
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+
+	if len(value)+1 <= size {
+		head := make([]byte, 1+len(value))
+		head[0] = chunkInline
+		copy(head[1:], value)
+		return []Chunk{{Key: key, Data: head}}
+	}
+
+	count := (len(value) + size - 1) / size
+	manifest := make([]byte, manifestSize)
+	manifest[0] = chunkManifest
+	binary.BigEndian.PutUint32(manifest[1:], uint32(count))
+	binary.BigEndian.PutUint64(manifest[5:], uint64(len(value)))
+	binary.BigEndian.PutUint32(manifest[13:], crc32.ChecksumIEEE(value))
+
+	chunks := make([]Chunk, 0, count+1)
+	chunks = append(chunks, Chunk{Key: key, Data: manifest})
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(value) {
+			end = len(value)
+		}
+
+		chunks = append(chunks, Chunk{
+			Key:  ChunkKey(key, i),
+			Data: value[i*size : end],
+		})
+	}
+
+	return chunks
+}
+
+// Join will reassemble a value from the data stored at its key, calling
+// fetch for each of the remaining chunks when the value was split.
+// It will return ErrCorruptChunk if the reassembled value does not match
+// the length and checksum recorded when it was split.
+func (c *Chunker) Join(key string, head []byte, fetch func(key string) ([]byte, error)) ([]byte, error) {
+	if len(head) == 0 {
+		return nil, ErrCorruptChunk
+	}
+
+	switch head[0] {
+	case chunkInline:
+		return head[1:], nil
+	case chunkManifest:
+	default:
+		return nil, ErrCorruptChunk
+	}
+
+	if len(head) != manifestSize {
+		return nil, ErrCorruptChunk
+	}
+
+	count := int(binary.BigEndian.Uint32(head[1:]))
+	length := binary.BigEndian.Uint64(head[5:])
+	sum := binary.BigEndian.Uint32(head[13:])
+
+	value := make([]byte, 0, length)
+	for i := 0; i < count; i++ {
+		data, err := fetch(ChunkKey(key, i))
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, data...)
+	}
+
+	if uint64(len(value)) != length || crc32.ChecksumIEEE(value) != sum {
+		return nil, ErrCorruptChunk
+	}
+
+	return value, nil
+}
+
+// Keys will return the keys of the chunks a value was split into,
+// given the data stored at its key. It returns no keys for values
+// that are stored inline or for data that is not a valid header.
+func (c *Chunker) Keys(key string, head []byte) []string {
+	if len(head) != manifestSize || head[0] != chunkManifest {
+		return nil
+	}
+
+	count := int(binary.BigEndian.Uint32(head[1:]))
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = ChunkKey(key, i)
+	}
+
+	return keys
+}
+
+// ChunkKey will return the key the i'th chunk of a value is stored at
+func ChunkKey(key string, i int) string {
+	return key + "#chunk-" + strconv.Itoa(i)
+}
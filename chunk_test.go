@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChunkerSplitJoin(t *testing.T) {
+	chunker := &Chunker{Size: 16}
+	value := bytes.Repeat([]byte("0123456789"), 10)
+
+	store := make(map[string][]byte)
+	chunks := chunker.Split("key", value)
+	if len(chunks) != 8 {
+		t.Errorf("value was split into %d chunks", len(chunks))
+	}
+
+	for i, c := range chunks {
+		if i > 0 && len(c.Data) > chunker.Size {
+			t.Errorf("chunk %s was %d bytes", c.Key, len(c.Data))
+		}
+		store[c.Key] = c.Data
+	}
+
+	fetch := func(key string) ([]byte, error) {
+		data, ok := store[key]
+		if !ok {
+			return nil, ErrDNE
+		}
+		return data, nil
+	}
+
+	joined, err := chunker.Join("key", store["key"], fetch)
+	if err != nil {
+		t.Errorf("error while joining chunks: %+v", err)
+	}
+
+	if !bytes.Equal(joined, value) {
+		t.Error("joined value did not match split value")
+	}
+
+	store[ChunkKey("key", 3)] = []byte("corrupted-chunk!")
+	_, err = chunker.Join("key", store["key"], fetch)
+	if err != ErrCorruptChunk {
+		t.Errorf("should have returned ErrCorruptChunk but returned %+v", err)
+	}
+
+	delete(store, ChunkKey("key", 0))
+	_, err = chunker.Join("key", store["key"], fetch)
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+}
+
+func TestChunkerInline(t *testing.T) {
+	chunker := &Chunker{Size: 16}
+	chunks := chunker.Split("key", []byte("small"))
+	if len(chunks) != 1 || chunks[0].Key != "key" {
+		t.Errorf("small value was split into %+v", chunks)
+	}
+
+	value, err := chunker.Join("key", chunks[0].Data, nil)
+	if err != nil {
+		t.Errorf("error while joining chunks: %+v", err)
+	}
+
+	if string(value) != "small" {
+		t.Errorf("joined value was %s", value)
+	}
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Entry is an item removed from or read out of the cache with its key
+type Entry struct {
+	Key       string
+	Item      interface{}
+	ExpiresAt time.Time
+}
+
+// DeleteExpired will remove the expired items from the cache and return
+// them, in order of their expiration. It lets callers that disabled the
+// background cleaner with DisableCleaner drive expiration themselves.
+// The expiration callbacks are run before it returns.
+func (t *Cache) DeleteExpired() []Entry {
+	slots, removed := t.clean()
+	if t.shadow != nil {
+		t.shadow.clean()
+	}
+
+	t.expire(slots, true)
+	t.notify(removed, ReasonExpired, true)
+
+	return entries(slots)
+}
+
+// entries will return the keys and items of the slots
+func entries(slots []Slot) []Entry {
+	entries := make([]Entry, len(slots))
+	for i, slot := range slots {
+		entries[i] = Entry{
+			Key:       slot.name,
+			Item:      slot.Item,
+			ExpiresAt: slot.ExpiresAt,
+		}
+	}
+
+	return entries
+}
+
+// PauseCleaning will stop the cleaner from removing expired items until
+// ResumeCleaning is called, so that a bulk load or a snapshot is not
+// raced by expiration. Expired items are still missing from Get while
+// cleaning is paused. Calls nest, and cleaning resumes once each call
+// has been resumed.
+func (t *Cache) PauseCleaning() {
+	atomic.AddInt32(&t.pauses, 1)
+}
+
+// ResumeCleaning will undo a call to PauseCleaning. Items that expired
+// while cleaning was paused are removed by the next clean cycle.
+func (t *Cache) ResumeCleaning() {
+	for {
+		n := atomic.LoadInt32(&t.pauses)
+		if n <= 0 || atomic.CompareAndSwapInt32(&t.pauses, n, n-1) {
+			return
+		}
+	}
+}
+
+// paused reports whether cleaning is paused
+func (t *Cache) paused() bool {
+	return atomic.LoadInt32(&t.pauses) > 0
+}
+
+// CleanNow will run a clean cycle now, even while cleaning is paused,
+// and return the number of expired items removed. Unlike DeleteExpired
+// the expiration callbacks run in the background, as they do for the
+// cleaner.
+func (t *Cache) CleanNow() int {
+	return t.cleanDue()
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeleteExpired(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	var expired int64
+	cache, err := NewCacheWithOptions(
+		WithClock(clock),
+		WithoutCleaner(),
+		WithOnExpires(func(item interface{}) {
+			atomic.AddInt64(&expired, 1)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	if clock.Waiters() != 0 {
+		t.Errorf("expected no cleaner to be waiting on the clock, got %d", clock.Waiters())
+	}
+
+	cache.Add("later", 1, 2*time.Minute)
+	cache.Add("first", 2, time.Minute)
+	cache.Add("second", 3, 90*time.Second)
+	cache.Add("forever", 4, 0)
+
+	if entries := cache.DeleteExpired(); len(entries) != 0 {
+		t.Errorf("expected nothing to expire yet, got %+v", entries)
+	}
+
+	clock.Advance(100 * time.Second)
+	entries := cache.DeleteExpired()
+	if len(entries) != 2 || entries[0].Key != "first" || entries[1].Key != "second" {
+		t.Fatalf("expected the expired items in order, got %+v", entries)
+	}
+
+	if entries[0].Item != 2 || !entries[0].ExpiresAt.Equal(clock.Now().Add(-40*time.Second)) {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	if atomic.LoadInt64(&expired) != 2 {
+		t.Errorf("expected the callbacks to have run, got %d", atomic.LoadInt64(&expired))
+	}
+
+	if len(cache.Keys()) != 2 {
+		t.Errorf("expected two items to remain, got %d", len(cache.Keys()))
+	}
+
+	if entries := cache.DeleteExpired(); len(entries) != 0 {
+		t.Errorf("expected the items to be deleted once, got %+v", entries)
+	}
+}
+
+func TestPauseCleaning(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, CleanDuration: time.Minute})
+	defer cache.Close()
+
+	cache.Add("key", "value", time.Second)
+	cache.PauseCleaning()
+	cache.PauseCleaning()
+
+	waitFor(func() bool { return clock.Waiters() == 1 })
+	clock.Advance(time.Minute)
+	waitFor(func() bool { return clock.Waiters() == 1 })
+
+	if cache.Stats().Entries != 1 {
+		t.Errorf("expected the cleaner not to run while paused")
+	}
+
+	if _, err := cache.Get("key"); err != ErrDNE {
+		t.Errorf("expected the expired item to be missing while paused, got %+v", err)
+	}
+
+	cache.ResumeCleaning()
+	clock.Advance(time.Minute)
+	waitFor(func() bool { return clock.Waiters() == 1 })
+
+	if cache.Stats().Entries != 1 {
+		t.Errorf("expected cleaning to stay paused until every pause is resumed")
+	}
+
+	if n := cache.CleanNow(); n != 1 || cache.Stats().Entries != 0 {
+		t.Errorf("expected CleanNow to clean while paused, removed %d", n)
+	}
+
+	cache.ResumeCleaning()
+	cache.ResumeCleaning()
+	cache.Add("other", "value", time.Second)
+	clock.Advance(time.Minute)
+
+	if !waitFor(func() bool { return cache.Stats().Entries == 0 }) {
+		t.Errorf("expected the cleaner to run once resumed")
+	}
+
+	if n := cache.CleanNow(); n != 0 {
+		t.Errorf("expected nothing left to clean, removed %d", n)
+	}
+}
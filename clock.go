@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for a cache. Expirations, refreshes and
+// the cleaner all read the time from it, so that tests can replace it
+// with a ManualClock and advance time without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending call started by Clock.AfterFunc
+type Timer interface {
+	// Stop will prevent the call, returning false if it has already run or been stopped
+	Stop() bool
+}
+
+// systemClock is the Clock reading the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// ManualClock is a Clock whose time only moves when it is advanced,
+// for testing expiration without sleeping. Timers and channels due
+// within an advance fire in order of their deadlines.
+type ManualClock struct {
+	now     time.Time
+	waiters []*manualWaiter
+	mu      *sync.Mutex
+}
+
+type manualWaiter struct {
+	at    time.Time
+	ch    chan time.Time
+	fn    func()
+	clock *ManualClock
+}
+
+// NewManualClock will create and return a pointer to
+// a new ManualClock set to the given time
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{
+		now: now,
+		mu:  &sync.Mutex{},
+	}
+}
+
+// Now will return the time the clock is set to
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After will return a channel that receives the
+// time once the clock has advanced by d
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	w := &manualWaiter{ch: make(chan time.Time, 1)}
+	c.wait(w, d)
+	return w.ch
+}
+
+// AfterFunc will call f in its own goroutine
+// once the clock has advanced by d
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &manualWaiter{fn: f}
+	c.wait(w, d)
+	return w
+}
+
+// Advance will move the clock forward by d, firing the
+// timers and channels that become due
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var due []*manualWaiter
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(now) {
+			pending = append(pending, w)
+		} else {
+			due = append(due, w)
+		}
+	}
+	c.waiters = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].at.Before(due[j].at)
+	})
+
+	for _, w := range due {
+		if w.ch != nil {
+			w.ch <- now
+		} else {
+			go w.fn()
+		}
+	}
+}
+
+// Waiters will return the number of timers and channels that
+// have not fired, so that tests can wait for a goroutine to
+// start waiting before advancing the clock
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+func (c *ManualClock) wait(w *manualWaiter, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.clock = c
+	w.at = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+}
+
+func (w *manualWaiter) Stop() bool {
+	c := w.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// now will return the current time of the cache's clock
+func (t *Cache) now() time.Time {
+	return t.config.Clock.Now().UTC()
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	ch := clock.After(time.Minute)
+	var fired int64
+	clock.AfterFunc(2*time.Minute, func() {
+		atomic.AddInt64(&fired, 1)
+	})
+	stopped := clock.AfterFunc(time.Second, func() {
+		atomic.AddInt64(&fired, 10)
+	})
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Errorf("expected Stop to report whether the timer was pending")
+	}
+
+	clock.Advance(90 * time.Second)
+	select {
+	case now := <-ch:
+		if !now.Equal(start.Add(90 * time.Second)) {
+			t.Errorf("unexpected time %v", now)
+		}
+	default:
+		t.Errorf("expected the channel to fire")
+	}
+
+	if clock.Waiters() != 1 {
+		t.Errorf("expected one pending timer, got %d", clock.Waiters())
+	}
+
+	clock.Advance(time.Minute)
+	if !waitFor(func() bool { return atomic.LoadInt64(&fired) == 1 }) {
+		t.Errorf("expected only the pending timer to fire, got %d", atomic.LoadInt64(&fired))
+	}
+
+	if !clock.Now().Equal(start.Add(150 * time.Second)) {
+		t.Errorf("unexpected time %v", clock.Now())
+	}
+}
+
+func TestCacheClock(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	var expired int64
+	cache, err := NewCacheWithOptions(
+		WithClock(clock),
+		WithCleanInterval(time.Second),
+		WithOnExpires(func(item interface{}) {
+			atomic.AddInt64(&expired, 1)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	cache.Add("key", 1, time.Minute)
+	if ttl, _ := cache.TTL("key"); ttl != time.Minute {
+		t.Errorf("expected the ttl to be read from the clock, got %v", ttl)
+	}
+
+	clock.Advance(30 * time.Second)
+	if _, err := cache.Get("key"); err != nil {
+		t.Errorf("expected the item before its ttl, got %+v", err)
+	}
+
+	clock.Advance(31 * time.Second)
+	if _, err := cache.Get("key"); err != ErrDNE {
+		t.Errorf("expected the item to expire, got %+v", err)
+	}
+
+	// the cleaner waits on the clock, so advancing it runs a clean
+	if !waitFor(func() bool { return clock.Waiters() > 0 }) {
+		t.Fatalf("expected the cleaner to wait on the clock")
+	}
+	clock.Advance(time.Second)
+
+	if !waitFor(func() bool { return atomic.LoadInt64(&expired) == 1 }) {
+		t.Errorf("expected the cleaner to expire the item")
+	}
+}
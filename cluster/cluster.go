@@ -0,0 +1,530 @@
+// Package cluster replicates changes between cache instances over UDP.
+// Nodes discover each other by gossiping their member lists, starting
+// from a set of seed addresses, and replicate Add, Set, Update, Delete
+// and expiration events to every live member without a central broker.
+// Replication is eventually consistent: events are fire-and-forget
+// datagrams that receivers also relay to a few random members, so a
+// lost datagram is usually made up for by a relay.
+//
+// Every datagram is signed with an HMAC-SHA256 of the Secret shared by
+// the members, and datagrams whose signature does not match are dropped,
+// so that only nodes holding the Secret can join or change the caches.
+// Datagrams are not encrypted.
+//
+// Items are encoded with encoding/gob, so types other than the
+// basic types must be registered with gob.Register, and every
+// event must fit into a single datagram.
+package cluster
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrClosed is returned by the methods of a closed Node
+	ErrClosed = errors.New("cluster: node closed")
+
+	// ErrNoSecret is returned by New when no Secret is configured
+	ErrNoSecret = errors.New("cluster: no secret configured")
+
+	defaultGossipInterval = 200 * time.Millisecond
+	defaultDeadAfter      = 5 * time.Second
+	defaultFanout         = 3
+)
+
+const (
+	maxDatagram = 64 * 1024
+	seenFor     = time.Minute // how long event ids are remembered to filter duplicates
+)
+
+// Config is used to configure a Node
+type Config struct {
+	Bind           string             // UDP address the node listens on, e.g. ":7946"
+	Secret         []byte             // key shared by the members that signs every datagram, required
+	Seeds          []string           // addresses of existing nodes to join through
+	Cache          *cache.CacheConfig // configuration of the local cache
+	GossipInterval time.Duration      // interval at which member lists are gossiped, defaults to 200ms
+	DeadAfter      time.Duration      // members whose heartbeat has not advanced for this long are removed, defaults to 5s
+	Fanout         int                // members each gossip and relayed event is sent to, defaults to 3
+}
+
+// Node is a cache that replicates its changes to the other members of a cluster
+type Node struct {
+	cache  *cache.Cache
+	config Config
+	id     string
+	conn   *net.UDPConn
+
+	heartbeat uint64
+	seq       uint64
+	members   map[string]*member
+	dead      map[string]*member // removed members, so that stale gossip does not revive them
+	seen      map[string]time.Time
+
+	done chan struct{}
+	wg   *sync.WaitGroup
+	mu   *sync.Mutex
+}
+
+type member struct {
+	addr      *net.UDPAddr
+	heartbeat uint64
+	updated   time.Time
+}
+
+type kind uint8
+
+const (
+	kindGossip kind = iota
+	kindSet
+	kindDelete
+	kindExpire
+	kindUpdate
+)
+
+// message is the datagram exchanged between nodes
+type message struct {
+	Kind      kind
+	From      string
+	Heartbeat uint64
+	Members   []gossipMember
+
+	Origin string
+	Seq    uint64
+	Key    string
+	Item   interface{}
+	TTL    time.Duration
+}
+
+type gossipMember struct {
+	ID        string
+	Addr      string
+	Heartbeat uint64
+}
+
+// New will create a Node listening on the configured address
+// and start gossiping with the seed nodes.
+func New(config *Config) (*Node, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if len(config.Secret) == 0 {
+		return nil, ErrNoSecret
+	}
+
+	n := &Node{
+		config:  *config,
+		members: make(map[string]*member),
+		dead:    make(map[string]*member),
+		seen:    make(map[string]time.Time),
+		done:    make(chan struct{}),
+		wg:      &sync.WaitGroup{},
+		mu:      &sync.Mutex{},
+	}
+
+	if n.config.GossipInterval <= 0 {
+		n.config.GossipInterval = defaultGossipInterval
+	}
+
+	if n.config.DeadAfter <= 0 {
+		n.config.DeadAfter = defaultDeadAfter
+	}
+
+	if n.config.Fanout <= 0 {
+		n.config.Fanout = defaultFanout
+	}
+
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	n.id = hex.EncodeToString(id)
+
+	addr, err := net.ResolveUDPAddr("udp", n.config.Bind)
+	if err != nil {
+		return nil, err
+	}
+
+	n.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheConfig := &cache.CacheConfig{}
+	if config.Cache != nil {
+		c := *config.Cache
+		cacheConfig = &c
+	}
+
+	onBatch := cacheConfig.OnExpiresBatch
+	cacheConfig.OnExpiresBatch = func(items []cache.Expired) {
+		for _, item := range items {
+			n.broadcast(&message{Kind: kindExpire, Key: item.Key})
+		}
+
+		if onBatch != nil {
+			onBatch(items)
+		}
+	}
+	n.cache = cache.NewCache(cacheConfig)
+
+	n.wg.Add(2)
+	go n.receive()
+	go n.gossiper()
+
+	return n, nil
+}
+
+// Addr will return the address the node is listening on
+func (n *Node) Addr() net.Addr {
+	return n.conn.LocalAddr()
+}
+
+// Cache will return the local cache. Changes made directly to it stay
+// local and are not replicated, so they must be made with the methods
+// of the Node to reach the other members. Only its expirations are
+// replicated.
+func (n *Node) Cache() *cache.Cache {
+	return n.cache
+}
+
+// Members will return the sorted addresses of the other live members
+func (n *Node) Members() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	addrs := make([]string, 0, len(n.members))
+	for _, m := range n.members {
+		addrs = append(addrs, m.addr.String())
+	}
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+// Add will add the item to the local cache and replicate it to
+// the other members, where it replaces any existing item.
+func (n *Node) Add(key string, item interface{}, expiresIn time.Duration) error {
+	err := n.cache.Add(key, item, expiresIn)
+	if err != nil {
+		return err
+	}
+
+	n.broadcast(&message{Kind: kindSet, Key: key, Item: item, TTL: expiresIn})
+	return nil
+}
+
+// Set will add or replace the item in the local cache and replicate it to the other members.
+func (n *Node) Set(key string, item interface{}, expiresIn time.Duration) error {
+	err := n.cache.Set(key, item, expiresIn)
+	if err != nil {
+		return err
+	}
+
+	n.broadcast(&message{Kind: kindSet, Key: key, Item: item, TTL: expiresIn})
+	return nil
+}
+
+// Update will replace the item of an existing key in the local cache, keeping its
+// expiration, and replicate it to the other members that hold the key.
+func (n *Node) Update(key string, item interface{}) error {
+	err := n.cache.Update(key, item)
+	if err != nil {
+		return err
+	}
+
+	n.broadcast(&message{Kind: kindUpdate, Key: key, Item: item})
+	return nil
+}
+
+// Delete will delete the key from the local cache and from the other members.
+func (n *Node) Delete(key string) error {
+	err := n.cache.Delete(key)
+	if err != nil && err != cache.ErrDNE {
+		return err
+	}
+
+	n.broadcast(&message{Kind: kindDelete, Key: key})
+	return err
+}
+
+// Get will get an item from the local cache
+func (n *Node) Get(key string) (interface{}, error) {
+	return n.cache.Get(key)
+}
+
+// Close will stop gossiping, close the connection and close the local cache
+func (n *Node) Close() error {
+	n.mu.Lock()
+	select {
+	case <-n.done:
+		n.mu.Unlock()
+		return ErrClosed
+	default:
+	}
+	close(n.done)
+	n.mu.Unlock()
+
+	err := n.conn.Close()
+	n.wg.Wait()
+	n.cache.Close()
+
+	return err
+}
+
+// broadcast will stamp the event with the node's id and send it to every live member
+func (n *Node) broadcast(msg *message) {
+	n.mu.Lock()
+	n.seq++
+	msg.Origin = n.id
+	msg.Seq = n.seq
+	addrs := n.addrs(len(n.members), "")
+	n.mu.Unlock()
+
+	n.send(msg, addrs)
+}
+
+// gossiper will send the member list to a few random members every
+// GossipInterval, and to the seeds until the node knows of a member.
+func (n *Node) gossiper() {
+	defer n.wg.Done()
+
+	ticker := time.NewTicker(n.config.GossipInterval)
+	defer ticker.Stop()
+
+	n.gossip()
+	for {
+		select {
+		case <-ticker.C:
+			n.gossip()
+		case <-n.done:
+			return
+		}
+	}
+}
+
+func (n *Node) gossip() {
+	now := time.Now()
+
+	n.mu.Lock()
+	n.heartbeat++
+	for id, m := range n.members {
+		if now.Sub(m.updated) > n.config.DeadAfter {
+			m.updated = now
+			n.dead[id] = m
+			delete(n.members, id)
+		}
+	}
+
+	for id, m := range n.dead {
+		if now.Sub(m.updated) > seenFor {
+			delete(n.dead, id)
+		}
+	}
+
+	for id, seen := range n.seen {
+		if now.Sub(seen) > seenFor {
+			delete(n.seen, id)
+		}
+	}
+
+	msg := &message{
+		Kind:      kindGossip,
+		Heartbeat: n.heartbeat,
+		Members:   make([]gossipMember, 0, len(n.members)),
+	}
+	for id, m := range n.members {
+		msg.Members = append(msg.Members, gossipMember{
+			ID:        id,
+			Addr:      m.addr.String(),
+			Heartbeat: m.heartbeat,
+		})
+	}
+
+	addrs := n.addrs(n.config.Fanout, "")
+	n.mu.Unlock()
+
+	if len(addrs) == 0 {
+		for _, seed := range n.config.Seeds {
+			addr, err := net.ResolveUDPAddr("udp", seed)
+			if err == nil {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	n.send(msg, addrs)
+}
+
+// addrs will return the addresses of up to count random
+// members, excluding the member with the given id.
+// The node lock must be held.
+func (n *Node) addrs(count int, exclude string) []*net.UDPAddr {
+	addrs := make([]*net.UDPAddr, 0, len(n.members))
+	for id, m := range n.members {
+		if id != exclude {
+			addrs = append(addrs, m.addr)
+		}
+	}
+
+	mrand.Shuffle(len(addrs), func(i, j int) {
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	})
+
+	if len(addrs) > count {
+		addrs = addrs[:count]
+	}
+
+	return addrs
+}
+
+func (n *Node) send(msg *message, addrs []*net.UDPAddr) {
+	if len(addrs) == 0 {
+		return
+	}
+
+	msg.From = n.id
+
+	var buf bytes.Buffer
+	buf.Write(make([]byte, sha256.Size))
+	err := gob.NewEncoder(&buf).Encode(msg)
+	if err != nil || buf.Len() > maxDatagram {
+		return
+	}
+	n.sign(buf.Bytes())
+
+	for _, addr := range addrs {
+		n.conn.WriteToUDP(buf.Bytes(), addr)
+	}
+}
+
+// receive will read datagrams until the connection is closed
+func (n *Node) receive() {
+	defer n.wg.Done()
+
+	buf := make([]byte, maxDatagram)
+	for {
+		size, addr, err := n.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-n.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		if !n.verify(buf[:size]) {
+			continue
+		}
+
+		var msg message
+		err = gob.NewDecoder(bytes.NewReader(buf[sha256.Size:size])).Decode(&msg)
+		if err != nil || msg.From == n.id {
+			continue
+		}
+
+		n.handle(&msg, addr)
+	}
+}
+
+func (n *Node) handle(msg *message, addr *net.UDPAddr) {
+	now := time.Now()
+
+	n.mu.Lock()
+	n.heard(msg.From, addr, msg.Heartbeat, now)
+
+	if msg.Kind == kindGossip {
+		for _, gm := range msg.Members {
+			if gm.ID == n.id {
+				continue
+			}
+
+			addr, err := net.ResolveUDPAddr("udp", gm.Addr)
+			if err == nil {
+				n.heard(gm.ID, addr, gm.Heartbeat, now)
+			}
+		}
+		n.mu.Unlock()
+		return
+	}
+
+	id := fmt.Sprintf("%s/%d", msg.Origin, msg.Seq)
+	if _, ok := n.seen[id]; ok || msg.Origin == n.id {
+		n.mu.Unlock()
+		return
+	}
+	n.seen[id] = now
+	relay := n.addrs(n.config.Fanout, msg.From)
+	n.mu.Unlock()
+
+	switch msg.Kind {
+	case kindSet:
+		n.cache.Set(msg.Key, msg.Item, msg.TTL)
+	case kindUpdate:
+		n.cache.Update(msg.Key, msg.Item)
+	case kindDelete, kindExpire:
+		n.cache.Delete(msg.Key)
+	}
+
+	n.send(msg, relay)
+}
+
+// sign will write the HMAC of the payload that follows it
+// into the head of the datagram
+func (n *Node) sign(datagram []byte) {
+	mac := hmac.New(sha256.New, n.config.Secret)
+	mac.Write(datagram[sha256.Size:])
+	mac.Sum(datagram[:0])
+}
+
+// verify will report whether the datagram is signed with the Secret
+func (n *Node) verify(datagram []byte) bool {
+	if len(datagram) < sha256.Size {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, n.config.Secret)
+	mac.Write(datagram[sha256.Size:])
+	return hmac.Equal(mac.Sum(nil), datagram[:sha256.Size])
+}
+
+// heard will record the heartbeat of a member, adding it if it is new.
+// A member's entry is only refreshed when its heartbeat advances,
+// so that members that have stopped are eventually removed.
+// The node lock must be held.
+func (n *Node) heard(id string, addr *net.UDPAddr, heartbeat uint64, now time.Time) {
+	m, ok := n.members[id]
+	if !ok {
+		if dead, ok := n.dead[id]; ok {
+			if heartbeat <= dead.heartbeat {
+				return
+			}
+			delete(n.dead, id)
+		}
+
+		n.members[id] = &member{addr: addr, heartbeat: heartbeat, updated: now}
+		return
+	}
+
+	if heartbeat > m.heartbeat {
+		m.heartbeat = heartbeat
+		m.updated = now
+	}
+}
@@ -0,0 +1,198 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var testSecret = []byte("secret")
+
+func newTestNode(t *testing.T, seeds ...string) *Node {
+	return newTestSecretNode(t, testSecret, seeds...)
+}
+
+func newTestSecretNode(t *testing.T, secret []byte, seeds ...string) *Node {
+	n, err := New(&Config{
+		Bind:           "127.0.0.1:0",
+		Secret:         secret,
+		Seeds:          seeds,
+		Cache:          &cache.CacheConfig{CleanDuration: 10 * time.Millisecond},
+		GossipInterval: 10 * time.Millisecond,
+		DeadAfter:      200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New error: %+v", err)
+	}
+
+	return n
+}
+
+// eventually will poll fn until it returns true or the deadline passes
+func eventually(fn func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return false
+}
+
+func newTestCluster(t *testing.T, size int) []*Node {
+	nodes := []*Node{newTestNode(t)}
+	for i := 1; i < size; i++ {
+		nodes = append(nodes, newTestNode(t, nodes[0].Addr().String()))
+	}
+
+	ok := eventually(func() bool {
+		for _, n := range nodes {
+			if len(n.Members()) != size-1 {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		t.Fatalf("expected the members to discover each other")
+	}
+
+	return nodes
+}
+
+func TestClusterReplication(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	for _, n := range nodes {
+		defer n.Close()
+	}
+
+	err := nodes[1].Set("key", "value", time.Minute)
+	if err != nil {
+		t.Errorf("Set error: %+v", err)
+	}
+
+	ok := eventually(func() bool {
+		for _, n := range nodes {
+			item, err := n.Get("key")
+			if err != nil || item != "value" {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		t.Errorf("expected the item to be replicated to every node")
+	}
+
+	err = nodes[0].Update("key", "updated")
+	if err != nil {
+		t.Errorf("Update error: %+v", err)
+	}
+
+	ok = eventually(func() bool {
+		for _, n := range nodes {
+			item, err := n.Get("key")
+			if err != nil || item != "updated" {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		t.Errorf("expected the update to be replicated to every node")
+	}
+
+	err = nodes[2].Delete("key")
+	if err != nil {
+		t.Errorf("Delete error: %+v", err)
+	}
+
+	ok = eventually(func() bool {
+		for _, n := range nodes {
+			if _, err := n.Get("key"); err != cache.ErrDNE {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		t.Errorf("expected the delete to be replicated to every node")
+	}
+}
+
+func TestClusterExpire(t *testing.T) {
+	nodes := newTestCluster(t, 2)
+	for _, n := range nodes {
+		defer n.Close()
+	}
+
+	err := nodes[1].Cache().Set("key", "value", time.Minute)
+	if err != nil {
+		t.Errorf("Set error: %+v", err)
+	}
+
+	err = nodes[0].Cache().Set("key", "value", 20*time.Millisecond)
+	if err != nil {
+		t.Errorf("Set error: %+v", err)
+	}
+
+	ok := eventually(func() bool {
+		_, err := nodes[1].Get("key")
+		return err == cache.ErrDNE
+	})
+	if !ok {
+		t.Errorf("expected the expiration to be replicated")
+	}
+}
+
+func TestClusterDeadMember(t *testing.T) {
+	nodes := newTestCluster(t, 3)
+	defer nodes[0].Close()
+	defer nodes[1].Close()
+
+	nodes[2].Close()
+
+	ok := eventually(func() bool {
+		return len(nodes[0].Members()) == 1 && len(nodes[1].Members()) == 1
+	})
+	if !ok {
+		t.Errorf("expected the closed member to be removed, got %v", nodes[0].Members())
+	}
+
+	err := nodes[2].Close()
+	if err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %+v", err)
+	}
+}
+
+func TestClusterSecret(t *testing.T) {
+	if _, err := New(&Config{Bind: "127.0.0.1:0"}); err != ErrNoSecret {
+		t.Errorf("expected ErrNoSecret, got %+v", err)
+	}
+
+	nodes := newTestCluster(t, 2)
+	for _, n := range nodes {
+		defer n.Close()
+	}
+
+	other := newTestSecretNode(t, []byte("other"), nodes[0].Addr().String())
+	defer other.Close()
+
+	other.Set("key", "value", time.Minute)
+	time.Sleep(100 * time.Millisecond)
+
+	if members := nodes[0].Members(); len(members) != 1 {
+		t.Errorf("expected the node with another secret to be ignored, got %v", members)
+	}
+
+	if members := other.Members(); len(members) != 0 {
+		t.Errorf("expected the members to ignore the node, got %v", members)
+	}
+
+	if _, err := nodes[0].Get("key"); err != cache.ErrDNE {
+		t.Errorf("expected the change of the node to be dropped, got %+v", err)
+	}
+}
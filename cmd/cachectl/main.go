@@ -0,0 +1,160 @@
+// Command cachectl inspects the files written by Cache.Save.
+//
+//	cachectl list FILE            list the keys with their ttls and sizes
+//	cachectl convert -to FORMAT IN OUT
+//	                              convert a snapshot between gob and json
+//	cachectl diff OLD NEW         list the keys added, removed and changed
+//
+// Snapshots in either format are accepted as input. Items are decoded
+// into interface values, so a gob snapshot of items of types other than
+// the builtin types can only be read by a build of cachectl that
+// registers those types with gob.Register. Items converted to json and
+// back are decoded as json values, so numbers become float64 and
+// structs become maps.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"text/tabwriter"
+	"time"
+)
+
+// now is the time ttls are measured from
+var now = time.Now
+
+var errUsage = errors.New("usage: cachectl list FILE | convert -to gob|json IN OUT | diff OLD NEW")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cachectl:", err)
+		if err == errUsage {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "list":
+		return list(args[1:], w)
+	case "convert":
+		return convert(args[1:])
+	case "diff":
+		return diff(args[1:], w)
+	}
+
+	return errUsage
+}
+
+func list(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "write the snapshot as json instead of a table")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	s, _, err := readSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return s.write(w, "json")
+	}
+
+	at := now()
+	held := s.buckets()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tBUCKET\tTTL\tSIZE")
+	for _, e := range s.Entries {
+		fmt.Fprintf(tw, "%q\t%s\t%s\t%d\n", e.Key, held[e.Key], ttl(e, at), size(e.Item))
+	}
+	fmt.Fprintf(tw, "\n%d entries, %d buckets\n", len(s.Entries), len(s.Buckets))
+
+	return tw.Flush()
+}
+
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	to := fs.String("to", "json", "format to convert to, gob or json")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 {
+		return errUsage
+	}
+
+	if *to != "gob" && *to != "json" {
+		return fmt.Errorf("unknown format %q", *to)
+	}
+
+	s, _, err := readSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	err = s.write(f, *to)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func diff(args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	old, _, err := readSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	cur, _, err := readSnapshot(args[1])
+	if err != nil {
+		return err
+	}
+
+	before := make(map[string]entry, len(old.Entries))
+	for _, e := range old.Entries {
+		before[e.Key] = e
+	}
+
+	after := make(map[string]bool, len(cur.Entries))
+	for _, e := range cur.Entries {
+		after[e.Key] = true
+
+		o, ok := before[e.Key]
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "+ %q\n", e.Key)
+		case !o.ExpiresAt.Equal(e.ExpiresAt):
+			fmt.Fprintf(w, "~ %q expires %s, was %s\n", e.Key, e.ExpiresAt.Format(time.RFC3339), o.ExpiresAt.Format(time.RFC3339))
+		case !reflect.DeepEqual(o.Item, e.Item) || !reflect.DeepEqual(o.Meta, e.Meta):
+			fmt.Fprintf(w, "~ %q\n", e.Key)
+		}
+	}
+
+	for _, e := range old.Entries {
+		if !after[e.Key] {
+			fmt.Fprintf(w, "- %q\n", e.Key)
+		}
+	}
+
+	return nil
+}
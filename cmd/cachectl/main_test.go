@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+func save(t *testing.T, filename string, clock cache.Clock, fill func(c *cache.Cache)) {
+	c := cache.NewCache(&cache.CacheConfig{Clock: clock, DisableCleaner: true})
+	defer c.Close()
+
+	fill(c)
+	if err := c.Save(filename); err != nil {
+		t.Fatalf("Save error: %+v", err)
+	}
+}
+
+// fields will collapse the padding of a table
+func fields(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func TestCachectl(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.gob")
+	newFile := filepath.Join(dir, "new.gob")
+
+	clock := cache.NewManualClock(start)
+	save(t, oldFile, clock, func(c *cache.Cache) {
+		c.Add("a", "apple", time.Minute)
+		c.Add("b", 42, cache.NoExpiration)
+		c.Add("c", "cherry", cache.NoExpiration)
+	})
+	save(t, newFile, clock, func(c *cache.Cache) {
+		c.Add("a", "apple", time.Hour)
+		c.Add("b", 43, cache.NoExpiration)
+		c.Bucket("fruit").Add("d", "date", cache.NoExpiration)
+	})
+
+	var out bytes.Buffer
+	if err := run([]string{"list", oldFile}, &out); err != nil {
+		t.Fatalf("list error: %+v", err)
+	}
+	for _, want := range []string{`"a" 1m0s 5`, `"b" never 2`, "3 entries, 0 buckets"} {
+		if !strings.Contains(fields(out.String()), want) {
+			t.Errorf("expected the listing to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	run([]string{"list", newFile}, &out)
+	if !strings.Contains(fields(out.String()), `"fruit:d" fruit never 4`) {
+		t.Errorf("expected the bucket of the item to be listed, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"diff", oldFile, newFile}, &out); err != nil {
+		t.Fatalf("diff error: %+v", err)
+	}
+	for _, want := range []string{`~ "a" expires 2020-01-01T01:00:00Z`, `~ "b"`, `+ "fruit:d"`, `- "c"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the diff to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	jsonFile := filepath.Join(dir, "old.json")
+	gobFile := filepath.Join(dir, "old-again.gob")
+	if err := run([]string{"convert", "-to", "json", oldFile, jsonFile}, &out); err != nil {
+		t.Fatalf("convert error: %+v", err)
+	}
+	if err := run([]string{"convert", "-to", "gob", jsonFile, gobFile}, &out); err != nil {
+		t.Fatalf("convert error: %+v", err)
+	}
+
+	c := cache.NewCache(&cache.CacheConfig{Clock: clock})
+	defer c.Close()
+	if err := c.Load(gobFile); err != nil {
+		t.Fatalf("expected the converted snapshot to load, got %+v", err)
+	}
+	if item, err := c.Get("c"); err != nil || item != "cherry" {
+		t.Errorf("expected the item to survive the conversion, got %v %+v", item, err)
+	}
+	if item, _ := c.Get("b"); item != float64(42) {
+		t.Errorf("expected numbers to be decoded from json as float64, got %T", item)
+	}
+
+	if err := run([]string{"bogus"}, &out); err != errUsage {
+		t.Errorf("expected errUsage, got %+v", err)
+	}
+}
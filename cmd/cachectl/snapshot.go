@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/JKhawaja/cache/memcache"
+)
+
+func init() {
+	// items of snapshots converted from json
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	// items set with flags through the memcached protocol of cached
+	gob.Register(&memcache.Item{})
+}
+
+// neverExpires is the expiration of items saved without a ttl
+var neverExpires = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// snapshot mirrors the gob encoding of a cache written by Save.
+// Gob matches fields by name, so the names must not change.
+type snapshot struct {
+	Entries []entry  `json:"entries"`
+	Buckets []bucket `json:"buckets"`
+}
+
+type entry struct {
+	Key       string            `json:"key"`
+	Item      interface{}       `json:"item"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+type bucket struct {
+	Name string   `json:"name"`
+	Keys []string `json:"keys"`
+}
+
+// the envelope Save wraps the gob encoding in, see envelope.go in the cache package
+const (
+	envelopeMagic   = "CACHESNP"
+	envelopeVersion = 1
+	envelopeHeader  = len(envelopeMagic) + 2 + 4 + 8 + 4
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// readSnapshot will read a snapshot in either format, telling json
+// from gob by the opening brace of a json object. Gob snapshots are
+// read with or without the envelope written by Save.
+func readSnapshot(filename string) (*snapshot, string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var s snapshot
+	if bytes.HasPrefix(data, []byte("{")) {
+		err = json.Unmarshal(data, &s)
+		if err != nil {
+			return nil, "", err
+		}
+		s.sort()
+		return &s, "json", nil
+	}
+
+	if bytes.HasPrefix(data, []byte("CACHEGCM")) {
+		return nil, "", errors.New("snapshot is encrypted")
+	}
+
+	count := -1
+	if bytes.HasPrefix(data, []byte(envelopeMagic)) {
+		data, count, err = openEnvelope(data)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if count >= 0 && count != len(s.Entries) {
+		return nil, "", fmt.Errorf("snapshot holds %d entries, its envelope records %d", len(s.Entries), count)
+	}
+	s.sort()
+
+	return &s, "gob", nil
+}
+
+func openEnvelope(data []byte) ([]byte, int, error) {
+	n := len(envelopeMagic)
+	if len(data) < envelopeHeader {
+		return nil, 0, errors.New("snapshot is truncated")
+	}
+
+	if v := binary.BigEndian.Uint16(data[n:]); v > envelopeVersion {
+		return nil, 0, fmt.Errorf("snapshot format version %d is newer than %d", v, envelopeVersion)
+	}
+
+	payload := data[envelopeHeader:]
+	if binary.BigEndian.Uint64(data[n+6:]) != uint64(len(payload)) {
+		return nil, 0, errors.New("snapshot is truncated")
+	}
+
+	sum := crc32.Update(crc32.Checksum(data[:envelopeHeader-4], castagnoli), castagnoli, payload)
+	if sum != binary.BigEndian.Uint32(data[envelopeHeader-4:]) {
+		return nil, 0, errors.New("snapshot checksum does not match")
+	}
+
+	return payload, int(binary.BigEndian.Uint32(data[n+2:])), nil
+}
+
+func (s *snapshot) sort() {
+	sort.Slice(s.Entries, func(i, j int) bool {
+		return s.Entries[i].Key < s.Entries[j].Key
+	})
+}
+
+// write will encode the snapshot in the format, wrapping gob in the
+// envelope written by Save
+func (s *snapshot) write(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(s)
+	if err != nil {
+		return err
+	}
+	payload := buf.Bytes()
+
+	header := make([]byte, envelopeHeader)
+	n := copy(header, envelopeMagic)
+	binary.BigEndian.PutUint16(header[n:], envelopeVersion)
+	binary.BigEndian.PutUint32(header[n+2:], uint32(len(s.Entries)))
+	binary.BigEndian.PutUint64(header[n+6:], uint64(len(payload)))
+	sum := crc32.Update(crc32.Checksum(header[:envelopeHeader-4], castagnoli), castagnoli, payload)
+	binary.BigEndian.PutUint32(header[envelopeHeader-4:], sum)
+
+	_, err = w.Write(append(header, payload...))
+	return err
+}
+
+// buckets will return the bucket holding each key
+func (s *snapshot) buckets() map[string]string {
+	held := make(map[string]string)
+	for _, b := range s.Buckets {
+		for _, k := range b.Keys {
+			held[k] = b.Name
+		}
+	}
+
+	return held
+}
+
+// size will return the size of the item as measured by the cache's default
+// Sizer for strings and byte slices, and by its json encoding otherwise
+func size(item interface{}) int {
+	switch v := item.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return 0
+	}
+
+	return len(data)
+}
+
+// ttl will return the time left until the entry expires, "never" or "expired"
+func ttl(e entry, now time.Time) string {
+	switch {
+	case e.ExpiresAt.Equal(neverExpires):
+		return "never"
+	case now.After(e.ExpiresAt):
+		return "expired"
+	default:
+		return e.ExpiresAt.Sub(now).Round(time.Second).String()
+	}
+}
@@ -0,0 +1,14 @@
+module github.com/JKhawaja/cache/cmd/cached
+
+go 1.15
+
+require (
+	github.com/JKhawaja/cache v0.0.0-00010101000000-000000000000
+	github.com/JKhawaja/cache/proto v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.40.0
+)
+
+replace (
+	github.com/JKhawaja/cache => ../../
+	github.com/JKhawaja/cache/proto => ../../proto
+)
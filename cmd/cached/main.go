@@ -0,0 +1,258 @@
+// Command cached runs a cache as a small standalone service, serving it
+// over HTTP (see package httpapi), the Redis protocol (package resp), the
+// memcached text protocol (package memcache) and gRPC (package grpccache
+// of the proto module). It is a module of its own, so that the gRPC
+// dependencies stay out of the cache module.
+//
+//	cached -http :8080 -resp :6379 -grpc :9090 -snapshot /var/lib/cached/cache.gob
+//
+// With -snapshot the cache is loaded from the file on start, saved to it
+// every -save-interval and saved again on SIGINT or SIGTERM, after which
+// the servers are stopped and the cache is shut down within -grace.
+// Items stored through the servers are byte slices, or memcache.Item
+// values for memcached items set with flags, which package memcache
+// registers with gob so that they are saved too.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/httpapi"
+	"github.com/JKhawaja/cache/memcache"
+	"github.com/JKhawaja/cache/proto/cachev1"
+	"github.com/JKhawaja/cache/proto/grpccache"
+	"github.com/JKhawaja/cache/resp"
+	"google.golang.org/grpc"
+)
+
+// config holds the flags of the server
+type config struct {
+	httpAddr     string
+	respAddr     string
+	memcacheAddr string
+	grpcAddr     string
+	snapshot     string
+	saveInterval time.Duration
+	maxMemory    int64
+	maxEntries   int
+	clean        time.Duration
+	defaultTTL   time.Duration
+	grace        time.Duration
+}
+
+func main() {
+	cfg, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("cached: %s, shutting down", sig)
+		cancel()
+	}()
+
+	err = serve(ctx, cfg, func(name string, addr net.Addr) {
+		log.Printf("cached: serving %s on %s", name, addr)
+	})
+	if err != nil {
+		log.Fatalf("cached: %+v", err)
+	}
+}
+
+func parseFlags(args []string, output io.Writer) (*config, error) {
+	cfg := &config{}
+	var maxMemory string
+
+	fs := flag.NewFlagSet("cached", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cfg.httpAddr, "http", ":8080", "address the HTTP API is served on, \"\" disables it")
+	fs.StringVar(&cfg.respAddr, "resp", "", "address the Redis protocol is served on, \"\" disables it")
+	fs.StringVar(&cfg.memcacheAddr, "memcache", "", "address the memcached protocol is served on, \"\" disables it")
+	fs.StringVar(&cfg.grpcAddr, "grpc", "", "address the gRPC service is served on, \"\" disables it")
+	fs.StringVar(&cfg.snapshot, "snapshot", "", "file the cache is loaded from on start and saved to on shutdown, \"\" disables persistence")
+	fs.DurationVar(&cfg.saveInterval, "save-interval", 0, "interval at which the cache is saved to the snapshot, 0 only saves it on shutdown")
+	fs.StringVar(&maxMemory, "max-memory", "", "size of the items beyond which items are evicted, e.g. 512MB, \"\" is a quarter of the memory limit")
+	fs.IntVar(&cfg.maxEntries, "max-entries", 0, "items beyond which items are evicted, 0 is unbounded")
+	fs.DurationVar(&cfg.clean, "clean-interval", 10*time.Second, "interval at which expired items are removed")
+	fs.DurationVar(&cfg.defaultTTL, "default-ttl", 0, "expiration of items stored over HTTP without a ttl, 0 never expires them")
+	fs.DurationVar(&cfg.grace, "grace", 10*time.Second, "time allowed for saving and closing the cache on shutdown")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxMemory != "" {
+		cfg.maxMemory, err = parseBytes(maxMemory)
+		if err != nil {
+			fmt.Fprintf(output, "invalid value %q for flag -max-memory: %+v\n", maxMemory, err)
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// memoryFraction of the container memory limit the items
+// are held within when -max-memory is not given
+const memoryFraction = 0.25
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"B", 1},
+}
+
+// parseBytes will parse a size such as 1024, 64KB, 512MB or 2GB
+func parseBytes(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	size := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			size = u.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	} else if n <= 0 {
+		return 0, errors.New("size must be positive")
+	}
+
+	return n * size, nil
+}
+
+// serve will run the cache and its servers until ctx is done, calling
+// listening with the address of each server once it accepts connections
+func serve(ctx context.Context, cfg *config, listening func(name string, addr net.Addr)) error {
+	cc := &cache.CacheConfig{
+		CleanDuration:  cfg.clean,
+		MaxBytes:       cfg.maxMemory,
+		MemoryFraction: memoryFraction,
+		MaxEntries:     cfg.maxEntries,
+		SaveOnShutdown: cfg.snapshot,
+	}
+
+	// the changes made over any protocol are streamed to gRPC watchers
+	var watcher *grpccache.Watcher
+	if cfg.grpcAddr != "" {
+		watcher = grpccache.NewWatcher()
+		cc.Invalidator = watcher
+		cc.OnExpire = watcher.Expired
+	}
+	c := cache.NewCache(cc)
+
+	if cfg.snapshot != "" {
+		err := c.Load(cfg.snapshot)
+		if err != nil && !os.IsNotExist(err) {
+			c.Close()
+			return err
+		}
+	}
+
+	var closers []func() error
+	errs := make(chan error, 4)
+	start := func(name, addr string, serve func(l net.Listener) error, close func() error) error {
+		if addr == "" {
+			return nil
+		}
+
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		closers = append(closers, close)
+		listening(name, l.Addr())
+
+		go func() {
+			errs <- fmt.Errorf("%s: %w", name, serve(l))
+		}()
+		return nil
+	}
+
+	hs := &http.Server{Handler: httpapi.NewHandler(c, &httpapi.Config{
+		SavePath:   cfg.snapshot,
+		DefaultTTL: cfg.defaultTTL,
+	})}
+	rs := resp.NewServer(c)
+	ms := memcache.NewServer(c)
+	gs := grpc.NewServer()
+	cachev1.RegisterCacheServer(gs, grpccache.NewServer(c, &grpccache.Config{Watcher: watcher}))
+
+	err := start("http", cfg.httpAddr, hs.Serve, hs.Close)
+	if err == nil {
+		err = start("resp", cfg.respAddr, rs.Serve, rs.Close)
+	}
+	if err == nil {
+		err = start("memcache", cfg.memcacheAddr, ms.Serve, ms.Close)
+	}
+	if err == nil {
+		err = start("grpc", cfg.grpcAddr, gs.Serve, func() error {
+			gs.Stop()
+			return nil
+		})
+	}
+
+	if err == nil && len(closers) == 0 {
+		err = errors.New("no address to serve on")
+	}
+
+	var saves <-chan time.Time
+	if err == nil && cfg.snapshot != "" && cfg.saveInterval > 0 {
+		ticker := time.NewTicker(cfg.saveInterval)
+		defer ticker.Stop()
+		saves = ticker.C
+	}
+
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case err = <-errs:
+		case <-saves:
+			if err := c.Save(cfg.snapshot); err != nil {
+				log.Printf("cached: saving the snapshot: %+v", err)
+			}
+		}
+	}
+
+	for _, close := range closers {
+		close()
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), cfg.grace)
+	defer cancel()
+
+	if serr := c.Shutdown(shutdown); serr != nil {
+		return serr
+	} else if err == ctx.Err() {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache/proto/grpccache"
+	"google.golang.org/grpc"
+)
+
+func TestParseFlags(t *testing.T) {
+	cfg, err := parseFlags([]string{"-max-memory", "64MB", "-max-entries", "10", "-clean-interval", "1m"}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("parseFlags error: %+v", err)
+	}
+
+	if cfg.maxMemory != 64<<20 || cfg.maxEntries != 10 || cfg.clean != time.Minute || cfg.httpAddr != ":8080" {
+		t.Errorf("expected the flags to be parsed, got %+v", cfg)
+	}
+
+	for _, s := range []string{"12", "12B", "3kb", "1 GB"} {
+		if _, err := parseBytes(s); err != nil {
+			t.Errorf("expected %q to parse, got %+v", s, err)
+		}
+	}
+
+	if _, err := parseFlags([]string{"-max-memory", "lots"}, ioutil.Discard); err == nil {
+		t.Errorf("expected an invalid size to be rejected")
+	}
+}
+
+// start will serve the config until the returned function is called
+func start(t *testing.T, cfg *config) (map[string]net.Addr, func() error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addrs := make(map[string]net.Addr)
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+
+	servers := 0
+	for _, addr := range []string{cfg.httpAddr, cfg.respAddr, cfg.memcacheAddr, cfg.grpcAddr} {
+		if addr != "" {
+			servers++
+		}
+	}
+
+	go func() {
+		done <- serve(ctx, cfg, func(name string, addr net.Addr) {
+			addrs[name] = addr
+			if len(addrs) == servers {
+				close(ready)
+			}
+		})
+	}()
+
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatalf("serve error: %+v", err)
+	}
+
+	return addrs, func() error {
+		cancel()
+		return <-done
+	}
+}
+
+func TestServe(t *testing.T) {
+	cfg := &config{
+		httpAddr: "127.0.0.1:0",
+		respAddr: "127.0.0.1:0",
+		snapshot: filepath.Join(t.TempDir(), "cache.gob"),
+		clean:    time.Minute,
+		grace:    time.Second,
+	}
+
+	addrs, stop := start(t, cfg)
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s/keys/a", addrs["http"]), strings.NewReader("apple"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT error: %+v", err)
+	}
+	res.Body.Close()
+
+	conn, err := net.Dial("tcp", addrs["resp"].String())
+	if err != nil {
+		t.Fatalf("Dial error: %+v", err)
+	}
+	fmt.Fprint(conn, "*2\r\n$3\r\nGET\r\n$1\r\na\r\n")
+	r := bufio.NewReader(conn)
+	r.ReadString('\n')
+	line, _ := r.ReadString('\n')
+	conn.Close()
+	if line != "apple\r\n" {
+		t.Errorf("expected the item stored over HTTP to be served over RESP, got %q", line)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("expected a clean shutdown, got %+v", err)
+	}
+
+	// the snapshot saved on shutdown is loaded on start
+	addrs, stop = start(t, cfg)
+	defer stop()
+
+	res, err = http.Get(fmt.Sprintf("http://%s/keys/a", addrs["http"]))
+	if err != nil {
+		t.Fatalf("GET error: %+v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "apple" {
+		t.Errorf("expected the item to be restored from the snapshot, got %q", body)
+	}
+}
+
+func TestServeGRPC(t *testing.T) {
+	cfg := &config{
+		httpAddr: "127.0.0.1:0",
+		grpcAddr: "127.0.0.1:0",
+		clean:    time.Minute,
+		grace:    time.Second,
+	}
+
+	addrs, stop := start(t, cfg)
+	defer stop()
+
+	conn, err := grpc.Dial(addrs["grpc"].String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("Dial error: %+v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan grpccache.Event, 10)
+	client := grpccache.NewClient(conn)
+	go client.Watch(ctx, "", func(e grpccache.Event) {
+		events <- e
+	})
+
+	// the item stored over HTTP is served and streamed over gRPC
+	var event grpccache.Event
+	for event.Key != "a" {
+		req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s/keys/a", addrs["http"]), strings.NewReader("apple"))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT error: %+v", err)
+		}
+		res.Body.Close()
+
+		select {
+		case event = <-events:
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("the change was not streamed over gRPC")
+		}
+	}
+
+	if event.Kind != grpccache.EventSet || string(event.Value) != "apple" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	value, _, err := client.Get(ctx, "a")
+	if err != nil || string(value) != "apple" {
+		t.Errorf("expected the item stored over HTTP to be served over gRPC, got %q: %+v", value, err)
+	}
+}
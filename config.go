@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var defaultConfigInterval = 30 * time.Second
+
+// ConfigCache serves a configuration map that is reloaded as a whole on
+// an interval. Reads are lock-free and always see a complete snapshot,
+// never a mix of an old and a new configuration.
+type ConfigCache struct {
+	snapshot atomic.Value // map[string]interface{}
+	config   *ConfigCacheConfig
+	reloadMu *sync.Mutex
+	stop     chan struct{}
+	once     *sync.Once
+}
+
+// ConfigCacheConfig is used to configure a config cache
+type ConfigCacheConfig struct {
+	Loader   ConfigLoader  // loads the entire configuration, nil serves an empty configuration
+	Interval time.Duration // time between reloads, defaults to 30 seconds
+	OnChange OnConfigChange
+	OnError  func(err error) // called when a background reload fails, the current snapshot is kept
+}
+
+// ConfigLoader is a function that will load an entire configuration
+type ConfigLoader func() (map[string]interface{}, error)
+
+// OnConfigChange is a function that will act on the changes
+// between two consecutive configuration snapshots.
+type OnConfigChange func(changes []ConfigChange)
+
+// ConfigChangeKind describes how a configuration key changed
+type ConfigChangeKind int
+
+const (
+	// ConfigAdded is a key that was not in the previous snapshot
+	ConfigAdded ConfigChangeKind = iota
+	// ConfigUpdated is a key whose value changed
+	ConfigUpdated
+	// ConfigRemoved is a key that is not in the new snapshot
+	ConfigRemoved
+)
+
+// ConfigChange is a change to one key of the configuration
+type ConfigChange struct {
+	Key  string
+	Kind ConfigChangeKind
+	Old  interface{}
+	New  interface{}
+}
+
+// NewConfigCache will create and return a pointer to a new ConfigCache
+// object, loading the configuration once before returning. It will
+// return the loader's error if the first load fails.
+func NewConfigCache(config *ConfigCacheConfig) (*ConfigCache, error) {
+	if config == nil {
+		config = &ConfigCacheConfig{}
+	}
+
+	if config.Interval == 0 {
+		config.Interval = defaultConfigInterval
+	}
+
+	c := &ConfigCache{
+		config:   config,
+		reloadMu: &sync.Mutex{},
+		stop:     make(chan struct{}),
+		once:     &sync.Once{},
+	}
+	c.snapshot.Store(map[string]interface{}{})
+
+	err := c.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				if err := c.Reload(); err != nil && config.OnError != nil {
+					config.OnError(err)
+				}
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// Get will return the value of the key in the current configuration.
+// It will return an ErrDNE value if the key is not in the configuration.
+func (c *ConfigCache) Get(key string) (interface{}, error) {
+	item, ok := c.Snapshot()[key]
+	if !ok {
+		return nil, ErrDNE
+	}
+
+	return item, nil
+}
+
+// Snapshot will return the current configuration.
+// The returned map is shared and must not be modified.
+func (c *ConfigCache) Snapshot() map[string]interface{} {
+	return c.snapshot.Load().(map[string]interface{})
+}
+
+// Reload will load the configuration immediately and swap it in,
+// calling OnChange with any differences from the previous snapshot.
+// The current snapshot is kept if the loader returns an error.
+func (c *ConfigCache) Reload() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	if c.config.Loader == nil {
+		return nil
+	}
+
+	next, err := c.config.Loader()
+	if err != nil {
+		return err
+	}
+
+	if next == nil {
+		next = map[string]interface{}{}
+	}
+
+	prev := c.Snapshot()
+	c.snapshot.Store(next)
+
+	if c.config.OnChange != nil {
+		if changes := diffConfig(prev, next); len(changes) > 0 {
+			c.config.OnChange(changes)
+		}
+	}
+
+	return nil
+}
+
+// Stop will stop reloading the configuration. It is safe to call more than once.
+func (c *ConfigCache) Stop() {
+	c.once.Do(func() {
+		close(c.stop)
+	})
+}
+
+// diffConfig will return the changes between two snapshots, ordered by key
+func diffConfig(prev, next map[string]interface{}) []ConfigChange {
+	var changes []ConfigChange
+	for key, item := range next {
+		old, ok := prev[key]
+		if !ok {
+			changes = append(changes, ConfigChange{Key: key, Kind: ConfigAdded, New: item})
+		} else if !reflect.DeepEqual(old, item) {
+			changes = append(changes, ConfigChange{Key: key, Kind: ConfigUpdated, Old: old, New: item})
+		}
+	}
+
+	for key, old := range prev {
+		if _, ok := next[key]; !ok {
+			changes = append(changes, ConfigChange{Key: key, Kind: ConfigRemoved, Old: old})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
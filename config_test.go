@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConfigCache(t *testing.T) {
+	mu := &sync.Mutex{}
+	current := map[string]interface{}{
+		"timeout": 30,
+		"region":  "us-east",
+	}
+	var loadErr error
+
+	changed := make(chan []ConfigChange, 10)
+	errs := make(chan error, 10)
+	configs, err := NewConfigCache(&ConfigCacheConfig{
+		Loader: func() (map[string]interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return current, loadErr
+		},
+		Interval: 10 * time.Millisecond,
+		OnChange: func(changes []ConfigChange) {
+			changed <- changes
+		},
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating config cache: %+v", err)
+	}
+	defer configs.Stop()
+
+	initial := <-changed
+	if len(initial) != 2 || initial[0].Key != "region" || initial[0].Kind != ConfigAdded {
+		t.Errorf("unexpected initial changes: %+v", initial)
+	}
+
+	item, err := configs.Get("timeout")
+	if err != nil || item != 30 {
+		t.Errorf("unexpected config value %v: %+v", item, err)
+	}
+
+	mu.Lock()
+	current = map[string]interface{}{
+		"timeout": 60,
+		"debug":   true,
+	}
+	mu.Unlock()
+
+	changes := <-changed
+	expected := []ConfigChange{
+		{Key: "debug", Kind: ConfigAdded, New: true},
+		{Key: "region", Kind: ConfigRemoved, Old: "us-east"},
+		{Key: "timeout", Kind: ConfigUpdated, Old: 30, New: 60},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("change %d was %+v, expected %+v", i, changes[i], expected[i])
+		}
+	}
+
+	if _, err := configs.Get("region"); err != ErrDNE {
+		t.Errorf("removed key was still served: %+v", err)
+	}
+
+	mu.Lock()
+	loadErr = errors.New("unavailable")
+	mu.Unlock()
+
+	if err := <-errs; err != loadErr {
+		t.Errorf("unexpected reload error: %+v", err)
+	}
+
+	if item, err := configs.Get("timeout"); err != nil || item != 60 {
+		t.Errorf("snapshot was not kept after a failed reload: %v %+v", item, err)
+	}
+}
+
+func TestConfigCacheInitialLoadError(t *testing.T) {
+	loadErr := errors.New("unavailable")
+	_, err := NewConfigCache(&ConfigCacheConfig{
+		Loader: func() (map[string]interface{}, error) {
+			return nil, loadErr
+		},
+	})
+	if err != loadErr {
+		t.Errorf("expected the loader error, got: %+v", err)
+	}
+}
+
+func TestConfigCacheNilConfig(t *testing.T) {
+	configs, err := NewConfigCache(nil)
+	if err != nil {
+		t.Fatalf("NewConfigCache error: %+v", err)
+	}
+	defer configs.Stop()
+
+	if len(configs.Snapshot()) != 0 {
+		t.Errorf("expected an empty configuration, got %v", configs.Snapshot())
+	}
+
+	if _, err := configs.Get("timeout"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
@@ -0,0 +1,348 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ConfigError is returned by NewCacheWithOptions and CacheConfig.Validate
+// for a configuration the cache cannot work with
+type ConfigError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid cache config: %s %s", e.Field, e.Reason)
+}
+
+// Option configures a cache created with NewCacheWithOptions
+type Option func(config *CacheConfig) error
+
+// NewCacheWithOptions will create and return a pointer to a new Cache
+// configured by the options, or return a *ConfigError if the resulting
+// configuration is invalid.
+func NewCacheWithOptions(opts ...Option) (*Cache, error) {
+	config := *defaultConfig
+	for _, opt := range opts {
+		err := opt(&config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCache(&config), nil
+}
+
+// WithConfig will start from a copy of the configuration,
+// to which the options following it are applied
+func WithConfig(config CacheConfig) Option {
+	return func(c *CacheConfig) error {
+		*c = config
+		return nil
+	}
+}
+
+// WithCleanInterval will set the interval at which expired items are removed
+func WithCleanInterval(d time.Duration) Option {
+	return func(c *CacheConfig) error {
+		c.CleanDuration = d
+		return nil
+	}
+}
+
+// WithoutCleaner will stop the background cleaner, leaving
+// expired items to be removed by DeleteExpired
+func WithoutCleaner() Option {
+	return func(c *CacheConfig) error {
+		c.DisableCleaner = true
+		return nil
+	}
+}
+
+// WithDefaultTTL will expire the items added with DefaultExpiration after d
+func WithDefaultTTL(d time.Duration) Option {
+	return func(c *CacheConfig) error {
+		c.DefaultTTL = d
+		return nil
+	}
+}
+
+// WithRefresh will extend the expiration of items by d each time they are read
+func WithRefresh(d time.Duration) Option {
+	return func(c *CacheConfig) error {
+		c.Refresh = true
+		c.RefreshDuration = d
+		return nil
+	}
+}
+
+// WithClock will read the time from the clock, e.g. a ManualClock in tests
+func WithClock(clock Clock) Option {
+	return func(c *CacheConfig) error {
+		if clock == nil {
+			return &ConfigError{Field: "Clock", Reason: "is nil"}
+		}
+		c.Clock = clock
+		return nil
+	}
+}
+
+// WithHasher will hash keys with the hasher
+func WithHasher(hasher Hasher) Option {
+	return func(c *CacheConfig) error {
+		if hasher == nil {
+			return &ConfigError{Field: "Hasher", Reason: "is nil"}
+		}
+		c.Hasher = hasher
+		return nil
+	}
+}
+
+// WithMaxEntries will evict items beyond n keys
+func WithMaxEntries(n int) Option {
+	return func(c *CacheConfig) error {
+		c.MaxEntries = n
+		return nil
+	}
+}
+
+// WithMaxBytes will evict items beyond n bytes, 0 or a negative n is unbounded
+func WithMaxBytes(n int64) Option {
+	return func(c *CacheConfig) error {
+		c.MaxBytes = n
+		return nil
+	}
+}
+
+// WithHeapLimit will shed items while the Go heap is over limit bytes,
+// checking it every interval, or every second if interval is 0
+func WithHeapLimit(limit int64, interval time.Duration) Option {
+	return func(c *CacheConfig) error {
+		c.HeapLimit = limit
+		c.PressureInterval = interval
+		return nil
+	}
+}
+
+// WithEvictionPolicy will select the items evicted
+// when MaxEntries or MaxBytes is reached
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *CacheConfig) error {
+		c.EvictionPolicy = policy
+		return nil
+	}
+}
+
+// WithOnExpires will call fn with every expired item
+func WithOnExpires(fn OnExpires) Option {
+	return func(c *CacheConfig) error {
+		if fn == nil {
+			return &ConfigError{Field: "OnExpires", Reason: "is nil"}
+		}
+		c.OnExpires = fn
+		return nil
+	}
+}
+
+// WithOnExpire will call fn with every item whose ttl was reached
+func WithOnExpire(fn OnRemoval) Option {
+	return func(c *CacheConfig) error {
+		if fn == nil {
+			return &ConfigError{Field: "OnExpire", Reason: "is nil"}
+		}
+		c.OnExpire = fn
+		return nil
+	}
+}
+
+// WithOnEvict will call fn with every item evicted
+// for capacity or memory pressure, or removed by Flush
+func WithOnEvict(fn OnRemoval) Option {
+	return func(c *CacheConfig) error {
+		if fn == nil {
+			return &ConfigError{Field: "OnEvict", Reason: "is nil"}
+		}
+		c.OnEvict = fn
+		return nil
+	}
+}
+
+// WithOnError will call fn with the errors from background work
+func WithOnError(fn OnError) Option {
+	return func(c *CacheConfig) error {
+		if fn == nil {
+			return &ConfigError{Field: "OnError", Reason: "is nil"}
+		}
+		c.OnError = fn
+		return nil
+	}
+}
+
+// WithHotKeys will count the given fraction of hits to find the keys
+// read most often within the window for HotKeys
+func WithHotKeys(rate float64, window time.Duration) Option {
+	return func(c *CacheConfig) error {
+		c.HotKeySampleRate = rate
+		c.HotKeyWindow = window
+		return nil
+	}
+}
+
+// WithTrace will record the gets, adds and deletes of the cache to the recorder
+func WithTrace(r *TraceRecorder) Option {
+	return func(c *CacheConfig) error {
+		if r == nil {
+			return &ConfigError{Field: "Trace", Reason: "is nil"}
+		}
+		c.Trace = r
+		return nil
+	}
+}
+
+// WithTimerWheel will run the cleaner as a timer of the wheel, such
+// as the SharedTimerWheel, rather than a goroutine of the cache
+func WithTimerWheel(w *TimerWheel) Option {
+	return func(c *CacheConfig) error {
+		if w == nil {
+			return &ConfigError{Field: "TimerWheel", Reason: "is nil"}
+		}
+		c.TimerWheel = w
+		return nil
+	}
+}
+
+// WithStore will write changes through to the store,
+// or batch them asynchronously if writeBack is set
+func WithStore(store Store, writeBack bool) Option {
+	return func(c *CacheConfig) error {
+		if store == nil {
+			return &ConfigError{Field: "Store", Reason: "is nil"}
+		}
+		c.Store = store
+		c.WriteBack = writeBack
+		return nil
+	}
+}
+
+// WithEncryptionKey will encrypt the files written by Save with
+// the AES key of 16, 24 or 32 bytes, and decrypt them in Load
+func WithEncryptionKey(key []byte) Option {
+	return WithKeyring(&Keyring{Keys: map[uint32][]byte{0: key}})
+}
+
+// WithKeyring will encrypt the files written by Save with the current
+// key of the keyring, and decrypt them in Load with any of its keys
+func WithKeyring(ring *Keyring) Option {
+	return func(c *CacheConfig) error {
+		if ring == nil {
+			return &ConfigError{Field: "Keyring", Reason: "is nil"}
+		}
+		c.Keyring = ring
+		return nil
+	}
+}
+
+// Validate will return a *ConfigError for the first setting
+// the cache cannot work with, or nil if there is none
+func (c *CacheConfig) Validate() error {
+	durations := []struct {
+		field string
+		d     time.Duration
+	}{
+		{"CleanDuration", c.CleanDuration},
+		{"DefaultTTL", c.DefaultTTL},
+		{"RefreshDuration", c.RefreshDuration},
+		{"FloodWindow", c.FloodWindow},
+		{"StaleGrace", c.StaleGrace},
+		{"ReloadJitter", c.ReloadJitter},
+		{"ExpireTimeout", c.ExpireTimeout},
+		{"WriteInterval", c.WriteInterval},
+		{"PressureInterval", c.PressureInterval},
+		{"HotKeyWindow", c.HotKeyWindow},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			return &ConfigError{Field: d.field, Reason: "is negative"}
+		}
+	}
+
+	counts := []struct {
+		field string
+		n     int
+	}{
+		{"MaxEntries", c.MaxEntries},
+		{"FloodThreshold", c.FloodThreshold},
+		{"MaxReloads", c.MaxReloads},
+		{"ExpireWorkers", c.ExpireWorkers},
+		{"WriteRetries", c.WriteRetries},
+		{"InvalidateQueue", c.InvalidateQueue},
+		{"DependencyDepth", c.DependencyDepth},
+		{"BloomCapacity", c.BloomCapacity},
+	}
+	for _, n := range counts {
+		if n.n < 0 {
+			return &ConfigError{Field: n.field, Reason: "is negative"}
+		}
+	}
+
+	fractions := []struct {
+		field string
+		f     float64
+		max   float64
+	}{
+		{"MemoryFraction", c.MemoryFraction, 1},
+		{"AccessSampleRate", c.AccessSampleRate, 1},
+		{"HotKeySampleRate", c.HotKeySampleRate, 1},
+		{"ReloadAhead", c.ReloadAhead, 1},
+		{"TTLJitter", c.TTLJitter, 1},
+		{"BloomFPRate", c.BloomFPRate, 1},
+	}
+	for _, f := range fractions {
+		if f.f < 0 || f.f > f.max {
+			return &ConfigError{Field: f.field, Reason: fmt.Sprintf("is outside [0, %g]", f.max)}
+		}
+	}
+
+	switch {
+	case c.Evictor == nil && (c.EvictionPolicy < EvictTTL || c.EvictionPolicy > EvictARC):
+		return &ConfigError{Field: "EvictionPolicy", Reason: fmt.Sprintf("%d is unknown", c.EvictionPolicy)}
+	case c.FloodThreshold > 0 && c.OnHashFlood == nil && !c.AutoReseed:
+		return &ConfigError{Field: "FloodThreshold", Reason: "is set without OnHashFlood or AutoReseed"}
+	case c.ExpireOnFlush && c.OnExpires == nil && c.OnExpiresBatch == nil:
+		return &ConfigError{Field: "ExpireOnFlush", Reason: "is set without OnExpires or OnExpiresBatch"}
+	case c.WriteBack && c.Store == nil:
+		return &ConfigError{Field: "WriteBack", Reason: "is set without a Store"}
+	}
+
+	if c.SpillDir != "" {
+		info, err := os.Stat(c.SpillDir)
+		if err != nil || !info.IsDir() {
+			return &ConfigError{Field: "SpillDir", Reason: "is not a directory"}
+		}
+	}
+
+	if c.Keyring != nil {
+		err := c.Keyring.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Shadow != nil {
+		err := c.Shadow.Validate()
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			return &ConfigError{Field: "Shadow." + configErr.Field, Reason: configErr.Reason}
+		}
+	}
+
+	return nil
+}
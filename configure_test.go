@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewCacheWithOptions(t *testing.T) {
+	var expired []interface{}
+	cache, err := NewCacheWithOptions(
+		WithCleanInterval(5*time.Millisecond),
+		WithRefresh(time.Minute),
+		WithMaxEntries(2),
+		WithEvictionPolicy(EvictLRU),
+		WithOnExpires(func(item interface{}) {
+			expired = append(expired, item)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	if cache.config.CleanDuration != 5*time.Millisecond || !cache.config.Refresh || cache.config.MaxEntries != 2 {
+		t.Errorf("options were not applied: %+v", cache.config)
+	}
+
+	if defaultConfig.CleanDuration != defaultCleanDuration || defaultConfig.Refresh {
+		t.Errorf("the default configuration was modified: %+v", defaultConfig)
+	}
+
+	base := CacheConfig{MaxEntries: 10, ExpireWorkers: 2}
+	cache, err = NewCacheWithOptions(WithConfig(base), WithMaxEntries(5))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	if cache.config.MaxEntries != 5 || cache.config.ExpireWorkers != 2 || base.CleanDuration != 0 {
+		t.Errorf("unexpected configuration %+v", cache.config)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		opts  []Option
+		field string
+	}{
+		{[]Option{WithCleanInterval(-time.Second)}, "CleanDuration"},
+		{[]Option{WithRefresh(-time.Second)}, "RefreshDuration"},
+		{[]Option{WithMaxEntries(-1)}, "MaxEntries"},
+		{[]Option{WithEvictionPolicy(EvictionPolicy(99))}, "EvictionPolicy"},
+		{[]Option{WithOnExpires(nil)}, "OnExpires"},
+		{[]Option{WithOnError(nil)}, "OnError"},
+		{[]Option{WithStore(nil, false)}, "Store"},
+		{[]Option{WithConfig(CacheConfig{TTLJitter: 1.5})}, "TTLJitter"},
+		{[]Option{WithConfig(CacheConfig{FloodThreshold: 10})}, "FloodThreshold"},
+		{[]Option{WithConfig(CacheConfig{ExpireOnFlush: true})}, "ExpireOnFlush"},
+		{[]Option{WithConfig(CacheConfig{WriteBack: true})}, "WriteBack"},
+		{[]Option{WithConfig(CacheConfig{Shadow: &CacheConfig{MaxEntries: -1}})}, "Shadow.MaxEntries"},
+	}
+
+	for _, test := range tests {
+		_, err := NewCacheWithOptions(test.opts...)
+		configErr, ok := err.(*ConfigError)
+		if !ok || configErr.Field != test.field {
+			t.Errorf("expected a ConfigError for %s, got %+v", test.field, err)
+		}
+	}
+
+	if err := (&CacheConfig{}).Validate(); err != nil {
+		t.Errorf("expected the zero configuration to be valid, got %+v", err)
+	}
+}
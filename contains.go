@@ -0,0 +1,30 @@
+package cache
+
+// Contains reports whether the key holds an unexpired item. Unlike Get
+// it only takes the read lock, never extends the expiration of the item
+// and is not counted in the hit and miss statistics. With a bloom filter
+// configured most absent keys are answered without taking the lock.
+func (t *Cache) Contains(key string) bool {
+	if t.bloom != nil && !t.bloom.contains(key) {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	hashedKey := t.hash(key)
+
+	idx, ok := t.live(hashedKey)
+	if !ok {
+		return false
+	}
+
+	slot := t.slots[idx]
+	return slot.name == key && !t.now().After(slot.ExpiresAt)
+}
+
+// Contains reports whether the bucket holds an unexpired item at the key,
+// without extending its expiration, see Cache.Contains
+func (b *Bucket) Contains(key string) bool {
+	return b.cache.Contains(b.key(key))
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContains(t *testing.T) {
+	for _, capacity := range []int{0, 100} {
+		cache := NewCache(&CacheConfig{Refresh: true, RefreshDuration: time.Minute, BloomCapacity: capacity})
+
+		cache.Add("a", 1, 10*time.Minute)
+		cache.Add("expired", 1, time.Millisecond)
+		cache.Add("hidden", 1, 10*time.Minute)
+		cache.SoftDelete("hidden")
+		cache.Bucket("bucket").Add("b", 2, 10*time.Minute)
+		time.Sleep(5 * time.Millisecond)
+
+		ttl, _ := cache.TTL("a")
+		if !cache.Contains("a") || !cache.Bucket("bucket").Contains("b") {
+			t.Errorf("expected the keys to be contained")
+		}
+
+		for _, key := range []string{"missing", "expired", "hidden"} {
+			if cache.Contains(key) {
+				t.Errorf("expected %s not to be contained", key)
+			}
+		}
+
+		if after, _ := cache.TTL("a"); after > ttl {
+			t.Errorf("expected Contains not to refresh the item")
+		}
+
+		if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+			t.Errorf("expected Contains not to be counted, got %+v", stats)
+		}
+	}
+}
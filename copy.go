@@ -0,0 +1,83 @@
+package cache
+
+// CopyFrom will copy the items of the other cache whose keys the filter
+// reports true for, or all of its items if filter is nil, so that a new
+// cache can start warm from a running one. The buckets of the other
+// cache are created in this one, and its items keep their expirations
+// and metadata. Items are not copied themselves, so both caches share
+// any item that is a pointer, map or slice.
+//
+// The items are read from a Snapshot of the other cache and added in
+// batches like Warm, without being written to the Store. Items that
+// cannot be added are skipped, and the first error adding one is returned.
+func (t *Cache) CopyFrom(other *Cache, filter func(key string) bool) error {
+	if other == t {
+		return nil
+	}
+
+	for _, name := range other.Buckets() {
+		t.Bucket(name)
+	}
+
+	var entries []Entry
+	for _, e := range other.Snapshot() {
+		if filter == nil || filter(e.Key) {
+			entries = append(entries, e)
+		}
+	}
+
+	var first error
+	for len(entries) > 0 {
+		n := warmBatch
+		if n > len(entries) {
+			n = len(entries)
+		}
+
+		// the metadata is read before taking the lock of this cache,
+		// so that two caches copying from each other cannot deadlock
+		err := t.copyEntries(entries[:n], other.metas(entries[:n]))
+		if first == nil {
+			first = err
+		}
+		entries = entries[n:]
+	}
+
+	return first
+}
+
+func (t *Cache) copyEntries(entries []Entry, metas []map[string]string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var first error
+	for i, e := range entries {
+		hk := t.hash(e.Key)
+		err := t.set(hk, e.Key, e.Item, e.ExpiresAt)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+
+		t.slots[t.keys[hk]].meta = metas[i]
+	}
+
+	return first
+}
+
+// metas will return copies of the metadata of the entries' items
+func (t *Cache) metas(entries []Entry) []map[string]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	metas := make([]map[string]string, len(entries))
+	for i, e := range entries {
+		hk := t.hash(e.Key)
+		if idx, ok := t.live(hk); ok && t.slots[idx].name == e.Key {
+			metas[i] = copyMeta(t.slots[idx].meta)
+		}
+	}
+
+	return metas
+}
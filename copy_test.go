@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyFrom(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	src := NewCache(&CacheConfig{Clock: clock})
+	defer src.Close()
+
+	src.Add("a", 1, time.Minute)
+	src.Add("b", 2, NoExpiration, WithMeta(map[string]string{"etag": "v1"}))
+	src.Add("skip:c", 3, NoExpiration)
+	src.Bucket("bucket").Add("d", 4, time.Hour)
+
+	dst := NewCache(&CacheConfig{Clock: clock})
+	defer dst.Close()
+
+	err := dst.CopyFrom(src, func(key string) bool {
+		return !strings.HasPrefix(key, "skip:")
+	})
+	if err != nil {
+		t.Fatalf("CopyFrom error: %+v", err)
+	}
+
+	if _, meta, err := dst.GetWithMeta("b"); err != nil || meta["etag"] != "v1" {
+		t.Errorf("expected the item and its metadata to be copied, got %v %+v", meta, err)
+	}
+
+	if _, err := dst.Get("skip:c"); err != ErrDNE {
+		t.Errorf("expected the filtered item to be left out, got %+v", err)
+	}
+
+	if item, err := dst.Bucket("bucket").Get("d"); err != nil || item != 4 {
+		t.Errorf("expected the bucket item to be copied, got %v %+v", item, err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := dst.Get("a"); err != ErrDNE {
+		t.Errorf("expected the item to keep its expiration, got %+v", err)
+	}
+
+	if err := dst.CheckInvariants(); err != nil {
+		t.Errorf("CheckInvariants error: %+v", err)
+	}
+
+	if err := dst.CopyFrom(dst, nil); err != nil {
+		t.Errorf("expected copying from itself to do nothing, got %+v", err)
+	}
+}
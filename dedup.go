@@ -0,0 +1,44 @@
+package cache
+
+import "time"
+
+// dedupItem is the item stored at a key seen by Deduplicate
+type dedupItem struct{}
+
+// Deduplicate will return true the first time the key is seen within
+// the window and false for every call after it until the window has
+// passed, e.g. to process each delivery of a webhook only once. The
+// check and the marking of the key are atomic. A key that cannot be
+// marked, because it collides with another key, is reported as unseen
+// so that its work is repeated rather than lost, as is a key the
+// Admission policy rejects.
+func (t *Cache) Deduplicate(key string, window time.Duration) bool {
+	seen := false
+	t.write(func() error {
+		hashedKey := t.hash(key)
+
+		if idx, ok := t.live(hashedKey); ok && t.slots[idx].name == key && !t.now().After(t.slots[idx].ExpiresAt) {
+			seen = true
+			return nil
+		}
+
+		var o addOptions
+		err := t.admit(hashedKey, key, dedupItem{}, o)
+		if err != nil {
+			return err
+		}
+
+		tx := t.beginStore(hashedKey, key)
+		err = t.place(hashedKey, key, dedupItem{}, t.expiration(window), o)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+		t.added(key, window, o)
+
+		return nil
+	})
+
+	return !seen
+}
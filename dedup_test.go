@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeduplicate(t *testing.T) {
+	cache := NewCache(nil)
+
+	var first int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cache.Deduplicate("event:1", 30*time.Millisecond) {
+				atomic.AddInt64(&first, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if first != 1 {
+		t.Errorf("expected the key to be seen first once, got %d", first)
+	}
+
+	if !cache.Deduplicate("event:2", 30*time.Millisecond) {
+		t.Errorf("expected a different key to be seen first")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if !cache.Deduplicate("event:1", 30*time.Millisecond) {
+		t.Errorf("expected the key to be seen first again after the window")
+	}
+
+	if cache.Deduplicate("event:1", 30*time.Millisecond) {
+		t.Errorf("expected the key to be deduplicated in the new window")
+	}
+}
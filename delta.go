@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+)
+
+// gobDelta is the changes to a cache since a time
+type gobDelta struct {
+	Since   time.Time
+	Removed []string // keys removed since then
+	Flushed bool     // the cache was flushed since then
+	Entries []gobEntry
+	Buckets []string
+}
+
+// tombstones records when keys were removed from the cache, so that a
+// delta lists only the keys removed since its time. Removals are only
+// recorded once the cache has been saved with Save or SaveDelta, and a
+// save drops the records that deltas saved after it no longer need.
+type tombstones struct {
+	removed map[string]time.Time // nil until the cache is saved
+	flushed time.Time
+	mu      *sync.Mutex
+}
+
+func newTombstones() *tombstones {
+	return &tombstones{mu: &sync.Mutex{}}
+}
+
+// add will record the removal of the key
+func (ts *tombstones) add(name string, at time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.removed != nil {
+		ts.removed[name] = at
+	}
+}
+
+// drop will forget the removal of a key that was added again
+func (ts *tombstones) drop(name string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	delete(ts.removed, name)
+}
+
+// flush will replace the removals with a flush of the whole cache
+func (ts *tombstones) flush(at time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.removed != nil {
+		ts.removed = make(map[string]time.Time)
+	}
+	ts.flushed = at
+}
+
+// since will return the keys removed since the time,
+// and whether the cache was flushed since then
+func (ts *tombstones) since(since time.Time) ([]string, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var names []string
+	for name, at := range ts.removed {
+		if !at.Before(since) {
+			names = append(names, name)
+		}
+	}
+
+	return names, !ts.flushed.IsZero() && !ts.flushed.Before(since)
+}
+
+// prune will start recording removals, dropping those before the time
+func (ts *tombstones) prune(before time.Time) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.removed == nil {
+		ts.removed = make(map[string]time.Time)
+	}
+
+	for name, at := range ts.removed {
+		if at.Before(before) {
+			delete(ts.removed, name)
+		}
+	}
+}
+
+// SaveDelta will save the items added or changed since the time to
+// a file of the given name, so that a large cache can be persisted
+// often by writing a full Save now and then and a delta in between.
+// A change is a write of an item, its expiration or its metadata.
+// The delta also lists the keys removed since then, so that LoadDelta
+// removes them too. Removals are recorded from the first Save or
+// SaveDelta of the cache, and each save forgets those that happened
+// before its time, or before since for a delta, so a delta should not
+// be saved with a time earlier than that of the save before it.
+//
+// A delta is wrapped in the same envelope as Save, and encrypted
+// with the Keyring if there is one.
+func (c *Cache) SaveDelta(filename string, since time.Time) error {
+	c.mu.RLock()
+	gd := gobDelta{Since: since}
+	gd.Removed, gd.Flushed = c.tombstones.since(since)
+	for _, slot := range c.slots {
+		if slot.empty {
+			continue
+		}
+
+		if slot.deleted {
+			gd.Removed = append(gd.Removed, slot.name)
+			continue
+		}
+
+		if b, ok := slot.Item.(*Bucket); ok {
+			gd.Buckets = append(gd.Buckets, b.name)
+			continue
+		}
+
+		if slot.modified.Before(since) {
+			continue
+		}
+
+		expiresAt := slot.ExpiresAt
+		if !slot.held.IsZero() {
+			expiresAt = slot.held
+		}
+
+		gd.Entries = append(gd.Entries, gobEntry{
+			Key:       slot.name,
+			Item:      slot.Item,
+			ExpiresAt: expiresAt,
+			Meta:      slot.meta,
+		})
+	}
+	c.mu.RUnlock()
+
+	var buff bytes.Buffer
+	err := gob.NewEncoder(&buff).Encode(gd)
+	if err != nil {
+		return err
+	}
+
+	err = c.writeFile(filename, envelope(deltaMagic, buff.Bytes(), len(gd.Entries)))
+	if err != nil {
+		return err
+	}
+
+	c.tombstones.prune(since)
+
+	return nil
+}
+
+// LoadDelta will merge a delta written by SaveDelta into the cache,
+// setting the items it holds and removing the keys it lists as removed. Loading the last full Save followed by each later delta in
+// order restores the cache as it was when the last delta was saved.
+// A file that was truncated or corrupted returns ErrCorrupt and leaves
+// the cache as it was.
+func (c *Cache) LoadDelta(filename string) error {
+	data, err := c.readFile(filename)
+	if err != nil {
+		return err
+	}
+
+	data, count, err := openEnvelope(deltaMagic, data)
+	if err != nil {
+		return err
+	}
+
+	var gd gobDelta
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&gd)
+	if err != nil || count != len(gd.Entries) {
+		return ErrCorrupt
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.merge(gd)
+}
+
+// merge will apply the delta to the cache. The lock must be held.
+func (c *Cache) merge(gd gobDelta) error {
+	for _, name := range gd.Buckets {
+		_, err := c.bucket(name, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if gd.Flushed {
+		for idx, slot := range c.slots {
+			if slot.empty {
+				continue
+			}
+
+			if _, ok := slot.Item.(*Bucket); !ok {
+				c.remove(idx)
+			}
+		}
+	}
+
+	for _, name := range gd.Removed {
+		if idx, ok := c.keys[c.hash(name)]; ok && c.slots[idx].name == name {
+			c.remove(idx)
+		}
+	}
+
+	for _, entry := range gd.Entries {
+		hk := c.hash(entry.Key)
+		err := c.set(hk, entry.Key, entry.Item, entry.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		if idx, ok := c.keys[hk]; ok {
+			c.slots[idx].meta = entry.Meta
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSaveDelta(t *testing.T) {
+	dir := t.TempDir()
+	full := filepath.Join(dir, "full.gob")
+	delta := filepath.Join(dir, "delta.gob")
+
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCache(&CacheConfig{Clock: clock})
+	defer c.Close()
+
+	c.Add("a", 1, time.Hour)
+	c.Add("b", 2, time.Hour)
+	c.Add("c", 3, time.Hour)
+	if err := c.Save(full); err != nil {
+		t.Fatalf("Save error: %+v", err)
+	}
+
+	clock.Advance(time.Minute)
+	since := clock.Now()
+	c.Set("a", 10, time.Hour)
+	c.Delete("b")
+	c.Bucket("bucket").Add("d", 4, time.Hour)
+	c.SetMeta("c", map[string]string{"owner": "test"})
+	c.Add("e", 5, time.Hour)
+	c.SoftDelete("e")
+
+	if err := c.SaveDelta(delta, since); err != nil {
+		t.Fatalf("SaveDelta error: %+v", err)
+	}
+
+	data, _ := ioutil.ReadFile(delta)
+	payload, count, err := openEnvelope(deltaMagic, data)
+	if err != nil || count != 3 {
+		t.Errorf("expected only the 3 changed items in the delta, got %d %+v", count, err)
+	}
+
+	var gd gobDelta
+	gob.NewDecoder(bytes.NewReader(payload)).Decode(&gd)
+	sort.Strings(gd.Removed)
+	if len(gd.Removed) != 2 || gd.Removed[0] != "b" || gd.Removed[1] != "e" || gd.Flushed {
+		t.Errorf("expected only the removed keys in the delta, got %+v", gd.Removed)
+	}
+
+	restored := NewCache(&CacheConfig{Clock: clock})
+	defer restored.Close()
+	if err := restored.Load(full); err != nil {
+		t.Fatalf("Load error: %+v", err)
+	}
+	if err := restored.LoadDelta(delta); err != nil {
+		t.Fatalf("LoadDelta error: %+v", err)
+	}
+
+	if got, want := restored.Keys(), c.Keys(); len(got) != len(want) {
+		t.Fatalf("expected the keys %v, got %v", want, got)
+	}
+
+	if item, _ := restored.Get("a"); item != 10 {
+		t.Errorf("expected the changed item, got %v", item)
+	}
+
+	if _, err := restored.Get("b"); err != ErrDNE {
+		t.Errorf("expected the deleted item to be removed, got %+v", err)
+	}
+
+	if _, err := restored.Get("e"); err != ErrDNE {
+		t.Errorf("expected the soft deleted item to be left out, got %+v", err)
+	}
+
+	if item, _ := restored.Bucket("bucket").Get("d"); item != 4 {
+		t.Errorf("expected the bucket item, got %v", item)
+	}
+
+	if _, meta, _ := restored.GetWithMeta("c"); meta["owner"] != "test" {
+		t.Errorf("expected the changed metadata, got %v", meta)
+	}
+
+	if err := restored.CheckInvariants(); err != nil {
+		t.Errorf("CheckInvariants error: %+v", err)
+	}
+
+	ioutil.WriteFile(delta, data[:len(data)-1], 0600)
+	if err := restored.LoadDelta(delta); err != ErrCorrupt {
+		t.Errorf("expected ErrCorrupt, got %+v", err)
+	}
+}
+
+func TestSaveDeltaFlush(t *testing.T) {
+	dir := t.TempDir()
+	full := filepath.Join(dir, "full.gob")
+	first := filepath.Join(dir, "first.gob")
+	second := filepath.Join(dir, "second.gob")
+
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCache(&CacheConfig{Clock: clock})
+	defer c.Close()
+
+	c.Add("a", 1, time.Hour)
+	c.Add("b", 2, time.Hour)
+	c.Save(full)
+
+	clock.Advance(time.Minute)
+	since := clock.Now()
+	c.Flush()
+	c.Add("c", 3, time.Hour)
+	if err := c.SaveDelta(first, since); err != nil {
+		t.Fatalf("SaveDelta error: %+v", err)
+	}
+
+	clock.Advance(time.Minute)
+	since = clock.Now()
+	c.Delete("c")
+	c.Add("d", 4, time.Hour)
+	if err := c.SaveDelta(second, since); err != nil {
+		t.Fatalf("SaveDelta error: %+v", err)
+	}
+
+	restored := NewCache(&CacheConfig{Clock: clock})
+	defer restored.Close()
+	if err := restored.Load(full); err != nil {
+		t.Fatalf("Load error: %+v", err)
+	}
+
+	for _, file := range []string{first, second} {
+		if err := restored.LoadDelta(file); err != nil {
+			t.Fatalf("LoadDelta error: %+v", err)
+		}
+	}
+
+	if keys := restored.Keys(); len(keys) != 1 || keys[0] != "d" {
+		t.Errorf("expected the flush and the removal to be applied, got %v", keys)
+	}
+}
@@ -0,0 +1,162 @@
+package cache
+
+import "errors"
+
+var (
+	// ErrDependencyCycle is returned by AddDependency when the
+	// dependency would make a key depend on itself
+	ErrDependencyCycle = errors.New("dependency cycle")
+
+	// ErrDependencyDepth is returned by AddDependency when the dependency
+	// would make a chain of dependent keys longer than DependencyDepth
+	ErrDependencyDepth = errors.New("dependency chain too deep")
+
+	defaultDependencyDepth = 16
+)
+
+// dependencies is the graph of keys that depend on other keys
+type dependencies struct {
+	children map[string]map[string]struct{} // keys that depend on each key
+	parents  map[string]map[string]struct{} // keys each key depends on
+}
+
+// AddDependency will make the child key depend on the parent key, so that
+// deleting or expiring the parent also deletes the child, and the keys that
+// depend on the child in turn. Both keys must be in the cache. A dependency
+// is forgotten once either key is removed.
+func (t *Cache) AddDependency(child, parent string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, name := range []string{child, parent} {
+		key := t.hash(name)
+		if idx, ok := t.live(key); !ok || t.slots[idx].name != name {
+			return ErrDNE
+		}
+	}
+
+	if child == parent || t.deps.reaches(child, parent) {
+		return ErrDependencyCycle
+	}
+
+	if t.deps.height(parent, t.deps.parents)+t.deps.height(child, t.deps.children)+1 > t.config.DependencyDepth {
+		return ErrDependencyDepth
+	}
+
+	t.deps.link(child, parent)
+	return nil
+}
+
+func newDependencies() *dependencies {
+	return &dependencies{
+		children: make(map[string]map[string]struct{}),
+		parents:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (d *dependencies) link(child, parent string) {
+	if d.children[parent] == nil {
+		d.children[parent] = make(map[string]struct{})
+	}
+	d.children[parent][child] = struct{}{}
+
+	if d.parents[child] == nil {
+		d.parents[child] = make(map[string]struct{})
+	}
+	d.parents[child][parent] = struct{}{}
+}
+
+// unlink will forget every dependency of and on the key
+func (d *dependencies) unlink(name string) {
+	for parent := range d.parents[name] {
+		delete(d.children[parent], name)
+		if len(d.children[parent]) == 0 {
+			delete(d.children, parent)
+		}
+	}
+	delete(d.parents, name)
+
+	for child := range d.children[name] {
+		delete(d.parents[child], name)
+		if len(d.parents[child]) == 0 {
+			delete(d.parents, child)
+		}
+	}
+	delete(d.children, name)
+}
+
+// reaches reports whether to depends on from, directly or transitively
+func (d *dependencies) reaches(from, to string) bool {
+	seen := map[string]bool{from: true}
+	level := []string{from}
+	for len(level) > 0 {
+		var next []string
+		for _, name := range level {
+			for child := range d.children[name] {
+				if child == to {
+					return true
+				}
+
+				if !seen[child] {
+					seen[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+		level = next
+	}
+
+	return false
+}
+
+// height will return the length of the longest chain of
+// edges leading away from the key in the direction given
+func (d *dependencies) height(name string, edges map[string]map[string]struct{}) int {
+	var longest int
+	for next := range edges[name] {
+		if h := d.height(next, edges) + 1; h > longest {
+			longest = h
+		}
+	}
+
+	return longest
+}
+
+// dependents will return the keys that depend on the key, directly
+// or transitively, up to DependencyDepth dependencies away
+func (t *Cache) dependents(name string) []string {
+	if len(t.deps.children[name]) == 0 {
+		return nil
+	}
+
+	var dependents []string
+	seen := map[string]bool{name: true}
+	level := []string{name}
+	for depth := 0; depth < t.config.DependencyDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, parent := range level {
+			for child := range t.deps.children[parent] {
+				if !seen[child] {
+					seen[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+
+		dependents = append(dependents, next...)
+		level = next
+	}
+
+	return dependents
+}
+
+// cascade will remove the keys that depended on a removed key.
+// The cache lock must be held.
+func (t *Cache) cascade(dependents []string) {
+	for _, name := range dependents {
+		key := t.hash(name)
+		if idx, ok := t.keys[key]; ok && t.slots[idx].name == name {
+			t.remove(idx)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDependencyCascade(t *testing.T) {
+	c := NewCache(&CacheConfig{})
+	defer c.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Add(key, key, time.Minute)
+	}
+
+	// c depends on b which depends on a, d is unrelated
+	if err := c.AddDependency("b", "a"); err != nil {
+		t.Errorf("AddDependency error: %+v", err)
+	}
+
+	if err := c.AddDependency("c", "b"); err != nil {
+		t.Errorf("AddDependency error: %+v", err)
+	}
+
+	c.Delete("a")
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := c.Get(key); err != ErrDNE {
+			t.Errorf("expected %s to be deleted, got %+v", key, err)
+		}
+	}
+
+	if _, err := c.Get("d"); err != nil {
+		t.Errorf("expected d to remain, got %+v", err)
+	}
+
+	if len(c.deps.children) != 0 || len(c.deps.parents) != 0 {
+		t.Errorf("expected the dependency graph to be empty, got %+v", c.deps)
+	}
+}
+
+func TestDependencyExpire(t *testing.T) {
+	c := NewCache(&CacheConfig{CleanDuration: 10 * time.Millisecond})
+	defer c.Close()
+
+	c.Add("parent", 1, 20*time.Millisecond)
+	c.Add("child", 2, time.Minute)
+	c.AddDependency("child", "parent")
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := c.Get("child"); err != ErrDNE {
+		t.Errorf("expected the child to be deleted with its expired parent, got %+v", err)
+	}
+}
+
+func TestDependencyErrors(t *testing.T) {
+	c := NewCache(&CacheConfig{DependencyDepth: 3})
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		c.Add("k"+strconv.Itoa(i), i, time.Minute)
+	}
+
+	if err := c.AddDependency("k0", "missing"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	if err := c.AddDependency("k0", "k0"); err != ErrDependencyCycle {
+		t.Errorf("expected ErrDependencyCycle, got %+v", err)
+	}
+
+	c.AddDependency("k1", "k0")
+	c.AddDependency("k2", "k1")
+
+	if err := c.AddDependency("k0", "k2"); err != ErrDependencyCycle {
+		t.Errorf("expected ErrDependencyCycle, got %+v", err)
+	}
+
+	if err := c.AddDependency("k3", "k2"); err != nil {
+		t.Errorf("AddDependency error: %+v", err)
+	}
+
+	if err := c.AddDependency("k4", "k3"); err != ErrDependencyDepth {
+		t.Errorf("expected ErrDependencyDepth, got %+v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrUnknownFormat is returned by Dump for formats other than "text" and "json"
+var ErrUnknownFormat = errors.New("unknown dump format")
+
+// Dump describes the contents of a cache, see Cache.Dump
+type Dump struct {
+	Slots    int          `json:"slots"`    // slots allocated, occupied or not
+	Occupied int          `json:"occupied"` // slots holding an item or a bucket
+	Free     int          `json:"free"`     // empty slots available for reuse
+	Bytes    int64        `json:"bytes"`
+	Entries  []DumpEntry  `json:"entries"`
+	Buckets  []DumpBucket `json:"buckets"`
+}
+
+// DumpEntry describes an item in a Dump
+type DumpEntry struct {
+	Slot      int       `json:"slot"`
+	Key       string    `json:"key"`
+	Bucket    string    `json:"bucket,omitempty"` // bucket holding the item, if any
+	ExpiresAt time.Time `json:"expires_at"`
+	TTL       string    `json:"ttl"` // time left until the item expires, "never" or "expired"
+	Size      int64     `json:"size"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	Priority  string    `json:"priority"`
+}
+
+// DumpBucket describes a bucket in a Dump
+type DumpBucket struct {
+	Slot int    `json:"slot"`
+	Name string `json:"name"`
+	Keys int    `json:"keys"` // keys of the bucket that are in the cache
+}
+
+// Dump will write a description of every slot of the cache to w, for
+// debugging why a key is missing or large. The "text" format is a table
+// for reading, and the "json" format encodes a Dump. Expired and soft
+// deleted items that have not been removed yet are included.
+func (t *Cache) Dump(w io.Writer, format string) error {
+	if format != "text" && format != "json" {
+		return ErrUnknownFormat
+	}
+
+	d := t.dump()
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "slots: %d\toccupied: %d\tfree: %d\tbytes: %d\n\n", d.Slots, d.Occupied, d.Free, d.Bytes)
+
+	fmt.Fprintln(tw, "SLOT\tKEY\tBUCKET\tTTL\tSIZE\tFLAGS")
+	for _, e := range d.Entries {
+		flags := e.Priority
+		if e.Pinned {
+			flags += ",pinned"
+		}
+		if e.Deleted {
+			flags += ",deleted"
+		}
+		fmt.Fprintf(tw, "%d\t%q\t%s\t%s\t%d\t%s\n", e.Slot, e.Key, e.Bucket, e.TTL, e.Size, flags)
+	}
+
+	if len(d.Buckets) > 0 {
+		fmt.Fprintln(tw, "\nSLOT\tBUCKET\tKEYS")
+		for _, b := range d.Buckets {
+			fmt.Fprintf(tw, "%d\t%q\t%d\n", b.Slot, b.Name, b.Keys)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func (t *Cache) dump() Dump {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+	d := Dump{
+		Slots:   len(t.slots),
+		Free:    len(t.free),
+		Bytes:   t.bytes,
+		Entries: make([]DumpEntry, 0, len(t.keys)),
+		Buckets: make([]DumpBucket, 0),
+	}
+
+	for i, slot := range t.slots {
+		if slot.empty {
+			continue
+		}
+		d.Occupied++
+
+		if b, ok := slot.Item.(*Bucket); ok {
+			keys := 0
+			for _, k := range b.list {
+				if _, ok := t.keys[k]; ok {
+					keys++
+				}
+			}
+			d.Buckets = append(d.Buckets, DumpBucket{Slot: i, Name: b.name, Keys: keys})
+			continue
+		}
+
+		e := DumpEntry{
+			Slot:      i,
+			Key:       slot.name,
+			ExpiresAt: slot.ExpiresAt,
+			Size:      slot.size,
+			Deleted:   slot.deleted,
+			Pinned:    slot.pinned,
+			Priority:  slot.priority.String(),
+		}
+		if b, _ := t.bucketOf(slot.name); b != nil {
+			e.Bucket = b.name
+		}
+
+		switch {
+		case slot.ExpiresAt.Equal(neverExpires):
+			e.TTL = "never"
+		case now.After(slot.ExpiresAt):
+			e.TTL = "expired"
+		default:
+			e.TTL = slot.ExpiresAt.Sub(now).String()
+		}
+
+		d.Entries = append(d.Entries, e)
+	}
+
+	return d
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDump(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, DisableCleaner: true, MaxBytes: 1 << 20})
+	defer cache.Close()
+
+	cache.Add("short", "value", time.Minute)
+	cache.Add("forever", "value", NoExpiration)
+	cache.Bucket("users").Add("42", "gopher", time.Hour)
+	cache.Add("gone", 1, time.Minute)
+	cache.Delete("gone")
+	clock.Advance(2 * time.Minute)
+
+	var buf bytes.Buffer
+	if err := cache.Dump(&buf, "json"); err != nil {
+		t.Fatalf("Dump error: %+v", err)
+	}
+
+	var d Dump
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Fatalf("Unmarshal error: %+v", err)
+	}
+
+	if d.Slots != 5 || d.Occupied != 4 || d.Free != 1 || len(d.Entries) != 3 || len(d.Buckets) != 1 {
+		t.Fatalf("unexpected dump %+v", d)
+	}
+
+	ttls := make(map[string]string)
+	for _, e := range d.Entries {
+		ttls[e.Key] = e.TTL
+		if e.Key == "users:42" && (e.Bucket != "users" || e.Size != 6) {
+			t.Errorf("expected the bucket item to be attributed to its bucket, got %+v", e)
+		}
+	}
+
+	if ttls["short"] != "expired" || ttls["forever"] != "never" || ttls["users:42"] != "58m0s" {
+		t.Errorf("unexpected ttls %+v", ttls)
+	}
+
+	if b := d.Buckets[0]; b.Name != "users" || b.Keys != 1 {
+		t.Errorf("unexpected bucket %+v", b)
+	}
+
+	buf.Reset()
+	if err := cache.Dump(&buf, "text"); err != nil {
+		t.Fatalf("Dump error: %+v", err)
+	}
+
+	if out := buf.String(); !strings.Contains(out, `"users:42"`) || !strings.Contains(out, "58m0s") {
+		t.Errorf("unexpected text dump:\n%s", out)
+	}
+
+	if err := cache.Dump(&buf, "yaml"); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %+v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// encryptedMagic begins a cache saved with a Keyring,
+// it cannot begin a gob encoded cache
+const encryptedMagic = "CACHEGCM"
+
+// encryptedVersion is the version of the header of an encrypted cache
+const encryptedVersion = 1
+
+// length of the magic, the version and the key id
+const encryptedHeader = len(encryptedMagic) + 1 + 4
+
+var (
+	// ErrEncrypted is returned by Load for an encrypted file when the cache has no Keyring
+	ErrEncrypted = errors.New("saved cache is encrypted")
+	// ErrUnencrypted is returned by Load for an unencrypted file when the cache has a Keyring
+	ErrUnencrypted = errors.New("saved cache is not encrypted")
+	// ErrUnknownKey is returned by Load when the Keyring has no key by the id a file was encrypted with
+	ErrUnknownKey = errors.New("saved cache is encrypted with an unknown key")
+	// ErrDecrypt is returned by Load for an encrypted file that is corrupt or was modified
+	ErrDecrypt = errors.New("saved cache could not be decrypted")
+)
+
+// Keyring holds the AES keys that encrypt the files written by Save.
+// Each file records the id of the key it was encrypted with, so keys
+// can be rotated by adding a new key as Current while keeping the old
+// keys until every file encrypted with them has been saved again.
+// Unencrypted files are rejected, as they could have been written by
+// anyone, unless AllowPlaintext is set while migrating to encryption.
+type Keyring struct {
+	Keys           map[uint32][]byte // AES keys of 16, 24 or 32 bytes by their ids
+	Current        uint32            // id of the key that new files are encrypted with
+	AllowPlaintext bool              // unencrypted files are read too
+}
+
+func (k *Keyring) validate() error {
+	if _, ok := k.Keys[k.Current]; !ok {
+		return &ConfigError{Field: "Keyring", Reason: fmt.Sprintf("has no current key %d", k.Current)}
+	}
+
+	for id, key := range k.Keys {
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return &ConfigError{Field: "Keyring", Reason: fmt.Sprintf("key %d is %d bytes, not 16, 24 or 32", id, len(key))}
+		}
+	}
+
+	return nil
+}
+
+func (k *Keyring) gcm(id uint32) (cipher.AEAD, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Seal will encrypt the data with AES-GCM under the current key,
+// behind a header holding the version of the format and the key's id
+func (k *Keyring) Seal(data []byte) ([]byte, error) {
+	gcm, err := k.gcm(k.Current)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, encryptedHeader, encryptedHeader+gcm.NonceSize()+len(data)+gcm.Overhead())
+	copy(header, encryptedMagic)
+	header[len(encryptedMagic)] = encryptedVersion
+	binary.BigEndian.PutUint32(header[len(encryptedMagic)+1:], k.Current)
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+// Open will decrypt data encrypted by Seal with any key of the keyring
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	if !encrypted(data) || len(data) < encryptedHeader || data[len(encryptedMagic)] != encryptedVersion {
+		return nil, ErrDecrypt
+	}
+
+	header := data[:encryptedHeader]
+	gcm, err := k.gcm(binary.BigEndian.Uint32(header[len(encryptedMagic)+1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	data = data[encryptedHeader:]
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plain, nil
+}
+
+// encrypted will report whether the data was written by Keyring.Seal
+func encrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
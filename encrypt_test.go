@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptedSave(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 16)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	filename := filepath.Join(t.TempDir(), "cache.gob")
+
+	c, err := NewCacheWithOptions(WithEncryptionKey(oldKey), WithoutCleaner())
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer c.Close()
+
+	c.Add("session", "secret-token", time.Hour)
+	if err := c.Save(filename); err != nil {
+		t.Fatalf("Save error: %+v", err)
+	}
+
+	data, _ := ioutil.ReadFile(filename)
+	if bytes.Contains(data, []byte("secret-token")) || !encrypted(data) {
+		t.Errorf("expected the saved cache to be encrypted")
+	}
+
+	plain := NewCache(nil)
+	defer plain.Close()
+	if err := plain.Load(filename); err != ErrEncrypted {
+		t.Errorf("expected ErrEncrypted, got %+v", err)
+	}
+
+	// the old key still decrypts after rotating to the new key
+	ring := &Keyring{Keys: map[uint32][]byte{0: oldKey, 1: newKey}, Current: 1}
+	rotated, err := NewCacheWithOptions(WithKeyring(ring), WithoutCleaner())
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer rotated.Close()
+
+	if err := rotated.Load(filename); err != nil {
+		t.Fatalf("Load error: %+v", err)
+	}
+	if item, err := rotated.Get("session"); err != nil || item != "secret-token" {
+		t.Errorf("expected the item to be decrypted, got %v %+v", item, err)
+	}
+
+	if err := rotated.Save(filename); err != nil {
+		t.Fatalf("Save error: %+v", err)
+	}
+	if err := c.Load(filename); err != ErrUnknownKey {
+		t.Errorf("expected ErrUnknownKey for a file encrypted with the new key, got %+v", err)
+	}
+
+	data, _ = ioutil.ReadFile(filename)
+	data[len(data)-1] ^= 1
+	ioutil.WriteFile(filename, data, 0600)
+	if err := rotated.Load(filename); err != ErrDecrypt {
+		t.Errorf("expected ErrDecrypt for a modified file, got %+v", err)
+	}
+
+	plain.Add("plain", 1, NoExpiration)
+	plain.Save(filename)
+	if err := rotated.Load(filename); err != ErrUnencrypted {
+		t.Errorf("expected ErrUnencrypted for an unencrypted file, got %+v", err)
+	}
+
+	if info, err := os.Stat(filename); err != nil || info.Mode().Perm()&0077 != 0 {
+		t.Errorf("expected the file to be private to its owner, got %v: %+v", info.Mode(), err)
+	}
+
+	// unencrypted files can be loaded while migrating to encryption
+	ring.AllowPlaintext = true
+	if err := rotated.Load(filename); err != nil {
+		t.Errorf("expected an unencrypted file to load, got %+v", err)
+	}
+}
+
+func TestKeyringValidate(t *testing.T) {
+	if _, err := NewCacheWithOptions(WithEncryptionKey([]byte("short"))); err == nil {
+		t.Errorf("expected a key of the wrong size to be rejected")
+	}
+
+	ring := &Keyring{Keys: map[uint32][]byte{1: make([]byte, 16)}, Current: 2}
+	if _, err := NewCacheWithOptions(WithKeyring(ring)); err == nil {
+		t.Errorf("expected a keyring without its current key to be rejected")
+	}
+
+	if _, err := NewCacheWithOptions(WithKeyring(nil)); err == nil {
+		t.Errorf("expected a nil keyring to be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// envelopeMagic begins a cache written by Save, and deltaMagic
+// the changes to a cache written by SaveDelta
+const (
+	envelopeMagic = "CACHESNP"
+	deltaMagic    = "CACHEDLT"
+)
+
+// envelopeVersion is the version of the envelope written by Save.
+// Load reads every version up to it.
+const envelopeVersion = 1
+
+// length of the magic, the version, the number of entries,
+// the length of the payload and the checksum
+const envelopeHeader = len(envelopeMagic) + 2 + 4 + 8 + 4
+
+var (
+	// ErrCorrupt is returned by Load for a file that was truncated or corrupted
+	ErrCorrupt = errors.New("saved cache is corrupt")
+	// ErrVersion is returned by Load for a file written by a newer version of the package
+	ErrVersion = errors.New("saved cache has an unsupported format version")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// envelope will wrap the payload holding count entries in a header
+// beginning with the magic, and recording the format version, the
+// count, the length of the payload and a CRC-32C of the header and
+// the payload
+func envelope(magic string, payload []byte, count int) []byte {
+	data := make([]byte, envelopeHeader, envelopeHeader+len(payload))
+	n := copy(data, magic)
+	binary.BigEndian.PutUint16(data[n:], envelopeVersion)
+	binary.BigEndian.PutUint32(data[n+2:], uint32(count))
+	binary.BigEndian.PutUint64(data[n+6:], uint64(len(payload)))
+
+	sum := crc32.Update(crc32.Checksum(data[:envelopeHeader-4], castagnoli), castagnoli, payload)
+	binary.BigEndian.PutUint32(data[envelopeHeader-4:], sum)
+
+	return append(data, payload...)
+}
+
+// openEnvelope will verify the envelope beginning with the magic
+// and return its payload and the number of entries it holds
+func openEnvelope(magic string, data []byte) ([]byte, int, error) {
+	if !bytes.HasPrefix(data, []byte(magic)) || len(data) < envelopeHeader {
+		return nil, 0, ErrCorrupt
+	}
+
+	n := len(magic)
+	if binary.BigEndian.Uint16(data[n:]) > envelopeVersion {
+		return nil, 0, ErrVersion
+	}
+
+	count := binary.BigEndian.Uint32(data[n+2:])
+	payload := data[envelopeHeader:]
+	if binary.BigEndian.Uint64(data[n+6:]) != uint64(len(payload)) {
+		return nil, 0, ErrCorrupt
+	}
+
+	sum := crc32.Update(crc32.Checksum(data[:envelopeHeader-4], castagnoli), castagnoli, payload)
+	if sum != binary.BigEndian.Uint32(data[envelopeHeader-4:]) {
+		return nil, 0, ErrCorrupt
+	}
+
+	return payload, int(count), nil
+}
+
+// enveloped will report whether the data was written by envelope
+func enveloped(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(envelopeMagic))
+}
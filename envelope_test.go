@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnvelope(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cache.gob")
+
+	c := NewCache(nil)
+	defer c.Close()
+	c.Add("a", 1, time.Hour)
+	c.Bucket("b").Add("c", 2, time.Hour)
+
+	if err := c.Save(filename); err != nil {
+		t.Fatalf("Save error: %+v", err)
+	}
+	data, _ := ioutil.ReadFile(filename)
+
+	loaded := NewCache(nil)
+	defer loaded.Close()
+	if err := loaded.Load(filename); err != nil {
+		t.Fatalf("Load error: %+v", err)
+	}
+	if item, _ := loaded.Bucket("b").Get("c"); item != 2 {
+		t.Errorf("expected the bucket item to be loaded, got %v", item)
+	}
+
+	corrupt := map[string][]byte{
+		"truncated": data[:len(data)-10],
+		"flipped":   append([]byte{}, data...),
+		"header":    data[:envelopeHeader-1],
+	}
+	corrupt["flipped"][len(data)-5] ^= 1
+
+	for name, bad := range corrupt {
+		ioutil.WriteFile(filename, bad, 0600)
+
+		empty := NewCache(nil)
+		if err := empty.Load(filename); err != ErrCorrupt {
+			t.Errorf("expected ErrCorrupt for the %s file, got %+v", name, err)
+		}
+		if len(empty.Keys()) != 0 {
+			t.Errorf("expected nothing to be restored from the %s file", name)
+		}
+		empty.Close()
+	}
+
+	newer := append([]byte{}, data...)
+	binary.BigEndian.PutUint16(newer[len(envelopeMagic):], envelopeVersion+1)
+	ioutil.WriteFile(filename, newer, 0600)
+	if err := loaded.Load(filename); err != ErrVersion {
+		t.Errorf("expected ErrVersion, got %+v", err)
+	}
+
+	// the count of entries is checked after decoding
+	payload, _, _ := openEnvelope(envelopeMagic, data)
+	ioutil.WriteFile(filename, envelope(envelopeMagic, payload, 5), 0600)
+	if err := loaded.Load(filename); err != ErrCorrupt {
+		t.Errorf("expected ErrCorrupt for a wrong count, got %+v", err)
+	}
+
+	// files saved before the envelope are still loaded
+	legacy, _ := c.GobEncode()
+	ioutil.WriteFile(filename, legacy, 0600)
+	old := NewCache(nil)
+	defer old.Close()
+	if err := old.Load(filename); err != nil {
+		t.Errorf("expected a plain gob file to load, got %+v", err)
+	}
+	if item, _ := old.Get("a"); item != 1 {
+		t.Errorf("expected the item of the plain gob file, got %v", item)
+	}
+}
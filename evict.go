@@ -0,0 +1,461 @@
+package cache
+
+import (
+	"container/list"
+	"math/rand"
+	"sync"
+)
+
+// EvictionPolicy selects the items that are evicted
+// when the cache reaches MaxEntries or MaxBytes
+type EvictionPolicy int
+
+const (
+	// EvictTTL evicts the items closest to expiring
+	EvictTTL EvictionPolicy = iota
+	// EvictLRU evicts the least recently used items
+	EvictLRU
+	// EvictLFU evicts the least frequently used items
+	EvictLFU
+	// EvictCLOCK evicts items with the second-chance CLOCK algorithm
+	EvictCLOCK
+	// EvictRandom evicts items at random
+	EvictRandom
+	// EvictARC evicts items with the Adaptive Replacement Cache algorithm,
+	// balancing recency and frequency
+	EvictARC
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case EvictTTL:
+		return "ttl"
+	case EvictLRU:
+		return "lru"
+	case EvictLFU:
+		return "lfu"
+	case EvictCLOCK:
+		return "clock"
+	case EvictRandom:
+		return "random"
+	case EvictARC:
+		return "arc"
+	default:
+		return "unknown"
+	}
+}
+
+// Evictor chooses which items are evicted from the cache.
+// Add and Remove are called with the cache lock held, while Access
+// may be called concurrently by readers holding the read lock.
+// Buckets are never added to an Evictor.
+type Evictor interface {
+	Add(key uint64)    // an item was added to the cache
+	Access(key uint64) // an item was read from the cache
+	Remove(key uint64) // an item was removed from the cache
+	// Victim returns the next item to evict, passing over keys that skip
+	// reports true for. It returns false when there is nothing to evict.
+	Victim(skip func(key uint64) bool) (uint64, bool)
+}
+
+func newEvictor(policy EvictionPolicy, t *Cache) Evictor {
+	switch policy {
+	case EvictLRU:
+		return newLRUEvictor()
+	case EvictLFU:
+		return newLFUEvictor()
+	case EvictCLOCK:
+		return newClockEvictor()
+	case EvictRandom:
+		return newRandomEvictor()
+	case EvictARC:
+		return newARCEvictor()
+	}
+
+	return &ttlEvictor{cache: t}
+}
+
+// evict will remove items chosen by the evictor until the cache is
+// within MaxEntries and MaxBytes, never evicting the item at keep.
+// Evicted items are removed without invoking the expiration callbacks,
+// are passed to the OnEvict callbacks with ReasonCapacity, and are
+// written to the spill log when SpillDir is set.
+func (t *Cache) evict(keep uint64) {
+	skip := func(key uint64) bool {
+		return key == keep
+	}
+
+	var evicted []Slot
+	for t.overCapacity() {
+		slot, ok := t.evictVictim(skip)
+		if !ok {
+			break
+		}
+		evicted = append(evicted, slot)
+	}
+
+	// the lock is held, so the callbacks are run once it is released
+	if removed := t.removals(evicted, ReasonCapacity); removed != nil {
+		go t.notify(removed, ReasonCapacity, false)
+	}
+}
+
+// evictVictim will evict the next item chosen by the evictor, passing
+// over pinned keys and the keys skip reports true for, and return the
+// evicted slot. Items of a lower Priority are evicted first.
+// It returns false when there is nothing to evict.
+func (t *Cache) evictVictim(skip func(key uint64) bool) (Slot, bool) {
+	skip = t.unpinned(skip)
+	for {
+		victim, ok := t.victim(skip)
+		if !ok {
+			return Slot{}, false
+		}
+
+		idx, ok := t.keys[victim]
+		if !ok {
+			t.evictor.Remove(victim)
+			continue
+		}
+
+		slot := t.slots[idx]
+		t.spillSlot(idx)
+		t.remove(idx)
+		t.countEviction(slot.name)
+
+		return slot, true
+	}
+}
+
+func (t *Cache) overCapacity() bool {
+	if t.config.MaxEntries > 0 && len(t.keys) > t.config.MaxEntries {
+		return true
+	}
+
+	return t.config.MaxBytes > 0 && t.bytes > t.config.MaxBytes
+}
+
+/* ttl */
+
+// ttlEvictor evicts the items closest to expiring,
+// found with the expiry index of the cache
+type ttlEvictor struct {
+	cache *Cache
+}
+
+func (e *ttlEvictor) Add(key uint64)    {}
+func (e *ttlEvictor) Access(key uint64) {}
+func (e *ttlEvictor) Remove(key uint64) {}
+
+func (e *ttlEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	t := e.cache
+	victim, ok := t.expiry.first(func(x expiryEntry) bool {
+		slot := t.slots[x.idx]
+		if slot.empty || skip(slot.key) {
+			return false
+		}
+
+		_, bucket := slot.Item.(*Bucket)
+		return !bucket
+	})
+	if !ok {
+		return 0, false
+	}
+
+	return t.slots[victim.idx].key, true
+}
+
+/* lru */
+
+type lruEvictor struct {
+	order *list.List // most recently used at the front
+	items map[uint64]*list.Element
+	mu    *sync.Mutex
+}
+
+func newLRUEvictor() *lruEvictor {
+	return &lruEvictor{
+		order: list.New(),
+		items: make(map[uint64]*list.Element),
+		mu:    &sync.Mutex{},
+	}
+}
+
+func (e *lruEvictor) Add(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if el, ok := e.items[key]; ok {
+		e.order.MoveToFront(el)
+		return
+	}
+	e.items[key] = e.order.PushFront(key)
+}
+
+func (e *lruEvictor) Access(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if el, ok := e.items[key]; ok {
+		e.order.MoveToFront(el)
+	}
+}
+
+func (e *lruEvictor) Remove(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if el, ok := e.items[key]; ok {
+		e.order.Remove(el)
+		delete(e.items, key)
+	}
+}
+
+func (e *lruEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for el := e.order.Back(); el != nil; el = el.Prev() {
+		key := el.Value.(uint64)
+		if !skip(key) {
+			return key, true
+		}
+	}
+
+	return 0, false
+}
+
+/* lfu */
+
+// lfuEvictor estimates access frequencies with a count-min sketch
+// and evicts the least frequent of a sample of keys, the oldest of them
+// when their frequencies tie. Frequencies are
+// remembered for keys that have been evicted, so popular items are not
+// displaced by scans of keys that are only seen once.
+type lfuEvictor struct {
+	keys   map[uint64]uint64 // order in which each key was added
+	added  uint64
+	sketch *sketch
+	mu     *sync.Mutex
+}
+
+// lfuSamples is the number of keys compared when choosing a victim
+const lfuSamples = 8
+
+func newLFUEvictor() *lfuEvictor {
+	return &lfuEvictor{
+		keys:   make(map[uint64]uint64),
+		sketch: newSketch(defaultSketchWidth),
+		mu:     &sync.Mutex{},
+	}
+}
+
+func (e *lfuEvictor) Add(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.keys[key]; !ok {
+		e.added++
+		e.keys[key] = e.added
+	}
+	e.sketch.increment(key)
+}
+
+func (e *lfuEvictor) Access(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.sketch.increment(key)
+}
+
+func (e *lfuEvictor) Remove(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.keys, key)
+}
+
+func (e *lfuEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var victim uint64
+	var min uint8
+	var found bool
+	var sampled int
+	for key := range e.keys {
+		if skip(key) {
+			continue
+		}
+
+		count := e.sketch.estimate(key)
+		if !found || count < min || count == min && e.keys[key] < e.keys[victim] {
+			victim = key
+			min = count
+			found = true
+		}
+
+		sampled++
+		if sampled == lfuSamples {
+			break
+		}
+	}
+
+	return victim, found
+}
+
+/* clock */
+
+// clockEvictor gives recently accessed items a second chance:
+// the hand clears their reference bit instead of evicting them.
+type clockEvictor struct {
+	keys       []uint64
+	referenced []bool
+	positions  map[uint64]int
+	hand       int
+	mu         *sync.Mutex
+}
+
+func newClockEvictor() *clockEvictor {
+	return &clockEvictor{
+		positions: make(map[uint64]int),
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (e *clockEvictor) Add(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if pos, ok := e.positions[key]; ok {
+		e.referenced[pos] = true
+		return
+	}
+
+	e.positions[key] = len(e.keys)
+	e.keys = append(e.keys, key)
+	e.referenced = append(e.referenced, false)
+}
+
+func (e *clockEvictor) Access(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if pos, ok := e.positions[key]; ok {
+		e.referenced[pos] = true
+	}
+}
+
+func (e *clockEvictor) Remove(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pos, ok := e.positions[key]
+	if !ok {
+		return
+	}
+
+	last := len(e.keys) - 1
+	e.keys[pos] = e.keys[last]
+	e.referenced[pos] = e.referenced[last]
+	e.positions[e.keys[pos]] = pos
+	e.keys = e.keys[:last]
+	e.referenced = e.referenced[:last]
+	delete(e.positions, key)
+
+	if e.hand >= len(e.keys) {
+		e.hand = 0
+	}
+}
+
+func (e *clockEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// two sweeps clear every reference bit, so a victim is found if one exists
+	for i := 0; i < 2*len(e.keys); i++ {
+		if e.hand >= len(e.keys) {
+			e.hand = 0
+		}
+
+		pos := e.hand
+		e.hand++
+
+		if skip(e.keys[pos]) {
+			continue
+		}
+
+		if e.referenced[pos] {
+			e.referenced[pos] = false
+			continue
+		}
+
+		return e.keys[pos], true
+	}
+
+	return 0, false
+}
+
+/* random */
+
+type randomEvictor struct {
+	keys      []uint64
+	positions map[uint64]int
+	mu        *sync.Mutex
+}
+
+func newRandomEvictor() *randomEvictor {
+	return &randomEvictor{
+		positions: make(map[uint64]int),
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (e *randomEvictor) Add(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.positions[key]; ok {
+		return
+	}
+	e.positions[key] = len(e.keys)
+	e.keys = append(e.keys, key)
+}
+
+func (e *randomEvictor) Access(key uint64) {}
+
+func (e *randomEvictor) Remove(key uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pos, ok := e.positions[key]
+	if !ok {
+		return
+	}
+
+	last := len(e.keys) - 1
+	e.keys[pos] = e.keys[last]
+	e.positions[e.keys[pos]] = pos
+	e.keys = e.keys[:last]
+	delete(e.positions, key)
+}
+
+func (e *randomEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := len(e.keys)
+	if n == 0 {
+		return 0, false
+	}
+
+	start := rand.Intn(n)
+	for i := 0; i < n; i++ {
+		key := e.keys[(start+i)%n]
+		if !skip(key) {
+			return key, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testEvictionPolicy(t *testing.T, policy EvictionPolicy, evicted string) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     2,
+		EvictionPolicy: policy,
+	})
+
+	for _, key := range []string{"a", "b"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.Get("a")
+		if err != nil {
+			t.Errorf("error while getting key: %+v", err)
+		}
+	}
+
+	err := cache.Add("c", "c", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	if len(cache.keys) != 2 {
+		t.Errorf("cache held %d keys", len(cache.keys))
+	}
+
+	_, err = cache.Get(evicted)
+	if err != ErrDNE {
+		t.Errorf("key %s was not evicted: %+v", evicted, err)
+	}
+
+	_, err = cache.Get("c")
+	if err != nil {
+		t.Errorf("newly added key was evicted: %+v", err)
+	}
+}
+
+func TestEvictLRU(t *testing.T) {
+	testEvictionPolicy(t, EvictLRU, "b")
+}
+
+func TestEvictLFU(t *testing.T) {
+	testEvictionPolicy(t, EvictLFU, "b")
+}
+
+func TestEvictCLOCK(t *testing.T) {
+	testEvictionPolicy(t, EvictCLOCK, "b")
+}
+
+func TestEvictRandom(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     2,
+		EvictionPolicy: EvictRandom,
+	})
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	if len(cache.keys) != 2 {
+		t.Errorf("cache held %d keys", len(cache.keys))
+	}
+
+	_, err := cache.Get("d")
+	if err != nil {
+		t.Errorf("newly added key was evicted: %+v", err)
+	}
+}
+
+// fifoEvictor evicts the oldest added item
+type fifoEvictor struct {
+	keys []uint64
+}
+
+func (e *fifoEvictor) Add(key uint64)    { e.keys = append(e.keys, key) }
+func (e *fifoEvictor) Access(key uint64) {}
+
+func (e *fifoEvictor) Remove(key uint64) {
+	for i, k := range e.keys {
+		if k == key {
+			e.keys = append(e.keys[:i], e.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *fifoEvictor) Victim(skip func(key uint64) bool) (uint64, bool) {
+	for _, k := range e.keys {
+		if !skip(k) {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+func TestCustomEvictor(t *testing.T) {
+	evictor := &fifoEvictor{}
+	cache := NewCache(&CacheConfig{
+		MaxEntries: 2,
+		Evictor:    evictor,
+	})
+
+	for _, key := range []string{"a", "b", "c"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	_, err := cache.Get("a")
+	if err != ErrDNE {
+		t.Errorf("oldest key was not evicted: %+v", err)
+	}
+
+	if len(evictor.keys) != 2 {
+		t.Errorf("evictor tracked %d keys", len(evictor.keys))
+	}
+}
+
+func TestEvictLFUScanResistance(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     10,
+		EvictionPolicy: EvictLFU,
+	})
+
+	for i := 0; i < 5; i++ {
+		key := "hot-" + strconv.Itoa(i)
+		err := cache.Add(key, i, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+
+		for j := 0; j < 10; j++ {
+			_, err = cache.Get(key)
+			if err != nil {
+				t.Errorf("error while getting key: %+v", err)
+			}
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		err := cache.Add("scan-"+strconv.Itoa(i), i, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := cache.Get("hot-" + strconv.Itoa(i))
+		if err != nil {
+			t.Errorf("hot key was evicted by a scan: %+v", err)
+		}
+	}
+}
+
+func TestEvictLFUTies(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     4,
+		EvictionPolicy: EvictLFU,
+	})
+	defer cache.Close()
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	// keys read as often as each other are evicted oldest first
+	for _, key := range []string{"a", "b"} {
+		if _, err := cache.Get(key); err != ErrDNE {
+			t.Errorf("key %s was not evicted: %+v", key, err)
+		}
+	}
+
+	for _, key := range []string{"c", "d", "e", "f"} {
+		if _, err := cache.Get(key); err != nil {
+			t.Errorf("key %s was evicted: %+v", key, err)
+		}
+	}
+}
+
+// benchmarkHitRatio replays a zipfian workload interleaved with
+// scans of one-time keys and reports the resulting hit ratio.
+func benchmarkHitRatio(b *testing.B, policy EvictionPolicy) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     1000,
+		EvictionPolicy: policy,
+	})
+
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 100000)
+
+	var scan int
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := strconv.FormatUint(zipf.Uint64(), 10)
+		if i%4 == 0 {
+			key = "scan-" + strconv.Itoa(scan)
+			scan++
+		}
+
+		_, err := cache.Get(key)
+		if err == ErrDNE {
+			cache.Add(key, i, 10*time.Minute)
+		}
+	}
+
+	b.ReportMetric(cache.Stats().HitRate(), "hit-ratio")
+}
+
+func BenchmarkHitRatioLRU(b *testing.B) {
+	benchmarkHitRatio(b, EvictLRU)
+}
+
+func BenchmarkHitRatioLFU(b *testing.B) {
+	benchmarkHitRatio(b, EvictLFU)
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrCallbackTimeout is reported to OnError when an expiration
+	// callback runs for longer than ExpireTimeout
+	ErrCallbackTimeout = errors.New("expiration callback timed out")
+
+	defaultExpireWorkers = 1
+	defaultExpireQueue   = 1024
+)
+
+// OnError is a function that will be called with the errors
+// from work the cache does in the background.
+type OnError func(err error)
+
+// CallbackPanic is reported to OnError when an expiration callback panics
+type CallbackPanic struct {
+	Value interface{} // the value passed to panic
+}
+
+func (p *CallbackPanic) Error() string {
+	return fmt.Sprintf("expiration callback panicked: %v", p.Value)
+}
+
+// CleanerPanic is reported to OnError when a clean of the cache panics,
+// for example in a custom Evictor. The cleaner keeps running.
+type CleanerPanic struct {
+	Value interface{} // the value passed to panic
+}
+
+func (p *CleanerPanic) Error() string {
+	return fmt.Sprintf("cache cleaner panicked: %v", p.Value)
+}
+
+// expirer runs the expiration callbacks on a pool of workers, so that a
+// slow or panicking callback cannot stall or stop the cleaner.
+type expirer struct {
+	jobs    chan func()
+	timeout time.Duration
+	onError OnError
+	closed  bool
+	idle    int32 // workers waiting for a callback
+	workers *sync.WaitGroup
+	sending *sync.WaitGroup // dispatches queueing callbacks
+	mu      *sync.RWMutex
+}
+
+func newExpirer(workers int, timeout time.Duration, onError OnError) *expirer {
+	e := &expirer{
+		jobs:    make(chan func(), defaultExpireQueue),
+		timeout: timeout,
+		onError: onError,
+		idle:    int32(workers),
+		workers: &sync.WaitGroup{},
+		sending: &sync.WaitGroup{},
+		mu:      &sync.RWMutex{},
+	}
+
+	e.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer e.workers.Done()
+			for fn := range e.jobs {
+				atomic.AddInt32(&e.idle, -1)
+				e.run(fn)
+				atomic.AddInt32(&e.idle, 1)
+			}
+		}()
+	}
+
+	return e
+}
+
+// dispatch will queue the callbacks on the workers, waiting until
+// they have all run if wait is set. Once the pool is closed the
+// callbacks are run by the caller instead.
+//
+// A callback can remove items itself, dispatching more callbacks from
+// a worker, which must not wait on the workers or on a full queue that
+// only they drain. So the caller runs the callbacks when it would wait
+// with every worker busy, and runs those that do not fit in the queue.
+func (e *expirer) dispatch(fns []func(), wait bool) {
+	e.mu.RLock()
+	if e.closed {
+		e.mu.RUnlock()
+		for _, fn := range fns {
+			e.run(fn)
+		}
+		return
+	}
+
+	// the lock is not held while queueing, close waits for the
+	// callbacks being queued before it closes the queue instead
+	e.sending.Add(1)
+	e.mu.RUnlock()
+	defer e.sending.Done()
+
+	if wait && atomic.LoadInt32(&e.idle) == 0 {
+		for _, fn := range fns {
+			e.run(fn)
+		}
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, fn := range fns {
+		if wait {
+			wg.Add(1)
+			fn := fn
+			job := func() {
+				defer wg.Done()
+				fn()
+			}
+			select {
+			case e.jobs <- job:
+			default:
+				e.run(job)
+			}
+			continue
+		}
+
+		select {
+		case e.jobs <- fn:
+		default:
+			e.run(fn)
+		}
+	}
+	wg.Wait()
+}
+
+// close will stop the workers once the queued callbacks have run
+func (e *expirer) close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	e.mu.Unlock()
+
+	e.sending.Wait()
+	close(e.jobs)
+}
+
+// wait will return once the workers have run the queued
+// callbacks and stopped, after the pool is closed
+func (e *expirer) wait() {
+	e.workers.Wait()
+}
+
+// run will call the callback, reporting a panic or a timeout to OnError.
+// A callback that times out is abandoned and keeps running on its own.
+func (e *expirer) run(fn func()) {
+	if e.timeout <= 0 {
+		e.call(fn)
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.call(fn)
+	}()
+
+	timer := time.NewTimer(e.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		e.report(ErrCallbackTimeout)
+	}
+}
+
+func (e *expirer) call(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.report(&CallbackPanic{Value: r})
+		}
+	}()
+
+	fn()
+}
+
+func (e *expirer) report(err error) {
+	if e.onError != nil {
+		e.onError(err)
+	}
+}
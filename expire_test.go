@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpirePanicIsolation(t *testing.T) {
+	errs := make(chan error, 10)
+	var expired int32
+	cache := NewCache(&CacheConfig{
+		OnExpires: func(item interface{}) {
+			if item == "panic" {
+				panic("callback failed")
+			}
+			atomic.AddInt32(&expired, 1)
+		},
+		OnError: func(err error) {
+			errs <- err
+		},
+		CleanDuration: 10 * time.Millisecond,
+	})
+	defer cache.Close()
+
+	cache.Add("a", "panic", time.Millisecond)
+
+	select {
+	case err := <-errs:
+		if p, ok := err.(*CallbackPanic); !ok || p.Value != "callback failed" {
+			t.Errorf("unexpected error: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic was not reported")
+	}
+
+	cache.Add("b", "ok", time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&expired); n != 1 {
+		t.Errorf("cleaner stopped after a panicking callback: %d expired", n)
+	}
+}
+
+func TestExpireTimeout(t *testing.T) {
+	errs := make(chan error, 10)
+	release := make(chan struct{})
+	var expired int32
+	cache := NewCache(&CacheConfig{
+		OnExpires: func(item interface{}) {
+			if item == "slow" {
+				<-release
+			}
+			atomic.AddInt32(&expired, 1)
+		},
+		OnError: func(err error) {
+			errs <- err
+		},
+		ExpireTimeout: 20 * time.Millisecond,
+		CleanDuration: 10 * time.Millisecond,
+	})
+	defer cache.Close()
+	defer close(release)
+
+	cache.Add("a", "slow", time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	cache.Add("b", "fast", time.Millisecond)
+
+	select {
+	case err := <-errs:
+		if err != ErrCallbackTimeout {
+			t.Errorf("unexpected error: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout was not reported")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&expired); n != 1 {
+		t.Errorf("slow callback blocked the workers: %d expired", n)
+	}
+}
+
+func TestExpireWorkers(t *testing.T) {
+	var running, peak int32
+	cache := NewCache(&CacheConfig{
+		OnExpires: func(item interface{}) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		},
+		ExpireWorkers: 3,
+		ExpireOnFlush: true,
+	})
+	defer cache.Close()
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		cache.Add(key, key, time.Hour)
+	}
+	cache.Flush()
+
+	if p := atomic.LoadInt32(&peak); p != 3 {
+		t.Errorf("%d callbacks ran at once", p)
+	}
+
+	if n := atomic.LoadInt32(&running); n != 0 {
+		t.Errorf("flush returned with %d callbacks running", n)
+	}
+}
+
+func TestCacheClose(t *testing.T) {
+	var expired int32
+	cache := NewCache(&CacheConfig{
+		OnExpires: func(item interface{}) {
+			atomic.AddInt32(&expired, 1)
+		},
+		CleanDuration: 10 * time.Millisecond,
+		ExpireOnFlush: true,
+	})
+
+	cache.Close()
+	cache.Close()
+
+	cache.Add("a", "a", time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&expired); n != 0 {
+		t.Errorf("cleaner ran after the cache was closed")
+	}
+
+	cache.Flush()
+
+	if n := atomic.LoadInt32(&expired); n != 1 {
+		t.Errorf("flush callbacks did not run after the cache was closed")
+	}
+}
+
+// panicEvictor panics the first time an item is removed from it
+type panicEvictor struct {
+	Evictor
+	panicked int32
+}
+
+func (e *panicEvictor) Remove(key uint64) {
+	if atomic.CompareAndSwapInt32(&e.panicked, 0, 1) {
+		panic("evictor failed")
+	}
+	e.Evictor.Remove(key)
+}
+
+func TestCleanerPanicRecovery(t *testing.T) {
+	errs := make(chan error, 10)
+	cache := NewCache(&CacheConfig{
+		Evictor: &panicEvictor{Evictor: newLRUEvictor()},
+		OnError: func(err error) {
+			errs <- err
+		},
+		CleanDuration: 10 * time.Millisecond,
+	})
+	defer cache.Close()
+
+	cache.Add("a", 1, time.Millisecond)
+
+	select {
+	case err := <-errs:
+		if p, ok := err.(*CleanerPanic); !ok || p.Value != "evictor failed" {
+			t.Errorf("unexpected error: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic was not reported")
+	}
+
+	cache.Add("b", 2, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	if n := cache.Stats().Entries; n != 0 {
+		t.Errorf("cleaner stopped after a panic: %d entries left", n)
+	}
+}
+
+func TestCleanerWithoutCallbacks(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+	})
+	defer cache.Close()
+
+	cache.Add("a", 1, time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	if n := cache.Stats().Expirations; n != 1 {
+		t.Errorf("%d items were expired", n)
+	}
+}
+
+func TestExpireFromCallback(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	var cache *Cache
+	var expired int32
+	cache = NewCache(&CacheConfig{
+		Clock:          clock,
+		DisableCleaner: true,
+		ExpireWorkers:  1,
+		ExpireOnFlush:  true,
+		OnExpires: func(item interface{}) {
+			atomic.AddInt32(&expired, 1)
+			if item == "short" {
+				// waits on the callbacks for the flushed items
+				// from the only worker
+				cache.Flush()
+			}
+		},
+	})
+	defer cache.Close()
+
+	cache.Add("short", "short", time.Minute)
+	cache.Add("long", "long", time.Hour)
+	clock.Advance(2 * time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.DeleteExpired()
+		cache.expirer.close()
+		cache.expirer.wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a flush from an expiration callback deadlocked")
+	}
+
+	if n := atomic.LoadInt32(&expired); n != 2 {
+		t.Errorf("expected the callbacks of both items to run, got %d", n)
+	}
+}
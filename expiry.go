@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// expiryIndex is a min-heap of the occupied slots ordered by
+// expiration, so that the cleaner and ExpiringWithin only visit
+// the items that are due rather than scanning every slot.
+type expiryIndex struct {
+	entries []expiryEntry
+	pos     map[int]int // position of each slot in entries
+}
+
+type expiryEntry struct {
+	idx int
+	at  time.Time
+}
+
+func newExpiryIndex() *expiryIndex {
+	return &expiryIndex{pos: make(map[int]int)}
+}
+
+func (x *expiryIndex) Len() int {
+	return len(x.entries)
+}
+
+func (x *expiryIndex) Less(i, j int) bool {
+	return x.entries[i].at.Before(x.entries[j].at)
+}
+
+func (x *expiryIndex) Swap(i, j int) {
+	x.entries[i], x.entries[j] = x.entries[j], x.entries[i]
+	x.pos[x.entries[i].idx] = i
+	x.pos[x.entries[j].idx] = j
+}
+
+func (x *expiryIndex) Push(v interface{}) {
+	e := v.(expiryEntry)
+	x.pos[e.idx] = len(x.entries)
+	x.entries = append(x.entries, e)
+}
+
+func (x *expiryIndex) Pop() interface{} {
+	e := x.entries[len(x.entries)-1]
+	x.entries = x.entries[:len(x.entries)-1]
+	delete(x.pos, e.idx)
+	return e
+}
+
+// set will add the slot to the index or move it to its new expiration
+func (x *expiryIndex) set(idx int, at time.Time) {
+	if i, ok := x.pos[idx]; ok {
+		x.entries[i].at = at
+		heap.Fix(x, i)
+		return
+	}
+
+	heap.Push(x, expiryEntry{idx: idx, at: at})
+}
+
+func (x *expiryIndex) remove(idx int) {
+	if i, ok := x.pos[idx]; ok {
+		heap.Remove(x, i)
+	}
+}
+
+// next will return the entry expiring first
+func (x *expiryIndex) next() (expiryEntry, bool) {
+	if len(x.entries) == 0 {
+		return expiryEntry{}, false
+	}
+
+	return x.entries[0], true
+}
+
+// walk will call fn with the entries in heap order, only
+// descending below the entries for which fn returns true
+func (x *expiryIndex) walk(fn func(e expiryEntry) bool) {
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(x.entries) {
+			continue
+		}
+
+		if fn(x.entries[i]) {
+			stack = append(stack, 2*i+1, 2*i+2)
+		}
+	}
+}
+
+// first will return the entry expiring first among those fn reports
+// true for. Entries are visited in order of expiration, from a frontier
+// of the heap below the entries passed over, so finding the entry costs
+// O(k log k) for the k entries passed over before it.
+func (x *expiryIndex) first(fn func(e expiryEntry) bool) (expiryEntry, bool) {
+	if len(x.entries) == 0 {
+		return expiryEntry{}, false
+	}
+
+	f := &expiryFrontier{index: x, pos: []int{0}}
+	for f.Len() > 0 {
+		i := heap.Pop(f).(int)
+		if fn(x.entries[i]) {
+			return x.entries[i], true
+		}
+
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(x.entries) {
+				heap.Push(f, child)
+			}
+		}
+	}
+
+	return expiryEntry{}, false
+}
+
+// expiryFrontier is a min-heap of positions in an expiryIndex
+type expiryFrontier struct {
+	index *expiryIndex
+	pos   []int
+}
+
+func (f *expiryFrontier) Len() int {
+	return len(f.pos)
+}
+
+func (f *expiryFrontier) Less(i, j int) bool {
+	return f.index.entries[f.pos[i]].at.Before(f.index.entries[f.pos[j]].at)
+}
+
+func (f *expiryFrontier) Swap(i, j int) {
+	f.pos[i], f.pos[j] = f.pos[j], f.pos[i]
+}
+
+func (f *expiryFrontier) Push(v interface{}) {
+	f.pos = append(f.pos, v.(int))
+}
+
+func (f *expiryFrontier) Pop() interface{} {
+	i := f.pos[len(f.pos)-1]
+	f.pos = f.pos[:len(f.pos)-1]
+	return i
+}
+
+// expireAt will set the expiration of the item in the slot
+func (t *Cache) expireAt(idx int, expiresAt time.Time) {
+	if !t.slots[idx].held.IsZero() {
+		// pinned, so the expiration only applies once it is unpinned
+		t.slots[idx].held = expiresAt
+		expiresAt = neverExpires
+	}
+
+	t.slots[idx].ExpiresAt = expiresAt
+	t.slots[idx].modified = t.now()
+	t.expiry.set(idx, expiresAt)
+	t.gen++
+
+	if t.nextExp.IsZero() || t.nextExp.After(expiresAt) {
+		t.nextExp = expiresAt
+	}
+}
+
+// expiring reports whether the slot holds an item that can expire,
+// as opposed to buckets, soft deleted items and items without a ttl
+func (t *Cache) expiring(idx int) bool {
+	slot := t.slots[idx]
+	if slot.deleted || slot.ExpiresAt.Equal(neverExpires) {
+		return false
+	}
+
+	_, ok := slot.Item.(*Bucket)
+	return !ok
+}
+
+// ExpiringWithin will return the keys of the items that will
+// expire within the duration, in order of their expiration.
+func (t *Cache) ExpiringWithin(d time.Duration) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+	limit := now.Add(d)
+
+	var entries []expiryEntry
+	t.expiry.walk(func(e expiryEntry) bool {
+		if e.at.After(limit) {
+			return false
+		}
+
+		if e.at.After(now) && t.expiring(e.idx) {
+			entries = append(entries, e)
+		}
+		return true
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].at.Before(entries[j].at)
+	})
+
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = t.slots[e.idx].name
+	}
+
+	return keys
+}
+
+// NextExpiration will return the time at which the next item will
+// expire. It returns false if no item in the cache will expire.
+func (t *Cache) NextExpiration() (time.Time, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+
+	var next time.Time
+	t.expiry.walk(func(e expiryEntry) bool {
+		if !next.IsZero() && !e.at.Before(next) {
+			return false
+		}
+
+		if e.at.After(now) && t.expiring(e.idx) {
+			next = e.at
+			return false
+		}
+		return true
+	})
+
+	return next, !next.IsZero()
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiringWithin(t *testing.T) {
+	cache := NewCache(nil)
+
+	cache.Add("c", 3, 3*time.Minute)
+	cache.Add("a", 1, time.Minute)
+	cache.Add("b", 2, 2*time.Minute)
+	cache.Add("forever", 0, 0)
+	cache.Add("later", 0, time.Hour)
+	cache.Add("hidden", 0, time.Minute)
+	cache.SoftDelete("hidden")
+	cache.Bucket("bucket").Add("d", 4, 90*time.Second)
+
+	keys := cache.ExpiringWithin(5 * time.Minute)
+	expected := []string{"a", "bucket:d", "b", "c"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	cache.Touch("a", 10*time.Minute)
+	keys = cache.ExpiringWithin(150 * time.Second)
+	expected = []string{"bucket:d", "b"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v after Touch, got %v", expected, keys)
+	}
+}
+
+func TestNextExpiration(t *testing.T) {
+	cache := NewCache(nil)
+
+	if _, ok := cache.NextExpiration(); ok {
+		t.Errorf("expected no expiration in an empty cache")
+	}
+
+	cache.Add("forever", 0, 0)
+	if _, ok := cache.NextExpiration(); ok {
+		t.Errorf("expected no expiration for items without a ttl")
+	}
+
+	for i := 1; i <= 50; i++ {
+		cache.Add(strconv.Itoa(i), i, time.Duration(i)*time.Minute)
+	}
+
+	next, ok := cache.NextExpiration()
+	if !ok || next.Sub(time.Now()) > time.Minute {
+		t.Errorf("expected the next expiration within a minute, got %v", next)
+	}
+
+	cache.Delete("1")
+	next, ok = cache.NextExpiration()
+	if d := next.Sub(time.Now()); !ok || d < time.Minute || d > 2*time.Minute {
+		t.Errorf("expected the next expiration in about two minutes, got %v", d)
+	}
+}
+
+func TestCleanUsesExpiryIndex(t *testing.T) {
+	cache := NewCache(&CacheConfig{CleanDuration: 5 * time.Millisecond})
+
+	for i := 0; i < 100; i++ {
+		ttl := 10 * time.Minute
+		if i%2 == 0 {
+			ttl = 20 * time.Millisecond
+		}
+		cache.Add(strconv.Itoa(i), i, ttl)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	if len(cache.keys) != 50 || cache.expiry.Len() != 50 {
+		t.Errorf("expected 50 items left, got %d keys and %d indexed", len(cache.keys), cache.expiry.Len())
+	}
+
+	for idx, pos := range cache.expiry.pos {
+		if cache.expiry.entries[pos].idx != idx || cache.slots[idx].empty {
+			t.Errorf("expiry index out of sync at slot %d", idx)
+		}
+	}
+}
+
+func TestExpiryIndexFirst(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	x := newExpiryIndex()
+	for i := 0; i < 1000; i++ {
+		// a permutation of the minutes, so that every entry is distinct
+		x.set(i, now.Add(time.Duration(i*7919%1000)*time.Minute))
+	}
+
+	for _, mod := range []int{1, 2, 3, 10, 999} {
+		e, ok := x.first(func(e expiryEntry) bool {
+			return e.idx%mod == mod-1
+		})
+
+		var want expiryEntry
+		for _, entry := range x.entries {
+			if entry.idx%mod == mod-1 && (want.at.IsZero() || entry.at.Before(want.at)) {
+				want = entry
+			}
+		}
+
+		if !ok || e != want {
+			t.Errorf("expected %+v to expire first of every %d entries, got %+v", want, mod, e)
+		}
+	}
+
+	if _, ok := x.first(func(e expiryEntry) bool { return false }); ok {
+		t.Errorf("expected no entry when every entry is passed over")
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"errors"
+	"expvar"
+)
+
+// ErrExpvarExists is returned by PublishExpvar when
+// an expvar variable is already published by the name
+var ErrExpvarExists = errors.New("expvar name already published")
+
+// PublishExpvar will publish the statistics of the cache as an expvar
+// variable by the name, so that they are served on /debug/vars with
+// the rest of the process's variables. The statistics are read each
+// time the variable is served. Since expvar variables cannot be
+// removed, the cache is referenced for the life of the process.
+func (t *Cache) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return ErrExpvarExists
+	}
+
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return t.Stats().expvar()
+	}))
+
+	return nil
+}
+
+// expvar will return the statistics as a map of their
+// snake cased names, which encodes to a JSON object
+func (s Stats) expvar() map[string]interface{} {
+	vars := map[string]interface{}{
+		"hits":        s.Hits,
+		"misses":      s.Misses,
+		"hit_rate":    s.HitRate(),
+		"evictions":   s.Evictions,
+		"rejections":  s.Rejections,
+		"expirations": s.Expirations,
+		"collisions":  s.Collisions,
+		"faults":      s.Faults,
+		"spilled":     s.Spilled,
+		"pinned":      s.Pinned,
+		"entries":     s.Entries,
+		"bytes":       s.Bytes,
+	}
+
+	if s.AgeHistogram != nil {
+		vars["age_histogram"] = s.AgeHistogram.expvar()
+		vars["ttl_histogram"] = s.TTLHistogram.expvar()
+	}
+
+	return vars
+}
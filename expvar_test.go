@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	cache := NewCache(nil)
+	defer cache.Close()
+
+	if err := cache.PublishExpvar("test-cache"); err != nil {
+		t.Fatalf("PublishExpvar error: %+v", err)
+	}
+
+	if err := cache.PublishExpvar("test-cache"); err != ErrExpvarExists {
+		t.Errorf("expected ErrExpvarExists, got %+v", err)
+	}
+
+	cache.Add("key", "value", time.Minute)
+	cache.Get("key")
+	cache.Get("missing")
+
+	var stats map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get("test-cache").String()), &stats); err != nil {
+		t.Fatalf("Unmarshal error: %+v", err)
+	}
+
+	if stats["hits"] != 1.0 || stats["misses"] != 1.0 || stats["hit_rate"] != 0.5 || stats["entries"] != 1.0 {
+		t.Errorf("unexpected published stats %+v", stats)
+	}
+}
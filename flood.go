@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"hash/maphash"
+	"time"
+)
+
+var (
+	// ErrHashFlood is returned for hash collisions while the cache is being flooded
+	ErrHashFlood = errors.New("hash flooding detected")
+
+	defaultFloodWindow = 1 * time.Minute
+)
+
+// OnHashFlood is a function that will be called with the number of
+// hash collisions seen in the current window when flooding is detected.
+type OnHashFlood func(collisions int)
+
+// Collisions returns the number of hash collisions
+// between distinct keys seen by the cache.
+func (t *Cache) Collisions() uint64 {
+	return t.Stats().Collisions
+}
+
+// collision will record a hash collision between two distinct keys
+// and return the error for it. Once FloodThreshold collisions occur
+// within FloodWindow the OnHashFlood alarm is raised, the cache
+// optionally switches to a seeded hasher, and ErrHashFlood is returned.
+func (t *Cache) collision() error {
+	t.collisions++
+	if t.config.FloodThreshold <= 0 {
+		return ErrCollision
+	}
+
+	now := t.now()
+	if now.Sub(t.floodStart) > t.config.FloodWindow {
+		t.floodStart = now
+		t.floodCount = 0
+	}
+	t.floodCount++
+
+	if t.floodCount < t.config.FloodThreshold {
+		return ErrCollision
+	}
+
+	if t.floodCount == t.config.FloodThreshold {
+		if t.config.OnHashFlood != nil {
+			go t.config.OnHashFlood(t.floodCount)
+		}
+
+		if t.config.AutoReseed && t.seed == nil {
+			t.reseed()
+		}
+	}
+
+	return ErrHashFlood
+}
+
+// hash will return the hashed key, using the configured Hasher
+// until the cache has switched to a seeded hasher. The lock must
+// be held, since with a Hasher128 the key depends on the keys in use.
+func (t *Cache) hash(key string) uint64 {
+	return t.hashIn(t.keys, key)
+}
+
+// hashIn will return the hashed key of the name among the keys
+func (t *Cache) hashIn(keys map[uint64]int, name string) uint64 {
+	_, wide := t.config.Hasher.(Hasher128)
+	if t.seed != nil {
+		var hasher maphash.Hash
+		hasher.SetSeed(*t.seed)
+		hasher.WriteString(name)
+		hi := hasher.Sum64()
+		if !wide {
+			return hi
+		}
+
+		// extending the input gives an independent second hash
+		hasher.WriteByte(0)
+		return t.slotKey(keys, name, hi, hasher.Sum64())
+	}
+
+	if wide {
+		hi, lo := t.config.Hasher.(Hasher128).Sum128(name)
+		return t.slotKey(keys, name, hi, lo)
+	}
+
+	return t.config.Hasher.Sum64(name)
+}
+
+// reseed will switch the cache to a randomly seeded hasher
+// and rehash every key, including the keys listed in buckets.
+// Items whose keys collide under the new hasher are removed,
+// along with the items depending on them, and passed to the
+// OnEvict callbacks with ReasonCollision.
+func (t *Cache) reseed() {
+	seed := maphash.MakeSeed()
+	t.seed = &seed
+
+	keys := make(map[uint64]int, len(t.keys))
+	var colliding []int
+	for i, slot := range t.slots {
+		if slot.empty {
+			continue
+		}
+
+		hk := t.hashIn(keys, slot.name)
+		if _, ok := keys[hk]; ok {
+			colliding = append(colliding, i)
+			continue
+		}
+		keys[hk] = i
+	}
+
+	// the colliding items are removed under their old keys,
+	// before the keys of the others are changed
+	var dropped []Slot
+	var dependents []string
+	for _, i := range colliding {
+		dropped = append(dropped, t.slots[i])
+		dependents = append(dependents, t.dependents(t.slots[i].name)...)
+		t.remove(i)
+	}
+
+	moved := make(map[uint64]uint64, len(keys))
+	for hk, i := range keys {
+		slot := t.slots[i]
+		moved[slot.key] = hk
+		if _, ok := slot.Item.(*Bucket); !ok {
+			t.evictor.Remove(slot.key)
+			t.evictor.Add(hk)
+		}
+		t.slots[i].key = hk
+	}
+	t.keys = keys
+
+	if t.spill != nil {
+		for old, hk := range t.spill.rekey(t.hash) {
+			moved[old] = hk
+		}
+	}
+
+	for _, slot := range t.slots {
+		if b, ok := slot.Item.(*Bucket); ok {
+			for i, k := range b.list {
+				if hk, ok := moved[k]; ok {
+					b.list[i] = hk
+				}
+			}
+		}
+	}
+
+	t.cascade(dependents)
+
+	// the lock is held, so the callbacks are run once it is released
+	if removed := t.removals(dropped, ReasonCollision); removed != nil {
+		go t.notify(removed, ReasonCollision, false)
+	}
+}
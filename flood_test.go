@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// collide will add an item under the hash of key
+// but with a different original key.
+func collide(t *testing.T, cache *Cache, key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	hk := cache.hash(key)
+
+	err := cache.add(hk, "colliding-"+key, "value", time.Now().UTC().Add(10*time.Minute))
+	if err != nil {
+		t.Errorf("error adding colliding key: %+v", err)
+	}
+}
+
+func TestCacheCollisions(t *testing.T) {
+	cache := NewCache(nil)
+	collide(t, cache, "key")
+
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != ErrCollision {
+		t.Errorf("did not return collision error for colliding key: %+v", err)
+	}
+
+	if cache.Collisions() != 1 {
+		t.Errorf("cache recorded %d collisions", cache.Collisions())
+	}
+}
+
+func TestCacheHashFlood(t *testing.T) {
+	alarms := make(chan int, 1)
+	cache := NewCache(&CacheConfig{
+		FloodThreshold: 3,
+		OnHashFlood: func(collisions int) {
+			alarms <- collisions
+		},
+		AutoReseed: true,
+	})
+
+	b := cache.Bucket("my-bucket")
+	err := b.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	var errs []error
+	for i := 0; i < 3; i++ {
+		key := strconv.Itoa(i)
+		collide(t, cache, key)
+		errs = append(errs, cache.Add(key, "value", 10*time.Minute))
+	}
+
+	if errs[0] != ErrCollision || errs[2] != ErrHashFlood {
+		t.Errorf("did not return flood error when flooding was detected: %+v", errs)
+	}
+
+	select {
+	case n := <-alarms:
+		if n != 3 {
+			t.Errorf("alarm was raised with %d collisions", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hash flood alarm was not raised")
+	}
+
+	if cache.seed == nil {
+		t.Error("cache did not switch to a seeded hasher")
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := cache.Get("colliding-" + strconv.Itoa(i))
+		if err != nil {
+			t.Errorf("error while getting rehashed key: %+v", err)
+		}
+
+		if value.(string) != "value" {
+			t.Errorf("returned value was %s", value)
+		}
+	}
+
+	value, err := b.Get("key")
+	if err != nil {
+		t.Errorf("error while getting rehashed bucket key: %+v", err)
+	}
+
+	if value.(string) != "value" {
+		t.Errorf("returned value was %s", value)
+	}
+}
+
+func TestReseedCollisions(t *testing.T) {
+	var evicted removalLog
+	cache := NewCache(&CacheConfig{OnEvict: evicted.record})
+	defer cache.Close()
+
+	cache.Add("key", "value", 10*time.Minute)
+	cache.AddTagged("child", "value", 10*time.Minute, "tag")
+	err := cache.AddDependency("child", "key")
+	if err != nil {
+		t.Errorf("error adding dependency: %+v", err)
+	}
+
+	// a second item by the name at another key collides
+	// with the first once the keys are rehashed
+	cache.mu.Lock()
+	cache.add(cache.hash("key")+1, "key", "stale", time.Now().UTC().Add(10*time.Minute))
+	cache.reseed()
+	cache.mu.Unlock()
+
+	var records []removalRecord
+	waitFor(func() bool {
+		records = append(records, evicted.take()...)
+		return len(records) > 0
+	})
+	if len(records) != 1 || records[0] != (removalRecord{"key", ReasonCollision}) {
+		t.Errorf("expected the colliding item to be passed to OnEvict, got %+v", records)
+	}
+
+	value, err := cache.Get("key")
+	if err != nil || value.(string) != "value" {
+		t.Errorf("expected the first item to be kept, got %v: %+v", value, err)
+	}
+
+	_, err = cache.Get("child")
+	if err != ErrDNE {
+		t.Errorf("expected the dependent of the colliding item to be removed: %+v", err)
+	}
+
+	if n := cache.InvalidateTag("tag"); n != 0 {
+		t.Errorf("tag still listed %d removed items", n)
+	}
+
+	if n := cache.Stats().Entries; n != 1 {
+		t.Errorf("cache holds %d items", n)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build go1.18
+// +build go1.18
+
+package cache
+
+import "testing"
+
+func FuzzOperations(f *testing.F) {
+	f.Add([]byte{0, 1, 4, 1, 6, 2, 2, 1})
+	f.Add([]byte{4, 3, 10, 3, 6, 2, 11, 3, 15, 8})
+	f.Add([]byte{0, 0, 0, 1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7, 0, 8, 14, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		runOps(t, data)
+	})
+}
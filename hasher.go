@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"hash/maphash"
+)
+
+// Hasher hashes the keys of a cache. It must be safe for concurrent
+// use and return the same hash for a key for the life of the cache.
+type Hasher interface {
+	Sum64(key string) uint64
+}
+
+// Hasher128 is a Hasher producing 128-bit hashes. The cache still
+// indexes keys by 64-bit values, and uses the two 64-bit halves of a
+// key's hash as two places the key can be stored: a key whose first
+// half is taken by another key is stored under its second half. A key
+// returns ErrCollision only when both halves are taken by other keys,
+// which need not be the same key.
+type Hasher128 interface {
+	Hasher
+	Sum128(key string) (hi, lo uint64)
+}
+
+// FNVHasher hashes keys with FNV-1a. It is the default hasher of a
+// cache, and is fast but predictable, so keys chosen by an attacker
+// can be made to collide.
+type FNVHasher struct{}
+
+// Sum64 will return the 64-bit FNV-1a hash of the key
+func (FNVHasher) Sum64(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// FNV128Hasher hashes keys with 128-bit FNV-1a, so that a key
+// practically never collides by accident under both of its halves
+type FNV128Hasher struct{}
+
+// Sum64 will return the 64-bit FNV-1a hash of the key
+func (FNV128Hasher) Sum64(key string) uint64 {
+	return FNVHasher{}.Sum64(key)
+}
+
+// Sum128 will return the 128-bit FNV-1a hash of the key
+func (FNV128Hasher) Sum128(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+}
+
+// MaphashHasher hashes keys with hash/maphash under random seeds, so
+// that which keys collide cannot be predicted, resisting hash flooding.
+type MaphashHasher struct {
+	seeds [2]maphash.Seed
+}
+
+// NewMaphashHasher will create and return a
+// pointer to a new randomly seeded MaphashHasher
+func NewMaphashHasher() *MaphashHasher {
+	return &MaphashHasher{
+		seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+	}
+}
+
+// Sum64 will return the seeded hash of the key
+func (m *MaphashHasher) Sum64(key string) uint64 {
+	return m.sum(0, key)
+}
+
+// Sum128 will return two independently seeded hashes of the key
+func (m *MaphashHasher) Sum128(key string) (uint64, uint64) {
+	return m.sum(0, key), m.sum(1, key)
+}
+
+func (m *MaphashHasher) sum(seed int, key string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(m.seeds[seed])
+	h.WriteString(key)
+	return h.Sum64()
+}
+
+// slotKey will return which of the two halves of a 128-bit hash the
+// name is stored under in keys: the half already holding the name,
+// otherwise the first half unless it is taken by another key.
+func (t *Cache) slotKey(keys map[uint64]int, name string, hi, lo uint64) uint64 {
+	idx, ok := keys[hi]
+	if ok && t.slots[idx].name == name {
+		return hi
+	}
+
+	if idx, ok := keys[lo]; ok && t.slots[idx].name == name {
+		return lo
+	}
+
+	if !ok {
+		return hi
+	}
+
+	return lo
+}
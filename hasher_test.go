@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// constHasher hashes every key to the same first 64 bits
+type constHasher struct{}
+
+func (constHasher) Sum64(key string) uint64 {
+	return 1
+}
+
+// lenHasher128 collides on the first 64 bits, and on
+// the full 128-bit hash for keys of the same length
+type lenHasher128 struct {
+	constHasher
+}
+
+func (lenHasher128) Sum128(key string) (uint64, uint64) {
+	return 1, uint64(len(key))
+}
+
+func TestCustomHasher(t *testing.T) {
+	cache, err := NewCacheWithOptions(WithHasher(constHasher{}))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	cache.Add("first", 1, time.Minute)
+	if err := cache.Add("second", 2, time.Minute); err != ErrCollision {
+		t.Errorf("expected the custom hasher to be used, got %+v", err)
+	}
+}
+
+func TestHasher128(t *testing.T) {
+	cache, err := NewCacheWithOptions(WithHasher(lenHasher128{}))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	for _, key := range []string{"first", "second"} {
+		if err := cache.Add(key, key, time.Minute); err != nil {
+			t.Errorf("expected keys colliding on 64 bits to be stored, got %+v", err)
+		}
+	}
+
+	// both halves of the hash of the key are taken
+	if err := cache.Add("fourth", "fourth", time.Minute); err != ErrCollision {
+		t.Errorf("expected a collision on both halves, got %+v", err)
+	}
+
+	cache.Delete("first")
+	if item, err := cache.Get("second"); err != nil || item != "second" {
+		t.Errorf("expected the key stored under its second half to be found, got %v %+v", item, err)
+	}
+
+	cache.Add("first", "again", time.Minute)
+	for key, want := range map[string]string{"first": "again", "second": "second"} {
+		if item, err := cache.Get(key); err != nil || item != want {
+			t.Errorf("expected %s for %s, got %v %+v", want, key, item, err)
+		}
+	}
+}
+
+func TestBuiltinHashers(t *testing.T) {
+	for _, hasher := range []Hasher{FNVHasher{}, FNV128Hasher{}, NewMaphashHasher()} {
+		cache := NewCache(&CacheConfig{Hasher: hasher})
+		for i := 0; i < 1000; i++ {
+			if err := cache.Add(fmt.Sprintf("key-%d", i), i, time.Minute); err != nil {
+				t.Errorf("%T: Add error: %+v", hasher, err)
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+			if item, err := cache.Get(fmt.Sprintf("key-%d", i)); err != nil || item != i {
+				t.Errorf("%T: expected %d, got %v %+v", hasher, i, item, err)
+			}
+		}
+		cache.Close()
+	}
+
+	m := NewMaphashHasher()
+	if hi, lo := m.Sum128("key"); hi == lo || hi != m.Sum64("key") {
+		t.Errorf("expected independent halves with the first matching Sum64")
+	}
+}
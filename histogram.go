@@ -0,0 +1,112 @@
+package cache
+
+import "time"
+
+// histogramBounds are the upper bounds of the buckets of a Histogram
+var histogramBounds = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// Histogram counts durations in buckets. Counts[i] is the number of
+// durations up to Bounds[i], and above the bound before it, and the
+// last count, one more than there are bounds, is of longer durations.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []int
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{
+		Bounds: histogramBounds,
+		Counts: make([]int, len(histogramBounds)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	for i, bound := range h.Bounds {
+		if d <= bound {
+			h.Counts[i]++
+			return
+		}
+	}
+	h.Counts[len(h.Bounds)]++
+}
+
+// Total will return the number of durations counted
+func (h *Histogram) Total() int {
+	var total int
+	for _, n := range h.Counts {
+		total += n
+	}
+
+	return total
+}
+
+// Quantile will return the bound of the bucket holding the q quantile
+// of the durations, e.g. 0.5 for the median, or -1 if it is in the
+// last bucket, which has no bound
+func (h *Histogram) Quantile(q float64) time.Duration {
+	target := int(q * float64(h.Total()))
+	var seen int
+	for i, n := range h.Counts {
+		seen += n
+		if seen > target && i < len(h.Bounds) {
+			return h.Bounds[i]
+		} else if seen > target {
+			break
+		}
+	}
+
+	return -1
+}
+
+// expvar will return the counts by the bounds of their buckets
+func (h *Histogram) expvar() map[string]int {
+	counts := make(map[string]int, len(h.Counts))
+	for i, n := range h.Counts {
+		if i < len(h.Bounds) {
+			counts[h.Bounds[i].String()] = n
+		} else {
+			counts["+Inf"] = n
+		}
+	}
+
+	return counts
+}
+
+// histograms will count the ages and remaining ttls of the items in
+// the cache, excluding buckets, soft deleted and expired items. Items
+// that never expire are left out of the ttl histogram. The lock must
+// be held.
+func (t *Cache) histograms() (ages *Histogram, ttls *Histogram) {
+	ages, ttls = newHistogram(), newHistogram()
+	now := t.now()
+	for _, slot := range t.slots {
+		if slot.empty || slot.deleted || now.After(slot.ExpiresAt) {
+			continue
+		}
+
+		if _, ok := slot.Item.(*Bucket); ok {
+			continue
+		}
+
+		ages.observe(now.Sub(slot.added))
+
+		expiresAt := slot.ExpiresAt
+		if !slot.held.IsZero() {
+			expiresAt = slot.held
+		}
+		if !expiresAt.Equal(neverExpires) {
+			ttls.observe(expiresAt.Sub(now))
+		}
+	}
+
+	return ages, ttls
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHistograms(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, Histograms: true})
+	defer cache.Close()
+
+	cache.Add("old", 1, NoExpiration)
+	clock.Advance(2 * time.Hour)
+	cache.Add("a", 2, 30*time.Second)
+	cache.Add("b", 3, 5*time.Minute)
+	cache.Add("c", 4, 48*time.Hour)
+	cache.Bucket("bucket")
+	cache.Add("deleted", 5, time.Hour)
+	cache.SoftDelete("deleted")
+
+	stats := cache.Stats()
+	ages, ttls := stats.AgeHistogram, stats.TTLHistogram
+	if fmt.Sprint(ages.Counts) != "[3 0 0 0 0 1 0 0 0]" {
+		t.Errorf("unexpected ages %v", ages.Counts)
+	}
+
+	if fmt.Sprint(ttls.Counts) != "[0 0 1 1 0 0 0 1 0]" || ttls.Total() != 3 {
+		t.Errorf("expected the ttls of the items that expire, got %v", ttls.Counts)
+	}
+
+	if q := ttls.Quantile(0.5); q != 10*time.Minute {
+		t.Errorf("expected the median ttl to be within 10 minutes, got %s", q)
+	}
+
+	if vars := stats.expvar(); vars["ttl_histogram"].(map[string]int)["10m0s"] != 1 {
+		t.Errorf("expected the histograms to be published, got %v", vars["ttl_histogram"])
+	}
+
+	plain := NewCache(nil)
+	defer plain.Close()
+	if plain.Stats().AgeHistogram != nil {
+		t.Errorf("expected no histograms without Histograms set")
+	}
+}
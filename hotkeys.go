@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var defaultHotKeyWindow = 1 * time.Minute
+
+// hotKeyPeriods is the number of periods the window of the hot key
+// tracker is divided into, which slides by one period at a time
+const hotKeyPeriods = 6
+
+// HotKey is a key and the estimated number of hits
+// on it within the HotKeyWindow
+type HotKey struct {
+	Key  string
+	Hits uint64
+}
+
+// hotKeys counts the hits on a sample of the reads of the cache
+// in each period of a sliding window
+type hotKeys struct {
+	every   uint64 // one in every this many hits is counted
+	seen    uint64 // hits seen, updated atomically
+	period  time.Duration
+	periods [hotKeyPeriods]hotKeyPeriod
+	mu      *sync.Mutex
+}
+
+type hotKeyPeriod struct {
+	n      int64 // number of the period since the zero time
+	counts map[uint64]*HotKey
+}
+
+func newHotKeys(rate float64, window time.Duration) *hotKeys {
+	every := uint64(1)
+	if rate < 1 {
+		every = uint64(math.Round(1 / rate))
+	}
+
+	return &hotKeys{
+		every:  every,
+		period: window / hotKeyPeriods,
+		mu:     &sync.Mutex{},
+	}
+}
+
+// record will count the hit on the key if it is sampled
+func (h *hotKeys) record(key uint64, name string, now time.Time) {
+	if atomic.AddUint64(&h.seen, 1)%h.every != 0 {
+		return
+	}
+
+	n := now.UnixNano() / int64(h.period)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	p := &h.periods[n%hotKeyPeriods]
+	if p.n != n || p.counts == nil {
+		p.n = n
+		p.counts = make(map[uint64]*HotKey)
+	}
+
+	hk, ok := p.counts[key]
+	if !ok {
+		hk = &HotKey{Key: name}
+		p.counts[key] = hk
+	}
+	hk.Hits += h.every
+}
+
+// top will return the n keys with the most hits in the window ending now
+func (h *hotKeys) top(n int, now time.Time) []HotKey {
+	current := now.UnixNano() / int64(h.period)
+
+	h.mu.Lock()
+	totals := make(map[uint64]*HotKey)
+	for i := range h.periods {
+		p := &h.periods[i]
+		if p.counts == nil || p.n <= current-hotKeyPeriods || p.n > current {
+			continue
+		}
+
+		for key, hk := range p.counts {
+			total, ok := totals[key]
+			if !ok {
+				total = &HotKey{Key: hk.Key}
+				totals[key] = total
+			}
+			total.Hits += hk.Hits
+		}
+	}
+	h.mu.Unlock()
+
+	keys := make([]HotKey, 0, len(totals))
+	for _, hk := range totals {
+		keys = append(keys, *hk)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Hits != keys[j].Hits {
+			return keys[i].Hits > keys[j].Hits
+		}
+		return keys[i].Key < keys[j].Key
+	})
+
+	if n >= 0 && len(keys) > n {
+		keys = keys[:n]
+	}
+
+	return keys
+}
+
+// HotKeys will return the n keys read most often within the last
+// HotKeyWindow, in order of their hits, or nil if HotKeySampleRate is
+// not set. Only a sample of the hits are counted, so the hits of each
+// key are estimated by scaling up the sampled hits, and keys with few
+// hits may be missing. Misses are not counted.
+func (t *Cache) HotKeys(n int) []HotKey {
+	if t.hot == nil {
+		return nil
+	}
+
+	return t.hot.top(n, t.now())
+}
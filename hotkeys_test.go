@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHotKeys(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, HotKeySampleRate: 0.5, HotKeyWindow: time.Minute})
+	defer cache.Close()
+
+	for i := 0; i < 4; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), i, NoExpiration)
+	}
+
+	// key-i is read 100*(i+1) times
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 100*(i+1); j++ {
+			cache.Get(fmt.Sprintf("key-%d", i))
+		}
+	}
+
+	hot := cache.HotKeys(2)
+	if len(hot) != 2 || hot[0].Key != "key-3" || hot[1].Key != "key-2" {
+		t.Fatalf("expected the two keys read most, got %v", hot)
+	}
+
+	if hot[0].Hits != 400 {
+		t.Errorf("expected the sampled hits to be scaled up, got %d", hot[0].Hits)
+	}
+
+	// reads slide out of the window
+	clock.Advance(30 * time.Second)
+	cache.Get("key-0")
+	cache.Get("key-0")
+	clock.Advance(45 * time.Second)
+
+	hot = cache.HotKeys(-1)
+	if len(hot) != 1 || hot[0].Key != "key-0" || hot[0].Hits != 2 {
+		t.Errorf("expected only the reads within the window, got %v", hot)
+	}
+
+	if NewCache(nil).HotKeys(1) != nil {
+		t.Errorf("expected no hot keys without HotKeySampleRate")
+	}
+}
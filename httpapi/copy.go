@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+// serveEntries will stream a snapshot of the items of the cache. Items
+// that gob cannot encode, such as those of unregistered types, are
+// left out.
+func (h *handler) serveEntries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	w.Header().Set("Content-Type", "application/x-gob")
+	enc := gob.NewEncoder(w)
+	for _, e := range h.cache.Snapshot() {
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+
+		if err := enc.Encode(&e); err != nil && r.Context().Err() != nil {
+			return
+		}
+	}
+}
+
+// CopyFrom will warm the cache with the items of the cache served by
+// the handler at the base URL, such as "http://10.0.0.1:8080", whose
+// keys the filter reports true for, or with all of them if filter is
+// nil. The buckets of the other cache are created first. Items keep
+// the time left until they expire, and are added with Cache.Warm.
+func CopyFrom(ctx context.Context, c *cache.Cache, base string, filter func(key string) bool) error {
+	base = strings.TrimSuffix(base, "/")
+
+	var buckets []string
+	err := get(ctx, base+"/buckets", func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&buckets)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range buckets {
+		c.Bucket(name)
+	}
+
+	return get(ctx, base+"/entries", func(body io.Reader) error {
+		dec := gob.NewDecoder(body)
+		return c.Warm(ctx, func(yield func(key string, item interface{}, ttl time.Duration) error) error {
+			for {
+				var e cache.Entry
+				err := dec.Decode(&e)
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+
+				if filter != nil && !filter(e.Key) {
+					continue
+				}
+
+				ttl := time.Until(e.ExpiresAt)
+				if e.ExpiresAt.Year() >= 9999 {
+					ttl = cache.NoExpiration
+				} else if ttl <= 0 {
+					continue
+				}
+
+				err = yield(e.Key, e.Item, ttl)
+				if err != nil {
+					return err
+				}
+			}
+		})
+	})
+}
+
+func get(ctx context.Context, target string, read func(body io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("httpapi: GET %s: %s", target, res.Status)
+	}
+
+	return read(res.Body)
+}
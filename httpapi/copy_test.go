@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+func TestCopyFrom(t *testing.T) {
+	src := cache.NewCache(nil)
+	defer src.Close()
+
+	src.Add("user:1", []byte("alice"), time.Hour)
+	src.Add("user:2", "bob", cache.NoExpiration)
+	src.Add("session:1", []byte("token"), time.Hour)
+	src.Bucket("fruit").Add("apple", []byte("red"), time.Hour)
+
+	server := httptest.NewServer(NewHandler(src, nil))
+	defer server.Close()
+
+	dst := cache.NewCache(nil)
+	defer dst.Close()
+
+	err := CopyFrom(context.Background(), dst, server.URL, func(key string) bool {
+		return !strings.HasPrefix(key, "session:")
+	})
+	if err != nil {
+		t.Fatalf("CopyFrom error: %+v", err)
+	}
+
+	if item, err := dst.Get("user:1"); err != nil || string(item.([]byte)) != "alice" {
+		t.Errorf("expected the item to be copied, got %v %+v", item, err)
+	}
+
+	if item, err := dst.Get("user:2"); err != nil || item != "bob" {
+		t.Errorf("expected the string item to be copied, got %v %+v", item, err)
+	}
+
+	if _, err := dst.Get("session:1"); err != cache.ErrDNE {
+		t.Errorf("expected the filtered item to be left out, got %+v", err)
+	}
+
+	if item, err := dst.Bucket("fruit").Get("apple"); err != nil || string(item.([]byte)) != "red" {
+		t.Errorf("expected the bucket item to be copied, got %v %+v", item, err)
+	}
+
+	rec := do(NewHandler(src, nil), "GET", "/entries?prefix=user:", "")
+	if rec.Code != 200 || strings.Contains(rec.Body.String(), "session") {
+		t.Errorf("expected the entries to be filtered by prefix, got %d %q", rec.Code, rec.Body.String())
+	}
+}
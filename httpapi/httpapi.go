@@ -0,0 +1,215 @@
+// Package httpapi exposes a cache over HTTP, for inspecting a running
+// cache or operating it as a small standalone caching daemon.
+//
+// The handler serves the following endpoints:
+//
+//	GET    /keys/{key}   returns the item stored at the key
+//	PUT    /keys/{key}   stores the request body at the key, ?ttl=1m sets its expiration
+//	DELETE /keys/{key}   deletes the key
+//	GET    /buckets      lists the bucket names as JSON
+//	GET    /stats        returns the cache statistics as JSON
+//	POST   /save         saves the cache to the configured file
+//	POST   /load         loads the cache from the configured file
+//	GET    /entries      streams the items as gob encoded cache.Entry values, ?prefix= filters their keys
+//
+// CopyFrom reads /entries and /buckets to warm a cache from another node.
+package httpapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var defaultMaxBodySize int64 = 1 << 20
+
+// Config is used to configure the handler
+type Config struct {
+	SavePath    string        // file used by /save and /load, the endpoints are disabled if empty
+	DefaultTTL  time.Duration // expiration of stored items without a ttl parameter, 0 never expires
+	MaxBodySize int64         // largest item that can be stored, defaults to 1MB
+}
+
+type handler struct {
+	cache  *cache.Cache
+	config *Config
+}
+
+// NewHandler will return an http.Handler that serves the cache
+func NewHandler(c *cache.Cache, config *Config) http.Handler {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = defaultMaxBodySize
+	}
+
+	return &handler{
+		cache:  c,
+		config: config,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	switch {
+	case strings.HasPrefix(path, "/keys/"):
+		key, err := url.PathUnescape(strings.TrimPrefix(path, "/keys/"))
+		if err != nil || key == "" {
+			http.Error(w, "invalid key", http.StatusBadRequest)
+			return
+		}
+		h.serveKey(w, r, key)
+	case path == "/buckets":
+		h.serveBuckets(w, r)
+	case path == "/stats":
+		h.serveStats(w, r)
+	case path == "/save" || path == "/load":
+		h.servePersist(w, r, path)
+	case path == "/entries":
+		h.serveEntries(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *handler) serveKey(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		item, err := h.cache.Get(key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeItem(w, item)
+	case http.MethodPut:
+		ttl := h.config.DefaultTTL
+		if s := r.URL.Query().Get("ttl"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+			ttl = d
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, h.config.MaxBodySize))
+		if err != nil {
+			http.Error(w, "item too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		err = h.cache.Set(key, body, ttl)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		err := h.cache.Delete(key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, HEAD, PUT, DELETE")
+	}
+}
+
+func (h *handler) serveBuckets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	writeJSON(w, h.cache.Buckets())
+}
+
+func (h *handler) serveStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	stats := h.cache.Stats()
+	writeJSON(w, struct {
+		cache.Stats
+		HitRate float64
+	}{stats, stats.HitRate()})
+}
+
+func (h *handler) servePersist(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	if h.config.SavePath == "" {
+		http.Error(w, "persistence is not configured", http.StatusNotImplemented)
+		return
+	}
+
+	var err error
+	if path == "/save" {
+		err = h.cache.Save(h.config.SavePath)
+	} else {
+		err = h.cache.Load(h.config.SavePath)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeItem will write byte slices and strings as they are,
+// and any other item as JSON
+func writeItem(w http.ResponseWriter, item interface{}) {
+	switch v := item.(type) {
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(v)
+	case string:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(v))
+	default:
+		writeJSON(w, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch err {
+	case cache.ErrDNE:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case cache.ErrTooLarge:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case cache.ErrCollision, cache.ErrHashFlood:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestKeys(t *testing.T) {
+	c := cache.NewCache(nil)
+	h := NewHandler(c, nil)
+
+	rec := do(h, http.MethodPut, "/keys/user%2F1?ttl=1m", "alice")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("unexpected status for PUT: %d", rec.Code)
+	}
+
+	item, err := c.Get("user/1")
+	if err != nil || string(item.([]byte)) != "alice" {
+		t.Errorf("item was not stored: %v %+v", item, err)
+	}
+
+	rec = do(h, http.MethodGet, "/keys/user%2F1", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Errorf("unexpected response for GET: %d %q", rec.Code, rec.Body.String())
+	}
+
+	c.Add("count", 3, time.Minute)
+	rec = do(h, http.MethodGet, "/keys/count", "")
+	if rec.Body.String() != "3" || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("item was not returned as JSON: %q", rec.Body.String())
+	}
+
+	rec = do(h, http.MethodDelete, "/keys/user%2F1", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("unexpected status for DELETE: %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodGet, "/keys/user%2F1", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unexpected status for GET of a deleted key: %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodPut, "/keys/a?ttl=soon", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status for an invalid ttl: %d", rec.Code)
+	}
+
+	rec = do(h, http.MethodPost, "/keys/a", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status for POST: %d", rec.Code)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	h := NewHandler(cache.NewCache(nil), &Config{MaxBodySize: 4})
+
+	rec := do(h, http.MethodPut, "/keys/a", "12345")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("unexpected status for a large item: %d", rec.Code)
+	}
+}
+
+func TestBucketsAndStats(t *testing.T) {
+	c := cache.NewCache(nil)
+	h := NewHandler(c, nil)
+
+	c.Bucket("users")
+	c.Add("a", 1, time.Minute)
+	c.Get("a")
+
+	var names []string
+	rec := do(h, http.MethodGet, "/buckets", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &names); err != nil || len(names) != 1 || names[0] != "users" {
+		t.Errorf("unexpected buckets %q: %+v", rec.Body.String(), err)
+	}
+
+	var stats struct {
+		Hits    uint64
+		HitRate float64
+	}
+	rec = do(h, http.MethodGet, "/stats", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || stats.Hits != 1 || stats.HitRate != 1 {
+		t.Errorf("unexpected stats %q: %+v", rec.Body.String(), err)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpapi")
+	if err != nil {
+		t.Fatalf("error creating directory: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := cache.NewCache(nil)
+	h := NewHandler(c, &Config{SavePath: filepath.Join(dir, "cache.gob")})
+
+	c.Set("a", "value", time.Minute)
+
+	rec := do(h, http.MethodPost, "/save", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("unexpected status for save: %d %s", rec.Code, rec.Body.String())
+	}
+
+	c.Delete("a")
+
+	rec = do(h, http.MethodPost, "/load", "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("unexpected status for load: %d %s", rec.Code, rec.Body.String())
+	}
+
+	if item, err := c.Get("a"); err != nil || item != "value" {
+		t.Errorf("item was not loaded: %v %+v", item, err)
+	}
+
+	rec = do(NewHandler(c, nil), http.MethodPost, "/save", "")
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("unexpected status without a save path: %d", rec.Code)
+	}
+}
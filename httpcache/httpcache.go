@@ -0,0 +1,356 @@
+// Package httpcache provides middleware caching the responses of an
+// http.Handler, so that API servers can serve repeated requests from
+// memory without a separate caching proxy.
+//
+// Responses to GET requests are cached by method and URL, plus any
+// request headers listed in Vary, for the max-age of their Cache-Control
+// header or the configured TTL. HEAD requests are served from the cached
+// GET responses. Responses marked no-store or private, responses setting
+// cookies, and responses other than 200, 203, 301, 404 and 410 are not
+// cached. A successful request with any other method invalidates the
+// responses cached for its path.
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	defaultBucket            = "httpcache"
+	defaultTTL               = 1 * time.Minute
+	defaultMaxBytes    int64 = 64 << 20
+	defaultMaxBodySize int64 = 1 << 20
+)
+
+// statusMeta is the metadata key holding the status of a cached
+// response, the other keys are the names of its headers
+const statusMeta = ":status"
+
+// Config is used to configure the middleware
+type Config struct {
+	Cache       *cache.Cache                   // cache the responses are stored in, defaults to a new cache
+	Bucket      string                         // bucket of the cache the responses are stored in, defaults to "httpcache"
+	TTL         time.Duration                  // expiration of responses without a max-age, defaults to 1 minute
+	MaxBytes    int64                          // total size of the cached bodies, the oldest are dropped beyond it, defaults to 64MB
+	MaxBodySize int64                          // largest body that is cached, defaults to 1MB
+	Vary        []string                       // request headers included in the cache key, e.g. Accept-Encoding
+	Invalidates func(r *http.Request) []string // paths invalidated by a successful non-GET request, defaults to its own path
+}
+
+// Handler is an http.Handler serving the responses of the
+// next handler from the cache
+type Handler struct {
+	next    http.Handler
+	config  *Config
+	bucket  *cache.Bucket
+	order   *list.List               // stored responses, oldest first
+	entries map[string]*list.Element // stored responses by key
+	paths   map[string]map[string]struct{}
+	bytes   int64
+	mu      *sync.Mutex
+}
+
+type entry struct {
+	key  string
+	path string
+	size int64
+}
+
+// Middleware will return a Handler caching the responses of next
+func Middleware(next http.Handler, config *Config) *Handler {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Cache == nil {
+		config.Cache = cache.NewCache(nil)
+	}
+
+	if config.Bucket == "" {
+		config.Bucket = defaultBucket
+	}
+
+	if config.TTL == 0 {
+		config.TTL = defaultTTL
+	}
+
+	if config.MaxBytes == 0 {
+		config.MaxBytes = defaultMaxBytes
+	}
+
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = defaultMaxBodySize
+	}
+
+	return &Handler{
+		next:    next,
+		config:  config,
+		bucket:  config.Cache.Bucket(config.Bucket),
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		paths:   make(map[string]map[string]struct{}),
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		h.serveUnsafe(w, r)
+		return
+	}
+
+	if directives(r.Header.Get("Cache-Control"))["no-store"] != "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := h.key(r)
+	if h.serveCached(w, r, key) {
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &recorder{ResponseWriter: w, limit: h.config.MaxBodySize}
+	w.Header().Set("X-Cache", "MISS")
+	h.next.ServeHTTP(rec, r)
+	h.store(key, r.URL.Path, rec)
+}
+
+// Invalidate will drop the responses cached for the path
+// with any method, query or varying headers
+func (h *Handler) Invalidate(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key := range h.paths[path] {
+		h.drop(h.entries[key])
+	}
+}
+
+// Purge will drop every cached response
+func (h *Handler) Purge() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for h.order.Len() > 0 {
+		h.drop(h.order.Front())
+	}
+}
+
+// serveUnsafe will pass a request that may change the resource to the
+// next handler, invalidating the cached responses if it succeeds
+func (h *Handler) serveUnsafe(w http.ResponseWriter, r *http.Request) {
+	rec := &recorder{ResponseWriter: w}
+	h.next.ServeHTTP(rec, r)
+
+	if rec.status >= 400 {
+		return
+	}
+
+	paths := []string{r.URL.Path}
+	if h.config.Invalidates != nil {
+		paths = h.config.Invalidates(r)
+	}
+
+	for _, path := range paths {
+		h.Invalidate(path)
+	}
+}
+
+// serveCached will write the cached response for the key,
+// returning false if there is none
+func (h *Handler) serveCached(w http.ResponseWriter, r *http.Request, key string) bool {
+	item, meta, err := h.bucket.GetWithMeta(key)
+	if err != nil {
+		h.mu.Lock()
+		if el, ok := h.entries[key]; ok {
+			h.drop(el)
+		}
+		h.mu.Unlock()
+		return false
+	}
+
+	status, err := strconv.Atoi(meta[statusMeta])
+	if err != nil {
+		return false
+	}
+
+	header := w.Header()
+	for name, values := range meta {
+		if name != statusMeta {
+			header[name] = strings.Split(values, "\n")
+		}
+	}
+	header.Set("X-Cache", "HIT")
+
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(item.([]byte))
+	}
+
+	return true
+}
+
+// store will cache the recorded response if it is cacheable
+func (h *Handler) store(key, path string, rec *recorder) {
+	ttl, ok := h.ttl(rec)
+	if !ok {
+		return
+	}
+
+	meta := map[string]string{statusMeta: strconv.Itoa(rec.status)}
+	for name, values := range rec.header {
+		if name != "X-Cache" {
+			meta[name] = strings.Join(values, "\n")
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if el, ok := h.entries[key]; ok {
+		h.drop(el)
+	}
+
+	err := h.bucket.Add(key, rec.body.Bytes(), ttl, cache.WithMeta(meta))
+	if err != nil {
+		return
+	}
+	size := int64(rec.body.Len())
+
+	h.entries[key] = h.order.PushBack(&entry{key: key, path: path, size: size})
+	if h.paths[path] == nil {
+		h.paths[path] = make(map[string]struct{})
+	}
+	h.paths[path][key] = struct{}{}
+	h.bytes += size
+
+	for h.bytes > h.config.MaxBytes && h.order.Len() > 0 {
+		h.drop(h.order.Front())
+	}
+}
+
+// ttl will return how long the recorded response can be cached for
+func (h *Handler) ttl(rec *recorder) (time.Duration, bool) {
+	switch rec.status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false
+	}
+
+	if rec.over || rec.header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+
+	cc := directives(rec.header.Get("Cache-Control"))
+	if cc["no-store"] != "" || cc["private"] != "" || cc["no-cache"] != "" {
+		return 0, false
+	}
+
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[name]; ok {
+			secs, err := strconv.Atoi(v)
+			if err != nil || secs <= 0 {
+				return 0, false
+			}
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+
+	return h.config.TTL, true
+}
+
+// drop will delete a stored response. The lock must be held.
+func (h *Handler) drop(el *list.Element) {
+	e := h.order.Remove(el).(*entry)
+	delete(h.entries, e.key)
+	delete(h.paths[e.path], e.key)
+	if len(h.paths[e.path]) == 0 {
+		delete(h.paths, e.path)
+	}
+	h.bytes -= e.size
+
+	h.bucket.Delete(e.key)
+}
+
+// key will build the cache key of a request, which HEAD
+// requests share with GET requests for the same resource
+func (h *Handler) key(r *http.Request) string {
+	var k cache.KeyBuilder
+	k.Add(http.MethodGet).Add(r.URL.RequestURI())
+	for _, name := range h.config.Vary {
+		k.Add(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return k.String()
+}
+
+// directives will parse the directives of a Cache-Control header,
+// mapping directives without a value to themselves
+func directives(header string) map[string]string {
+	d := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, value := part, part
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, value = part[:i], strings.Trim(part[i+1:], `"`)
+		}
+		d[strings.ToLower(name)] = value
+	}
+
+	return d
+}
+
+// recorder passes a response through to the client, keeping
+// a copy of it to be cached
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+	limit  int64
+	over   bool // the body was larger than the limit
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if !r.over && r.limit > 0 {
+		if int64(r.body.Len()+len(p)) > r.limit {
+			r.over = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(p)
+		}
+	}
+
+	return r.ResponseWriter.Write(p)
+}
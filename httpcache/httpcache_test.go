@@ -0,0 +1,172 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func do(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// counter will return a handler that writes the number of
+// requests it has served, and the number of its calls
+func counter(header http.Header, status int) (http.Handler, *int64) {
+	var calls int64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&calls, 1)
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%s %d", r.URL.RequestURI(), n)
+	}), &calls
+}
+
+func TestMiddleware(t *testing.T) {
+	next, calls := counter(http.Header{"Content-Type": {"text/plain"}}, http.StatusOK)
+	h := Middleware(next, nil)
+
+	rec := do(h, http.MethodGet, "/users/1", nil)
+	if rec.Body.String() != "/users/1 1" || rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("unexpected first response: %q %v", rec.Body.String(), rec.Header())
+	}
+
+	rec = do(h, http.MethodGet, "/users/1", nil)
+	if rec.Body.String() != "/users/1 1" || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("unexpected cached response: %q %v", rec.Body.String(), rec.Header())
+	}
+
+	if rec.Header().Get("Content-Type") != "text/plain" || len(rec.Header()["X-Multi"]) != 2 {
+		t.Errorf("headers were not restored: %v", rec.Header())
+	}
+
+	rec = do(h, http.MethodHead, "/users/1", nil)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("unexpected HEAD response: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// queries are part of the key
+	rec = do(h, http.MethodGet, "/users/1?full=1", nil)
+	if rec.Body.String() != "/users/1?full=1 2" {
+		t.Errorf("unexpected response for query: %q", rec.Body.String())
+	}
+
+	// a successful write invalidates every response for the path
+	do(h, http.MethodPut, "/users/1", nil)
+	if rec = do(h, http.MethodGet, "/users/1", nil); rec.Body.String() != "/users/1 4" {
+		t.Errorf("expected the response to be invalidated, got %q", rec.Body.String())
+	}
+
+	if rec = do(h, http.MethodGet, "/users/1?full=1", nil); rec.Body.String() != "/users/1?full=1 5" {
+		t.Errorf("expected the response to be invalidated, got %q", rec.Body.String())
+	}
+
+	h.Purge()
+	do(h, http.MethodGet, "/users/1", nil)
+	if n := atomic.LoadInt64(calls); n != 6 {
+		t.Errorf("expected 6 calls after Purge, got %d", n)
+	}
+}
+
+func TestMiddlewareCacheable(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		status int
+		cached bool
+	}{
+		{nil, http.StatusOK, true},
+		{nil, http.StatusNotFound, true},
+		{nil, http.StatusInternalServerError, false},
+		{http.Header{"Cache-Control": {"no-store"}}, http.StatusOK, false},
+		{http.Header{"Cache-Control": {"private, max-age=60"}}, http.StatusOK, false},
+		{http.Header{"Cache-Control": {"max-age=0"}}, http.StatusOK, false},
+		{http.Header{"Cache-Control": {"public, max-age=60"}}, http.StatusOK, true},
+		{http.Header{"Set-Cookie": {"session=1"}}, http.StatusOK, false},
+	}
+
+	for _, test := range tests {
+		next, calls := counter(test.header, test.status)
+		h := Middleware(next, nil)
+
+		do(h, http.MethodGet, "/", nil)
+		do(h, http.MethodGet, "/", nil)
+
+		if cached := atomic.LoadInt64(calls) == 1; cached != test.cached {
+			t.Errorf("expected %d with %v to be cached %v", test.status, test.header, test.cached)
+		}
+	}
+}
+
+func TestMiddlewareVary(t *testing.T) {
+	next, calls := counter(nil, http.StatusOK)
+	h := Middleware(next, &Config{Vary: []string{"Accept-Encoding"}})
+
+	gzip := http.Header{"Accept-Encoding": {"gzip"}}
+	do(h, http.MethodGet, "/", gzip)
+	do(h, http.MethodGet, "/", gzip)
+	do(h, http.MethodGet, "/", nil)
+
+	if n := atomic.LoadInt64(calls); n != 2 {
+		t.Errorf("expected a call for each encoding, got %d", n)
+	}
+
+	// requests asking not to be stored bypass the cache
+	do(h, http.MethodGet, "/", http.Header{"Cache-Control": {"no-store"}})
+	if n := atomic.LoadInt64(calls); n != 3 {
+		t.Errorf("expected no-store requests to bypass the cache, got %d calls", n)
+	}
+}
+
+func TestMiddlewareLimits(t *testing.T) {
+	var calls int64
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		w.Write([]byte(strings.Repeat("x", len(r.URL.Path)*10)))
+	})
+	h := Middleware(next, &Config{MaxBytes: 70, MaxBodySize: 50, TTL: time.Minute})
+
+	// each body is ten times its path, so only the last two fit
+	for _, path := range []string{"/a", "/bb", "/cc", "/dd"} {
+		do(h, http.MethodGet, path, nil)
+	}
+
+	if h.bytes > 70 || h.order.Len() != 2 {
+		t.Errorf("expected two bodies within MaxBytes, got %d bytes in %d", h.bytes, h.order.Len())
+	}
+
+	before := atomic.LoadInt64(&calls)
+	do(h, http.MethodGet, "/dd", nil)
+	do(h, http.MethodGet, "/a", nil)
+	if n := atomic.LoadInt64(&calls) - before; n != 1 {
+		t.Errorf("expected the oldest body to be dropped, got %d calls", n)
+	}
+
+	// bodies beyond MaxBodySize are not cached
+	do(h, http.MethodGet, "/toolong", nil)
+	before = atomic.LoadInt64(&calls)
+	do(h, http.MethodGet, "/toolong", nil)
+	if n := atomic.LoadInt64(&calls) - before; n != 1 {
+		t.Errorf("expected a large body not to be cached")
+	}
+}
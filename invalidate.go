@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInvalidateQueue = 1024
+	invalidateRetry        = 1 * time.Second
+)
+
+// Invalidation is published when a key is changed or deleted,
+// so that other caches can drop their copy of the key.
+type Invalidation struct {
+	Origin string // id of the cache that changed the key, so that it can ignore its own invalidations
+	Key    string
+}
+
+// Invalidator publishes invalidations to a message bus and
+// delivers the invalidations published by other caches.
+// Subscribe is called once, when the cache is created.
+type Invalidator interface {
+	Publish(inv Invalidation) error
+	Subscribe(fn func(inv Invalidation)) error
+}
+
+// invalidator publishes the keys changed in the cache in order,
+// holding them while publishing fails and replaying them once
+// the Invalidator succeeds again.
+type invalidator struct {
+	bus     Invalidator
+	origin  string
+	limit   int
+	pending []string
+	wake    chan struct{}
+	mu      sync.Mutex
+}
+
+func newInvalidator(bus Invalidator, limit int) *invalidator {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &invalidator{
+		bus:    bus,
+		origin: hex.EncodeToString(id),
+		limit:  limit,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// queue will add the key to the invalidations waiting to be
+// published, dropping the oldest once the queue is full.
+func (i *invalidator) queue(key string) {
+	i.mu.Lock()
+	i.pending = append(i.pending, key)
+	if len(i.pending) > i.limit {
+		i.pending = i.pending[len(i.pending)-i.limit:]
+	}
+	i.mu.Unlock()
+
+	select {
+	case i.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publish will publish the pending invalidations until one fails
+func (i *invalidator) publish() error {
+	for {
+		i.mu.Lock()
+		if len(i.pending) == 0 {
+			i.mu.Unlock()
+			return nil
+		}
+		key := i.pending[0]
+		i.mu.Unlock()
+
+		err := i.bus.Publish(Invalidation{Origin: i.origin, Key: key})
+		if err != nil {
+			return err
+		}
+
+		i.mu.Lock()
+		if len(i.pending) > 0 && i.pending[0] == key {
+			i.pending = i.pending[1:]
+		}
+		i.mu.Unlock()
+	}
+}
+
+// invalidatePublisher will publish invalidations as they are queued,
+// retrying failed ones until the cache is closed.
+func (t *Cache) invalidatePublisher() {
+	ticker := time.NewTicker(invalidateRetry)
+	defer ticker.Stop()
+
+	var failing bool
+	for {
+		select {
+		case <-t.invalidate.wake:
+		case <-ticker.C:
+		case <-t.done:
+			t.invalidate.publish()
+			return
+		}
+
+		err := t.invalidate.publish()
+		if err != nil && !failing {
+			t.expirer.report(err)
+		}
+		failing = err != nil
+	}
+}
+
+// invalidated will drop a key invalidated by another cache
+// without publishing the deletion again.
+func (t *Cache) invalidated(inv Invalidation) {
+	if inv.Origin == t.invalidate.origin {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(inv.Key)
+
+	if idx, ok := t.keys[hashedKey]; ok && t.slots[idx].name == inv.Key {
+		t.delete(hashedKey)
+	}
+}
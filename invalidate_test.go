@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBus is an in-memory Invalidator shared by several caches
+type memoryBus struct {
+	subscribers []func(inv Invalidation)
+	published   []Invalidation
+	fail        bool
+	mu          *sync.Mutex
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{mu: &sync.Mutex{}}
+}
+
+func (b *memoryBus) Publish(inv Invalidation) error {
+	b.mu.Lock()
+	if b.fail {
+		b.mu.Unlock()
+		return errStoreDown
+	}
+	subscribers := b.subscribers
+	b.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(inv)
+	}
+
+	b.mu.Lock()
+	b.published = append(b.published, inv)
+	b.mu.Unlock()
+
+	return nil
+}
+
+func (b *memoryBus) Subscribe(fn func(inv Invalidation)) error {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, fn)
+	b.mu.Unlock()
+
+	return nil
+}
+
+func (b *memoryBus) setFail(fail bool) {
+	b.mu.Lock()
+	b.fail = fail
+	b.mu.Unlock()
+}
+
+func (b *memoryBus) keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys := make([]string, len(b.published))
+	for i, inv := range b.published {
+		keys[i] = inv.Key
+	}
+
+	return keys
+}
+
+// waitFor will poll fn until it returns true or a second passes
+func waitFor(fn func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return false
+}
+
+func TestInvalidator(t *testing.T) {
+	bus := newMemoryBus()
+	c1 := NewCache(&CacheConfig{Invalidator: bus})
+	defer c1.Close()
+	c2 := NewCache(&CacheConfig{Invalidator: bus})
+	defer c2.Close()
+
+	c2.Add("key", "old", time.Minute)
+	waitFor(func() bool {
+		return len(bus.keys()) == 1
+	})
+	c1.Add("key", "new", time.Minute)
+
+	ok := waitFor(func() bool {
+		_, err := c2.Get("key")
+		return err == ErrDNE
+	})
+	if !ok {
+		t.Errorf("expected the key to be invalidated in the other cache")
+	}
+
+	item, err := c1.Get("key")
+	if err != nil || item != "new" {
+		t.Errorf("expected the cache to ignore its own invalidation, got %v, %+v", item, err)
+	}
+
+	c2.Add("other", "value", time.Minute)
+	c1.Delete("other")
+
+	_, err = c2.Get("other")
+	if err != nil {
+		t.Errorf("expected a failed delete not to invalidate the key, got %+v", err)
+	}
+}
+
+func TestInvalidatorReplay(t *testing.T) {
+	bus := newMemoryBus()
+	bus.setFail(true)
+
+	errs := make(chan error, 1)
+	c := NewCache(&CacheConfig{
+		Invalidator: bus,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	defer c.Close()
+
+	c.Add("a", 1, time.Minute)
+	c.Add("b", 2, time.Minute)
+
+	select {
+	case err := <-errs:
+		if err != errStoreDown {
+			t.Errorf("expected the publish error, got %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the failed publish to be reported")
+	}
+
+	bus.setFail(false)
+	c.Delete("a")
+
+	ok := waitFor(func() bool {
+		return len(bus.keys()) == 3
+	})
+	if !ok {
+		t.Fatalf("expected the held invalidations to be replayed, got %v", bus.keys())
+	}
+
+	keys := bus.keys()
+	if keys[0] != "a" || keys[1] != "b" || keys[2] != "a" {
+		t.Errorf("expected the invalidations in order, got %v", keys)
+	}
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// CheckInvariants will verify that the internal indexes of the cache
+// agree with each other, returning an error describing the first
+// disagreement found. It is meant for tests and debugging, and holds
+// the read lock while it walks every slot.
+func (t *Cache) CheckInvariants() error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for key, idx := range t.keys {
+		if idx < 0 || idx >= len(t.slots) {
+			return fmt.Errorf("cache: key %x indexes slot %d of %d", key, idx, len(t.slots))
+		}
+
+		if t.slots[idx].empty {
+			return fmt.Errorf("cache: key %x indexes empty slot %d", key, idx)
+		}
+
+		if t.slots[idx].key != key {
+			return fmt.Errorf("cache: key %x indexes slot %d holding key %x", key, idx, t.slots[idx].key)
+		}
+	}
+
+	free := make(map[int]bool, len(t.free))
+	for _, idx := range t.free {
+		if idx < 0 || idx >= len(t.slots) || !t.slots[idx].empty {
+			return fmt.Errorf("cache: free slot %d is not empty", idx)
+		}
+
+		if free[idx] {
+			return fmt.Errorf("cache: slot %d is free twice", idx)
+		}
+		free[idx] = true
+	}
+
+	var bytes int64
+	var pins, prioritized int
+	earliest := neverExpires
+	for idx, slot := range t.slots {
+		if slot.empty {
+			if !free[idx] {
+				return fmt.Errorf("cache: empty slot %d is not free", idx)
+			}
+			continue
+		}
+
+		if i, ok := t.keys[slot.key]; !ok || i != idx {
+			return fmt.Errorf("cache: slot %d holding %q is not indexed by its key", idx, slot.name)
+		}
+
+		bytes += slot.size
+		if slot.pinned {
+			pins++
+		}
+		if slot.priority != PriorityNormal {
+			prioritized++
+		}
+
+		if _, ok := slot.Item.(*Bucket); !ok && slot.ExpiresAt.Before(earliest) {
+			earliest = slot.ExpiresAt
+		}
+	}
+
+	if bytes != t.bytes {
+		return fmt.Errorf("cache: slots hold %d bytes, counted %d", bytes, t.bytes)
+	}
+
+	if pins != t.pins || prioritized != t.prioritized {
+		return fmt.Errorf("cache: %d pinned and %d prioritized slots, counted %d and %d", pins, prioritized, t.pins, t.prioritized)
+	}
+
+	// a zero nextExp makes the cleaner run, so it is never late
+	if !t.nextExp.IsZero() && t.nextExp.After(earliest) {
+		return fmt.Errorf("cache: next expiration %s is after the earliest %s", t.nextExp.Format(time.RFC3339Nano), earliest.Format(time.RFC3339Nano))
+	}
+
+	for _, slot := range t.slots {
+		b, ok := slot.Item.(*Bucket)
+		if !ok || slot.empty {
+			continue
+		}
+
+		listed := make(map[uint64]bool, len(b.list))
+		for _, key := range b.list {
+			if listed[key] {
+				return fmt.Errorf("cache: bucket %q lists key %x twice", b.name, key)
+			}
+			listed[key] = true
+
+			idx, ok := t.keys[key]
+			if !ok {
+				return fmt.Errorf("cache: bucket %q lists missing key %x", b.name, key)
+			}
+
+			if owner, _ := t.bucketOf(t.slots[idx].name); owner != b {
+				return fmt.Errorf("cache: bucket %q lists %q of another bucket", b.name, t.slots[idx].name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// runOps will apply a sequence of operations decoded from data to the
+// cache, checking the invariants after each one
+func runOps(t *testing.T, data []byte) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{
+		Clock:          clock,
+		DisableCleaner: true,
+		MaxEntries:     8,
+		EvictionPolicy: EvictLRU,
+	})
+	defer cache.Close()
+
+	buckets := []*Bucket{cache.Bucket("a"), cache.Bucket("b")}
+	for i := 0; i+1 < len(data); i += 2 {
+		op, arg := data[i], data[i+1]
+		key := fmt.Sprintf("key-%d", arg%16)
+		ttl := time.Duration(arg%4) * time.Minute
+		b := buckets[int(arg)%len(buckets)]
+
+		switch op % 16 {
+		case 0:
+			cache.Add(key, arg, ttl)
+		case 1:
+			cache.Set(key, arg, ttl)
+		case 2:
+			cache.Delete(key)
+		case 3:
+			cache.Get(key)
+		case 4:
+			b.Add(key, arg, ttl)
+		case 5:
+			b.Delete(key)
+		case 6:
+			clock.Advance(time.Duration(arg%3) * time.Minute)
+			cache.DeleteExpired()
+		case 7:
+			cache.Touch(key, ttl)
+		case 8:
+			cache.SoftDelete(key)
+		case 9:
+			cache.Restore(key)
+		case 10:
+			cache.Pin(key, arg%2 == 0)
+		case 11:
+			cache.Unpin(key)
+		case 12:
+			cache.SetPriority(key, Priority(int(arg%3)-1))
+		case 13:
+			cache.Update(key, arg)
+		case 14:
+			cache.shed(0.25)
+		case 15:
+			if arg%8 == 0 {
+				cache.Flush()
+			} else {
+				b.Get(key)
+			}
+		}
+
+		if err := cache.CheckInvariants(); err != nil {
+			t.Fatalf("after op %d (%d, %d): %+v", i/2, op%16, arg, err)
+		}
+	}
+}
+
+func TestInvariants(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		data := make([]byte, 200)
+		r.Read(data)
+		runOps(t, data)
+	}
+}
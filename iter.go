@@ -0,0 +1,111 @@
+//go:build go1.23
+// +build go1.23
+
+package cache
+
+import "iter"
+
+// All will return an iterator over the keys and items in the cache,
+// excluding buckets and expired items. The iterator ranges over a
+// snapshot taken when iteration starts, so the loop body may safely
+// call back into the cache.
+//
+// The snapshot is taken under the read lock and each key is yielded at
+// most once. Items added, replaced or deleted after iteration starts,
+// whether by the loop body or by other goroutines, are not reflected:
+// a deleted item may still be yielded and an added item is not. Items
+// spilled to SpillDir are not included. Each call to the iterator
+// takes a new snapshot, so the sequence may be ranged over repeatedly.
+func (t *Cache) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		t.mu.RLock()
+		now := t.now()
+		snapshot := make([]Slot, 0, len(t.keys))
+		for _, slot := range t.slots {
+			if slot.empty || slot.deleted || slot.ExpiresAt.Before(now) {
+				continue
+			}
+
+			if _, ok := slot.Item.(*Bucket); ok {
+				continue
+			}
+
+			snapshot = append(snapshot, slot)
+		}
+		t.mu.RUnlock()
+
+		for _, slot := range snapshot {
+			if !yield(slot.name, slot.Item) {
+				return
+			}
+		}
+	}
+}
+
+// All will return an iterator over the keys and items in the bucket,
+// excluding expired items. Keys are returned without the bucket prefix.
+// The iterator ranges over a snapshot taken when iteration starts, with
+// the same guarantees as Cache.All.
+func (b *Bucket) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		b.cache.mu.RLock()
+		now := b.cache.now()
+		snapshot := make([]Slot, 0, len(b.list))
+		for _, key := range b.list {
+			idx, ok := b.cache.keys[key]
+			if !ok {
+				continue
+			}
+
+			slot := b.cache.slots[idx]
+			if slot.deleted || slot.ExpiresAt.Before(now) {
+				continue
+			}
+
+			snapshot = append(snapshot, slot)
+		}
+		b.cache.mu.RUnlock()
+
+		for _, slot := range snapshot {
+			parts, err := ParseKey(slot.name)
+			if err != nil || len(parts) != 2 {
+				continue
+			}
+
+			if !yield(parts[1], slot.Item) {
+				return
+			}
+		}
+	}
+}
+
+// All will return an iterator over the keys and items of the scan
+func (s *Scanner) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for s.Next() {
+			if !yield(s.Key(), s.Item()) {
+				return
+			}
+		}
+	}
+}
+
+// Range will return an iterator over the keys from `from` up to but
+// excluding `to` and their items, in order of the keys. An empty `to`
+// leaves the range unbounded. Unlike All, only the keys are taken as a
+// snapshot: each item is read when it is reached, so keys deleted during
+// iteration are skipped and replaced items yield their new value.
+func (t *Cache) Range(from, to string) iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		for _, key := range t.RangeKeys(from, to) {
+			item, err := t.Peek(key)
+			if err != nil {
+				continue
+			}
+
+			if !yield(key, item) {
+				return
+			}
+		}
+	}
+}
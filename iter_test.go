@@ -0,0 +1,150 @@
+//go:build go1.23
+// +build go1.23
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheAll(t *testing.T) {
+	cache := NewCache(nil)
+
+	cache.Add("a", 1, 10*time.Minute)
+	cache.Add("b", 2, 10*time.Minute)
+	cache.Bucket("bucket").Add("c", 3, 10*time.Minute)
+
+	seen := make(map[string]interface{})
+	for key, item := range cache.All() {
+		seen[key] = item
+
+		// the iterator ranges over a snapshot, so this must not deadlock
+		cache.Delete(key)
+	}
+
+	if len(seen) != 3 || seen["a"] != 1 || seen["b"] != 2 || seen["bucket:c"] != 3 {
+		t.Errorf("unexpected items from iterator: %+v", seen)
+	}
+
+	for range cache.All() {
+		t.Errorf("deleted items were returned by the iterator")
+	}
+}
+
+func TestCacheAllSnapshot(t *testing.T) {
+	cache := NewCache(nil)
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			cache.Set(fmt.Sprintf("key-%d", i%100), -i, time.Hour)
+			cache.Delete(fmt.Sprintf("key-%d", (i+50)%100))
+		}
+	}()
+
+	all := cache.All()
+	for i := 0; i < 3; i++ {
+		seen := make(map[string]bool)
+		added := make(map[string]bool)
+		for key := range all {
+			if seen[key] {
+				t.Errorf("expected each key to be yielded once, got %q twice", key)
+			}
+			seen[key] = true
+
+			if added[key] {
+				t.Errorf("expected items added during iteration not to be yielded, got %q", key)
+			}
+
+			name := fmt.Sprintf("added-%d-%s", i, key)
+			added[name] = true
+			cache.Set(name, 0, time.Hour)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func TestBucketAll(t *testing.T) {
+	cache := NewCache(nil)
+	bucket := cache.Bucket("bucket")
+
+	bucket.Add("a", 1, 10*time.Minute)
+	bucket.Add("b", 2, 10*time.Minute)
+	bucket.Add("c", 3, 10*time.Minute)
+	cache.Add("d", 4, 10*time.Minute)
+
+	seen := make(map[string]interface{})
+	for key, item := range bucket.All() {
+		seen[key] = item
+		if len(seen) == 2 {
+			break
+		}
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("iterator did not stop early: %+v", seen)
+	}
+
+	for key := range seen {
+		if _, err := bucket.Get(key); err != nil {
+			t.Errorf("unexpected key from iterator %q: %+v", key, err)
+		}
+	}
+}
+
+func TestScannerAll(t *testing.T) {
+	cache := NewCache(nil)
+
+	cache.Add("a1", 1, 10*time.Minute)
+	cache.Add("a2", 2, 10*time.Minute)
+	cache.Add("b1", 3, 10*time.Minute)
+
+	seen := make(map[string]interface{})
+	for key, item := range cache.ScanPrefix("a", 0).All() {
+		seen[key] = item
+	}
+
+	if len(seen) != 2 || seen["a1"] != 1 || seen["a2"] != 2 {
+		t.Errorf("unexpected items from scan: %+v", seen)
+	}
+}
+
+func TestCacheRange(t *testing.T) {
+	cache := NewCache(&CacheConfig{SortedKeys: true})
+
+	cache.Add("c", 3, 10*time.Minute)
+	cache.Add("a", 1, 10*time.Minute)
+	cache.Add("b", 2, 10*time.Minute)
+
+	var keys []string
+	for key, item := range cache.Range("a", "c") {
+		keys = append(keys, key)
+		cache.Delete("b")
+
+		if key == "a" && item != 1 {
+			t.Errorf("unexpected item %+v", item)
+		}
+	}
+
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("unexpected keys from range: %v", keys)
+	}
+}
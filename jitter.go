@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"math/rand"
+	"time"
+)
+
+// jitter will randomize the ttl of an added item by up to TTLJitter
+// of the ttl either way, so that items added together do not all
+// expire in the same clean. Items that never expire are left as is.
+func (t *Cache) jitter(expiresIn time.Duration) time.Duration {
+	if t.config.TTLJitter <= 0 || expiresIn <= 0 {
+		return expiresIn
+	}
+
+	spread := t.config.TTLJitter
+	if spread > 1 {
+		spread = 1
+	}
+
+	jittered := expiresIn + time.Duration((rand.Float64()*2-1)*spread*float64(expiresIn))
+	if jittered <= 0 {
+		// a ttl of 0 would never expire
+		return 1
+	}
+
+	return jittered
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLJitter(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		TTLJitter: 0.1,
+	})
+
+	start := time.Now().UTC()
+	distinct := make(map[time.Time]bool)
+	for i := 0; i < 100; i++ {
+		key := string(rune('a' + i))
+		err := cache.Add(key, i, time.Hour)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+
+		expiresAt := cache.slots[cache.keys[cache.hash(key)]].ExpiresAt
+		if expiresAt.Before(start.Add(54*time.Minute)) || expiresAt.After(time.Now().UTC().Add(66*time.Minute)) {
+			t.Errorf("ttl was jittered outside ±10%%: %v", expiresAt.Sub(start))
+		}
+		distinct[expiresAt] = true
+	}
+
+	if len(distinct) < 50 {
+		t.Errorf("only %d distinct expirations for 100 items", len(distinct))
+	}
+
+	if ttl := cache.jitter(0); ttl != 0 {
+		t.Errorf("items that never expire were jittered to %v", ttl)
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/JKhawaja/cache/kafka
+
+go 1.15
+
+require (
+	github.com/JKhawaja/cache v0.0.0-00010101000000-000000000000
+	github.com/segmentio/kafka-go v0.4.17
+)
+
+replace github.com/JKhawaja/cache => ../
@@ -0,0 +1,261 @@
+// Package kafka provides a Kafka Invalidator for keeping several caches
+// coherent. It is a module of its own, so that the Kafka client it is
+// built on stays out of the dependencies of the cache module.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+var (
+	// ErrClosed is returned by the methods of a closed Invalidator
+	ErrClosed = errors.New("kafka: invalidator closed")
+
+	// ErrSubscribed is returned by Subscribe when the Invalidator is already subscribed
+	ErrSubscribed = errors.New("kafka: already subscribed")
+
+	// ErrNoBrokers is returned by Subscribe when none of the brokers can be reached
+	ErrNoBrokers = errors.New("kafka: no brokers reachable")
+
+	defaultDialTimeout  = 5 * time.Second
+	defaultWriteTimeout = 5 * time.Second
+	defaultReconnect    = 1 * time.Second
+)
+
+// Config is used to configure a Kafka invalidator
+type Config struct {
+	DialTimeout  time.Duration // defaults to 5 seconds
+	WriteTimeout time.Duration // time allowed for publishing an invalidation, defaults to 5 seconds
+	Reconnect    time.Duration // delay before reading a partition again after a read fails, defaults to 1 second
+	Replay       time.Duration // invalidations published this long before Subscribe are delivered too, 0 delivers only later ones
+}
+
+// writer publishes messages to the topic
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// reader reads the messages of a partition of the topic
+type reader interface {
+	ReadMessage(ctx context.Context) (kafkago.Message, error)
+	Close() error
+}
+
+// Invalidator is a cache.Invalidator that publishes invalidations as JSON
+// messages on a Kafka topic, keyed by the cache key so that the changes
+// to a key stay in order. Each partition of the topic is read from the
+// offset the Invalidator has reached, so invalidations published while
+// a broker connection was down are replayed once it is restored, for as
+// long as the topic retains them. Invalidations published by the cache
+// itself carry its origin and are ignored by it.
+type Invalidator struct {
+	brokers []string
+	topic   string
+	config  *Config
+	writer  writer
+	readers []reader
+	fn      func(inv cache.Invalidation)
+	closed  bool
+	ctx     context.Context
+	cancel  func()
+	mu      *sync.Mutex
+
+	// partitions will open a reader for each partition of the topic
+	partitions func() ([]reader, error)
+}
+
+// NewInvalidator will create and return a pointer to a new Invalidator for
+// the topic on the Kafka cluster of the brokers. It connects as needed.
+func NewInvalidator(brokers []string, topic string, config *Config) *Invalidator {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.DialTimeout == 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+
+	if config.WriteTimeout == 0 {
+		config.WriteTimeout = defaultWriteTimeout
+	}
+
+	if config.Reconnect == 0 {
+		config.Reconnect = defaultReconnect
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &Invalidator{
+		brokers: brokers,
+		topic:   topic,
+		config:  config,
+		writer: &kafkago.Writer{
+			Addr:         kafkago.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafkago.Hash{},
+			BatchSize:    1,
+			WriteTimeout: config.WriteTimeout,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		mu:     &sync.Mutex{},
+	}
+	i.partitions = i.openPartitions
+
+	return i
+}
+
+// Publish will publish the invalidation on the topic
+func (i *Invalidator) Publish(inv cache.Invalidation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	closed := i.closed
+	i.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+
+	ctx, cancel := context.WithTimeout(i.ctx, i.config.WriteTimeout)
+	defer cancel()
+
+	return i.writer.WriteMessages(ctx, kafkago.Message{Key: []byte(inv.Key), Value: data})
+}
+
+// Subscribe will read every partition of the topic and call fn with
+// each invalidation published on it until the Invalidator is closed
+func (i *Invalidator) Subscribe(fn func(inv cache.Invalidation)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return ErrClosed
+	} else if i.fn != nil {
+		return ErrSubscribed
+	}
+
+	readers, err := i.partitions()
+	if err != nil {
+		return err
+	}
+
+	i.fn = fn
+	i.readers = readers
+	for _, r := range readers {
+		go i.read(r)
+	}
+
+	return nil
+}
+
+// Close will stop reading the topic and close the connections
+func (i *Invalidator) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	i.cancel()
+
+	err := i.writer.Close()
+	for _, r := range i.readers {
+		if rerr := r.Close(); err == nil {
+			err = rerr
+		}
+	}
+
+	return err
+}
+
+// openPartitions will look up the partitions of the topic and open
+// a reader for each, starting Replay before now
+func (i *Invalidator) openPartitions() ([]reader, error) {
+	dialer := &kafkago.Dialer{Timeout: i.config.DialTimeout}
+
+	var partitions []kafkago.Partition
+	err := ErrNoBrokers
+	for _, broker := range i.brokers {
+		ctx, cancel := context.WithTimeout(i.ctx, i.config.DialTimeout)
+		partitions, err = dialer.LookupPartitions(ctx, "tcp", broker, i.topic)
+		cancel()
+
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	readers := make([]reader, 0, len(partitions))
+	for _, p := range partitions {
+		r := kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers:   i.brokers,
+			Topic:     i.topic,
+			Partition: p.ID,
+			Dialer:    dialer,
+		})
+
+		if i.config.Replay > 0 {
+			ctx, cancel := context.WithTimeout(i.ctx, i.config.DialTimeout)
+			err = r.SetOffsetAt(ctx, time.Now().Add(-i.config.Replay))
+			cancel()
+		} else {
+			err = r.SetOffset(kafkago.LastOffset)
+		}
+
+		if err != nil {
+			r.Close()
+			for _, r := range readers {
+				r.Close()
+			}
+			return nil, err
+		}
+
+		readers = append(readers, r)
+	}
+
+	return readers, nil
+}
+
+// read will pass the invalidations read from the partition
+// to the subscriber until the Invalidator is closed
+func (i *Invalidator) read(r reader) {
+	for {
+		msg, err := r.ReadMessage(i.ctx)
+		if err != nil {
+			// the reader resumes from its offset once it reconnects
+			select {
+			case <-i.ctx.Done():
+				return
+			case <-time.After(i.config.Reconnect):
+			}
+			continue
+		}
+
+		var inv cache.Invalidation
+		if json.Unmarshal(msg.Value, &inv) != nil {
+			continue
+		}
+
+		i.mu.Lock()
+		fn := i.fn
+		i.mu.Unlock()
+
+		fn(inv)
+	}
+}
@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+var errDown = errors.New("broker down")
+
+// memoryTopic is a single partition topic held in memory
+type memoryTopic struct {
+	messages []kafkago.Message
+	fail     int // reads that fail before the next succeeds
+	cond     *sync.Cond
+}
+
+func newMemoryTopic() *memoryTopic {
+	return &memoryTopic{cond: sync.NewCond(&sync.Mutex{})}
+}
+
+func (m *memoryTopic) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	m.cond.L.Lock()
+	defer m.cond.L.Unlock()
+
+	m.messages = append(m.messages, msgs...)
+	m.cond.Broadcast()
+	return nil
+}
+
+func (m *memoryTopic) Close() error {
+	return nil
+}
+
+// partition will return a reader of the topic starting at its end
+func (m *memoryTopic) partition() *memoryReader {
+	m.cond.L.Lock()
+	defer m.cond.L.Unlock()
+
+	return &memoryReader{topic: m, offset: len(m.messages)}
+}
+
+type memoryReader struct {
+	topic  *memoryTopic
+	offset int
+}
+
+func (r *memoryReader) ReadMessage(ctx context.Context) (kafkago.Message, error) {
+	m := r.topic
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.cond.L.Lock()
+			m.cond.Broadcast()
+			m.cond.L.Unlock()
+		case <-stop:
+		}
+	}()
+
+	m.cond.L.Lock()
+	defer m.cond.L.Unlock()
+
+	for r.offset == len(m.messages) && ctx.Err() == nil {
+		m.cond.Wait()
+	}
+
+	if ctx.Err() != nil {
+		return kafkago.Message{}, ctx.Err()
+	} else if m.fail > 0 {
+		m.fail--
+		return kafkago.Message{}, errDown
+	}
+
+	r.offset++
+	return m.messages[r.offset-1], nil
+}
+
+func (r *memoryReader) Close() error {
+	return nil
+}
+
+func newTestInvalidator(topic *memoryTopic) *Invalidator {
+	i := NewInvalidator(nil, "invalidations", &Config{Reconnect: time.Millisecond})
+	i.writer = topic
+	i.partitions = func() ([]reader, error) {
+		return []reader{topic.partition()}, nil
+	}
+
+	return i
+}
+
+func TestInvalidator(t *testing.T) {
+	topic := newMemoryTopic()
+	a, b := newTestInvalidator(topic), newTestInvalidator(topic)
+	defer a.Close()
+	defer b.Close()
+
+	received := make(chan cache.Invalidation, 10)
+	err := a.Subscribe(func(inv cache.Invalidation) {
+		received <- inv
+	})
+	if err != nil {
+		t.Fatalf("Subscribe error: %+v", err)
+	}
+
+	expect := func(want cache.Invalidation) {
+		t.Helper()
+
+		select {
+		case inv := <-received:
+			if inv != want {
+				t.Errorf("received %+v, expected %+v", inv, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%+v was not received", want)
+		}
+	}
+
+	err = b.Publish(cache.Invalidation{Origin: "b", Key: "key"})
+	if err != nil {
+		t.Errorf("Publish error: %+v", err)
+	}
+	expect(cache.Invalidation{Origin: "b", Key: "key"})
+
+	// a failed read is retried from the same offset
+	topic.cond.L.Lock()
+	topic.fail = 2
+	topic.cond.L.Unlock()
+
+	b.Publish(cache.Invalidation{Origin: "b", Key: "other"})
+	expect(cache.Invalidation{Origin: "b", Key: "other"})
+
+	if err := a.Subscribe(func(inv cache.Invalidation) {}); err != ErrSubscribed {
+		t.Errorf("expected ErrSubscribed, got %+v", err)
+	}
+
+	a.Close()
+	if err := a.Publish(cache.Invalidation{Key: "key"}); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %+v", err)
+	}
+
+	if err := a.Subscribe(func(inv cache.Invalidation) {}); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %+v", err)
+	}
+}
+
+func TestInvalidatorCache(t *testing.T) {
+	topic := newMemoryTopic()
+	a, b := newTestInvalidator(topic), newTestInvalidator(topic)
+	defer a.Close()
+	defer b.Close()
+
+	c := cache.NewCache(&cache.CacheConfig{Invalidator: a})
+	defer c.Close()
+
+	c.Set("key", "a", time.Minute)
+	c.Set("other", "a", time.Minute)
+	b.Publish(cache.Invalidation{Origin: "b", Key: "key"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Contains("key") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if c.Contains("key") {
+		t.Errorf("expected the key changed in the other cache to be dropped")
+	}
+
+	// the cache ignores the invalidations it published itself
+	if item, err := c.Get("other"); err != nil || item != "a" {
+		t.Errorf("expected the other key to be kept, got %v: %+v", item, err)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// keepAliveTTLs is the number of renewal intervals a kept alive key
+// survives without renewal, so one late tick does not expire it.
+const keepAliveTTLs = 2
+
+// Stopper stops a background task started by the cache
+type Stopper struct {
+	stop chan struct{}
+	once *sync.Once
+}
+
+// Stop will stop the task. It is safe to call more than once.
+func (s *Stopper) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// KeepAlive will renew the expiration of the item at the key every
+// interval until the returned Stopper is stopped, for heartbeat and
+// presence patterns. Each renewal lets the item live for two intervals,
+// so once renewal stops (or the key's owner dies) the item expires
+// within two intervals. Renewal also stops if the item is deleted or
+// expires, or the cache is closed. It will return ErrDNE if the key
+// does not exist.
+func (t *Cache) KeepAlive(key string, interval time.Duration) (*Stopper, error) {
+	err := t.Touch(key, keepAliveTTLs*interval)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stopper{
+		stop: make(chan struct{}),
+		once: &sync.Once{},
+	}
+
+	go func() {
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.done:
+				return
+			case <-t.config.Clock.After(interval):
+				if t.Touch(key, keepAliveTTLs*interval) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	return s, nil
+}
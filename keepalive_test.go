@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeepAlive(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+	})
+
+	err := cache.Add("worker-1", "alive", 30*time.Millisecond)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	stopper, err := cache.KeepAlive("worker-1", 20*time.Millisecond)
+	if err != nil {
+		t.Errorf("error keeping key alive: %+v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := cache.Get("worker-1"); err != nil {
+		t.Errorf("kept alive key expired: %+v", err)
+	}
+
+	stopper.Stop()
+	stopper.Stop()
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := cache.Get("worker-1"); err != ErrDNE {
+		t.Errorf("key did not expire after renewal stopped: %+v", err)
+	}
+}
+
+func TestKeepAliveMissing(t *testing.T) {
+	cache := NewCache(nil)
+
+	_, err := cache.KeepAlive("missing", time.Second)
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE, got: %+v", err)
+	}
+}
+
+func TestKeepAliveClose(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, DisableCleaner: true})
+
+	cache.Add("worker-1", "alive", time.Minute)
+	_, err := cache.KeepAlive("worker-1", time.Minute)
+	if err != nil {
+		t.Fatalf("error keeping key alive: %+v", err)
+	}
+
+	cache.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	// renewal has stopped with the cache, so the key is not touched again
+	clock.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+
+	if ttl, err := cache.TTL("worker-1"); err != nil || ttl != time.Minute {
+		t.Errorf("expected renewal to stop once the cache is closed, got %s: %+v", ttl, err)
+	}
+}
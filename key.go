@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrMalformedKey is returned when a composite key cannot be parsed
+var ErrMalformedKey = errors.New("malformed composite key")
+
+const (
+	keySeparator = ':'
+	keyEscape    = '\\'
+)
+
+// KeyBuilder assembles a composite key from parts, e.g. a tenant,
+// resource, and version. Separators and escapes inside a part are
+// escaped, so distinct parts can never build the same key, and the
+// parts of a built key are recovered with ParseKey.
+type KeyBuilder struct {
+	b     strings.Builder
+	parts int
+}
+
+// Add will append a part to the key
+func (k *KeyBuilder) Add(part string) *KeyBuilder {
+	if k.parts > 0 {
+		k.b.WriteByte(keySeparator)
+	}
+	k.parts++
+
+	for i := 0; i < len(part); i++ {
+		if part[i] == keySeparator || part[i] == keyEscape {
+			k.b.WriteByte(keyEscape)
+		}
+		k.b.WriteByte(part[i])
+	}
+
+	return k
+}
+
+// String will return the key built from the parts
+func (k *KeyBuilder) String() string {
+	return k.b.String()
+}
+
+// Reset will discard the parts added to the builder
+func (k *KeyBuilder) Reset() {
+	k.b.Reset()
+	k.parts = 0
+}
+
+// AddValue will append a part to the key as the tag of its type followed
+// by its string as converted by KeyString, or by fmt.Sprint for types
+// KeyString does not support, so that parts of other types never build
+// the same key, e.g. "42" and 42. ParseKey returns the part with its tag.
+func (k *KeyBuilder) AddValue(part interface{}) *KeyBuilder {
+	s, err := KeyString(part)
+	if err != nil {
+		s = fmt.Sprint(part)
+	}
+
+	return k.Add(string(keyTag(part, err)) + s)
+}
+
+// Key will build a composite key from the parts with a KeyBuilder,
+// adding each with AddValue, so Key("a:b", "c") and Key("a", "b:c")
+// are distinct keys, as are Key("42") and Key(42). The parts of a built
+// key are recovered with ParseKey, each with the tag of its type.
+func Key(parts ...interface{}) string {
+	var k KeyBuilder
+	for _, part := range parts {
+		k.AddValue(part)
+	}
+
+	return k.String()
+}
+
+// keyTag will return the tag of the type of a part of a key
+func keyTag(part interface{}, err error) byte {
+	if err != nil {
+		return 'v'
+	}
+
+	switch part.(type) {
+	case Keyer:
+		return 'k'
+	case string:
+		return 's'
+	case []byte:
+		return 'b'
+	case int, int8, int16, int32, int64:
+		return 'i'
+	case uint, uint8, uint16, uint32, uint64:
+		return 'u'
+	case bool:
+		return 't'
+	case time.Time:
+		return 'd'
+	case encoding.TextMarshaler:
+		return 'm'
+	}
+
+	return 'f'
+}
+
+// ParseKey will split a key built by KeyBuilder back into its parts.
+// It will return ErrMalformedKey if the key contains an invalid escape.
+func ParseKey(key string) ([]string, error) {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case keyEscape:
+			i++
+			if i == len(key) || (key[i] != keySeparator && key[i] != keyEscape) {
+				return nil, ErrMalformedKey
+			}
+			part.WriteByte(key[i])
+		case keySeparator:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(key[i])
+		}
+	}
+
+	return append(parts, part.String()), nil
+}
+
+// KeyOf will return the key in the cache of the item stored in the
+// bucket at the composite key of the parts built by Key, so that it can
+// also be used with the methods of the cache, e.g. with TagKeys or
+// AddDependency.
+func (b *Bucket) KeyOf(parts ...interface{}) string {
+	return b.key(Key(parts...))
+}
+
+// key will return the composite key of an item in the bucket
+func (b *Bucket) key(key string) string {
+	var k KeyBuilder
+	return k.Add(b.name).Add(key).String()
+}
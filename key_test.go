@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	tests := [][]string{
+		{"tenant", "resource", "v1"},
+		{"a:b", "c"},
+		{"a", "b:c"},
+		{`a\`, "b"},
+		{"", "", ""},
+		{"single"},
+	}
+
+	seen := make(map[string][]string)
+	for _, parts := range tests {
+		var k KeyBuilder
+		for _, part := range parts {
+			k.Add(part)
+		}
+		key := k.String()
+
+		if other, ok := seen[key]; ok {
+			t.Errorf("parts %q and %q built the same key %q", parts, other, key)
+		}
+		seen[key] = parts
+
+		parsed, err := ParseKey(key)
+		if err != nil {
+			t.Errorf("error parsing key %q: %+v", key, err)
+		}
+
+		if !reflect.DeepEqual(parsed, parts) {
+			t.Errorf("key %q parsed to %q, expected %q", key, parsed, parts)
+		}
+	}
+
+	for _, key := range []string{`a\`, `a\b`} {
+		if _, err := ParseKey(key); err != ErrMalformedKey {
+			t.Errorf("expected ErrMalformedKey for %q, got: %+v", key, err)
+		}
+	}
+}
+
+func TestBucketKeysDoNotCollide(t *testing.T) {
+	cache := NewCache(nil)
+
+	err := cache.Bucket("a:b").Add("c", 1, 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Bucket("a").Add("b:c", 2, 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	item, err := cache.Bucket("a:b").Get("c")
+	if err != nil || item != 1 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	distinct := [][2][]interface{}{
+		{{"a:b", "c"}, {"a", "b:c"}},
+		{{"42"}, {42}},
+		{{"true"}, {true}},
+		{{"1", "2"}, {"12"}},
+		{{int64(7)}, {uint(7)}},
+		{{""}, {}},
+		{{"s1:a"}, {"a"}},
+	}
+	for _, parts := range distinct {
+		if Key(parts[0]...) == Key(parts[1]...) {
+			t.Errorf("expected %#v and %#v to build distinct keys", parts[0], parts[1])
+		}
+	}
+
+	if key := Key("user", 42, true); key != "suser:i42:ttrue" {
+		t.Errorf("unexpected key %q", key)
+	}
+
+	if Key(int8(7)) != Key(7) {
+		t.Errorf("expected integers of any size to build the same key")
+	}
+
+	parts, err := ParseKey(Key("a:b", `c\`, 7, ""))
+	if err != nil || !reflect.DeepEqual(parts, []string{"sa:b", `sc\`, "i7", "s"}) {
+		t.Errorf("expected the tagged parts to be recovered, got %q: %+v", parts, err)
+	}
+
+	if key := Key(struct{ A int }{1}); key != "v{1}" {
+		t.Errorf("expected unsupported parts to be formatted, got %q", key)
+	}
+}
+
+func TestBucketKeyOf(t *testing.T) {
+	cache := NewCache(nil)
+	b := cache.Bucket("users")
+
+	err := b.Add(Key("acme", 7), "alice", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	item, err := cache.Get(b.KeyOf("acme", 7))
+	if err != nil || item != "alice" {
+		t.Errorf("expected the bucket item at its cache key, got %v: %+v", item, err)
+	}
+}
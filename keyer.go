@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrUnsupportedKey is returned for a key whose type cannot be
+// converted to the string the cache stores it under
+var ErrUnsupportedKey = errors.New("unsupported key type")
+
+// Keyer is implemented by types that can be used directly as
+// cache keys, such as structs identifying a record
+type Keyer interface {
+	CacheKey() []byte
+}
+
+// KeyString will convert a key to the string the cache stores it
+// under. Strings are used as they are, Keyers by their CacheKey, byte
+// slices by their bytes, integers and bools in decimal and text, and
+// other types by their MarshalText or String method, which covers most
+// UUID types. It will return ErrUnsupportedKey for any other type.
+//
+// Keys of different types converting to the same string, such as 7
+// and "7", refer to the same item.
+func KeyString(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case Keyer:
+		return string(k.CacheKey()), nil
+	case string:
+		return k, nil
+	case []byte:
+		return string(k), nil
+	case int:
+		return strconv.Itoa(k), nil
+	case int8:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(k), 10), nil
+	case int64:
+		return strconv.FormatInt(k, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(k), 10), nil
+	case uint64:
+		return strconv.FormatUint(k, 10), nil
+	case bool:
+		return strconv.FormatBool(k), nil
+	case time.Time:
+		return k.UTC().Format(time.RFC3339Nano), nil
+	case encoding.TextMarshaler:
+		text, err := k.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		return string(text), nil
+	case fmt.Stringer:
+		return k.String(), nil
+	}
+
+	return "", ErrUnsupportedKey
+}
+
+// AddKeyed will add the item to the cache at a key of any type
+// supported by KeyString, otherwise behaving like Add
+func (t *Cache) AddKeyed(key interface{}, item interface{}, expiresIn time.Duration, opts ...AddOption) error {
+	k, err := KeyString(key)
+	if err != nil {
+		return err
+	}
+
+	return t.Add(k, item, expiresIn, opts...)
+}
+
+// SetKeyed will set the item in the cache at a key of any type
+// supported by KeyString, otherwise behaving like Set
+func (t *Cache) SetKeyed(key interface{}, item interface{}, expiresIn time.Duration) error {
+	k, err := KeyString(key)
+	if err != nil {
+		return err
+	}
+
+	return t.Set(k, item, expiresIn)
+}
+
+// GetKeyed will return the item stored at a key of any type
+// supported by KeyString, otherwise behaving like Get
+func (t *Cache) GetKeyed(key interface{}, opts ...GetOption) (interface{}, error) {
+	k, err := KeyString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Get(k, opts...)
+}
+
+// DeleteKeyed will delete the item stored at a key of any type
+// supported by KeyString, otherwise behaving like Delete
+func (t *Cache) DeleteKeyed(key interface{}) error {
+	k, err := KeyString(key)
+	if err != nil {
+		return err
+	}
+
+	return t.Delete(k)
+}
+
+// AddKeyed will add the item to the bucket at a key of any type
+// supported by KeyString, otherwise behaving like Add
+func (b *Bucket) AddKeyed(key interface{}, item interface{}, expiresIn time.Duration, opts ...AddOption) error {
+	k, err := KeyString(key)
+	if err != nil {
+		return err
+	}
+
+	return b.Add(k, item, expiresIn, opts...)
+}
+
+// GetKeyed will return the item stored in the bucket at a key of
+// any type supported by KeyString, otherwise behaving like Get
+func (b *Bucket) GetKeyed(key interface{}, opts ...GetOption) (interface{}, error) {
+	k, err := KeyString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Get(k, opts...)
+}
+
+// DeleteKeyed will delete the item stored in the bucket at a key
+// of any type supported by KeyString, otherwise behaving like Delete
+func (b *Bucket) DeleteKeyed(key interface{}) error {
+	k, err := KeyString(key)
+	if err != nil {
+		return err
+	}
+
+	return b.Delete(k)
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+type userKey struct {
+	tenant string
+	id     int
+}
+
+func (k userKey) CacheKey() []byte {
+	var b KeyBuilder
+	return []byte(b.Add("user").Add(k.tenant).Add(mustKey(k.id)).String())
+}
+
+// mustKey will convert a key supported by KeyString, panicking otherwise
+func mustKey(key interface{}) string {
+	k, err := KeyString(key)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+type uuid [16]byte
+
+func (u uuid) String() string {
+	return "00000000-0000-0000-0000-000000000001"
+}
+
+func TestKeyString(t *testing.T) {
+	cases := []struct {
+		key      interface{}
+		expected string
+	}{
+		{"key", "key"},
+		{[]byte("bytes"), "bytes"},
+		{42, "42"},
+		{int8(-8), "-8"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{true, "true"},
+		{time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), "2020-01-01T00:00:00Z"},
+		{net.ParseIP("10.0.0.1"), "10.0.0.1"},
+		{uuid{}, "00000000-0000-0000-0000-000000000001"},
+		{userKey{"acme", 7}, "user:acme:7"},
+	}
+
+	for _, c := range cases {
+		k, err := KeyString(c.key)
+		if err != nil || k != c.expected {
+			t.Errorf("expected %T to convert to %q, got %q %+v", c.key, c.expected, k, err)
+		}
+	}
+
+	if _, err := KeyString(struct{}{}); err != ErrUnsupportedKey {
+		t.Errorf("expected ErrUnsupportedKey, got %+v", err)
+	}
+}
+
+func TestKeyedMethods(t *testing.T) {
+	cache := NewCache(nil)
+	defer cache.Close()
+
+	key := userKey{"acme", 7}
+	if err := cache.AddKeyed(key, "alice", time.Minute); err != nil {
+		t.Fatalf("AddKeyed error: %+v", err)
+	}
+
+	if item, err := cache.GetKeyed(key); err != nil || item != "alice" {
+		t.Errorf("expected the item, got %v %+v", item, err)
+	}
+
+	if item, err := cache.Get("user:acme:7"); err != nil || item != "alice" {
+		t.Errorf("expected the item at the converted key, got %v %+v", item, err)
+	}
+
+	cache.SetKeyed(key, "bob", time.Minute)
+	if err := cache.DeleteKeyed(key); err != nil {
+		t.Errorf("DeleteKeyed error: %+v", err)
+	}
+
+	if err := cache.AddKeyed(struct{}{}, 1, time.Minute); err != ErrUnsupportedKey {
+		t.Errorf("expected ErrUnsupportedKey, got %+v", err)
+	}
+
+	b := cache.Bucket("ids")
+	b.AddKeyed(1001, "carol", time.Minute)
+	if item, err := b.GetKeyed(1001); err != nil || item != "carol" {
+		t.Errorf("expected the item from the bucket, got %v %+v", item, err)
+	}
+
+	if err := b.DeleteKeyed(1001); err != nil {
+		t.Errorf("DeleteKeyed error: %+v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Lane is the priority with which a caller acquires the cache lock
+type Lane int
+
+const (
+	// LaneInteractive is for latency-critical callers, which
+	// background callers wait for before taking the cache lock
+	LaneInteractive Lane = iota
+	// LaneBackground is for warmers and janitors, which yield
+	// the cache lock to interactive callers
+	LaneBackground
+)
+
+// laneGate holds background callers back while
+// interactive callers are waiting for or holding the cache lock
+type laneGate struct {
+	interactive int
+	cond        *sync.Cond
+	mu          *sync.Mutex
+}
+
+func newLaneGate() *laneGate {
+	mu := &sync.Mutex{}
+	return &laneGate{
+		cond: sync.NewCond(mu),
+		mu:   mu,
+	}
+}
+
+// enter will wait until the lane may take the cache lock
+func (g *laneGate) enter(lane Lane) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if lane == LaneInteractive {
+		g.interactive++
+		return
+	}
+
+	for g.interactive > 0 {
+		g.cond.Wait()
+	}
+}
+
+// exit will release the lane after the cache lock has been released
+func (g *laneGate) exit(lane Lane) {
+	if lane != LaneInteractive {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.interactive--
+	if g.interactive == 0 {
+		g.cond.Broadcast()
+	}
+}
+
+// AddWithLane will add a key, value, and expiration duration to the cache
+// in the given lane. Background adds, such as cache warmers, wait for
+// interactive callers before taking the cache lock.
+func (t *Cache) AddWithLane(key string, item interface{}, expiresIn time.Duration, lane Lane) error {
+	return t.Add(key, item, expiresIn, InLane(lane))
+}
+
+// GetWithLane will return the value stored at the key, acquiring the
+// cache lock in the given lane. Interactive gets are served ahead of
+// background callers that are waiting for the lock.
+func (t *Cache) GetWithLane(key string, lane Lane) (interface{}, error) {
+	return t.Get(key, InLane(lane))
+}
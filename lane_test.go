@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheLanes(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.AddWithLane("key", "value", 10*time.Minute, LaneBackground)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	value, err := cache.GetWithLane("key", LaneInteractive)
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "value" {
+		t.Errorf("returned value was %s", value)
+	}
+
+	// hold the interactive lane open, background callers must wait
+	cache.lanes.enter(LaneInteractive)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := cache.GetWithLane("key", LaneBackground)
+		if err != nil {
+			t.Errorf("error while getting key: %+v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("background get did not wait for interactive caller")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cache.lanes.exit(LaneInteractive)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("background get was not released")
+	}
+}
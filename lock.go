@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrLocked is returned by TryLock when the key is held by another lease
+	ErrLocked = errors.New("key is locked")
+
+	// ErrLeaseLost is returned when renewing or releasing a lease
+	// that has expired or been taken over by another holder
+	ErrLeaseLost = errors.New("lease lost")
+
+	lockRetry = 5 * time.Millisecond
+)
+
+// Lease is the hold on a key acquired by Lock or TryLock. It is released
+// automatically when its ttl passes unless it is renewed first.
+type Lease struct {
+	cache *Cache
+	key   string
+	token uint64
+}
+
+// lockItem is the item stored at a locked key
+type lockItem struct{}
+
+// Lock will acquire a lease on the key for the ttl, waiting
+// until any other lease on the key is released or expires, and
+// retrying on the cache's Clock. It will return the error of ctx
+// if ctx is done before the lease is acquired.
+func (t *Cache) Lock(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	for {
+		lease, err := t.TryLock(key, ttl)
+		if err != ErrLocked {
+			return lease, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Lease{}, ctx.Err()
+		case <-t.config.Clock.After(lockRetry):
+		}
+	}
+}
+
+// TryLock will acquire a lease on the key for the ttl, or return
+// ErrLocked if the key holds an unexpired lease or item. The key
+// is free again once the lease is released or expires.
+func (t *Cache) TryLock(key string, ttl time.Duration) (Lease, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	if idx, ok := t.live(hashedKey); ok && !t.now().After(t.slots[idx].ExpiresAt) {
+		return Lease{}, ErrLocked
+	}
+
+	err := t.set(hashedKey, key, lockItem{}, t.expiration(ttl))
+	if err != nil {
+		return Lease{}, err
+	}
+
+	idx, ok := t.keys[hashedKey]
+	if !ok {
+		return Lease{}, ErrLeaseLost
+	}
+
+	return Lease{cache: t, key: key, token: t.slots[idx].version}, nil
+}
+
+// Key will return the key the lease is held on
+func (l Lease) Key() string {
+	return l.key
+}
+
+// Token will return the fencing token of the lease. Tokens increase
+// with every lease acquired, so a resource guarded by the lock can
+// reject writes carrying a lower token than one it has already seen,
+// which come from a holder whose lease expired without it noticing.
+func (l Lease) Token() uint64 {
+	return l.token
+}
+
+// Renew will reset the expiration of the lease to the ttl from now
+func (l Lease) Renew(ttl time.Duration) error {
+	t := l.cache
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey, ok := l.held()
+	if !ok {
+		return ErrLeaseLost
+	}
+
+	return t.touch(hashedKey, t.expiration(ttl))
+}
+
+// Release will release the lease, freeing the key for the next holder
+func (l Lease) Release() error {
+	t := l.cache
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey, ok := l.held()
+	if !ok {
+		return ErrLeaseLost
+	}
+
+	return t.delete(hashedKey)
+}
+
+// held will return the hashed key if the lease is still
+// held. The cache lock must be held.
+func (l Lease) held() (uint64, bool) {
+	t := l.cache
+	if t == nil {
+		return 0, false
+	}
+
+	hashedKey := t.hash(l.key)
+
+	idx, ok := t.live(hashedKey)
+	if !ok || t.slots[idx].version != l.token || t.now().After(t.slots[idx].ExpiresAt) {
+		return 0, false
+	}
+
+	return hashedKey, true
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	cache := NewCache(nil)
+
+	lease, err := cache.TryLock("job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock error: %+v", err)
+	}
+
+	if _, err := cache.TryLock("job", time.Minute); err != ErrLocked {
+		t.Errorf("expected ErrLocked, got %+v", err)
+	}
+
+	if err := lease.Renew(time.Minute); err != nil {
+		t.Errorf("Renew error: %+v", err)
+	}
+
+	if err := lease.Release(); err != nil {
+		t.Errorf("Release error: %+v", err)
+	}
+
+	if err := lease.Release(); err != ErrLeaseLost {
+		t.Errorf("expected ErrLeaseLost, got %+v", err)
+	}
+
+	next, err := cache.TryLock("job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock error after release: %+v", err)
+	}
+
+	if next.Token() <= lease.Token() || next.Key() != "job" {
+		t.Errorf("expected an increasing fencing token, got %d after %d", next.Token(), lease.Token())
+	}
+
+	// items that are not leases hold the key too
+	cache.Add("data", 1, time.Minute)
+	if _, err := cache.TryLock("data", time.Minute); err != ErrLocked {
+		t.Errorf("expected ErrLocked, got %+v", err)
+	}
+}
+
+func TestLockExpires(t *testing.T) {
+	cache := NewCache(nil)
+
+	lease, _ := cache.TryLock("job", 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+
+	next, err := cache.TryLock("job", time.Minute)
+	if err != nil {
+		t.Fatalf("expected the expired lease to be released, got %+v", err)
+	}
+
+	if err := lease.Renew(time.Minute); err != ErrLeaseLost {
+		t.Errorf("expected ErrLeaseLost, got %+v", err)
+	}
+
+	if err := lease.Release(); err != ErrLeaseLost {
+		t.Errorf("expected ErrLeaseLost, got %+v", err)
+	}
+
+	if err := next.Release(); err != nil {
+		t.Errorf("the new holder lost its lease: %+v", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	cache := NewCache(nil)
+
+	var mu sync.Mutex
+	var held, max int
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			lease, err := cache.Lock(context.Background(), "job", time.Minute)
+			if err != nil {
+				t.Errorf("Lock error: %+v", err)
+				return
+			}
+
+			mu.Lock()
+			held++
+			if held > max {
+				max = held
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			held--
+			mu.Unlock()
+
+			lease.Release()
+		}()
+	}
+	wg.Wait()
+
+	if max != 1 {
+		t.Errorf("expected one holder at a time, got %d", max)
+	}
+}
+
+func TestLockContext(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, DisableCleaner: true})
+	defer cache.Close()
+
+	cache.TryLock("job", time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := cache.Lock(ctx, "job", time.Minute)
+		done <- err
+	}()
+
+	// the retries wait on the cache's clock, which has not advanced
+	select {
+	case err := <-done:
+		t.Fatalf("expected Lock to wait, got %+v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %+v", err)
+	}
+
+	// the lease is acquired once it expires on the clock
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		_, err := cache.Lock(ctx, "job", time.Minute)
+		done <- err
+	}()
+
+	for i := 0; i < 100; i++ {
+		clock.Advance(time.Second)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Lock error: %+v", err)
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+	t.Errorf("expected the lease to be acquired once the other expired")
+}
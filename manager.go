@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ManagerConfig is used to configure a Manager
+type ManagerConfig struct {
+	CleanDuration time.Duration // interval at which the shared cleaner cleans every cache, defaults to 10 seconds
+	Clock         Clock         // source of the interval, defaults to the system clock
+}
+
+// Manager owns a group of named caches. Rather than each cache running
+// a cleaner of its own, one goroutine cleans every cache of the manager,
+// so that an application with dozens of caches does not need dozens of
+// goroutines. The caches and their statistics can be listed by name.
+type Manager struct {
+	config   *ManagerConfig
+	caches   map[string]*managed
+	done     chan struct{}
+	stopOnce *sync.Once
+	mu       *sync.Mutex
+}
+
+type managed struct {
+	cache *Cache
+	clean bool // the shared cleaner cleans the cache
+}
+
+// NewManager will create a Manager and start its cleaner
+func NewManager(config *ManagerConfig) *Manager {
+	if config == nil {
+		config = &ManagerConfig{}
+	}
+
+	if config.CleanDuration <= 0 {
+		config.CleanDuration = defaultCleanDuration
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	m := &Manager{
+		config:   config,
+		caches:   make(map[string]*managed),
+		done:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		mu:       &sync.Mutex{},
+	}
+	go m.cleaner()
+
+	return m
+}
+
+// Cache will return the cache by the name, creating it with the config
+// if it does not already exist. The config of an existing cache is not
+// changed. The caches are cleaned by the manager's cleaner, every
+// CleanDuration of the manager, unless their config sets DisableCleaner.
+func (m *Manager) Cache(name string, config *CacheConfig) *Cache {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mc, ok := m.caches[name]; ok {
+		return mc.cache
+	}
+
+	// the cache is created from a copy, so that the
+	// config can be passed to Cache again unchanged
+	c := *defaultConfig
+	if config != nil {
+		c = *config
+	}
+
+	mc := &managed{clean: !c.DisableCleaner}
+	c.DisableCleaner = true
+	mc.cache = NewCache(&c)
+	m.caches[name] = mc
+
+	return mc.cache
+}
+
+// Lookup will return the cache by the name, if the manager has it
+func (m *Manager) Lookup(name string) (*Cache, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mc, ok := m.caches[name]
+	if !ok {
+		return nil, false
+	}
+
+	return mc.cache, true
+}
+
+// Names will return the names of the caches of the manager, in order
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.caches))
+	for name := range m.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Remove will close the cache by the name and remove it from the
+// manager, reporting whether the manager had it
+func (m *Manager) Remove(name string) bool {
+	m.mu.Lock()
+	mc, ok := m.caches[name]
+	delete(m.caches, name)
+	m.mu.Unlock()
+
+	if ok {
+		mc.cache.Close()
+	}
+
+	return ok
+}
+
+// Stats will return the current statistics of every cache by name
+func (m *Manager) Stats() map[string]Stats {
+	stats := make(map[string]Stats)
+	for name, c := range m.all() {
+		stats[name] = c.Stats()
+	}
+
+	return stats
+}
+
+// PublishExpvar will publish the statistics of every cache as an
+// expvar variable by the name, holding an object of the statistics
+// of each cache by its name. Caches added after it is published are
+// included, and like Cache.PublishExpvar the manager is referenced
+// for the life of the process.
+func (m *Manager) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return ErrExpvarExists
+	}
+
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		vars := make(map[string]interface{})
+		for name, stats := range m.Stats() {
+			vars[name] = stats.expvar()
+		}
+		return vars
+	}))
+
+	return nil
+}
+
+// Close will stop the cleaner and close every cache of the manager
+func (m *Manager) Close() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+
+	m.mu.Lock()
+	caches := m.caches
+	m.caches = make(map[string]*managed)
+	m.mu.Unlock()
+
+	for _, mc := range caches {
+		mc.cache.Close()
+	}
+}
+
+// all will return the caches of the manager by name
+func (m *Manager) all() map[string]*Cache {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	caches := make(map[string]*Cache, len(m.caches))
+	for name, mc := range m.caches {
+		caches[name] = mc.cache
+	}
+
+	return caches
+}
+
+// cleaner will clean the caches every CleanDuration until the manager is closed
+func (m *Manager) cleaner() {
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-m.config.Clock.After(m.config.CleanDuration):
+		}
+
+		m.clean()
+	}
+}
+
+// clean will run a clean cycle of each cache the manager cleans
+func (m *Manager) clean() {
+	m.mu.Lock()
+	caches := make([]*Cache, 0, len(m.caches))
+	for _, mc := range m.caches {
+		if mc.clean && !mc.cache.closed() {
+			caches = append(caches, mc.cache)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, c := range caches {
+		c.cleanCycle()
+	}
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	m := NewManager(&ManagerConfig{CleanDuration: time.Minute, Clock: clock})
+	defer m.Close()
+
+	users := m.Cache("users", &CacheConfig{Clock: clock})
+	pages := m.Cache("pages", &CacheConfig{Clock: clock})
+	manual := m.Cache("manual", &CacheConfig{Clock: clock, DisableCleaner: true})
+
+	if m.Cache("users", nil) != users {
+		t.Errorf("expected the existing cache by the name")
+	}
+
+	if names := m.Names(); len(names) != 3 || names[0] != "manual" || names[2] != "users" {
+		t.Errorf("expected the names in order, got %v", names)
+	}
+
+	for deadline := time.Now().Add(time.Second); clock.Waiters() != 1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if clock.Waiters() != 1 {
+		t.Fatalf("expected one cleaner for every cache, got %d", clock.Waiters())
+	}
+
+	users.Add("key", "value", 30*time.Second)
+	pages.Add("key", "value", 30*time.Second)
+	manual.Add("key", "value", 30*time.Second)
+	users.Get("key")
+
+	clock.Advance(time.Minute)
+	for deadline := time.Now().Add(time.Second); (users.Stats().Entries != 0 || pages.Stats().Entries != 0) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if users.Stats().Entries != 0 || pages.Stats().Entries != 0 {
+		t.Errorf("expected the shared cleaner to clean every cache")
+	}
+
+	if manual.Stats().Entries != 1 {
+		t.Errorf("expected a cache with DisableCleaner not to be cleaned")
+	}
+
+	stats := m.Stats()
+	if len(stats) != 3 || stats["users"].Hits != 1 || stats["users"].Expirations != 1 {
+		t.Errorf("expected the stats of every cache, got %+v", stats)
+	}
+
+	if !m.Remove("pages") || m.Remove("pages") {
+		t.Errorf("expected the cache to be removed once")
+	}
+
+	if _, ok := m.Lookup("pages"); ok {
+		t.Errorf("expected the removed cache to be gone")
+	}
+
+	if !pages.closed() {
+		t.Errorf("expected the removed cache to be closed")
+	}
+
+	m.Close()
+	if !users.closed() || len(m.Names()) != 0 {
+		t.Errorf("expected Close to close every cache")
+	}
+}
+
+func TestManagerSharedConfig(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	m := NewManager(&ManagerConfig{CleanDuration: time.Minute, Clock: clock})
+	defer m.Close()
+
+	config := &CacheConfig{Clock: clock}
+	first := m.Cache("first", config)
+	second := m.Cache("second", config)
+
+	if config.DisableCleaner {
+		t.Errorf("expected the config passed to Cache to be unchanged")
+	}
+
+	first.Add("key", "value", 30*time.Second)
+	second.Add("key", "value", 30*time.Second)
+
+	for deadline := time.Now().Add(time.Second); clock.Waiters() != 1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(time.Minute)
+	for deadline := time.Now().Add(time.Second); (first.Stats().Entries != 0 || second.Stats().Entries != 0) && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	if first.Stats().Expirations != 1 || second.Stats().Expirations != 1 {
+		t.Errorf("expected both caches of the config to be cleaned, got %+v and %+v", first.Stats(), second.Stats())
+	}
+}
+
+func TestManagerPublishExpvar(t *testing.T) {
+	m := NewManager(nil)
+	defer m.Close()
+
+	m.Cache("users", nil).Add("key", "value", 0)
+	err := m.PublishExpvar("manager_test_caches")
+	if err != nil {
+		t.Fatalf("PublishExpvar error: %+v", err)
+	}
+
+	if err := m.PublishExpvar("manager_test_caches"); err != ErrExpvarExists {
+		t.Errorf("expected ErrExpvarExists, got %+v", err)
+	}
+
+	var vars map[string]map[string]interface{}
+	err = json.Unmarshal([]byte(expvar.Get("manager_test_caches").String()), &vars)
+	if err != nil {
+		t.Fatalf("Unmarshal error: %+v", err)
+	}
+
+	if vars["users"]["entries"] != float64(1) {
+		t.Errorf("expected the stats of the cache, got %v", vars)
+	}
+}
@@ -0,0 +1,493 @@
+// Package memcache serves a cache over the memcached text protocol, so
+// that existing memcached clients can use it as an embedded or
+// standalone cache. The get, gets, set, add, replace, cas, delete,
+// incr, decr, touch, version and quit commands are supported. The cas
+// unique of an item is the version the cache tracks for it.
+package memcache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrServerClosed is returned by Serve after the server is closed
+	ErrServerClosed = errors.New("memcache: server closed")
+
+	defaultMaxItemSize = 1024 * 1024
+)
+
+const (
+	maxLineSize    = 2048
+	maxRelativeTTL = 30 * 24 * 60 * 60 // larger expiration times are unix timestamps
+	version        = "1.0.0"
+)
+
+// Item is stored in the cache for values set with non-zero flags.
+// Values set without flags are stored as byte slices. Item is
+// registered with gob, so caches holding them can be saved.
+type Item struct {
+	Flags uint32
+	Value []byte
+}
+
+func init() {
+	gob.Register(&Item{})
+}
+
+// Server serves a cache over the memcached text protocol
+type Server struct {
+	cache       *cache.Cache
+	MaxItemSize int // largest value accepted by storage commands, defaults to 1MB
+
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	mu        *sync.Mutex
+}
+
+// NewServer will create and return a pointer to a new Server for the cache
+func NewServer(c *cache.Cache) *Server {
+	return &Server{
+		cache:       c,
+		MaxItemSize: defaultMaxItemSize,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+		mu:          &sync.Mutex{},
+	}
+}
+
+// ListenAndServe will listen on the TCP address and serve connections
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve will accept connections on the listener and serve each of them
+// on its own goroutine. It always returns a non-nil error, which is
+// ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close will close the listeners and every open connection
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	r := bufio.NewReaderSize(conn, maxLineSize)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(r)
+		if err == errLineTooLong {
+			w.WriteString("CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return
+		} else if err != nil {
+			return
+		}
+
+		if !s.handle(r, w, strings.Fields(line)) {
+			w.Flush()
+			return
+		}
+
+		if r.Buffered() == 0 {
+			if w.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// handle will run one command, returning false when the connection
+// should be closed
+func (s *Server) handle(r *bufio.Reader, w *bufio.Writer, args []string) bool {
+	if len(args) == 0 {
+		w.WriteString("ERROR\r\n")
+		return true
+	}
+
+	switch args[0] {
+	case "get", "gets":
+		s.get(w, args[1:], args[0] == "gets")
+	case "set", "add", "replace", "cas":
+		return s.store(r, w, args)
+	case "delete":
+		s.delete(w, args[1:])
+	case "incr", "decr":
+		s.incr(w, args[1:], args[0] == "decr")
+	case "touch":
+		s.touch(w, args[1:])
+	case "version":
+		w.WriteString("VERSION " + version + "\r\n")
+	case "quit":
+		return false
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+
+	return true
+}
+
+func (s *Server) get(w *bufio.Writer, keys []string, cas bool) {
+	if len(keys) == 0 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+
+	for _, key := range keys {
+		item, version, err := s.cache.GetVersioned(key)
+		if err != nil {
+			continue
+		}
+
+		flags, value, ok := encode(item)
+		if !ok {
+			continue
+		}
+
+		if cas {
+			fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, flags, len(value), version)
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, flags, len(value))
+		}
+		w.Write(value)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("END\r\n")
+}
+
+// store will run a set, add, replace or cas command:
+// <command> <key> <flags> <exptime> <bytes> [noreply] or
+// cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (s *Server) store(r *bufio.Reader, w *bufio.Writer, args []string) bool {
+	n := 5
+	if args[0] == "cas" {
+		n = 6
+	}
+
+	if len(args) != n && len(args) != n+1 {
+		w.WriteString("ERROR\r\n")
+		return true
+	}
+
+	var unique uint64
+	var err4 error
+	if args[0] == "cas" {
+		unique, err4 = strconv.ParseUint(args[5], 10, 64)
+	}
+
+	flags, err1 := strconv.ParseUint(args[2], 10, 32)
+	exptime, err2 := strconv.ParseInt(args[3], 10, 64)
+	size, err3 := strconv.Atoi(args[4])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || size < 0 {
+		w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return true
+	}
+
+	if size > s.MaxItemSize {
+		w.WriteString("SERVER_ERROR object too large for cache\r\n")
+		// the data block cannot be skipped reliably, so the connection is closed
+		return false
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		// skip the rest of the oversized data block
+		if data[size+1] != '\n' {
+			if _, err := readLine(r); err != nil {
+				return false
+			}
+		}
+		w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return true
+	}
+
+	var item interface{} = data[:size]
+	if flags != 0 {
+		item = &Item{Flags: uint32(flags), Value: data[:size]}
+	}
+
+	reply := "STORED\r\n"
+	ttl, expired := expiration(exptime)
+	switch {
+	case args[0] == "add":
+		err := s.cache.Add(args[1], item, ttl)
+		if err == cache.ErrCollision {
+			reply = "NOT_STORED\r\n"
+		} else if err != nil {
+			reply = serverError(err)
+		}
+	case args[0] == "replace":
+		err := s.cache.Update(args[1], item)
+		if err == nil {
+			err = s.cache.Touch(args[1], ttl)
+		}
+
+		if err == cache.ErrDNE {
+			reply = "NOT_STORED\r\n"
+		} else if err != nil {
+			reply = serverError(err)
+		}
+	case args[0] == "cas":
+		err := s.cache.SetVersioned(args[1], item, ttl, unique)
+		if err == cache.ErrDNE {
+			reply = "NOT_FOUND\r\n"
+		} else if err == cache.ErrVersionMismatch {
+			reply = "EXISTS\r\n"
+		} else if err != nil {
+			reply = serverError(err)
+		}
+	default:
+		if err := s.cache.Set(args[1], item, ttl); err != nil {
+			reply = serverError(err)
+		}
+	}
+
+	if expired && reply == "STORED\r\n" {
+		s.cache.Delete(args[1])
+	}
+
+	if len(args) != n+1 || args[n] != "noreply" {
+		w.WriteString(reply)
+	}
+
+	return true
+}
+
+// delete will run a delete command: delete <key> [noreply]
+func (s *Server) delete(w *bufio.Writer, args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+
+	reply := "DELETED\r\n"
+	if err := s.cache.Delete(args[0]); err == cache.ErrDNE {
+		reply = "NOT_FOUND\r\n"
+	} else if err != nil {
+		reply = serverError(err)
+	}
+
+	if len(args) != 2 || args[1] != "noreply" {
+		w.WriteString(reply)
+	}
+}
+
+// incr will run an incr or decr command: incr <key> <value> [noreply].
+// Decrementing below zero stops at zero and incrementing wraps around
+// at 64 bits, as in memcached.
+func (s *Server) incr(w *bufio.Writer, args []string, decr bool) {
+	if len(args) != 2 && len(args) != 3 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+		return
+	}
+
+	var result uint64
+	numeric := true
+	err = s.cache.UpdateIf(args[0], func(cur interface{}) (interface{}, bool) {
+		flags, value, ok := encode(cur)
+		if !ok {
+			numeric = false
+			return nil, false
+		}
+
+		n, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			numeric = false
+			return nil, false
+		}
+
+		switch {
+		case !decr:
+			result = n + delta
+		case delta > n:
+			result = 0
+		default:
+			result = n - delta
+		}
+
+		next := []byte(strconv.FormatUint(result, 10))
+		if flags != 0 {
+			return &Item{Flags: flags, Value: next}, true
+		}
+		return next, true
+	})
+
+	var reply string
+	switch {
+	case err == cache.ErrDNE:
+		reply = "NOT_FOUND\r\n"
+	case err != nil:
+		reply = serverError(err)
+	case !numeric:
+		reply = "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"
+	default:
+		reply = strconv.FormatUint(result, 10) + "\r\n"
+	}
+
+	if len(args) != 3 || args[2] != "noreply" {
+		w.WriteString(reply)
+	}
+}
+
+// touch will run a touch command: touch <key> <exptime> [noreply]
+func (s *Server) touch(w *bufio.Writer, args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		w.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+		return
+	}
+
+	reply := "TOUCHED\r\n"
+	ttl, expired := expiration(exptime)
+	if expired {
+		err = s.cache.Delete(args[0])
+	} else {
+		err = s.cache.Touch(args[0], ttl)
+	}
+
+	if err == cache.ErrDNE {
+		reply = "NOT_FOUND\r\n"
+	} else if err != nil {
+		reply = serverError(err)
+	}
+
+	if len(args) != 3 || args[2] != "noreply" {
+		w.WriteString(reply)
+	}
+}
+
+// expiration will convert a memcached expiration time to a ttl, which
+// is 0 for items that never expire. Times beyond 30 days are unix
+// timestamps, and it reports true for times that have already passed.
+func expiration(exptime int64) (time.Duration, bool) {
+	switch {
+	case exptime < 0:
+		return 0, true
+	case exptime == 0:
+		return 0, false
+	case exptime <= maxRelativeTTL:
+		return time.Duration(exptime) * time.Second, false
+	}
+
+	ttl := time.Until(time.Unix(exptime, 0))
+	if ttl <= 0 {
+		return 0, true
+	}
+
+	return ttl, false
+}
+
+// encode will return the flags and data for an item in the cache
+func encode(item interface{}) (uint32, []byte, bool) {
+	switch v := item.(type) {
+	case *Item:
+		return v.Flags, v.Value, true
+	case []byte:
+		return 0, v, true
+	case string:
+		return 0, []byte(v), true
+	}
+
+	return 0, nil, false
+}
+
+func serverError(err error) string {
+	return "SERVER_ERROR " + err.Error() + "\r\n"
+}
+
+var errLineTooLong = errors.New("line too long")
+
+// readLine will read a command line without its line ending
+func readLine(r *bufio.Reader) (string, error) {
+	line, isPrefix, err := r.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	if isPrefix {
+		return "", errLineTooLong
+	}
+
+	return string(line), nil
+}
@@ -0,0 +1,210 @@
+package memcache
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newTestServer(t *testing.T) (*cache.Cache, *client, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %+v", err)
+	}
+
+	c := cache.NewCache(nil)
+	s := NewServer(c)
+	go s.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("error connecting: %+v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return c, &client{t: t, conn: conn, r: bufio.NewReader(conn)}, func() {
+		conn.Close()
+		s.Close()
+	}
+}
+
+// do will send the command and check the response
+func (c *client) do(command string, expected ...string) {
+	c.t.Helper()
+
+	_, err := io.WriteString(c.conn, command)
+	if err != nil {
+		c.t.Fatalf("error writing command: %+v", err)
+	}
+
+	for _, want := range expected {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("error reading response to %q: %+v", command, err)
+		}
+
+		if got := strings.TrimSuffix(line, "\r\n"); got != want {
+			c.t.Errorf("response to %q was %q, expected %q", command, got, want)
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	c, client, stop := newTestServer(t)
+	defer stop()
+
+	client.do("set a 0 0 5\r\nhello\r\n", "STORED")
+	client.do("get a\r\n", "VALUE a 0 5", "hello", "END")
+
+	client.do("set b 42 60 3\r\nbye\r\n", "STORED")
+	client.do("get a b missing\r\n", "VALUE a 0 5", "hello", "VALUE b 42 3", "bye", "END")
+	_, version, _ := c.GetVersioned("b")
+	client.do("gets b\r\n", "VALUE b 42 3 "+strconv.FormatUint(version, 10), "bye", "END")
+
+	client.do("add a 0 0 1\r\nx\r\n", "NOT_STORED")
+	client.do("replace missing 0 0 1\r\nx\r\n", "NOT_STORED")
+	client.do("replace a 0 0 2\r\nhi\r\n", "STORED")
+	client.do("get a\r\n", "VALUE a 0 2", "hi", "END")
+
+	client.do("set quiet 0 0 1 noreply\r\nq\r\n")
+	client.do("delete quiet\r\n", "DELETED")
+	client.do("delete quiet\r\n", "NOT_FOUND")
+
+	client.do("set bad 0 0 1\r\nxyz\r\n", "CLIENT_ERROR bad data chunk")
+
+	c.Add("native", "string", time.Minute)
+	client.do("get native\r\n", "VALUE native 0 6", "string", "END")
+}
+
+func TestSaveFlaggedItems(t *testing.T) {
+	c, client, stop := newTestServer(t)
+	defer stop()
+
+	client.do("set a 0 0 5\r\nhello\r\n", "STORED")
+	client.do("set b 42 0 3\r\nbye\r\n", "STORED")
+
+	filename := filepath.Join(t.TempDir(), "cache.gob")
+	err := c.Save(filename)
+	if err != nil {
+		t.Fatalf("error saving the cache: %+v", err)
+	}
+
+	loaded := cache.NewCache(nil)
+	defer loaded.Close()
+
+	err = loaded.Load(filename)
+	if err != nil {
+		t.Fatalf("error loading the cache: %+v", err)
+	}
+
+	item, err := loaded.Get("b")
+	if i, ok := item.(*Item); err != nil || !ok || i.Flags != 42 || string(i.Value) != "bye" {
+		t.Errorf("expected the flagged item to be loaded, got %#v: %+v", item, err)
+	}
+}
+
+func TestIncr(t *testing.T) {
+	_, client, stop := newTestServer(t)
+	defer stop()
+
+	client.do("set n 0 0 2\r\n10\r\n", "STORED")
+	client.do("incr n 5\r\n", "15")
+	client.do("decr n 20\r\n", "0")
+	client.do("incr missing 1\r\n", "NOT_FOUND")
+
+	client.do("set s 0 0 3\r\nabc\r\n", "STORED")
+	client.do("incr s 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	client.do("incr n x\r\n", "CLIENT_ERROR invalid numeric delta argument")
+}
+
+func TestCAS(t *testing.T) {
+	c, client, stop := newTestServer(t)
+	defer stop()
+
+	client.do("set a 0 0 1\r\nx\r\n", "STORED")
+	_, version, err := c.GetVersioned("a")
+	if err != nil {
+		t.Fatalf("error getting key: %+v", err)
+	}
+	unique := strconv.FormatUint(version, 10)
+
+	client.do("gets a\r\n", "VALUE a 0 1 "+unique, "x", "END")
+	client.do("cas a 7 100 1 "+unique+"\r\ny\r\n", "STORED")
+	client.do("cas a 0 0 1 "+unique+"\r\nz\r\n", "EXISTS")
+	client.do("get a\r\n", "VALUE a 7 1", "y", "END")
+
+	_, version, _ = c.GetVersioned("a")
+	if strconv.FormatUint(version, 10) == unique {
+		t.Errorf("cas did not change the version of the item")
+	}
+
+	if ttl, err := c.TTL("a"); err != nil || ttl <= 0 || ttl > 100*time.Second {
+		t.Errorf("expected cas to set the expiration, got %s: %+v", ttl, err)
+	}
+
+	client.do("cas missing 0 0 1 1\r\nx\r\n", "NOT_FOUND")
+	client.do("cas a 0 0 1\r\n", "ERROR")
+	client.do("cas a 0 0 1 x\r\n", "CLIENT_ERROR bad command line format")
+}
+
+func TestTouch(t *testing.T) {
+	c, client, stop := newTestServer(t)
+	defer stop()
+
+	client.do("set a 0 1 1\r\nx\r\n", "STORED")
+	client.do("touch a 0\r\n", "TOUCHED")
+	client.do("touch missing 10\r\n", "NOT_FOUND")
+
+	time.Sleep(1100 * time.Millisecond)
+	client.do("get a\r\n", "VALUE a 0 1", "x", "END")
+
+	client.do("touch a -1\r\n", "TOUCHED")
+	if _, err := c.Get("a"); err != cache.ErrDNE {
+		t.Errorf("item was not expired by a negative exptime: %+v", err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	_, client, stop := newTestServer(t)
+	defer stop()
+
+	client.do("bogus\r\n", "ERROR")
+	client.do("set a x 0 1\r\n", "CLIENT_ERROR bad command line format")
+	client.do("version\r\n", "VERSION "+version)
+	client.do("quit\r\n")
+
+	if _, err := client.r.ReadByte(); err != io.EOF {
+		t.Errorf("connection was not closed by quit: %+v", err)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	if ttl, expired := expiration(0); ttl != 0 || expired {
+		t.Errorf("0 should never expire")
+	}
+
+	if ttl, _ := expiration(60); ttl != time.Minute {
+		t.Errorf("relative expiration was %v", ttl)
+	}
+
+	if _, expired := expiration(time.Now().Add(-time.Hour).Unix()); !expired {
+		t.Errorf("past timestamp was not expired")
+	}
+
+	if ttl, _ := expiration(time.Now().Add(40 * 24 * time.Hour).Unix()); ttl < 39*24*time.Hour {
+		t.Errorf("timestamp expiration was %v", ttl)
+	}
+}
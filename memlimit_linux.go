@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup hierarchies are mounted
+var cgroupRoot = "/sys/fs/cgroup"
+
+// memoryLimit will return the memory limit of the container
+// the process is running in, if it has one.
+func memoryLimit() (int64, bool) {
+	data, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		data = nil
+	}
+
+	return cgroupMemoryLimit(cgroupRoot, string(data))
+}
+
+// cgroupMemoryLimit will return the lowest memory limit of the cgroup
+// listed for the process in the /proc/self/cgroup data and those above
+// it, under the cgroup v1 memory controller if it is listed and the
+// cgroup v2 hierarchy otherwise. Without a listed cgroup the limits of
+// the roots are used, which are the process's own in a cgroup namespace.
+func cgroupMemoryLimit(root, cgroups string) (int64, bool) {
+	v1 := path.Join(root, "memory")
+	files := map[string]string{v1: "memory.limit_in_bytes", root: "memory.max"}
+	groups := map[string]string{}
+	for _, line := range strings.Split(cgroups, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[0] == "0" && fields[1] == "" {
+			groups[root] = fields[2]
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "memory" {
+				groups[v1] = fields[2]
+			}
+		}
+	}
+
+	dirs := []string{v1, root}
+	if _, ok := groups[v1]; ok {
+		dirs = []string{v1}
+	} else if _, ok := groups[root]; ok {
+		dirs = []string{root}
+	}
+
+	for _, dir := range dirs {
+		var limit int64
+		found := false
+		for group := path.Clean("/" + groups[dir]); ; group = path.Dir(group) {
+			l, ok := readMemoryLimit(path.Join(dir, group, files[dir]))
+			if ok && (!found || l < limit) {
+				limit, found = l, true
+			}
+
+			if group == "/" {
+				break
+			}
+		}
+
+		if found {
+			return limit, true
+		}
+	}
+
+	return 0, false
+}
+
+// readMemoryLimit will read a cgroup memory limit file,
+// reporting false if it is missing or unlimited
+func readMemoryLimit(file string) (int64, bool) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, false
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	// cgroup v1 reports a page-rounded max int64 when unlimited
+	if limit <= 0 || limit >= 1<<62 {
+		return 0, false
+	}
+
+	return limit, true
+}
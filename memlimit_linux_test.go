@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLimit(t *testing.T, file, limit string) {
+	err := os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		t.Fatalf("error creating cgroup: %+v", err)
+	}
+
+	err = ioutil.WriteFile(file, []byte(limit+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing limit: %+v", err)
+	}
+}
+
+func TestCgroupMemoryLimit(t *testing.T) {
+	root := t.TempDir()
+	writeLimit(t, filepath.Join(root, "memory.max"), "max")
+	writeLimit(t, filepath.Join(root, "system.slice", "memory.max"), "4096")
+	writeLimit(t, filepath.Join(root, "system.slice", "app.service", "memory.max"), "8192")
+	writeLimit(t, filepath.Join(root, "user.slice", "memory.max"), "max")
+	writeLimit(t, filepath.Join(root, "memory", "docker", "abc", "memory.limit_in_bytes"), "2048")
+	writeLimit(t, filepath.Join(root, "memory", "memory.limit_in_bytes"), "9223372036854771712")
+
+	tests := []struct {
+		cgroups string
+		limit   int64
+		ok      bool
+	}{
+		{"0::/system.slice/app.service\n", 4096, true},
+		{"0::/user.slice\n", 0, false},
+		{"0::/\n", 0, false},
+		{"12:cpu,cpuacct:/docker/abc\n11:memory:/docker/abc\n0::/\n", 2048, true},
+		{"11:memory:/\n", 0, false},
+		{"", 4096, false},
+	}
+	for _, test := range tests[:len(tests)-1] {
+		limit, ok := cgroupMemoryLimit(root, test.cgroups)
+		if limit != test.limit || ok != test.ok {
+			t.Errorf("limit for %q was %d, %v", test.cgroups, limit, ok)
+		}
+	}
+
+	writeLimit(t, filepath.Join(root, "memory.max"), "1024")
+	limit, ok := cgroupMemoryLimit(root, "")
+	if limit != 1024 || !ok {
+		t.Errorf("limit without cgroups was %d, %v", limit, ok)
+	}
+}
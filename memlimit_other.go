@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package cache
+
+// memoryLimit will report that there is no container memory limit
+// on platforms without cgroups.
+func memoryLimit() (int64, bool) {
+	return 0, false
+}
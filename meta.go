@@ -0,0 +1,69 @@
+package cache
+
+// WithMeta will attach metadata to the added item, such as the ETag and
+// Last-Modified headers of a cached response. The metadata stays with
+// the item until it is removed or replaced with SetMeta.
+func WithMeta(meta map[string]string) AddOption {
+	return addOptionFunc(func(o *addOptions) {
+		if o.meta == nil {
+			o.meta = make(map[string]string, len(meta))
+		}
+
+		for k, v := range meta {
+			o.meta[k] = v
+		}
+	})
+}
+
+// GetWithMeta will get an item from the cache along with a copy
+// of its metadata, which is nil if the item has none.
+func (t *Cache) GetWithMeta(key string, opts ...GetOption) (interface{}, map[string]string, error) {
+	o := newGetOptions(opts)
+	t.lockGet(&o)
+	defer t.unlockGet(&o)
+
+	hashedKey := t.hash(key)
+
+	item, err := t.getWithOptions(hashedKey, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return item, copyMeta(t.slots[t.keys[hashedKey]].meta), nil
+}
+
+// SetMeta will replace the metadata of the item at the key
+func (t *Cache) SetMeta(key string, meta map[string]string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	idx, ok := t.live(hashedKey)
+	if !ok {
+		return ErrDNE
+	}
+	t.slots[idx].meta = copyMeta(meta)
+	t.slots[idx].modified = t.now()
+
+	return nil
+}
+
+// GetWithMeta will get an item from the bucket along with a copy
+// of its metadata, which is nil if the item has none.
+func (b *Bucket) GetWithMeta(key string, opts ...GetOption) (interface{}, map[string]string, error) {
+	return b.cache.GetWithMeta(b.key(key), opts...)
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	if len(meta) == 0 {
+		return nil
+	}
+
+	c := make(map[string]string, len(meta))
+	for k, v := range meta {
+		c[k] = v
+	}
+
+	return c
+}
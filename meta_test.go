@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetWithMeta(t *testing.T) {
+	cache := NewCache(nil)
+
+	meta := map[string]string{"ETag": `"abc"`, "Last-Modified": "Mon, 02 Jan 2006 15:04:05 GMT"}
+	err := cache.Add("page", []byte("<html>"), 10*time.Minute, WithMeta(meta))
+	if err != nil {
+		t.Errorf("Add error: %+v", err)
+	}
+	meta["ETag"] = "changed"
+
+	item, got, err := cache.GetWithMeta("page")
+	if err != nil || string(item.([]byte)) != "<html>" {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	if got["ETag"] != `"abc"` || len(got) != 2 {
+		t.Errorf("unexpected metadata: %+v", got)
+	}
+	got["ETag"] = "changed"
+
+	// metadata survives an update and is returned as a copy
+	cache.Update("page", []byte("<html></html>"))
+	_, got, _ = cache.GetWithMeta("page")
+	if got["ETag"] != `"abc"` {
+		t.Errorf("unexpected metadata after Update: %+v", got)
+	}
+
+	err = cache.SetMeta("page", map[string]string{"ETag": `"def"`})
+	if err != nil {
+		t.Errorf("SetMeta error: %+v", err)
+	}
+
+	_, got, _ = cache.GetWithMeta("page")
+	if got["ETag"] != `"def"` || len(got) != 1 {
+		t.Errorf("unexpected metadata after SetMeta: %+v", got)
+	}
+
+	cache.Add("plain", 1, 10*time.Minute)
+	if _, got, err := cache.GetWithMeta("plain"); err != nil || got != nil {
+		t.Errorf("expected no metadata, got %+v: %+v", got, err)
+	}
+
+	if _, _, err := cache.GetWithMeta("missing"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	if err := cache.SetMeta("missing", meta); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	// re-adding a deleted key starts without metadata
+	cache.Delete("page")
+	cache.Add("page", 1, 10*time.Minute)
+	if _, got, _ := cache.GetWithMeta("page"); got != nil {
+		t.Errorf("expected no metadata after re-adding, got %+v", got)
+	}
+}
+
+func TestBucketGetWithMeta(t *testing.T) {
+	cache := NewCache(nil)
+	bucket := cache.Bucket("bucket")
+
+	bucket.Add("key", 1, 10*time.Minute, WithMeta(map[string]string{"a": "b"}))
+	item, meta, err := bucket.GetWithMeta("key")
+	if err != nil || item != 1 || meta["a"] != "b" {
+		t.Errorf("unexpected item %v with %+v: %+v", item, meta, err)
+	}
+}
+
+func TestMetaSaveLoad(t *testing.T) {
+	cache := NewCache(nil)
+	cache.Add("key", "value", 10*time.Minute, WithMeta(map[string]string{"ETag": "1"}))
+
+	filename := filepath.Join(t.TempDir(), "cache.gob")
+	if err := cache.Save(filename); err != nil {
+		t.Fatalf("Save error: %+v", err)
+	}
+
+	loaded := NewCache(nil)
+	if err := loaded.Load(filename); err != nil {
+		t.Fatalf("Load error: %+v", err)
+	}
+
+	if _, meta, err := loaded.GetWithMeta("key"); err != nil || meta["ETag"] != "1" {
+		t.Errorf("unexpected metadata %+v: %+v", meta, err)
+	}
+}
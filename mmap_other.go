@@ -0,0 +1,26 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package cache
+
+// MmapStorage is a Storage keeping pages in memory mapped outside
+// of the Go heap, which is unavailable on this platform
+type MmapStorage struct{}
+
+// NewMmapStorage will return ErrMmapUnsupported on this platform
+func NewMmapStorage(dir string) (*MmapStorage, error) {
+	return nil, ErrMmapUnsupported
+}
+
+// Alloc will return ErrMmapUnsupported
+func (s *MmapStorage) Alloc(size int) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+// Free does nothing on this platform
+func (s *MmapStorage) Free(page []byte) {}
+
+// Close does nothing on this platform
+func (s *MmapStorage) Close() error {
+	return nil
+}
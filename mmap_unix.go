@@ -0,0 +1,127 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// maxFreePages is the number of freed pages an MmapStorage
+// keeps mapped for reuse instead of unmapping them
+const maxFreePages = 64
+
+// MmapStorage is a Storage keeping pages in memory mapped outside of
+// the Go heap. Pages are anonymous mappings, or with a directory, are
+// backed by files in it so that the operating system can page values
+// out to disk under memory pressure.
+type MmapStorage struct {
+	dir    string
+	free   map[int][][]byte // unmapped pages by size, kept for reuse
+	mapped map[*byte][]byte // pages in use or kept for reuse
+	mu     *sync.Mutex
+}
+
+// NewMmapStorage will create and return a pointer to a new MmapStorage.
+// Pages are backed by files in dir, or by anonymous memory if dir is empty.
+func NewMmapStorage(dir string) (*MmapStorage, error) {
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			return nil, &os.PathError{Op: "mmap", Path: dir, Err: syscall.ENOTDIR}
+		}
+	}
+
+	return &MmapStorage{
+		dir:    dir,
+		free:   make(map[int][][]byte),
+		mapped: make(map[*byte][]byte),
+		mu:     &sync.Mutex{},
+	}, nil
+}
+
+// Alloc will map a page of size bytes
+func (s *MmapStorage) Alloc(size int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if free := s.free[size]; len(free) > 0 {
+		page := free[len(free)-1]
+		s.free[size] = free[:len(free)-1]
+		return page, nil
+	}
+
+	page, err := s.mmap(size)
+	if err != nil {
+		return nil, err
+	}
+	s.mapped[&page[0]] = page
+
+	return page, nil
+}
+
+// Free will keep the page for reuse, or unmap it
+// once enough pages of its size are kept
+func (s *MmapStorage) Free(page []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.mapped[&page[0]]; !ok {
+		return
+	}
+
+	size := len(page)
+	if len(s.free[size]) < maxFreePages {
+		s.free[size] = append(s.free[size], page)
+		return
+	}
+
+	delete(s.mapped, &page[0])
+	syscall.Munmap(page)
+}
+
+// Close will unmap every page, including those still in use,
+// which must not be accessed afterwards
+func (s *MmapStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for p, page := range s.mapped {
+		delete(s.mapped, p)
+		if e := syscall.Munmap(page); e != nil && err == nil {
+			err = e
+		}
+	}
+	s.free = make(map[int][][]byte)
+
+	return err
+}
+
+func (s *MmapStorage) mmap(size int) ([]byte, error) {
+	if s.dir == "" {
+		return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	}
+
+	f, err := ioutil.TempFile(s.dir, "cache-page-")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// the mapping keeps the unlinked file alive until it is unmapped
+	defer os.Remove(f.Name())
+
+	err = f.Truncate(int64(size))
+	if err != nil {
+		return nil, err
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+}
@@ -0,0 +1,305 @@
+// Package nats provides a NATS Invalidator for keeping several caches
+// coherent, using a minimal client for the NATS text protocol built on
+// the standard library.
+package nats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrClosed is returned by the methods of a closed Invalidator
+	ErrClosed = errors.New("nats: invalidator closed")
+
+	// ErrSubscribed is returned by Subscribe when the Invalidator is already subscribed
+	ErrSubscribed = errors.New("nats: already subscribed")
+
+	errProtocol = errors.New("nats: protocol error")
+
+	defaultDialTimeout = 5 * time.Second
+	defaultReconnect   = 1 * time.Second
+)
+
+const (
+	subscriptionID = "1"
+	maxPayload     = 1024 * 1024
+)
+
+// Config is used to configure a NATS invalidator
+type Config struct {
+	DialTimeout time.Duration // defaults to 5 seconds
+	Reconnect   time.Duration // delay between attempts to restore a dropped connection, defaults to 1 second
+}
+
+// Invalidator is a cache.Invalidator that publishes invalidations as
+// JSON on a NATS subject. A dropped connection is restored every
+// Reconnect while subscribed, and on the next Publish otherwise.
+// Invalidations published while it is down are missed, since core
+// NATS does not keep them.
+type Invalidator struct {
+	addr    string
+	subject string
+	config  *Config
+	conn    net.Conn
+	w       *bufio.Writer
+	fn      func(inv cache.Invalidation)
+	closed  bool
+	done    chan struct{}
+	mu      *sync.Mutex
+}
+
+// NewInvalidator will create and return a pointer to a new Invalidator for
+// the subject on the NATS server at the address. It connects as needed.
+func NewInvalidator(addr, subject string, config *Config) *Invalidator {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.DialTimeout == 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+
+	if config.Reconnect == 0 {
+		config.Reconnect = defaultReconnect
+	}
+
+	return &Invalidator{
+		addr:    addr,
+		subject: subject,
+		config:  config,
+		done:    make(chan struct{}),
+		mu:      &sync.Mutex{},
+	}
+}
+
+// Publish will publish the invalidation on the subject
+func (i *Invalidator) Publish(inv cache.Invalidation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return ErrClosed
+	}
+
+	if i.conn == nil {
+		err = i.connect()
+		if err != nil {
+			return err
+		}
+	}
+
+	i.w.WriteString("PUB " + i.subject + " " + strconv.Itoa(len(data)) + "\r\n")
+	i.w.Write(data)
+	i.w.WriteString("\r\n")
+
+	err = i.w.Flush()
+	if err != nil {
+		i.conn.Close()
+		i.conn = nil
+	}
+
+	return err
+}
+
+// Subscribe will subscribe to the subject and call fn with
+// each invalidation published on it until the Invalidator is closed.
+func (i *Invalidator) Subscribe(fn func(inv cache.Invalidation)) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return ErrClosed
+	} else if i.fn != nil {
+		return ErrSubscribed
+	}
+	i.fn = fn
+
+	if i.conn == nil {
+		err := i.connect()
+		if err != nil {
+			i.fn = nil
+		}
+		return err
+	}
+
+	return i.sub()
+}
+
+// Close will close the connection
+func (i *Invalidator) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return nil
+	}
+	i.closed = true
+	close(i.done)
+
+	if i.conn != nil {
+		return i.conn.Close()
+	}
+
+	return nil
+}
+
+// connect will dial the server, subscribe if there is a subscriber,
+// and start reading from the connection. The lock must be held.
+func (i *Invalidator) connect() error {
+	conn, err := net.DialTimeout("tcp", i.addr, i.config.DialTimeout)
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(i.config.DialTimeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	} else if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return errProtocol
+	}
+	conn.SetDeadline(time.Time{})
+
+	i.conn = conn
+	i.w = bufio.NewWriter(conn)
+	i.w.WriteString(`CONNECT {"verbose":false,"pedantic":false}` + "\r\n")
+
+	if i.fn != nil {
+		err = i.sub()
+	} else {
+		err = i.w.Flush()
+	}
+
+	if err != nil {
+		conn.Close()
+		i.conn = nil
+		return err
+	}
+
+	go i.read(conn, r)
+	return nil
+}
+
+// sub will subscribe the connection to the subject. The lock must be held.
+func (i *Invalidator) sub() error {
+	i.w.WriteString("SUB " + i.subject + " " + subscriptionID + "\r\n")
+	return i.w.Flush()
+}
+
+// read will handle the messages sent on the connection until it fails
+func (i *Invalidator) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			i.mu.Lock()
+			if i.conn == conn {
+				i.w.WriteString("PONG\r\n")
+				i.w.Flush()
+			}
+			i.mu.Unlock()
+		case "MSG":
+			err = i.message(r, args)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	conn.Close()
+	i.reconnect(conn)
+}
+
+// message will read the payload of a MSG and pass the invalidation it
+// holds to the subscriber. Its arguments are: <subject> <sid> [reply-to] <size>
+func (i *Invalidator) message(r *bufio.Reader, args []string) error {
+	if len(args) < 4 {
+		return errProtocol
+	}
+
+	size, err := strconv.Atoi(args[len(args)-1])
+	if err != nil || size < 0 || size > maxPayload {
+		return errProtocol
+	}
+
+	data := make([]byte, size+2)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return err
+	}
+
+	var inv cache.Invalidation
+	if json.Unmarshal(data[:size], &inv) != nil {
+		return nil
+	}
+
+	i.mu.Lock()
+	fn := i.fn
+	i.mu.Unlock()
+
+	if fn != nil {
+		fn(inv)
+	}
+
+	return nil
+}
+
+// reconnect will restore a failed connection every Reconnect while
+// subscribed, unless a Publish has already restored it or it is closed.
+func (i *Invalidator) reconnect(conn net.Conn) {
+	i.mu.Lock()
+	if i.conn == conn {
+		i.conn = nil
+	}
+	i.mu.Unlock()
+
+	for {
+		select {
+		case <-i.done:
+			return
+		case <-time.After(i.config.Reconnect):
+		}
+
+		i.mu.Lock()
+		if i.closed || i.fn == nil || i.conn != nil {
+			i.mu.Unlock()
+			return
+		}
+
+		err := i.connect()
+		i.mu.Unlock()
+
+		if err == nil {
+			return
+		}
+	}
+}
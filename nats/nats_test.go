@@ -0,0 +1,191 @@
+package nats
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+// server is a minimal NATS server supporting CONNECT, PING, SUB and PUB
+type server struct {
+	l     net.Listener
+	conns map[net.Conn]string // subject each connection is subscribed to
+	mu    *sync.Mutex
+}
+
+func newServer(t *testing.T) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %+v", err)
+	}
+
+	s := &server{l: l, conns: make(map[net.Conn]string), mu: &sync.Mutex{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *server) serve(conn net.Conn) {
+	s.mu.Lock()
+	s.conns[conn] = ""
+	s.mu.Unlock()
+
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	io.WriteString(conn, "INFO {}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		switch args[0] {
+		case "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case "SUB":
+			s.mu.Lock()
+			s.conns[conn] = args[1]
+			s.mu.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(args[2])
+			data := make([]byte, size+2)
+			io.ReadFull(r, data)
+
+			s.mu.Lock()
+			for c, subject := range s.conns {
+				if subject == args[1] {
+					io.WriteString(c, "MSG "+subject+" 1 "+args[2]+"\r\n"+string(data))
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// subscribers will return the number of subscribed connections
+func (s *server) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, subject := range s.conns {
+		if subject != "" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// drop will close every connection
+func (s *server) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+func waitFor(fn func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestInvalidator(t *testing.T) {
+	s := newServer(t)
+	defer s.l.Close()
+
+	config := &Config{Reconnect: 10 * time.Millisecond}
+	i1 := NewInvalidator(s.l.Addr().String(), "invalidations", config)
+	defer i1.Close()
+	i2 := NewInvalidator(s.l.Addr().String(), "invalidations", config)
+	defer i2.Close()
+
+	c1 := cache.NewCache(&cache.CacheConfig{Invalidator: i1})
+	defer c1.Close()
+	c2 := cache.NewCache(&cache.CacheConfig{Invalidator: i2})
+	defer c2.Close()
+
+	ok := waitFor(func() bool {
+		return s.subscribers() == 2
+	})
+	if !ok {
+		t.Fatalf("expected both caches to subscribe")
+	}
+
+	err := i1.Subscribe(func(cache.Invalidation) {})
+	if err != ErrSubscribed {
+		t.Errorf("expected ErrSubscribed, got %+v", err)
+	}
+
+	c2.Add("key", "old", time.Minute)
+	c1.Add("own", "value", time.Minute)
+	c1.Set("key", "new", time.Minute)
+
+	ok = waitFor(func() bool {
+		_, err := c2.Get("key")
+		return err == cache.ErrDNE
+	})
+	if !ok {
+		t.Errorf("expected the key to be invalidated")
+	}
+
+	item, err := c1.Get("own")
+	if err != nil || item != "value" {
+		t.Errorf("expected the publishing cache to keep its item, got %v, %+v", item, err)
+	}
+
+	s.drop()
+	ok = waitFor(func() bool {
+		return s.subscribers() == 2
+	})
+	if !ok {
+		t.Fatalf("expected the subscriptions to be restored")
+	}
+
+	c2.Add("key", "old", time.Minute)
+	c1.Set("key", "newer", time.Minute)
+
+	ok = waitFor(func() bool {
+		_, err := c2.Get("key")
+		return err == cache.ErrDNE
+	})
+	if !ok {
+		t.Errorf("expected the key to be invalidated after reconnecting")
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import "sync/atomic"
+
+// Observer is notified of the events of a cache, for instrumentation
+// such as the metrics and traces of the telemetry package. Its methods
+// are called while the cache lock is held, except for Load, so they
+// must be fast and must not call back into the cache.
+type Observer interface {
+	// Hit is called when a get finds the key
+	Hit()
+	// Miss is called when a get does not find the key
+	Miss()
+	// Evict is called when an item is evicted for MaxEntries,
+	// MaxBytes or HeapLimit
+	Evict()
+	// Expire is called when the cleaner removes an expired item
+	Expire()
+	// Load is called before a bucket's Loader or a refresher loads the
+	// item at the key, and returns a function called with the result
+	// of the load once it returns. Bucket is "" for refreshers.
+	Load(bucket, key string) func(err error)
+}
+
+func (t *Cache) countHit() {
+	atomic.AddUint64(&t.counters.hits, 1)
+	if t.config.Observer != nil {
+		t.config.Observer.Hit()
+	}
+}
+
+func (t *Cache) countMiss() {
+	atomic.AddUint64(&t.counters.misses, 1)
+	if t.config.Observer != nil {
+		t.config.Observer.Miss()
+	}
+}
+
+// countEviction will count the eviction of the item at the name,
+// in the stats of its bucket as well if it is in one
+func (t *Cache) countEviction(name string) {
+	atomic.AddUint64(&t.counters.evictions, 1)
+	if b, _ := t.bucketOf(name); b != nil && b.cache == t {
+		atomic.AddUint64(&b.counters.evictions, 1)
+	}
+	if t.config.Observer != nil {
+		t.config.Observer.Evict()
+	}
+}
+
+func (t *Cache) countExpiration() {
+	atomic.AddUint64(&t.counters.expirations, 1)
+	if t.config.Observer != nil {
+		t.config.Observer.Expire()
+	}
+}
+
+// observeLoad will call the Observer before a load,
+// returning the function to call with its result
+func (t *Cache) observeLoad(bucket, key string) func(err error) {
+	if t.config.Observer == nil {
+		return func(err error) {}
+	}
+
+	return t.config.Observer.Load(bucket, key)
+}
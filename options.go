@@ -0,0 +1,215 @@
+package cache
+
+import "time"
+
+// GetOption changes the behavior of a single Get
+type GetOption interface {
+	applyGet(o *getOptions)
+}
+
+// AddOption changes the behavior of a single Add
+type AddOption interface {
+	applyAdd(o *addOptions)
+}
+
+type getOptions struct {
+	noRefresh  bool
+	allowStale bool
+	lane       *Lane
+}
+
+type addOptions struct {
+	tier      Tier
+	refresher func() (interface{}, error)
+	lane      *Lane
+	tags      []string
+	meta      map[string]string
+	priority  *Priority
+	cost      int64
+}
+
+type getOptionFunc func(o *getOptions)
+
+func (f getOptionFunc) applyGet(o *getOptions) { f(o) }
+
+type addOptionFunc func(o *addOptions)
+
+func (f addOptionFunc) applyAdd(o *addOptions) { f(o) }
+
+// NoRefresh will read the item without extending its expiration when
+// Refresh is enabled, letting the read share the read lock
+func NoRefresh() GetOption {
+	return getOptionFunc(func(o *getOptions) {
+		o.noRefresh = true
+	})
+}
+
+// AllowStale will return an item that has expired but has
+// not yet been removed by the cleaner
+func AllowStale() GetOption {
+	return getOptionFunc(func(o *getOptions) {
+		o.allowStale = true
+	})
+}
+
+// WithTier will place the added item in the given storage tier,
+// see AddWithHint
+func WithTier(tier Tier) AddOption {
+	return addOptionFunc(func(o *addOptions) {
+		o.tier = tier
+	})
+}
+
+// WithRefresher will keep the added item warm by reloading it
+// before it expires, see AddWithRefresher
+func WithRefresher(refreshFn func() (interface{}, error)) AddOption {
+	return addOptionFunc(func(o *addOptions) {
+		o.refresher = refreshFn
+	})
+}
+
+// InLane will acquire the cache lock in the given lane,
+// for use with both Get and Add
+func InLane(lane Lane) interface {
+	GetOption
+	AddOption
+} {
+	return laneOption(lane)
+}
+
+type laneOption Lane
+
+func (l laneOption) applyGet(o *getOptions) {
+	lane := Lane(l)
+	o.lane = &lane
+}
+
+func (l laneOption) applyAdd(o *addOptions) {
+	lane := Lane(l)
+	o.lane = &lane
+}
+
+func newGetOptions(opts []GetOption) getOptions {
+	// only allocate when there are options to apply, so calls
+	// without options stay allocation free
+	if len(opts) == 0 {
+		return getOptions{}
+	}
+
+	o := &getOptions{}
+	for _, opt := range opts {
+		opt.applyGet(o)
+	}
+
+	return *o
+}
+
+func newAddOptions(opts []AddOption) addOptions {
+	// only allocate when there are options to apply, so calls
+	// without options stay allocation free
+	if len(opts) == 0 {
+		return addOptions{}
+	}
+
+	o := &addOptions{}
+	for _, opt := range opts {
+		opt.applyAdd(o)
+	}
+
+	return *o
+}
+
+// lockGet will take the cache lock for a get with the options
+func (t *Cache) lockGet(o *getOptions) {
+	if o.lane != nil {
+		t.lanes.enter(*o.lane)
+	}
+
+	if o.noRefresh {
+		t.mu.RLock()
+		return
+	}
+	t.readLock()
+}
+
+// unlockGet will release the lock taken by lockGet
+func (t *Cache) unlockGet(o *getOptions) {
+	if o.noRefresh {
+		t.mu.RUnlock()
+	} else {
+		t.readUnlock()
+	}
+
+	if o.lane != nil {
+		t.lanes.exit(*o.lane)
+	}
+}
+
+// enterAdd will check the options of an add and enter its lane,
+// before the cache lock is taken
+func (t *Cache) enterAdd(o *addOptions) error {
+	switch {
+	case o.tier == TierDisk && t.spill != nil:
+	case o.tier != TierMemory:
+		return ErrTierUnavailable
+	}
+
+	if o.lane != nil {
+		t.lanes.enter(*o.lane)
+	}
+
+	return nil
+}
+
+// exitAdd will leave the lane entered by enterAdd
+func (t *Cache) exitAdd(o *addOptions) {
+	if o.lane != nil {
+		t.lanes.exit(*o.lane)
+	}
+}
+
+// added will apply the options that act on an item once it has been
+// added to the cache. The cache lock must be held by the caller.
+func (t *Cache) added(name string, expiresIn time.Duration, o addOptions) {
+	key := t.hash(name)
+	if t.config.Trace != nil {
+		if idx, ok := t.keys[key]; ok {
+			t.trace(TraceAdd, name, t.slots[idx].size, expiresIn)
+		}
+	}
+
+	if o.cost != 0 {
+		if idx, ok := t.keys[key]; ok {
+			t.slots[idx].cost = o.cost
+		}
+	}
+
+	if o.meta != nil {
+		if idx, ok := t.keys[key]; ok {
+			t.slots[idx].meta = o.meta
+		}
+	}
+
+	if o.priority != nil {
+		if idx, ok := t.keys[key]; ok {
+			t.prioritize(idx, *o.priority)
+		}
+
+		t.mirror(func(shadow *Cache) {
+			if idx, ok := shadow.keys[key]; ok {
+				shadow.prioritize(idx, *o.priority)
+			}
+		})
+	}
+
+	if len(o.tags) > 0 {
+		t.tag(key, o.tags)
+	}
+
+	if o.refresher != nil && expiresIn > 0 {
+		t.scheduleReload(name, &reload{
+			fn:  o.refresher,
+			ttl: expiresIn,
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetOptions(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		Refresh:         true,
+		RefreshDuration: time.Hour,
+		CleanDuration:   time.Hour,
+	})
+
+	err := cache.Add("a", 1, 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+	idx := cache.keys[cache.hash("a")]
+	expiresAt := cache.slots[idx].ExpiresAt
+
+	_, err = cache.Get("a", NoRefresh(), InLane(LaneBackground))
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if !cache.slots[idx].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expiration was refreshed by a NoRefresh get")
+	}
+
+	err = cache.Add("b", 2, time.Millisecond)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := cache.Get("b"); err != ErrDNE {
+		t.Errorf("expired item was returned: %+v", err)
+	}
+
+	item, err := cache.Get("b", AllowStale())
+	if err != nil || item != 2 {
+		t.Errorf("expired item was not returned with AllowStale: %v %+v", item, err)
+	}
+
+	bucket := cache.Bucket("bucket")
+	bucket.Add("c", 3, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	item, err = bucket.Get("c", AllowStale(), NoRefresh())
+	if err != nil || item != 3 {
+		t.Errorf("expired bucket item was not returned with AllowStale: %v %+v", item, err)
+	}
+}
+
+func TestAddOptions(t *testing.T) {
+	cache := NewCache(nil)
+
+	err := cache.Add("a", 1, time.Minute, WithTier(TierRemote))
+	if err != ErrTierUnavailable {
+		t.Errorf("should have returned ErrTierUnavailable but returned %+v", err)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	err = cache.Add("b", 1, 20*time.Millisecond, InLane(LaneBackground), WithRefresher(func() (interface{}, error) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+		return 2, nil
+	}))
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("item added with a refresher was not reloaded")
+	}
+
+	_, err = cache.Bucket("bucket").AddSized("c", 3, time.Minute, WithTier(TierDisk))
+	if err != ErrTierUnavailable {
+		t.Errorf("should have returned ErrTierUnavailable but returned %+v", err)
+	}
+
+	cache.Close()
+}
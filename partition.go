@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+var (
+	defaultPartitionWidth = 1 * time.Minute
+	defaultPartitions     = 10
+)
+
+// PartitionedCache is an in-memory cache that groups entries
+// into time partitions by when they were added. Whole partitions
+// are dropped once they age out of the window, instead of
+// tracking an expiration time for every entry.
+type PartitionedCache struct {
+	partitions []*partition // ordered oldest to newest
+	config     *PartitionConfig
+	mu         *sync.Mutex
+}
+
+// PartitionConfig is used to configure a partitioned cache
+type PartitionConfig struct {
+	Width      time.Duration // span of time covered by each partition
+	Partitions int           // number of partitions kept in the window
+	Clock      Clock         // source of time, defaults to the system clock
+}
+
+type partition struct {
+	start time.Time
+	items map[uint64]interface{}
+}
+
+// NewPartitionedCache will create and return a pointer to a new PartitionedCache object.
+// Entries are kept for Width * Partitions, e.g. "the last 10 minutes" with
+// one minute partitions.
+func NewPartitionedCache(config *PartitionConfig) *PartitionedCache {
+	if config == nil {
+		config = &PartitionConfig{}
+	}
+
+	if config.Width == 0 {
+		config.Width = defaultPartitionWidth
+	}
+
+	if config.Partitions == 0 {
+		config.Partitions = defaultPartitions
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	return &PartitionedCache{
+		partitions: make([]*partition, 0, config.Partitions),
+		config:     config,
+		mu:         &sync.Mutex{},
+	}
+}
+
+// Add will add a key and value to the current partition.
+// If the key already exists in the current partition then an
+// ErrCollision value will be returned.
+func (p *PartitionedCache) Add(key string, item interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hasher := fnv.New64a()
+	_, err := hasher.Write([]byte(key))
+	if err != nil {
+		return err
+	}
+	hashedKey := hasher.Sum64()
+
+	current := p.rotate(p.config.Clock.Now().UTC())
+	if _, ok := current.items[hashedKey]; ok {
+		return ErrCollision
+	}
+	current.items[hashedKey] = item
+
+	return nil
+}
+
+// Delete will delete a key from every partition in the window.
+// It will return ErrDNE if the key does not exist.
+func (p *PartitionedCache) Delete(key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hasher := fnv.New64a()
+	_, err := hasher.Write([]byte(key))
+	if err != nil {
+		return err
+	}
+	hashedKey := hasher.Sum64()
+
+	p.rotate(p.config.Clock.Now().UTC())
+
+	var found bool
+	for _, part := range p.partitions {
+		if _, ok := part.items[hashedKey]; ok {
+			delete(part.items, hashedKey)
+			found = true
+		}
+	}
+
+	if !found {
+		return ErrDNE
+	}
+
+	return nil
+}
+
+// Get will return the newest value stored at the key within the window.
+// It will return an ErrDNE value if key is not in the cache.
+func (p *PartitionedCache) Get(key string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hasher := fnv.New64a()
+	_, err := hasher.Write([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	hashedKey := hasher.Sum64()
+
+	p.rotate(p.config.Clock.Now().UTC())
+
+	for i := len(p.partitions) - 1; i >= 0; i-- {
+		if item, ok := p.partitions[i].items[hashedKey]; ok {
+			return item, nil
+		}
+	}
+
+	return nil, ErrDNE
+}
+
+// Len returns the number of entries across all partitions in the window
+func (p *PartitionedCache) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rotate(p.config.Clock.Now().UTC())
+
+	var n int
+	for _, part := range p.partitions {
+		n += len(part.items)
+	}
+
+	return n
+}
+
+// rotate will drop partitions that have aged out of the window
+// and return the partition for the current time.
+func (p *PartitionedCache) rotate(now time.Time) *partition {
+	start := now.Truncate(p.config.Width)
+	oldest := start.Add(-time.Duration(p.config.Partitions-1) * p.config.Width)
+
+	var drop int
+	for drop < len(p.partitions) && p.partitions[drop].start.Before(oldest) {
+		p.partitions[drop] = nil
+		drop++
+	}
+	p.partitions = p.partitions[drop:]
+
+	if n := len(p.partitions); n > 0 && p.partitions[n-1].start.Equal(start) {
+		return p.partitions[n-1]
+	}
+
+	current := &partition{
+		start: start,
+		items: make(map[uint64]interface{}),
+	}
+	p.partitions = append(p.partitions, current)
+
+	return current
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewPartitionedCache(t *testing.T) {
+	cache := NewPartitionedCache(nil)
+	if cache == nil {
+		t.Error("new partitioned cache not created")
+	}
+}
+
+func TestPartitionedCacheAdd(t *testing.T) {
+	cache := NewPartitionedCache(nil)
+	err := cache.Add("key", "value")
+	if err != nil {
+		t.Errorf("error while adding k/v to cache: %+v", err)
+	}
+
+	err = cache.Add("key", "value")
+	if err != ErrCollision {
+		t.Errorf("did not return collision error when adding existing key: %+v", err)
+	}
+
+	if cache.Len() != 1 {
+		t.Errorf("length of cache was %d", cache.Len())
+	}
+}
+
+func TestPartitionedCacheDelete(t *testing.T) {
+	cache := NewPartitionedCache(nil)
+	err := cache.Delete("dne")
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	err = cache.Add("key", "value")
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Delete("key")
+	if err != nil {
+		t.Errorf("error while deleting key: %+v", err)
+	}
+
+	_, err = cache.Get("key")
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+}
+
+func TestPartitionedCacheRotate(t *testing.T) {
+	cache := NewPartitionedCache(&PartitionConfig{
+		Width:      20 * time.Millisecond,
+		Partitions: 2,
+	})
+
+	err := cache.Add("key", "value")
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	value, err := cache.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "value" {
+		t.Errorf("returned value was %s", value)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = cache.Get("key")
+	if err != ErrDNE {
+		t.Errorf("partition was not dropped: %+v", err)
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("length of cache was %d", cache.Len())
+	}
+}
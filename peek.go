@@ -0,0 +1,29 @@
+package cache
+
+// Peek will return the value stored at the key without side effects:
+// the expiration of the item is not extended by Refresh, the read is not
+// counted in the statistics and it does not make the item more recently
+// used for eviction. It will return ErrDNE if the key is not in cache.
+func (t *Cache) Peek(key string) (interface{}, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	hashedKey := t.hash(key)
+
+	return t.peek(hashedKey)
+}
+
+// Peek will return the value stored at the key in the
+// bucket without side effects, see Cache.Peek
+func (b *Bucket) Peek(key string) (interface{}, error) {
+	return b.cache.Peek(b.key(key))
+}
+
+func (t *Cache) peek(key uint64) (interface{}, error) {
+	idx, ok := t.live(key)
+	if !ok || t.now().After(t.slots[idx].ExpiresAt) {
+		return nil, ErrDNE
+	}
+
+	return t.slots[idx].Item, nil
+}
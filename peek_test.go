@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeek(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		Refresh:          true,
+		RefreshDuration:  time.Minute,
+		MaxEntries:       2,
+		EvictionPolicy:   EvictLRU,
+		AccessSampleRate: 1,
+	})
+
+	cache.Add("a", 1, 10*time.Minute)
+	cache.Add("b", 2, 10*time.Minute)
+	ttl, _ := cache.TTL("a")
+
+	item, err := cache.Peek("a")
+	if err != nil || item != 1 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	if after, _ := cache.TTL("a"); after > ttl {
+		t.Errorf("expected Peek not to refresh the item")
+	}
+
+	if stats := cache.Stats(); stats.Hits != 0 {
+		t.Errorf("expected Peek not to be counted, got %d hits", stats.Hits)
+	}
+
+	if stats := cache.AccessStats(); len(stats) != 0 {
+		t.Errorf("expected Peek not to be recorded, got %+v", stats)
+	}
+
+	// a peeked item is still the least recently used
+	cache.Add("c", 3, 10*time.Minute)
+	if _, err := cache.Peek("a"); err != ErrDNE {
+		t.Errorf("expected the peeked item to be evicted, got %+v", err)
+	}
+
+	cache.Add("expired", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := cache.Peek("expired"); err != ErrDNE {
+		t.Errorf("expected ErrDNE for an expired item, got %+v", err)
+	}
+
+	cache.Bucket("bucket").Add("d", 4, 10*time.Minute)
+	if item, err := cache.Bucket("bucket").Peek("d"); err != nil || item != 4 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+}
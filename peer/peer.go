@@ -0,0 +1,262 @@
+// Package peer fills cache misses from the node that owns each key,
+// in the style of groupcache. Keys are assigned to nodes with a
+// consistent hash ring, and a miss on any node is filled over HTTP
+// by the owner, which is the only node that invokes the loader.
+// Concurrent misses for a key are deduplicated by the bucket the
+// group is backed by, so each node makes one request per key at a time.
+//
+// Each node serves its groups at BasePath:
+//
+//	GET {BasePath}{group}/{key}   returns the value of the key, loading it if needed
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/ring"
+)
+
+var (
+	// ErrNoGroup is returned when a peer requests a group that does not exist
+	ErrNoGroup = errors.New("peer: no such group")
+
+	defaultBasePath = "/_cache/"
+	defaultTimeout  = 5 * time.Second
+)
+
+// Getter loads the value of a key on the node that owns it
+type Getter func(key string) ([]byte, error)
+
+// PoolConfig is used to configure a Pool
+type PoolConfig struct {
+	BasePath string       // path the groups are served at, defaults to "/_cache/"
+	Replicas int          // virtual nodes of each peer on the ring, defaults to 100
+	Client   *http.Client // client used to fetch from peers, defaults to one with a 5 second timeout
+}
+
+// Pool is the set of peers of a node. It is an http.Handler
+// that serves the node's groups to the other peers.
+type Pool struct {
+	self   string
+	cache  *cache.Cache
+	config *PoolConfig
+	ring   *ring.Ring
+	groups map[string]*Group
+	mu     *sync.RWMutex
+}
+
+// Group is a named set of keys filled from their owners
+type Group struct {
+	name   string
+	pool   *Pool
+	getter Getter
+	bucket *cache.Bucket
+}
+
+// NewPool will create and return a pointer to a new Pool for the node
+// reachable by the other peers at the self base URL, e.g. "http://10.0.0.1:8080".
+func NewPool(self string, c *cache.Cache, config *PoolConfig) *Pool {
+	if config == nil {
+		config = &PoolConfig{}
+	}
+
+	if config.BasePath == "" {
+		config.BasePath = defaultBasePath
+	}
+
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: defaultTimeout}
+	}
+
+	p := &Pool{
+		self:   self,
+		cache:  c,
+		config: config,
+		ring:   ring.New(config.Replicas),
+		groups: make(map[string]*Group),
+		mu:     &sync.RWMutex{},
+	}
+	p.ring.AddNode(self, nil)
+
+	return p
+}
+
+// Set will replace the peers with the base URLs,
+// which should include the node's own URL.
+func (p *Pool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		next[peer] = true
+	}
+
+	for _, peer := range p.ring.Nodes() {
+		if !next[peer] {
+			p.ring.RemoveNode(peer)
+		}
+	}
+
+	for peer := range next {
+		p.ring.AddNode(peer, nil)
+	}
+}
+
+// NewGroup will create a group whose keys are loaded with the getter by
+// their owner and kept in the bucket of the same name for the ttl.
+func (p *Pool) NewGroup(name string, ttl time.Duration, getter Getter) *Group {
+	g := &Group{
+		name:   name,
+		pool:   p,
+		getter: getter,
+	}
+	g.bucket = p.cache.BucketWithConfig(name, &cache.BucketConfig{
+		Loader:  g.load,
+		LoadTTL: ttl,
+	})
+
+	p.mu.Lock()
+	p.groups[name] = g
+	p.mu.Unlock()
+
+	return g
+}
+
+// Get will return the value of the key from the cache, filling
+// a miss from the owner of the key. If the owner cannot be reached
+// the value is loaded locally instead.
+func (g *Group) Get(key string) ([]byte, error) {
+	item, err := g.bucket.GetOrLoad(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return item.([]byte), nil
+}
+
+// load will fetch the value of the key from its owner,
+// or invoke the getter when the node owns the key itself
+func (g *Group) load(key string) (interface{}, error) {
+	owner := g.pool.owner(key)
+	if owner != g.pool.self {
+		value, err := g.pool.fetch(owner, g.name, key)
+		if err == nil {
+			return value, nil
+		} else if err == cache.ErrDNE {
+			return nil, err
+		}
+	}
+
+	return g.getter(key)
+}
+
+func (p *Pool) owner(key string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	name, _, err := p.ring.Node(key)
+	if err != nil {
+		return p.self
+	}
+
+	return name
+}
+
+// fetch will request the value of the key from a peer
+func (p *Pool) fetch(peer, group, key string) ([]byte, error) {
+	u := strings.TrimSuffix(peer, "/") + p.config.BasePath + url.PathEscape(group) + "/" + url.PathEscape(key)
+
+	resp, err := p.config.Client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound:
+		return nil, cache.ErrDNE
+	}
+
+	return nil, fmt.Errorf("peer: %s returned %s: %s", peer, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// ServeHTTP will serve the value of a key to a peer
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	if !strings.HasPrefix(path, p.config.BasePath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(path, p.config.BasePath), "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+
+	name, err := url.PathUnescape(parts[0])
+	if err != nil {
+		http.Error(w, "invalid group", http.StatusBadRequest)
+		return
+	}
+
+	key, err := url.PathUnescape(parts[1])
+	if err != nil || key == "" {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.RLock()
+	g, ok := p.groups[name]
+	p.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, ErrNoGroup.Error(), http.StatusNotFound)
+		return
+	}
+
+	value, err := g.serve(key)
+	if err == cache.ErrDNE {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+// serve will return the value of a key requested by a peer. Keys the node
+// does not own, which peers request while their rings disagree, are
+// loaded directly rather than forwarded so requests cannot loop.
+func (g *Group) serve(key string) ([]byte, error) {
+	if g.pool.owner(key) == g.pool.self {
+		return g.Get(key)
+	}
+
+	return g.getter(key)
+}
@@ -0,0 +1,140 @@
+package peer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+type testNode struct {
+	server *httptest.Server
+	pool   *Pool
+	group  *Group
+	cache  *cache.Cache
+}
+
+// newTestNodes will start size nodes sharing one
+// group, counting the loads made for each key
+func newTestNodes(t *testing.T, size int, loads map[string]int, mu *sync.Mutex) []*testNode {
+	var nodes []*testNode
+	var urls []string
+	for i := 0; i < size; i++ {
+		n := &testNode{cache: cache.NewCache(nil)}
+		n.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n.pool.ServeHTTP(w, r)
+		}))
+		n.pool = NewPool(n.server.URL, n.cache, nil)
+		n.group = n.pool.NewGroup("users", time.Minute, func(key string) ([]byte, error) {
+			if key == "missing" {
+				return nil, cache.ErrDNE
+			}
+
+			mu.Lock()
+			loads[key]++
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+			return []byte("value of " + key), nil
+		})
+
+		nodes = append(nodes, n)
+		urls = append(urls, n.server.URL)
+	}
+
+	for _, n := range nodes {
+		n.pool.Set(urls...)
+	}
+
+	return nodes
+}
+
+func closeNodes(nodes []*testNode) {
+	for _, n := range nodes {
+		n.server.Close()
+		n.cache.Close()
+	}
+}
+
+func TestPeerFill(t *testing.T) {
+	loads := make(map[string]int)
+	mu := &sync.Mutex{}
+	nodes := newTestNodes(t, 3, loads, mu)
+	defer closeNodes(nodes)
+
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i)
+		for _, n := range nodes {
+			value, err := n.group.Get(key)
+			if err != nil || string(value) != "value of "+key {
+				t.Errorf("unexpected value %q: %+v", value, err)
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(loads) != 20 {
+		t.Errorf("expected every key to be loaded, got %d", len(loads))
+	}
+
+	for key, count := range loads {
+		if count != 1 {
+			t.Errorf("expected %s to be loaded once across the peers, got %d", key, count)
+		}
+	}
+}
+
+func TestPeerFillConcurrent(t *testing.T) {
+	loads := make(map[string]int)
+	mu := &sync.Mutex{}
+	nodes := newTestNodes(t, 2, loads, mu)
+	defer closeNodes(nodes)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		n := nodes[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.group.Get("hot")
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if loads["hot"] != 1 {
+		t.Errorf("expected concurrent misses to be deduplicated, got %d loads", loads["hot"])
+	}
+}
+
+func TestPeerFillErrors(t *testing.T) {
+	loads := make(map[string]int)
+	mu := &sync.Mutex{}
+	nodes := newTestNodes(t, 2, loads, mu)
+	defer closeNodes(nodes)
+
+	for _, n := range nodes {
+		_, err := n.group.Get("missing")
+		if err != cache.ErrDNE {
+			t.Errorf("expected ErrDNE, got %+v", err)
+		}
+	}
+
+	// an unreachable owner falls back to loading locally
+	nodes[0].pool.Set(nodes[0].server.URL, "http://127.0.0.1:1")
+	for i := 0; i < 10; i++ {
+		key := "down" + strconv.Itoa(i)
+		value, err := nodes[0].group.Get(key)
+		if err != nil || string(value) != "value of "+key {
+			t.Errorf("unexpected value %q: %+v", value, err)
+		}
+	}
+}
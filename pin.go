@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotPinned is returned by Unpin when the item is not pinned
+var ErrNotPinned = errors.New("item is not pinned")
+
+// Pin will keep the item at the key in the cache when it is chosen for
+// eviction, so that it is never evicted for MaxEntries, MaxBytes or
+// HeapLimit. If holdExpiry is set the item also does not expire while
+// it is pinned, and once unpinned it expires at the expiration it would
+// otherwise have had. Pinned items are still returned by iteration and
+// counted by Stats, and are removed by Delete and Flush as usual. It
+// will return ErrDNE if the key does not exist or has expired.
+func (t *Cache) Pin(key string, holdExpiry bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	return t.pin(hashedKey, holdExpiry)
+}
+
+// Unpin will let the item at the key be evicted again, and expire
+// if its expiration was held. It will return ErrDNE if the key does
+// not exist or has expired, or ErrNotPinned if it is not pinned.
+func (t *Cache) Unpin(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	return t.unpin(hashedKey)
+}
+
+// Pinned will report whether the item at the key is pinned
+func (t *Cache) Pinned(key string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	hashedKey := t.hash(key)
+
+	idx, ok := t.live(hashedKey)
+	return ok && t.slots[idx].pinned
+}
+
+func (t *Cache) pin(key uint64, holdExpiry bool) error {
+	idx, ok := t.live(key)
+	if !ok || t.now().After(t.slots[idx].ExpiresAt) {
+		return ErrDNE
+	}
+
+	if !t.slots[idx].pinned {
+		t.slots[idx].pinned = true
+		t.pins++
+	}
+
+	if holdExpiry && t.slots[idx].held.IsZero() {
+		t.slots[idx].held = t.slots[idx].ExpiresAt
+		t.expireAt(idx, t.slots[idx].ExpiresAt)
+	}
+
+	t.mirror(func(shadow *Cache) {
+		shadow.pin(key, holdExpiry)
+	})
+
+	return nil
+}
+
+func (t *Cache) unpin(key uint64) error {
+	idx, ok := t.live(key)
+	if !ok || t.now().After(t.slots[idx].ExpiresAt) {
+		return ErrDNE
+	}
+
+	if !t.slots[idx].pinned {
+		return ErrNotPinned
+	}
+
+	t.slots[idx].pinned = false
+	t.pins--
+
+	if held := t.slots[idx].held; !held.IsZero() {
+		t.slots[idx].held = time.Time{}
+		t.expireAt(idx, held)
+	}
+
+	t.mirror(func(shadow *Cache) {
+		shadow.unpin(key)
+	})
+
+	return nil
+}
+
+// unpinned will wrap skip to also pass over pinned keys, for the evictor
+func (t *Cache) unpinned(skip func(key uint64) bool) func(key uint64) bool {
+	if t.pins == 0 {
+		return skip
+	}
+
+	return func(key uint64) bool {
+		if idx, ok := t.keys[key]; ok && t.slots[idx].pinned {
+			return true
+		}
+
+		return skip(key)
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictTTL, EvictLRU, EvictLFU, EvictARC} {
+		cache := NewCache(&CacheConfig{MaxEntries: 2, EvictionPolicy: policy})
+
+		cache.Add("flag", 1, time.Hour)
+		if err := cache.Pin("flag", false); err != nil {
+			t.Fatalf("Pin error: %+v", err)
+		}
+
+		for _, key := range []string{"a", "b", "c", "d"} {
+			cache.Add(key, 2, 2*time.Hour)
+		}
+
+		if _, err := cache.Get("flag"); err != nil {
+			t.Errorf("expected the pinned item to survive eviction with policy %v, got %+v", policy, err)
+		}
+
+		if stats := cache.Stats(); stats.Pinned != 1 || stats.Entries != 2 {
+			t.Errorf("expected the pinned item to be counted, got %+v", stats)
+		}
+
+		if err := cache.Unpin("flag"); err != nil || cache.Pinned("flag") {
+			t.Errorf("expected the item to be unpinned, got %+v", err)
+		}
+
+		if policy == EvictTTL {
+			cache.Add("e", 3, 2*time.Hour)
+			if _, err := cache.Get("flag"); err != ErrDNE {
+				t.Errorf("expected the unpinned item to be evicted, got %+v", err)
+			}
+		}
+
+		cache.Close()
+	}
+}
+
+func TestPinHoldExpiry(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, DisableCleaner: true})
+	defer cache.Close()
+
+	cache.Add("key", 1, time.Minute)
+	cache.Add("other", 2, time.Minute)
+	if err := cache.Pin("key", true); err != nil {
+		t.Fatalf("Pin error: %+v", err)
+	}
+	cache.Pin("other", false)
+
+	clock.Advance(2 * time.Minute)
+	cache.DeleteExpired()
+
+	if _, err := cache.Get("key"); err != nil {
+		t.Errorf("expected the held item not to expire, got %+v", err)
+	}
+
+	if _, err := cache.Get("other"); err != ErrDNE {
+		t.Errorf("expected a pinned item without a held expiry to expire, got %+v", err)
+	}
+
+	if cache.Stats().Pinned != 1 {
+		t.Errorf("expected the expired pin to be released, got %d", cache.Stats().Pinned)
+	}
+
+	// a touch while pinned applies once unpinned
+	cache.Touch("key", time.Minute)
+	clock.Advance(30 * time.Second)
+	if err := cache.Unpin("key"); err != nil {
+		t.Errorf("Unpin error: %+v", err)
+	}
+
+	if _, err := cache.Get("key"); err != nil {
+		t.Errorf("expected the item to keep its new expiration, got %+v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := cache.Get("key"); err != ErrDNE {
+		t.Errorf("expected the unpinned item to expire, got %+v", err)
+	}
+
+	if err := cache.Unpin("key"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	cache.Add("unpinned", 3, time.Minute)
+	if err := cache.Unpin("unpinned"); err != ErrNotPinned {
+		t.Errorf("expected ErrNotPinned, got %+v", err)
+	}
+
+	if err := cache.Pin("missing", true); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
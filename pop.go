@@ -0,0 +1,60 @@
+package cache
+
+// Pop will atomically get and delete the item at the key, so that
+// only one caller can ever receive it, e.g. to redeem a one-time token.
+// It will return ErrDNE if the key is not in cache.
+func (t *Cache) Pop(key string) (interface{}, error) {
+	t.trace(TraceGet, key, 0, 0)
+
+	var item interface{}
+	err := t.write(func() error {
+		hashedKey := t.hash(key)
+
+		var err error
+		item, err = t.get(hashedKey)
+		if err != nil {
+			return err
+		}
+
+		tx := t.beginStore(hashedKey, key)
+		err = t.delete(hashedKey)
+		if err != nil {
+			return err
+		}
+		t.trace(TraceDelete, key, 0, 0)
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Pop will atomically get and delete the item at the key
+// in the bucket, see Cache.Pop
+func (b *Bucket) Pop(key string) (interface{}, error) {
+	pk := b.key(key)
+	b.cache.trace(TraceGet, pk, 0, 0)
+
+	var item interface{}
+	err := b.cache.write(func() error {
+		hk := b.cache.hash(pk)
+
+		var err error
+		item, err = b.cache.get(hk)
+		if err != nil {
+			return err
+		}
+
+		return b.delete(hk, pk)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPop(t *testing.T) {
+	cache := NewCache(nil)
+	cache.Add("token", "reset-password", 10*time.Minute)
+
+	var redeemed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if item, err := cache.Pop("token"); err == nil && item == "reset-password" {
+				atomic.AddInt64(&redeemed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if redeemed != 1 {
+		t.Errorf("expected the token to be redeemed once, got %d", redeemed)
+	}
+
+	if _, err := cache.Pop("token"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	cache.Add("expired", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := cache.Pop("expired"); err != ErrDNE {
+		t.Errorf("expected ErrDNE for an expired item, got %+v", err)
+	}
+}
+
+func TestBucketPop(t *testing.T) {
+	cache := NewCache(nil)
+	bucket := cache.Bucket("nonces")
+	bucket.Add("n1", 1, 10*time.Minute)
+
+	item, err := bucket.Pop("n1")
+	if err != nil || item != 1 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	if bucket.Len() != 0 {
+		t.Errorf("expected the key to be removed from the bucket, got %d", bucket.Len())
+	}
+
+	if _, err := bucket.Pop("n1"); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
+
+func TestPopStoreFailure(t *testing.T) {
+	store := newMapStore()
+	cache := NewCache(&CacheConfig{Store: store})
+	cache.Add("token", 1, 10*time.Minute)
+
+	store.setFail(true)
+	if _, err := cache.Pop("token"); err != errStoreDown {
+		t.Errorf("expected the store error, got %+v", err)
+	}
+
+	if item, err := cache.Get("token"); err != nil || item != 1 {
+		t.Errorf("expected the token to be kept when the store fails, got %v: %+v", item, err)
+	}
+}
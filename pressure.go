@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var defaultPressureInterval = 1 * time.Second
+
+// heapInUse will return the bytes of the Go heap in use.
+// It is a variable so that tests can simulate memory pressure.
+var heapInUse = func() int64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
+
+// OnShed is called by a MemoryWatcher with the items it shed from a cache
+type OnShed func(cache *Cache, shed []Entry)
+
+// MemoryWatcherConfig is used to configure a MemoryWatcher
+type MemoryWatcherConfig struct {
+	SoftLimit int64         // bytes of Go heap beyond which items are shed
+	Interval  time.Duration // interval at which the heap is sampled, defaults to 1 second
+	Clock     Clock         // source of the interval, defaults to the system clock
+	OnShed    OnShed        // called with the items shed from each cache
+}
+
+// MemoryWatcher samples the Go heap on an interval and sheds items from
+// the caches it watches while the heap is over its soft limit. The share
+// of each cache's items shed is the share of the heap above the limit,
+// so a heap 10% over the limit sheds 10% of the items of every cache.
+// Keeping the soft limit below a container's hard memory limit lets the
+// caches give memory back before the process is killed.
+type MemoryWatcher struct {
+	config   *MemoryWatcherConfig
+	caches   []*Cache
+	done     chan struct{}
+	stopOnce *sync.Once
+	mu       *sync.Mutex
+}
+
+// NewMemoryWatcher will create a MemoryWatcher and start sampling the heap
+func NewMemoryWatcher(config *MemoryWatcherConfig) *MemoryWatcher {
+	if config == nil {
+		config = &MemoryWatcherConfig{}
+	}
+
+	if config.Interval <= 0 {
+		config.Interval = defaultPressureInterval
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	w := &MemoryWatcher{
+		config:   config,
+		done:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		mu:       &sync.Mutex{},
+	}
+	go w.run()
+
+	return w
+}
+
+// Watch will shed items from the caches while the heap is over the limit
+func (w *MemoryWatcher) Watch(caches ...*Cache) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.caches = append(w.caches, caches...)
+}
+
+// Unwatch will stop shedding items from the cache
+func (w *MemoryWatcher) Unwatch(cache *Cache) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i, c := range w.caches {
+		if c == cache {
+			w.caches = append(w.caches[:i], w.caches[i+1:]...)
+			return
+		}
+	}
+}
+
+// Check will sample the heap now, shedding items if it is over the
+// limit, and return the number of items shed from every cache
+func (w *MemoryWatcher) Check() int {
+	heap := heapInUse()
+	if w.config.SoftLimit <= 0 || heap <= w.config.SoftLimit {
+		return 0
+	}
+	fraction := float64(heap-w.config.SoftLimit) / float64(heap)
+
+	w.mu.Lock()
+	caches := make([]*Cache, 0, len(w.caches))
+	for _, c := range w.caches {
+		if !c.closed() {
+			caches = append(caches, c)
+		}
+	}
+	w.mu.Unlock()
+
+	var total int
+	for _, c := range caches {
+		slots := c.shed(fraction)
+		total += len(slots)
+
+		if w.config.OnShed != nil && len(slots) > 0 {
+			w.config.OnShed(c, entries(slots))
+		}
+	}
+
+	return total
+}
+
+// Stop will stop sampling the heap
+func (w *MemoryWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *MemoryWatcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.config.Clock.After(w.config.Interval):
+		}
+
+		w.Check()
+	}
+}
+
+// closed will report whether the cache has been closed
+func (t *Cache) closed() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// shed will evict the fraction of the items in the cache chosen by the
+// evictor, at least one, and return the evicted slots. Like items
+// evicted for capacity, their expiration callbacks are not invoked,
+// and they are passed to the OnEvict callbacks with ReasonPressure.
+func (t *Cache) shed(fraction float64) []Slot {
+	t.mu.Lock()
+
+	n := int(math.Ceil(fraction * float64(len(t.keys))))
+	skip := func(key uint64) bool {
+		return false
+	}
+
+	var shed []Slot
+	for len(shed) < n {
+		slot, ok := t.evictVictim(skip)
+		if !ok {
+			break
+		}
+		shed = append(shed, slot)
+	}
+	removed := t.removals(shed, ReasonPressure)
+	t.mu.Unlock()
+
+	t.notify(removed, ReasonPressure, false)
+
+	return shed
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryPressure(t *testing.T) {
+	var heap int64 = 100
+	defer func(read func() int64) {
+		heapInUse = read
+	}(heapInUse)
+	heapInUse = func() int64 {
+		return atomic.LoadInt64(&heap)
+	}
+
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{
+		Clock:          clock,
+		HeapLimit:      100,
+		EvictionPolicy: EvictLRU,
+		DisableCleaner: true,
+	})
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+	}
+
+	clock.Advance(time.Second)
+	waitFor(func() bool { return clock.Waiters() == 1 })
+	if n := len(cache.Keys()); n != 100 {
+		t.Errorf("expected no items to be shed within the limit, got %d", n)
+	}
+
+	// a heap 20% over the limit sheds 20% of the items
+	atomic.StoreInt64(&heap, 125)
+	clock.Advance(time.Second)
+	if !waitFor(func() bool { return len(cache.Keys()) == 80 }) {
+		t.Errorf("expected 20 items to be shed, got %d", len(cache.Keys()))
+	}
+
+	if _, err := cache.Get("key-0"); err != ErrDNE {
+		t.Errorf("expected the least recently used items to be shed, got %+v", err)
+	}
+
+	if cache.Stats().Evictions != 20 {
+		t.Errorf("expected shed items to count as evictions, got %d", cache.Stats().Evictions)
+	}
+}
+
+func TestShed(t *testing.T) {
+	cache := NewCache(nil)
+	defer cache.Close()
+
+	cache.Bucket("bucket")
+	for i := 0; i < 10; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+	}
+
+	if n := len(cache.shed(0.01)); n != 1 {
+		t.Errorf("expected at least one item to be shed, got %d", n)
+	}
+
+	if n := len(cache.shed(1)); n != 9 {
+		t.Errorf("expected the remaining items to be shed, got %d", n)
+	}
+
+	if len(cache.Buckets()) != 1 {
+		t.Errorf("expected buckets to be kept")
+	}
+}
+
+func TestMemoryWatcher(t *testing.T) {
+	var heap int64 = 100
+	defer func(read func() int64) {
+		heapInUse = read
+	}(heapInUse)
+	heapInUse = func() int64 {
+		return atomic.LoadInt64(&heap)
+	}
+
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	shed := make(chan []Entry, 2)
+	w := NewMemoryWatcher(&MemoryWatcherConfig{
+		SoftLimit: 100,
+		Interval:  time.Minute,
+		Clock:     clock,
+		OnShed: func(cache *Cache, entries []Entry) {
+			shed <- entries
+		},
+	})
+	defer w.Stop()
+
+	first := NewCache(&CacheConfig{EvictionPolicy: EvictLRU})
+	defer first.Close()
+	second := NewCache(&CacheConfig{EvictionPolicy: EvictLRU})
+	defer second.Close()
+	w.Watch(first, second)
+
+	for i := 0; i < 10; i++ {
+		first.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+		second.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+		second.Add(fmt.Sprintf("other-%d", i), i, time.Hour)
+	}
+
+	if n := w.Check(); n != 0 {
+		t.Errorf("expected no items to be shed within the limit, got %d", n)
+	}
+
+	// half of the heap is over the limit
+	atomic.StoreInt64(&heap, 200)
+	waitFor(func() bool { return clock.Waiters() == 1 })
+	clock.Advance(time.Minute)
+
+	for _, expected := range []int{5, 10} {
+		select {
+		case entries := <-shed:
+			if len(entries) != expected {
+				t.Errorf("expected %d items to be shed, got %d", expected, len(entries))
+			}
+			if len(entries) > 0 && entries[0].Key != "key-0" {
+				t.Errorf("expected the least recently used item first, got %+v", entries[0])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected OnShed to be called")
+		}
+	}
+
+	if len(first.Keys()) != 5 || len(second.Keys()) != 10 {
+		t.Errorf("expected half of each cache to remain, got %d and %d", len(first.Keys()), len(second.Keys()))
+	}
+
+	w.Unwatch(second)
+	if n := w.Check(); n != 3 {
+		t.Errorf("expected only the watched cache to be shed, got %d", n)
+	}
+	<-shed
+
+	first.Close()
+	if n := w.Check(); n != 0 {
+		t.Errorf("expected closed caches to be skipped, got %d", n)
+	}
+}
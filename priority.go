@@ -0,0 +1,105 @@
+package cache
+
+import "time"
+
+// Priority orders the items evicted for MaxEntries, MaxBytes and
+// HeapLimit. The eviction policy only chooses among the items of the
+// lowest priority in the cache, so low priority items are evicted
+// before any normal priority item, and high priority items last.
+type Priority int
+
+const (
+	// PriorityLow items are evicted first
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is the priority of items added without one
+	PriorityNormal
+	// PriorityHigh items are evicted last
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// WithPriority will give the added item the priority, overriding
+// the Priority of the bucket it is added to
+func WithPriority(p Priority) AddOption {
+	return addOptionFunc(func(o *addOptions) {
+		o.priority = &p
+	})
+}
+
+// AddWithPriority will add an item to the cache with the priority
+func (t *Cache) AddWithPriority(key string, item interface{}, expiresIn time.Duration, p Priority, opts ...AddOption) error {
+	return t.Add(key, item, expiresIn, append(opts, WithPriority(p))...)
+}
+
+// SetPriority will change the priority of the item at the key.
+// It will return ErrDNE if the key does not exist.
+func (t *Cache) SetPriority(key string, p Priority) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	idx, ok := t.live(hashedKey)
+	if !ok {
+		return ErrDNE
+	}
+	t.prioritize(idx, p)
+
+	t.mirror(func(shadow *Cache) {
+		if idx, ok := shadow.live(hashedKey); ok {
+			shadow.prioritize(idx, p)
+		}
+	})
+
+	return nil
+}
+
+// prioritize will set the priority of the item in the slot
+func (t *Cache) prioritize(idx int, p Priority) {
+	if t.slots[idx].priority != PriorityNormal {
+		t.prioritized--
+	}
+
+	if p != PriorityNormal {
+		t.prioritized++
+	}
+	t.slots[idx].priority = p
+}
+
+// victim will return the next item chosen by the evictor from the
+// items of the lowest priority, passing over the keys skip reports
+// true for. Keys that are no longer in the cache are chosen first.
+func (t *Cache) victim(skip func(key uint64) bool) (uint64, bool) {
+	if t.prioritized == 0 {
+		return t.evictor.Victim(skip)
+	}
+
+	for level := PriorityLow; level <= PriorityHigh; level++ {
+		level := level
+		victim, ok := t.evictor.Victim(func(key uint64) bool {
+			if skip(key) {
+				return true
+			}
+
+			idx, ok := t.keys[key]
+			return ok && t.slots[idx].priority > level
+		})
+		if ok {
+			return victim, true
+		}
+	}
+
+	return 0, false
+}
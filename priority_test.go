@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPriority(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictTTL, EvictLRU, EvictLFU, EvictARC} {
+		cache := NewCache(&CacheConfig{MaxEntries: 4, EvictionPolicy: policy})
+
+		cache.AddWithPriority("high", 1, time.Minute, PriorityHigh)
+		cache.Add("normal", 2, time.Minute)
+		cache.AddWithPriority("low", 3, time.Hour, PriorityLow)
+		cache.Add("other", 4, 2*time.Hour)
+
+		cache.Add("first", 5, 3*time.Hour)
+		if _, err := cache.Get("low"); err != ErrDNE {
+			t.Errorf("expected the low priority item to be evicted first with policy %v, got %+v", policy, err)
+		}
+
+		cache.Add("second", 6, 3*time.Hour)
+		if _, err := cache.Get("high"); err != nil {
+			t.Errorf("expected the high priority item to be kept with policy %v, got %+v", policy, err)
+		}
+
+		if err := cache.SetPriority("first", PriorityLow); err != nil {
+			t.Errorf("SetPriority error: %+v", err)
+		}
+
+		cache.Add("third", 7, 3*time.Hour)
+		if _, err := cache.Get("first"); err != ErrDNE {
+			t.Errorf("expected the item lowered by SetPriority to be evicted with policy %v, got %+v", policy, err)
+		}
+
+		cache.Close()
+	}
+}
+
+func TestBucketPriority(t *testing.T) {
+	cache := NewCache(&CacheConfig{MaxEntries: 5, EvictionPolicy: EvictLRU})
+	defer cache.Close()
+
+	important := cache.BucketWithConfig("important", &BucketConfig{Priority: PriorityHigh})
+	important.Add("a", 1, time.Hour)
+	important.Add("b", 2, time.Hour, WithPriority(PriorityLow))
+
+	for i := 0; i < 5; i++ {
+		cache.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+	}
+
+	if _, err := important.Get("a"); err != nil {
+		t.Errorf("expected the bucket's priority to keep the item, got %+v", err)
+	}
+
+	if _, err := important.Get("b"); err != ErrDNE {
+		t.Errorf("expected WithPriority to override the bucket's priority, got %+v", err)
+	}
+
+	if err := cache.SetPriority("missing", PriorityHigh); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
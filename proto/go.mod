@@ -0,0 +1,11 @@
+module github.com/JKhawaja/cache/proto
+
+go 1.15
+
+require (
+	github.com/JKhawaja/cache v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.27.1
+)
+
+replace github.com/JKhawaja/cache => ../
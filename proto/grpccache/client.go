@@ -0,0 +1,161 @@
+package grpccache
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/proto/cachev1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// EventKind is the kind of change of an Event
+type EventKind int32
+
+const (
+	// EventSet is a key set or updated
+	EventSet = EventKind(cachev1.WatchEvent_KIND_SET)
+	// EventDelete is a key deleted
+	EventDelete = EventKind(cachev1.WatchEvent_KIND_DELETE)
+	// EventExpire is a key whose ttl was reached
+	EventExpire = EventKind(cachev1.WatchEvent_KIND_EXPIRE)
+)
+
+// Event is a change to a key streamed by Watch
+type Event struct {
+	Kind  EventKind
+	Key   string
+	Value []byte // new value of an EventSet
+}
+
+// Client calls the Cache service of a Server
+type Client struct {
+	client cachev1.CacheClient
+}
+
+// NewClient will create and return a pointer to a new Client
+// calling the service over the connection
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{client: cachev1.NewCacheClient(conn)}
+}
+
+// Get will return the value stored at the key and the time until it
+// expires, or 0 if it never expires. It will return cache.ErrDNE if
+// the key does not exist.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	resp, err := c.client.Get(ctx, &cachev1.GetRequest{Key: key})
+	if err != nil {
+		return nil, 0, clientError(err)
+	}
+
+	var ttl time.Duration
+	if resp.Ttl != nil {
+		ttl = resp.Ttl.AsDuration()
+	}
+
+	return resp.Value, ttl, nil
+}
+
+// Set will store the value at the key, expiring it after the ttl,
+// or never if the ttl is cache.NoExpiration
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	req := &cachev1.SetRequest{Key: key, Value: value}
+	if ttl != cache.NoExpiration {
+		req.Ttl = durationpb.New(ttl)
+	}
+
+	_, err := c.client.Set(ctx, req)
+	return clientError(err)
+}
+
+// Delete will remove the key. It will return cache.ErrDNE
+// if the key does not exist.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.client.Delete(ctx, &cachev1.DeleteRequest{Key: key})
+	return clientError(err)
+}
+
+// Watch will call fn with each change to the keys with the prefix, all
+// keys if it is empty, until ctx is done or the stream fails. It returns
+// nil once ctx is done.
+func (c *Client) Watch(ctx context.Context, prefix string, fn func(e Event)) error {
+	stream, err := c.client.Watch(ctx, &cachev1.WatchRequest{Prefix: prefix})
+	if err != nil {
+		return clientError(err)
+	}
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return clientError(err)
+		}
+
+		fn(Event{Kind: EventKind(event.Kind), Key: event.Key, Value: event.Value})
+	}
+}
+
+// Scan will call fn with each key with the prefix, all keys if it is
+// empty, along with its value and the time until it expires, or 0 if it
+// never expires. It stops and returns the error fn returns.
+func (c *Client) Scan(ctx context.Context, prefix string, fn func(key string, value []byte, ttl time.Duration) error) error {
+	stream, err := c.client.Scan(ctx, &cachev1.ScanRequest{Prefix: prefix})
+	if err != nil {
+		return clientError(err)
+	}
+
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return clientError(err)
+		}
+
+		var ttl time.Duration
+		if entry.Ttl != nil {
+			ttl = entry.Ttl.AsDuration()
+		}
+
+		err = fn(entry.Key, entry.Value, ttl)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// CopyFrom will warm the cache with the items of the cache served by the
+// Server, whose keys the filter reports true for, or with all of them if
+// filter is nil, so that a new node can start from the items of a live
+// one. Values are added as byte slices and keep the time left until they
+// expire. They are added with Cache.Warm.
+func (c *Client) CopyFrom(ctx context.Context, dst *cache.Cache, filter func(key string) bool) error {
+	return dst.Warm(ctx, func(yield func(key string, item interface{}, ttl time.Duration) error) error {
+		return c.Scan(ctx, "", func(key string, value []byte, ttl time.Duration) error {
+			if filter != nil && !filter(key) {
+				return nil
+			}
+
+			if ttl == 0 {
+				ttl = cache.NoExpiration
+			}
+
+			return yield(key, value, ttl)
+		})
+	})
+}
+
+// clientError will convert the NotFound status to cache.ErrDNE
+func clientError(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return cache.ErrDNE
+	}
+
+	return err
+}
@@ -0,0 +1,307 @@
+// Package grpccache serves a cache over gRPC with the Cache service of
+// proto/cache.proto, so that several services can share one cache node,
+// and provides a client for the service.
+//
+// Values are byte slices. Get serves items stored as strings too, and
+// fails with FailedPrecondition for items of other types.
+package grpccache
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/proto/cachev1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var defaultWatchBuffer = 64
+
+// Config is used to configure the server
+type Config struct {
+	Watcher     *Watcher // streams the changes of the cache to Watch, which is unavailable if nil
+	WatchBuffer int      // events held for a slow Watch stream before it is ended, defaults to 64
+}
+
+// Server implements the Cache service for a cache
+type Server struct {
+	cachev1.UnimplementedCacheServer
+
+	cache  *cache.Cache
+	config *Config
+}
+
+// NewServer will create and return a pointer to a new Server for the
+// cache, to be registered with cachev1.RegisterCacheServer
+func NewServer(c *cache.Cache, config *Config) *Server {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.WatchBuffer <= 0 {
+		config.WatchBuffer = defaultWatchBuffer
+	}
+
+	if config.Watcher != nil {
+		config.Watcher.attach(c)
+	}
+
+	return &Server{
+		cache:  c,
+		config: config,
+	}
+}
+
+// Get will return the value stored at the key and the time until it expires
+func (s *Server) Get(ctx context.Context, req *cachev1.GetRequest) (*cachev1.GetResponse, error) {
+	item, err := s.cache.Get(req.Key)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	value, ok := encode(item)
+	if !ok {
+		return nil, status.Error(codes.FailedPrecondition, "item is not a byte slice or string")
+	}
+
+	resp := &cachev1.GetResponse{Value: value}
+	ttl, err := s.cache.TTL(req.Key)
+	if err != nil {
+		return nil, statusError(err)
+	} else if ttl > 0 {
+		resp.Ttl = durationpb.New(ttl)
+	}
+
+	return resp, nil
+}
+
+// Set will store the value at the key, never expiring it if the ttl is unset
+func (s *Server) Set(ctx context.Context, req *cachev1.SetRequest) (*cachev1.SetResponse, error) {
+	ttl := cache.NoExpiration
+	if req.Ttl != nil {
+		ttl = req.Ttl.AsDuration()
+		if ttl <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "ttl must be positive")
+		}
+	}
+
+	err := s.cache.Set(req.Key, req.Value, ttl)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &cachev1.SetResponse{}, nil
+}
+
+// Delete will remove the key
+func (s *Server) Delete(ctx context.Context, req *cachev1.DeleteRequest) (*cachev1.DeleteResponse, error) {
+	err := s.cache.Delete(req.Key)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &cachev1.DeleteResponse{}, nil
+}
+
+// Watch will stream the changes to the keys with the prefix until the
+// client cancels it. A stream that falls WatchBuffer events behind is
+// ended with ResourceExhausted.
+func (s *Server) Watch(req *cachev1.WatchRequest, stream cachev1.Cache_WatchServer) error {
+	if s.config.Watcher == nil {
+		return status.Error(codes.Unimplemented, "watch is not enabled")
+	}
+
+	events, cancel := s.config.Watcher.watch(req.Prefix, s.config.WatchBuffer)
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watch fell behind")
+			}
+
+			err := stream.Send(event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Scan will stream the keys with the prefix along with their values and
+// the time until they expire. It streams a snapshot of the cache, and
+// leaves out the items that are not byte slices or strings.
+func (s *Server) Scan(req *cachev1.ScanRequest, stream cachev1.Cache_ScanServer) error {
+	for _, e := range s.cache.Snapshot() {
+		if !strings.HasPrefix(e.Key, req.Prefix) {
+			continue
+		}
+
+		value, ok := encode(e.Item)
+		if !ok {
+			continue
+		}
+
+		// the ttl is read from the cache, whose clock the snapshot
+		// expirations are relative to
+		ttl, err := s.cache.TTL(e.Key)
+		if err != nil {
+			continue
+		}
+
+		entry := &cachev1.ScanEntry{Key: e.Key, Value: value}
+		if ttl > 0 {
+			entry.Ttl = durationpb.New(ttl)
+		}
+
+		err = stream.Send(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Watcher collects the changes of a cache for the Watch streams of a
+// Server. Set it as the Invalidator of the cache to stream every key
+// set, updated or deleted, and as its OnExpire to stream expirations:
+//
+//	w := grpccache.NewWatcher()
+//	c := cache.NewCache(&cache.CacheConfig{Invalidator: w, OnExpire: w.Expired})
+//	s := grpccache.NewServer(c, &grpccache.Config{Watcher: w})
+//
+// Changes are published once the cache has made them, so an event for
+// a key set carries the value at the key when it is published.
+type Watcher struct {
+	cache    *cache.Cache
+	watchers map[*watcher]struct{}
+	mu       *sync.Mutex
+}
+
+type watcher struct {
+	prefix string
+	events chan *cachev1.WatchEvent
+}
+
+// NewWatcher will create and return a pointer to a new Watcher
+func NewWatcher() *Watcher {
+	return &Watcher{
+		watchers: make(map[*watcher]struct{}),
+		mu:       &sync.Mutex{},
+	}
+}
+
+// Publish will send a set event with the value now stored at the key,
+// or a delete event if it is no longer in the cache. It implements
+// cache.Invalidator.
+func (w *Watcher) Publish(inv cache.Invalidation) error {
+	w.mu.Lock()
+	c := w.cache
+	w.mu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+
+	event := &cachev1.WatchEvent{Kind: cachev1.WatchEvent_KIND_DELETE, Key: inv.Key}
+	item, err := c.Peek(inv.Key)
+	if err == nil {
+		event.Kind = cachev1.WatchEvent_KIND_SET
+		event.Value, _ = encode(item)
+	}
+	w.send(event)
+
+	return nil
+}
+
+// Subscribe implements cache.Invalidator. A Watcher delivers no
+// invalidations from other caches, so fn is never called.
+func (w *Watcher) Subscribe(fn func(inv cache.Invalidation)) error {
+	return nil
+}
+
+// Expired will send an expire event for the key, as the OnExpire of the cache
+func (w *Watcher) Expired(key string, item interface{}, reason cache.RemovalReason) {
+	w.send(&cachev1.WatchEvent{Kind: cachev1.WatchEvent_KIND_EXPIRE, Key: key})
+}
+
+// attach will set the cache whose values are sent with set events
+func (w *Watcher) attach(c *cache.Cache) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.cache = c
+}
+
+// watch will register a stream of the events for the keys with the prefix,
+// returning the function that unregisters it
+func (w *Watcher) watch(prefix string, buffer int) (<-chan *cachev1.WatchEvent, func()) {
+	ww := &watcher{prefix: prefix, events: make(chan *cachev1.WatchEvent, buffer)}
+
+	w.mu.Lock()
+	w.watchers[ww] = struct{}{}
+	w.mu.Unlock()
+
+	return ww.events, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if _, ok := w.watchers[ww]; ok {
+			delete(w.watchers, ww)
+			close(ww.events)
+		}
+	}
+}
+
+// send will send the event to the streams watching its key, closing
+// the streams that have fallen behind
+func (w *Watcher) send(event *cachev1.WatchEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ww := range w.watchers {
+		if !strings.HasPrefix(event.Key, ww.prefix) {
+			continue
+		}
+
+		select {
+		case ww.events <- event:
+		default:
+			delete(w.watchers, ww)
+			close(ww.events)
+		}
+	}
+}
+
+// encode will return the bytes of an item in the cache
+func encode(item interface{}) ([]byte, bool) {
+	switch v := item.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	}
+
+	return nil, false
+}
+
+// statusError will convert an error of the cache to a gRPC status
+func statusError(err error) error {
+	switch err {
+	case cache.ErrDNE:
+		return status.Error(codes.NotFound, err.Error())
+	case cache.ErrTooLarge:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case cache.ErrCollision, cache.ErrHashFlood:
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,209 @@
+package grpccache
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/proto/cachev1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestServer(t *testing.T, c *cache.Cache, config *Config) (*Client, func()) {
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	cachev1.RegisterCacheServer(s, NewServer(c, config))
+	go s.Serve(l)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return l.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("error connecting: %+v", err)
+	}
+
+	return NewClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestServer(t *testing.T) {
+	c := cache.NewCache(nil)
+	defer c.Close()
+
+	client, stop := newTestServer(t, c, nil)
+	defer stop()
+
+	ctx := context.Background()
+	err := client.Set(ctx, "a", []byte("hello"), time.Minute)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	value, ttl, err := client.Get(ctx, "a")
+	if err != nil || string(value) != "hello" || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("unexpected value %q with ttl %s: %+v", value, ttl, err)
+	}
+
+	err = client.Set(ctx, "b", []byte("forever"), cache.NoExpiration)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	if _, ttl, err := client.Get(ctx, "b"); err != nil || ttl != 0 {
+		t.Errorf("expected the key to never expire, got ttl %s: %+v", ttl, err)
+	}
+
+	c.Add("string", "value", time.Minute)
+	if value, _, err := client.Get(ctx, "string"); err != nil || string(value) != "value" {
+		t.Errorf("expected strings to be served, got %q: %+v", value, err)
+	}
+
+	c.Add("number", 42, time.Minute)
+	if _, _, err := client.Get(ctx, "number"); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition for an item of another type, got %+v", err)
+	}
+
+	if err := client.Set(ctx, "c", nil, -2*time.Second); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a negative ttl, got %+v", err)
+	}
+
+	err = client.Delete(ctx, "a")
+	if err != nil {
+		t.Errorf("error deleting key: %+v", err)
+	}
+
+	if _, _, err := client.Get(ctx, "a"); err != cache.ErrDNE {
+		t.Errorf("expected ErrDNE for a deleted key, got %+v", err)
+	}
+
+	if err := client.Delete(ctx, "a"); err != cache.ErrDNE {
+		t.Errorf("expected ErrDNE deleting a missing key, got %+v", err)
+	}
+
+	err = client.Watch(ctx, "", func(e Event) {})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected Watch to be unavailable without a Watcher, got %+v", err)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	clock := cache.NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	w := NewWatcher()
+	c := cache.NewCache(&cache.CacheConfig{
+		Clock:          clock,
+		DisableCleaner: true,
+		Invalidator:    w,
+		OnExpire:       w.Expired,
+	})
+	defer c.Close()
+
+	client, stop := newTestServer(t, c, &Config{Watcher: w})
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event, 10)
+	done := make(chan error)
+	go func() {
+		done <- client.Watch(ctx, "user:", func(e Event) {
+			events <- e
+		})
+	}()
+
+	// the stream is registered once the first event is delivered
+	for i := 0; ; i++ {
+		client.Set(context.Background(), "user:ready", []byte("1"), cache.NoExpiration)
+		select {
+		case <-events:
+		case <-time.After(10 * time.Millisecond):
+			if i < 100 {
+				continue
+			}
+			t.Fatal("the watch stream was not registered")
+		}
+		break
+	}
+
+	expect := func(want Event) {
+		t.Helper()
+
+		for {
+			select {
+			case e := <-events:
+				if e.Kind == EventSet && e.Key == "user:ready" {
+					// published by a retry of the registration
+					continue
+				}
+
+				if e.Kind != want.Kind || e.Key != want.Key || string(e.Value) != string(want.Value) {
+					t.Errorf("event was %+v, expected %+v", e, want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no event for %+v", want)
+			}
+			return
+		}
+	}
+
+	client.Set(context.Background(), "other", []byte("x"), cache.NoExpiration)
+	client.Set(context.Background(), "user:1", []byte("alice"), time.Minute)
+	expect(Event{Kind: EventSet, Key: "user:1", Value: []byte("alice")})
+
+	c.Delete("user:ready")
+	expect(Event{Kind: EventDelete, Key: "user:ready"})
+
+	clock.Advance(2 * time.Minute)
+	c.CleanNow()
+	expect(Event{Kind: EventExpire, Key: "user:1"})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch error: %+v", err)
+	}
+}
+
+func TestClientCopyFrom(t *testing.T) {
+	src := cache.NewCache(nil)
+	defer src.Close()
+
+	src.Set("user:1", []byte("alice"), time.Hour)
+	src.Set("user:2", "bob", cache.NoExpiration)
+	src.Set("user:3", 3, time.Hour)
+	src.Set("session:1", []byte("x"), time.Hour)
+
+	client, stop := newTestServer(t, src, nil)
+	defer stop()
+
+	dst := cache.NewCache(nil)
+	defer dst.Close()
+
+	err := client.CopyFrom(context.Background(), dst, func(key string) bool {
+		return strings.HasPrefix(key, "user:")
+	})
+	if err != nil {
+		t.Fatalf("CopyFrom error: %+v", err)
+	}
+
+	if keys := dst.Keys(); len(keys) != 2 {
+		t.Errorf("expected the two user values to be copied, got %+v", keys)
+	}
+
+	if item, err := dst.Get("user:1"); err != nil || string(item.([]byte)) != "alice" {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	if ttl, err := dst.TTL("user:1"); err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("expected the ttl to be kept, got %s: %+v", ttl, err)
+	}
+
+	if ttl, err := dst.TTL("user:2"); err != nil || ttl != 0 {
+		t.Errorf("expected the key to never expire, got %s: %+v", ttl, err)
+	}
+}
@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrSubscribed is returned by Subscribe when the Invalidator is already subscribed
+	ErrSubscribed = errors.New("redis: already subscribed")
+
+	// ErrClosed is returned by Subscribe after the Invalidator is closed
+	ErrClosed = errors.New("redis: invalidator closed")
+)
+
+// Invalidator is a cache.Invalidator that publishes invalidations
+// as JSON on a Redis pub/sub channel. A dropped subscription is
+// restored every Reconnect until it succeeds. Invalidations published
+// while it is down are missed, since Redis pub/sub does not keep them.
+type Invalidator struct {
+	backend *Backend
+	channel string
+	sub     *conn
+	started bool
+	closed  bool
+	done    chan struct{}
+	mu      *sync.Mutex
+}
+
+// NewInvalidator will create and return a pointer to a new Invalidator
+// for the channel on the Redis server at the address.
+func NewInvalidator(addr, channel string, config *Config) *Invalidator {
+	return &Invalidator{
+		backend: NewBackend(addr, config),
+		channel: channel,
+		done:    make(chan struct{}),
+		mu:      &sync.Mutex{},
+	}
+}
+
+// Publish will publish the invalidation on the channel
+func (i *Invalidator) Publish(inv cache.Invalidation) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	_, err = i.backend.do("PUBLISH", i.channel, string(data))
+	return err
+}
+
+// Subscribe will subscribe to the channel and call fn with
+// each invalidation published on it until the Invalidator is closed.
+func (i *Invalidator) Subscribe(fn func(inv cache.Invalidation)) error {
+	i.mu.Lock()
+	if i.started {
+		i.mu.Unlock()
+		return ErrSubscribed
+	}
+	i.started = true
+	i.mu.Unlock()
+
+	c, err := i.subscribe()
+	if err != nil {
+		i.mu.Lock()
+		i.started = false
+		i.mu.Unlock()
+		return err
+	}
+
+	go i.listen(c, fn)
+	return nil
+}
+
+// Close will close the subscription and the connections used to publish
+func (i *Invalidator) Close() error {
+	i.mu.Lock()
+	if !i.closed {
+		i.closed = true
+		close(i.done)
+
+		if i.sub != nil {
+			i.sub.Close()
+		}
+	}
+	i.mu.Unlock()
+
+	return i.backend.Close()
+}
+
+// subscribe will open a connection subscribed to the channel
+func (i *Invalidator) subscribe() (*conn, error) {
+	c, err := i.backend.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if i.backend.config.Timeout > 0 {
+		c.SetDeadline(time.Now().Add(i.backend.config.Timeout))
+	}
+
+	_, err = c.do([]string{"SUBSCRIBE", i.channel})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		c.Close()
+		return nil, ErrClosed
+	}
+	i.sub = c
+
+	return c, nil
+}
+
+// listen will read the messages published on the channel,
+// restoring the subscription whenever it is dropped.
+func (i *Invalidator) listen(c *conn, fn func(inv cache.Invalidation)) {
+	for {
+		reply, err := c.read()
+		if err != nil {
+			c.Close()
+
+			c = i.resubscribe()
+			if c == nil {
+				return
+			}
+			continue
+		}
+
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) != 3 {
+			continue
+		}
+
+		kind, _ := msg[0].([]byte)
+		data, _ := msg[2].([]byte)
+		if string(kind) != "message" {
+			continue
+		}
+
+		var inv cache.Invalidation
+		if json.Unmarshal(data, &inv) == nil {
+			fn(inv)
+		}
+	}
+}
+
+// resubscribe will try to subscribe every Reconnect
+// until it succeeds, or return nil once closed
+func (i *Invalidator) resubscribe() *conn {
+	for {
+		select {
+		case <-i.done:
+			return nil
+		case <-time.After(i.backend.config.Reconnect):
+		}
+
+		c, err := i.subscribe()
+		if err == nil {
+			return c
+		} else if err == ErrClosed {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/resp"
+)
+
+func TestInvalidator(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %+v", err)
+	}
+
+	s := resp.NewServer(cache.NewCache(nil))
+	go s.Serve(l)
+	defer s.Close()
+
+	config := &Config{Timeout: 5 * time.Second}
+	i1 := NewInvalidator(l.Addr().String(), "invalidations", config)
+	defer i1.Close()
+	i2 := NewInvalidator(l.Addr().String(), "invalidations", config)
+	defer i2.Close()
+
+	c1 := cache.NewCache(&cache.CacheConfig{Invalidator: i1})
+	defer c1.Close()
+	c2 := cache.NewCache(&cache.CacheConfig{Invalidator: i2})
+	defer c2.Close()
+
+	err = i1.Subscribe(func(cache.Invalidation) {})
+	if err != ErrSubscribed {
+		t.Errorf("expected ErrSubscribed, got %+v", err)
+	}
+
+	c2.Add("key", "old", time.Minute)
+	c1.Add("own", "value", time.Minute)
+
+	err = c1.Set("key", "new", time.Minute)
+	if err != nil {
+		t.Errorf("Set error: %+v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := c2.Get("key"); err == cache.ErrDNE {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	_, err = c2.Get("key")
+	if err != cache.ErrDNE {
+		t.Errorf("expected the key to be invalidated, got %+v", err)
+	}
+
+	item, err := c1.Get("own")
+	if err != nil || item != "value" {
+		t.Errorf("expected the publishing cache to keep its item, got %v, %+v", item, err)
+	}
+}
@@ -0,0 +1,511 @@
+package redis
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+// rdbVersion is the version of the dumps written by ExportRDB, which
+// Redis 5.0 and later can restore
+const rdbVersion = 9
+
+// opcodes of an RDB dump
+const (
+	rdbSlotInfo     = 0xf4
+	rdbFunction     = 0xf5
+	rdbModuleAux    = 0xf7
+	rdbIdle         = 0xf8
+	rdbFreq         = 0xf9
+	rdbAux          = 0xfa
+	rdbResizeDB     = 0xfb
+	rdbExpireTimeMs = 0xfc
+	rdbExpireTime   = 0xfd
+	rdbSelectDB     = 0xfe
+	rdbEOF          = 0xff
+)
+
+// types of the values in an RDB dump
+const (
+	rdbString         = 0
+	rdbList           = 1
+	rdbSet            = 2
+	rdbZSet           = 3
+	rdbHash           = 4
+	rdbZSet2          = 5
+	rdbHashZipmap     = 9
+	rdbListZiplist    = 10
+	rdbSetIntset      = 11
+	rdbZSetZiplist    = 12
+	rdbHashZiplist    = 13
+	rdbListQuicklist  = 14
+	rdbHashListpack   = 16
+	rdbZSetListpack   = 17
+	rdbListQuicklist2 = 18
+	rdbSetListpack    = 20
+)
+
+// encodings of the strings in an RDB dump
+const (
+	rdbInt8  = 0
+	rdbInt16 = 1
+	rdbInt32 = 2
+	rdbLZF   = 3
+)
+
+var (
+	// ErrNotRDB is returned by ImportRDB for data that is not an RDB dump
+	ErrNotRDB = errors.New("redis: not an RDB dump")
+	// ErrRDBChecksum is returned by ImportRDB for a dump whose checksum does not match
+	ErrRDBChecksum = errors.New("redis: RDB checksum mismatch")
+)
+
+var rdbTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// rdbCRC is the crc64 of Redis, which uses the Jones polynomial and,
+// unlike hash/crc64, does not invert the sum
+type rdbCRC uint64
+
+func (c *rdbCRC) Write(p []byte) (int, error) {
+	*c = rdbCRC(^crc64.Update(^uint64(*c), rdbTable, p))
+	return len(p), nil
+}
+
+// RDBConfig is used to configure ImportRDB
+type RDBConfig struct {
+	Clock cache.Clock // measures the ttls of the imported keys, defaults to the system clock
+}
+
+// ImportRDB will add the string keys of a Redis RDB dump to the cache,
+// as byte slices expiring with the ttls they had in Redis, and return
+// the number of keys added. The keys of every database are added, keys
+// that have already expired are dropped, and lists, sets, sorted sets
+// and hashes are skipped. A dump holding streams or module types cannot
+// be read and returns an error, as do keys the cache does not accept.
+func ImportRDB(c *cache.Cache, r io.Reader, config *RDBConfig) (int, error) {
+	now := time.Now
+	if config != nil && config.Clock != nil {
+		now = config.Clock.Now
+	}
+
+	d := &rdbReader{r: bufio.NewReader(r)}
+	header := make([]byte, 9)
+	if err := d.full(header); err != nil || string(header[:5]) != "REDIS" {
+		return 0, ErrNotRDB
+	}
+
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil {
+		return 0, ErrNotRDB
+	}
+
+	var added int
+	var expiresAt time.Time
+	for {
+		op, err := d.byte()
+		if err != nil {
+			return added, err
+		}
+
+		switch op {
+		case rdbEOF:
+			return added, d.checksum(version)
+		case rdbAux:
+			if _, err := d.string(); err != nil {
+				return added, err
+			}
+			if _, err := d.string(); err != nil {
+				return added, err
+			}
+		case rdbSelectDB:
+			if _, err := d.length(); err != nil {
+				return added, err
+			}
+		case rdbResizeDB:
+			if _, err := d.length(); err != nil {
+				return added, err
+			}
+			if _, err := d.length(); err != nil {
+				return added, err
+			}
+		case rdbExpireTime:
+			buf := make([]byte, 4)
+			if err := d.full(buf); err != nil {
+				return added, err
+			}
+			expiresAt = time.Unix(int64(binary.LittleEndian.Uint32(buf)), 0)
+		case rdbExpireTimeMs:
+			buf := make([]byte, 8)
+			if err := d.full(buf); err != nil {
+				return added, err
+			}
+			ms := int64(binary.LittleEndian.Uint64(buf))
+			expiresAt = time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+		case rdbIdle:
+			if _, err := d.length(); err != nil {
+				return added, err
+			}
+		case rdbFreq:
+			if _, err := d.byte(); err != nil {
+				return added, err
+			}
+		case rdbSlotInfo:
+			for i := 0; i < 3; i++ {
+				if _, err := d.length(); err != nil {
+					return added, err
+				}
+			}
+		case rdbFunction, rdbModuleAux:
+			return added, fmt.Errorf("redis: unsupported RDB opcode %#x", op)
+		default:
+			key, err := d.string()
+			if err != nil {
+				return added, err
+			}
+
+			if op != rdbString {
+				if err := d.skip(op); err != nil {
+					return added, err
+				}
+				expiresAt = time.Time{}
+				continue
+			}
+
+			value, err := d.string()
+			if err != nil {
+				return added, err
+			}
+
+			ttl := cache.NoExpiration
+			if !expiresAt.IsZero() {
+				ttl = expiresAt.Sub(now())
+			}
+			expiresAt = time.Time{}
+			if ttl != cache.NoExpiration && ttl <= 0 {
+				continue
+			}
+
+			if err := c.Set(key, []byte(value), ttl); err != nil {
+				return added, err
+			}
+			added++
+		}
+	}
+}
+
+// ExportRDB will write the cache to w as a Redis RDB dump, which Redis
+// 5.0 and later can load, and return the number of keys written. Items
+// that are strings or byte slices are written as string keys with their
+// ttls, and other items are skipped. Items in buckets are written with
+// their keys in the cache.
+func ExportRDB(c *cache.Cache, w io.Writer) (int, error) {
+	var strs []cache.Entry
+	var expires int
+	for _, e := range c.Snapshot() {
+		switch e.Item.(type) {
+		case string, []byte:
+			strs = append(strs, e)
+			if expiring(e) {
+				expires++
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	var crc rdbCRC
+	out := io.MultiWriter(bw, &crc)
+
+	fmt.Fprintf(out, "REDIS%04d", rdbVersion)
+	out.Write([]byte{rdbSelectDB})
+	writeLength(out, 0)
+	out.Write([]byte{rdbResizeDB})
+	writeLength(out, uint64(len(strs)))
+	writeLength(out, uint64(expires))
+
+	for _, e := range strs {
+		if expiring(e) {
+			buf := make([]byte, 9)
+			buf[0] = rdbExpireTimeMs
+			binary.LittleEndian.PutUint64(buf[1:], uint64(e.ExpiresAt.UnixNano()/int64(time.Millisecond)))
+			out.Write(buf)
+		}
+
+		out.Write([]byte{rdbString})
+		writeString(out, e.Key)
+		switch v := e.Item.(type) {
+		case string:
+			writeString(out, v)
+		case []byte:
+			writeString(out, string(v))
+		}
+	}
+
+	out.Write([]byte{rdbEOF})
+	sum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sum, uint64(crc))
+	bw.Write(sum)
+
+	return len(strs), bw.Flush()
+}
+
+// expiring will report whether the entry has a ttl. Entries that never
+// expire are held until the end of year 9999.
+func expiring(e cache.Entry) bool {
+	return e.ExpiresAt.Year() < 9999
+}
+
+func writeLength(w io.Writer, n uint64) {
+	switch {
+	case n < 1<<6:
+		w.Write([]byte{byte(n)})
+	case n < 1<<14:
+		w.Write([]byte{byte(n>>8) | 0x40, byte(n)})
+	case n <= math.MaxUint32:
+		buf := make([]byte, 5)
+		buf[0] = 0x80
+		binary.BigEndian.PutUint32(buf[1:], uint32(n))
+		w.Write(buf)
+	default:
+		buf := make([]byte, 9)
+		buf[0] = 0x81
+		binary.BigEndian.PutUint64(buf[1:], n)
+		w.Write(buf)
+	}
+}
+
+func writeString(w io.Writer, s string) {
+	writeLength(w, uint64(len(s)))
+	io.WriteString(w, s)
+}
+
+// rdbReader reads the values of an RDB dump, summing what it reads
+type rdbReader struct {
+	r   *bufio.Reader
+	crc rdbCRC
+}
+
+func (d *rdbReader) full(buf []byte) error {
+	_, err := io.ReadFull(d.r, buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	d.crc.Write(buf)
+	return err
+}
+
+func (d *rdbReader) byte() (byte, error) {
+	buf := make([]byte, 1)
+	err := d.full(buf)
+	return buf[0], err
+}
+
+// lengthOrEncoding will read a length, or the encoding of a
+// specially encoded string along with true
+func (d *rdbReader) lengthOrEncoding() (uint64, bool, error) {
+	b, err := d.byte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3f), false, nil
+	case 1:
+		next, err := d.byte()
+		return uint64(b&0x3f)<<8 | uint64(next), false, err
+	case 2:
+		if b == 0x80 {
+			buf := make([]byte, 4)
+			err := d.full(buf)
+			return uint64(binary.BigEndian.Uint32(buf)), false, err
+		} else if b == 0x81 {
+			buf := make([]byte, 8)
+			err := d.full(buf)
+			return binary.BigEndian.Uint64(buf), false, err
+		}
+		return 0, false, fmt.Errorf("redis: invalid RDB length %#x", b)
+	default:
+		return uint64(b & 0x3f), true, nil
+	}
+}
+
+func (d *rdbReader) length() (uint64, error) {
+	n, encoded, err := d.lengthOrEncoding()
+	if err == nil && encoded {
+		err = fmt.Errorf("redis: unexpected RDB string encoding %d", n)
+	}
+	return n, err
+}
+
+func (d *rdbReader) string() (string, error) {
+	n, encoded, err := d.lengthOrEncoding()
+	if err != nil {
+		return "", err
+	}
+
+	if !encoded {
+		buf := make([]byte, n)
+		err := d.full(buf)
+		return string(buf), err
+	}
+
+	switch n {
+	case rdbInt8:
+		b, err := d.byte()
+		return strconv.Itoa(int(int8(b))), err
+	case rdbInt16:
+		buf := make([]byte, 2)
+		err := d.full(buf)
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(buf)))), err
+	case rdbInt32:
+		buf := make([]byte, 4)
+		err := d.full(buf)
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(buf)))), err
+	case rdbLZF:
+		clen, err := d.length()
+		if err != nil {
+			return "", err
+		}
+		ulen, err := d.length()
+		if err != nil {
+			return "", err
+		}
+		buf := make([]byte, clen)
+		if err := d.full(buf); err != nil {
+			return "", err
+		}
+		out, err := lzfDecompress(buf, int(ulen))
+		return string(out), err
+	}
+
+	return "", fmt.Errorf("redis: unknown RDB string encoding %d", n)
+}
+
+// skip will read past a value of the type
+func (d *rdbReader) skip(typ byte) error {
+	var strings uint64
+	switch typ {
+	case rdbHashZipmap, rdbListZiplist, rdbSetIntset, rdbZSetZiplist,
+		rdbHashZiplist, rdbHashListpack, rdbZSetListpack, rdbSetListpack:
+		strings = 1
+	case rdbList, rdbSet, rdbListQuicklist, rdbHash, rdbZSet, rdbZSet2, rdbListQuicklist2:
+		n, err := d.length()
+		if err != nil {
+			return err
+		}
+		strings = n
+		if typ == rdbHash {
+			strings = 2 * n
+		}
+	default:
+		return fmt.Errorf("redis: unsupported RDB type %d", typ)
+	}
+
+	for i := uint64(0); i < strings; i++ {
+		if typ == rdbListQuicklist2 {
+			// the container of each node
+			if _, err := d.length(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := d.string(); err != nil {
+			return err
+		}
+
+		switch typ {
+		case rdbZSet:
+			// a score as a string of up to 255 bytes, or nan and the infinities
+			n, err := d.byte()
+			if err == nil && n < 253 {
+				err = d.full(make([]byte, n))
+			}
+			if err != nil {
+				return err
+			}
+		case rdbZSet2:
+			if err := d.full(make([]byte, 8)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checksum will compare the sum of the dump with the one that ends it,
+// which dumps before version 5 do not have and which is 0 when Redis
+// was configured not to compute it
+func (d *rdbReader) checksum(version int) error {
+	if version < 5 {
+		return nil
+	}
+
+	want := uint64(d.crc)
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	if sum := binary.LittleEndian.Uint64(buf); sum != 0 && sum != want {
+		return ErrRDBChecksum
+	}
+
+	return nil
+}
+
+// lzfDecompress will decompress data compressed with LZF to its length
+func lzfDecompress(in []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		if ctrl < 1<<5 {
+			// a literal run of ctrl+1 bytes
+			n := ctrl + 1
+			if i+n > len(in) {
+				return nil, errors.New("redis: corrupt LZF data")
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+
+		// a back reference of n bytes
+		n := ctrl >> 5
+		if n == 7 {
+			if i >= len(in) {
+				return nil, errors.New("redis: corrupt LZF data")
+			}
+			n += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errors.New("redis: corrupt LZF data")
+		}
+		ref := len(out) - ((ctrl&0x1f)<<8 | int(in[i])) - 1
+		i++
+		if ref < 0 {
+			return nil, errors.New("redis: corrupt LZF data")
+		}
+
+		for j := 0; j < n+2; j++ {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != length {
+		return nil, errors.New("redis: corrupt LZF data")
+	}
+
+	return out, nil
+}
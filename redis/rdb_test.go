@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+func TestRDBChecksum(t *testing.T) {
+	var crc rdbCRC
+	crc.Write([]byte("123456789"))
+	if crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("expected the crc64 of Redis, got %x", uint64(crc))
+	}
+}
+
+// dump will end the body of an RDB dump with its checksum
+func dump(body []byte) []byte {
+	var crc rdbCRC
+	body = append(body, rdbEOF)
+	crc.Write(body)
+
+	sum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(sum, uint64(crc))
+	return append(body, sum...)
+}
+
+func TestImportRDB(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := cache.NewManualClock(now)
+	c := cache.NewCache(&cache.CacheConfig{Clock: clock})
+	defer c.Close()
+
+	var body bytes.Buffer
+	body.WriteString("REDIS0009")
+	body.Write([]byte{rdbAux, 9})
+	body.WriteString("redis-ver")
+	body.Write([]byte{5})
+	body.WriteString("5.0.7")
+	body.Write([]byte{rdbSelectDB, 0, rdbResizeDB, 5, 1})
+
+	// a plain string
+	body.Write([]byte{rdbString, 5})
+	body.WriteString("plain")
+	body.Write([]byte{3})
+	body.WriteString("abc")
+
+	// an integer encoded string, expiring in a minute
+	expires := make([]byte, 8)
+	binary.LittleEndian.PutUint64(expires, uint64(now.Add(time.Minute).UnixNano()/int64(time.Millisecond)))
+	body.Write([]byte{rdbExpireTimeMs})
+	body.Write(expires)
+	body.Write([]byte{rdbString, 3})
+	body.WriteString("int")
+	body.Write([]byte{0xc0 | rdbInt16, 0x39, 0x30})
+
+	// an LZF compressed string of ten a's
+	body.Write([]byte{rdbString, 3})
+	body.WriteString("lzf")
+	body.Write([]byte{0xc0 | rdbLZF, 5, 10, 0x00, 'a', 0xe0, 0x00, 0x00})
+
+	// an expired string
+	binary.LittleEndian.PutUint64(expires, uint64(now.Add(-time.Minute).UnixNano()/int64(time.Millisecond)))
+	body.Write([]byte{rdbExpireTimeMs})
+	body.Write(expires)
+	body.Write([]byte{rdbString, 4})
+	body.WriteString("gone")
+	body.Write([]byte{1})
+	body.WriteString("x")
+
+	// a hash, which is skipped
+	body.Write([]byte{rdbHash, 1})
+	body.WriteString("h")
+	body.Write([]byte{1, 1})
+	body.WriteString("f")
+	body.Write([]byte{1})
+	body.WriteString("v")
+
+	data := dump(body.Bytes())
+	n, err := ImportRDB(c, bytes.NewReader(data), &RDBConfig{Clock: clock})
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 keys to be imported, got %d %+v", n, err)
+	}
+
+	for key, want := range map[string]string{"plain": "abc", "int": "12345", "lzf": "aaaaaaaaaa"} {
+		if item, err := c.Get(key); err != nil || string(item.([]byte)) != want {
+			t.Errorf("expected %s to be %q, got %v %+v", key, want, item, err)
+		}
+	}
+
+	if _, err := c.Get("gone"); err != cache.ErrDNE {
+		t.Errorf("expected the expired key to be dropped, got %+v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := c.Get("int"); err != cache.ErrDNE {
+		t.Errorf("expected the key to keep its ttl, got %+v", err)
+	}
+
+	data[len(data)-1] ^= 0xff
+	if _, err := ImportRDB(c, bytes.NewReader(data), nil); err != ErrRDBChecksum {
+		t.Errorf("expected ErrRDBChecksum, got %+v", err)
+	}
+
+	if _, err := ImportRDB(c, bytes.NewReader([]byte("not a dump")), nil); err != ErrNotRDB {
+		t.Errorf("expected ErrNotRDB, got %+v", err)
+	}
+}
+
+func TestExportRDB(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := cache.NewManualClock(now)
+	c := cache.NewCache(&cache.CacheConfig{Clock: clock})
+	defer c.Close()
+
+	c.Add("a", "apple", time.Minute)
+	c.Add("b", []byte("banana"), cache.NoExpiration)
+	c.Add("n", 42, cache.NoExpiration)
+	c.Bucket("fruit").Add("c", make([]byte, 100), cache.NoExpiration)
+
+	var buf bytes.Buffer
+	n, err := ExportRDB(c, &buf)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 keys to be exported, got %d %+v", n, err)
+	}
+
+	imported := cache.NewCache(&cache.CacheConfig{Clock: clock})
+	defer imported.Close()
+
+	n, err = ImportRDB(imported, &buf, &RDBConfig{Clock: clock})
+	if err != nil || n != 3 {
+		t.Fatalf("expected the export to be imported, got %d %+v", n, err)
+	}
+
+	if item, err := imported.Get("a"); err != nil || string(item.([]byte)) != "apple" {
+		t.Errorf("expected the string to round trip, got %v %+v", item, err)
+	}
+
+	if item, err := imported.Get("fruit:c"); err != nil || len(item.([]byte)) != 100 {
+		t.Errorf("expected the bucket item to round trip, got %v %+v", item, err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := imported.Get("a"); err != cache.ErrDNE {
+		t.Errorf("expected the ttl to round trip, got %+v", err)
+	}
+	if _, err := imported.Get("b"); err != nil {
+		t.Errorf("expected the key without a ttl to never expire, got %+v", err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package redis provides a Redis Backend for a cache.TieredCache and
+// a Redis pub/sub Invalidator for keeping several caches coherent,
+// using a minimal RESP client built on the standard library. ImportRDB
+// and ExportRDB move string keys between a cache and RDB dump files,
+// for migrating from Redis or back to it.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	defaultMaxIdle     = 4
+	defaultDialTimeout = 5 * time.Second
+	defaultReconnect   = 1 * time.Second
+)
+
+// Error is an error reply from the Redis server
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Backend is a cache.Backend that stores values in Redis
+type Backend struct {
+	addr   string
+	config *Config
+	dialer *net.Dialer
+	idle   []*conn // connections kept for reuse
+	closed bool
+	mu     *sync.Mutex
+}
+
+// Config is used to configure a Redis backend
+type Config struct {
+	MaxIdle     int           // idle connections kept for reuse, defaults to 4
+	DialTimeout time.Duration // defaults to 5 seconds
+	Timeout     time.Duration // deadline for each command, 0 waits indefinitely
+	Reconnect   time.Duration // delay between attempts to restore a dropped subscription, defaults to 1 second
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// NewBackend will create and return a pointer to a new Backend
+// for the Redis server at the address. Connections are made as needed.
+func NewBackend(addr string, config *Config) *Backend {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.MaxIdle == 0 {
+		config.MaxIdle = defaultMaxIdle
+	}
+
+	if config.DialTimeout == 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+
+	if config.Reconnect == 0 {
+		config.Reconnect = defaultReconnect
+	}
+
+	return &Backend{
+		addr:   addr,
+		config: config,
+		dialer: &net.Dialer{Timeout: config.DialTimeout},
+		mu:     &sync.Mutex{},
+	}
+}
+
+// Get will return the value stored at the key, or cache.ErrDNE
+func (b *Backend) Get(key string) ([]byte, error) {
+	reply, err := b.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply == nil {
+		return nil, cache.ErrDNE
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply to GET: %v", reply)
+	}
+
+	return value, nil
+}
+
+// Set will store the value at the key, expiring after the ttl
+// unless it is 0
+func (b *Backend) Set(key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		ms := int64(ttl / time.Millisecond)
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+
+	_, err := b.do(args...)
+	return err
+}
+
+// Delete will remove the key, or return cache.ErrDNE if it does not exist
+func (b *Backend) Delete(key string) error {
+	reply, err := b.do("DEL", key)
+	if err != nil {
+		return err
+	}
+
+	if reply == int64(0) {
+		return cache.ErrDNE
+	}
+
+	return nil
+}
+
+// TTL will return the time until the key expires, 0 if it
+// never expires, or cache.ErrDNE if it does not exist
+func (b *Backend) TTL(key string) (time.Duration, error) {
+	reply, err := b.do("PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+
+	ms, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected reply to PTTL: %v", reply)
+	}
+
+	switch ms {
+	case -2:
+		return 0, cache.ErrDNE
+	case -1:
+		return 0, nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Close will close the idle connections. Connections in use
+// are closed when their commands complete.
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, c := range b.idle {
+		c.Close()
+	}
+	b.idle = nil
+
+	return nil
+}
+
+// do will send a command and read its reply. A connection that fails
+// is closed rather than returned to the idle connections.
+func (b *Backend) do(args ...string) (interface{}, error) {
+	c, err := b.get()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.config.Timeout > 0 {
+		c.SetDeadline(time.Now().Add(b.config.Timeout))
+	}
+
+	reply, err := c.do(args)
+	if _, ok := err.(Error); err != nil && !ok {
+		c.Close()
+		return nil, err
+	}
+
+	b.put(c)
+	return reply, err
+}
+
+func (b *Backend) get() (*conn, error) {
+	b.mu.Lock()
+	if n := len(b.idle); n > 0 {
+		c := b.idle[n-1]
+		b.idle = b.idle[:n-1]
+		b.mu.Unlock()
+		return c, nil
+	}
+	b.mu.Unlock()
+
+	return b.dial()
+}
+
+func (b *Backend) dial() (*conn, error) {
+	nc, err := b.dialer.Dial("tcp", b.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{
+		Conn: nc,
+		r:    bufio.NewReader(nc),
+		w:    bufio.NewWriter(nc),
+	}, nil
+}
+
+func (b *Backend) put(c *conn) {
+	if b.config.Timeout > 0 {
+		c.SetDeadline(time.Time{})
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || len(b.idle) >= b.config.MaxIdle {
+		c.Close()
+		return
+	}
+	b.idle = append(b.idle, c)
+}
+
+func (c *conn) do(args []string) (interface{}, error) {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return c.read()
+}
+
+// read will read one reply: a status string, an Error, an int64,
+// a []byte, nil for a null reply, or an []interface{} for an array
+func (c *conn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		if n < 0 {
+			return nil, nil
+		}
+
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		if n < 0 {
+			return nil, nil
+		}
+
+		array := make([]interface{}, n)
+		for i := range array {
+			array[i], err = c.read()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	}
+
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
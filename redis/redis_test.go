@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/resp"
+)
+
+// newTestBackend will return a backend connected to a
+// Redis protocol server backed by a cache
+func newTestBackend(t *testing.T) (*Backend, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %+v", err)
+	}
+
+	s := resp.NewServer(cache.NewCache(nil))
+	go s.Serve(l)
+
+	b := NewBackend(l.Addr().String(), &Config{Timeout: 5 * time.Second})
+	return b, func() {
+		b.Close()
+		s.Close()
+	}
+}
+
+func TestBackend(t *testing.T) {
+	b, stop := newTestBackend(t)
+	defer stop()
+
+	var _ cache.Backend = b
+
+	err := b.Set("a", []byte("value"), time.Minute)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	value, err := b.Get("a")
+	if err != nil || string(value) != "value" {
+		t.Errorf("unexpected value %q: %+v", value, err)
+	}
+
+	ttl, err := b.TTL("a")
+	if err != nil || ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("unexpected ttl %v: %+v", ttl, err)
+	}
+
+	b.Set("forever", []byte("x"), 0)
+	if ttl, err := b.TTL("forever"); err != nil || ttl != 0 {
+		t.Errorf("unexpected ttl for a key without expiration %v: %+v", ttl, err)
+	}
+
+	err = b.Delete("a")
+	if err != nil {
+		t.Errorf("error deleting key: %+v", err)
+	}
+
+	if _, err := b.Get("a"); err != cache.ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	if err := b.Delete("a"); err != cache.ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	if _, err := b.TTL("a"); err != cache.ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	b, stop := newTestBackend(t)
+	defer stop()
+
+	value := bytes.Repeat([]byte("x"), 100)
+	writer := cache.NewTieredCache(cache.NewCache(nil), b, &cache.TieredConfig{
+		Chunker: &cache.Chunker{Size: 16},
+	})
+
+	err := writer.Set("big", value, time.Minute)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	reader := cache.NewTieredCache(cache.NewCache(nil), b, &cache.TieredConfig{
+		Chunker: &cache.Chunker{Size: 16},
+	})
+
+	got, err := reader.Get("big")
+	if err != nil || !bytes.Equal(got, value) {
+		t.Errorf("unexpected value %q: %+v", got, err)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	b, stop := newTestBackend(t)
+	defer stop()
+
+	_, err := b.do("BOGUS")
+	if _, ok := err.(Error); !ok {
+		t.Errorf("expected an error reply, got: %+v", err)
+	}
+
+	// the connection is still usable after an error reply
+	if err := b.Set("a", []byte("1"), 0); err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var defaultReloadAhead = 0.1
+
+// reloader schedules the background reloads of items
+// added with AddWithRefresher
+type reloader struct {
+	timers map[string]*reload
+	sem    chan struct{} // limits concurrent reloads, nil is unbounded
+	mu     *sync.Mutex
+}
+
+type reload struct {
+	fn    func() (interface{}, error)
+	ttl   time.Duration
+	timer Timer
+}
+
+func newReloader(max int) *reloader {
+	r := &reloader{
+		timers: make(map[string]*reload),
+		mu:     &sync.Mutex{},
+	}
+
+	if max > 0 {
+		r.sem = make(chan struct{}, max)
+	}
+
+	return r
+}
+
+// AddWithRefresher will add a key, value, and expiration duration to the
+// cache, and keep the item warm by calling refreshFn shortly before it
+// expires and swapping in the new value with a fresh expiration. Reloads
+// start ReloadAhead of the ttl before expiry, brought forward by up to
+// ReloadJitter so that items added together do not reload together, and
+// at most MaxReloads run at once. If refreshFn returns an error the item
+// is left to expire. Reloading stops when the item is removed.
+func (t *Cache) AddWithRefresher(key string, item interface{}, expiresIn time.Duration, refreshFn func() (interface{}, error)) error {
+	return t.Add(key, item, expiresIn, WithRefresher(refreshFn))
+}
+
+// scheduleReload will start the timer for the next reload of the key
+func (t *Cache) scheduleReload(key string, r *reload) {
+	delay := r.ttl - time.Duration(float64(r.ttl)*t.config.ReloadAhead)
+	if t.config.ReloadJitter > 0 {
+		delay -= time.Duration(rand.Int63n(int64(t.config.ReloadJitter)))
+	}
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	t.reload.mu.Lock()
+	defer t.reload.mu.Unlock()
+
+	if prev, ok := t.reload.timers[key]; ok && prev != r {
+		prev.timer.Stop()
+	}
+
+	t.reload.timers[key] = r
+	r.timer = t.config.Clock.AfterFunc(delay, func() {
+		t.runReload(key, r)
+	})
+}
+
+// runReload will call the refresh function and store the new value,
+// unless reloading of the key was stopped while it was running
+func (t *Cache) runReload(key string, r *reload) {
+	if t.reload.sem != nil {
+		t.reload.sem <- struct{}{}
+		defer func() { <-t.reload.sem }()
+	}
+
+	done := t.observeLoad("", key)
+	item, err := r.fn()
+	done(err)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reload.mu.Lock()
+	current := t.reload.timers[key] == r
+	t.reload.mu.Unlock()
+
+	if !current || err != nil {
+		return
+	}
+
+	hashedKey := t.hash(key)
+
+	if _, ok := t.keys[hashedKey]; !ok {
+		return
+	}
+
+	if t.set(hashedKey, key, item, t.expiration(r.ttl)) == nil {
+		t.scheduleReload(key, r)
+	}
+}
+
+// stopReload will stop reloading the key
+func (t *Cache) stopReload(key string) {
+	t.reload.mu.Lock()
+	defer t.reload.mu.Unlock()
+
+	if r, ok := t.reload.timers[key]; ok {
+		r.timer.Stop()
+		delete(t.reload.timers, key)
+	}
+}
+
+// stopReloads will stop reloading every key
+func (t *Cache) stopReloads() {
+	t.reload.mu.Lock()
+	defer t.reload.mu.Unlock()
+
+	for key, r := range t.reload.timers {
+		r.timer.Stop()
+		delete(t.reload.timers, key)
+	}
+}
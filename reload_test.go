@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAddWithRefresher(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+		ReloadAhead:   0.5,
+		ReloadJitter:  5 * time.Millisecond,
+	})
+
+	var version int32
+	err := cache.AddWithRefresher("config", int32(0), 40*time.Millisecond, func() (interface{}, error) {
+		return atomic.AddInt32(&version, 1), nil
+	})
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	item, err := cache.Get("config")
+	if err != nil {
+		t.Errorf("refreshed item expired: %+v", err)
+	}
+
+	if item.(int32) < 2 {
+		t.Errorf("item was reloaded %d times", item)
+	}
+
+	err = cache.Delete("config")
+	if err != nil {
+		t.Errorf("error while deleting key: %+v", err)
+	}
+
+	reloads := atomic.LoadInt32(&version)
+	time.Sleep(60 * time.Millisecond)
+
+	// a reload that was already running when the item was deleted may
+	// still call the refresh function, but its value is discarded
+	if n := atomic.LoadInt32(&version); n > reloads+1 {
+		t.Errorf("deleted item was reloaded %d times", n-reloads)
+	}
+
+	if _, err := cache.Get("config"); err != ErrDNE {
+		t.Errorf("deleted item was restored by a reload: %+v", err)
+	}
+}
+
+func TestAddWithRefresherError(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+	})
+
+	err := cache.AddWithRefresher("a", 1, 20*time.Millisecond, func() (interface{}, error) {
+		return nil, ErrDNE
+	})
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if _, err := cache.Get("a"); err != ErrDNE {
+		t.Errorf("item did not expire after a failed reload: %+v", err)
+	}
+}
+
+func TestMaxReloads(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxReloads: 2,
+	})
+
+	var running, peak int32
+	release := make(chan struct{})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.AddWithRefresher(key, 0, 10*time.Millisecond, func() (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+
+			<-release
+			atomic.AddInt32(&running, -1)
+			return 1, nil
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Errorf("%d reloads ran at once", p)
+	}
+
+	cache.Flush()
+}
@@ -0,0 +1,153 @@
+package cache
+
+import "strings"
+
+// RemovalReason is the reason an item was removed from the cache,
+// passed to the OnExpire and OnEvict callbacks
+type RemovalReason int
+
+const (
+	// ReasonExpired is an item whose ttl was reached
+	ReasonExpired RemovalReason = iota
+	// ReasonCapacity is an item evicted for MaxEntries or MaxBytes
+	ReasonCapacity
+	// ReasonPressure is an item shed while the Go heap was over its limit
+	ReasonPressure
+	// ReasonFlushed is an item removed by Flush
+	ReasonFlushed
+	// ReasonCollision is an item whose key collided with another's
+	// when the cache switched to a seeded hasher
+	ReasonCollision
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonPressure:
+		return "pressure"
+	case ReasonFlushed:
+		return "flushed"
+	case ReasonCollision:
+		return "collision"
+	default:
+		return "unknown"
+	}
+}
+
+// OnRemoval is a function that will act on an item removed from
+// the cache. For the callbacks of a bucket the key is the item's
+// key within the bucket.
+type OnRemoval func(key string, item interface{}, reason RemovalReason)
+
+// removal is an item removed from the cache with
+// the callback of the bucket it was in
+type removal struct {
+	slot     Slot
+	key      string // key of the item within the bucket
+	onBucket OnRemoval
+}
+
+// removals will find the callbacks of the buckets of the removed slots,
+// so that they can be run once the lock is released. It returns nil
+// when neither the cache nor the buckets of the slots have a callback
+// for the reason. The lock must be held.
+func (t *Cache) removals(slots []Slot, reason RemovalReason) []removal {
+	var removed []removal
+	notify := t.onRemoval(reason) != nil
+	for _, slot := range slots {
+		r := removal{slot: slot}
+
+		// a shadow holds the buckets of the cache it mirrors,
+		// whose callbacks are only run by that cache
+		if b, key := t.bucketOf(slot.name); b != nil && b.cache == t {
+			r.key = key
+			r.onBucket = b.onRemoval(reason)
+			notify = notify || r.onBucket != nil
+		}
+		removed = append(removed, r)
+	}
+
+	if !notify {
+		return nil
+	}
+
+	return removed
+}
+
+// notify will run the callbacks of the removed items for the reason,
+// those of an item's bucket before those of the cache
+func (t *Cache) notify(removed []removal, reason RemovalReason, wait bool) {
+	if len(removed) == 0 {
+		return
+	}
+
+	onCache := t.onRemoval(reason)
+	var callbacks []func()
+	for _, r := range removed {
+		r := r
+		if r.onBucket != nil {
+			callbacks = append(callbacks, func() {
+				r.onBucket(r.key, r.slot.Item, reason)
+			})
+		}
+
+		if onCache != nil {
+			callbacks = append(callbacks, func() {
+				onCache(r.slot.name, r.slot.Item, reason)
+			})
+		}
+	}
+
+	t.expirer.dispatch(callbacks, wait)
+}
+
+func (t *Cache) onRemoval(reason RemovalReason) OnRemoval {
+	if reason == ReasonExpired {
+		return t.config.OnExpire
+	}
+
+	return t.config.OnEvict
+}
+
+func (b *Bucket) onRemoval(reason RemovalReason) OnRemoval {
+	if b.config == nil {
+		return nil
+	}
+
+	if reason == ReasonExpired {
+		return b.config.OnExpire
+	}
+
+	return b.config.OnEvict
+}
+
+// bucketOf will return the bucket holding the item at the name and
+// the item's key within it, or nil if it is not in a bucket.
+// The lock must be held.
+func (t *Cache) bucketOf(name string) (*Bucket, string) {
+	if strings.IndexByte(name, keySeparator) < 0 {
+		return nil, ""
+	}
+
+	parts, err := ParseKey(name)
+	if err != nil || len(parts) != 2 {
+		return nil, ""
+	}
+
+	hk := t.hash(parts[0])
+
+	idx, ok := t.keys[hk]
+	if !ok {
+		return nil, ""
+	}
+
+	b, ok := t.slots[idx].Item.(*Bucket)
+	if !ok || b.name != parts[0] {
+		return nil, ""
+	}
+
+	return b, parts[1]
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type removalRecord struct {
+	key    string
+	reason RemovalReason
+}
+
+type removalLog struct {
+	records []removalRecord
+	mu      sync.Mutex
+}
+
+func (l *removalLog) record(key string, item interface{}, reason RemovalReason) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = append(l.records, removalRecord{key: key, reason: reason})
+}
+
+func (l *removalLog) take() []removalRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := l.records
+	l.records = nil
+	return records
+}
+
+func TestRemovalCallbacks(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	var expired, evicted, bucketExpired, bucketEvicted removalLog
+	cache := NewCache(&CacheConfig{
+		Clock:          clock,
+		DisableCleaner: true,
+		MaxEntries:     3,
+		EvictionPolicy: EvictLRU,
+		OnExpire:       expired.record,
+		OnEvict:        evicted.record,
+	})
+	defer cache.Close()
+
+	b := cache.BucketWithConfig("bucket", &BucketConfig{
+		OnExpire: bucketExpired.record,
+		OnEvict:  bucketEvicted.record,
+	})
+	b.Add("a:b", 1, time.Minute)
+	cache.Add("key", 2, time.Hour)
+
+	clock.Advance(2 * time.Minute)
+	cache.DeleteExpired()
+
+	if r := bucketExpired.take(); len(r) != 1 || r[0] != (removalRecord{"a:b", ReasonExpired}) {
+		t.Errorf("expected the bucket's OnExpire to get the key within the bucket, got %+v", r)
+	}
+
+	if r := expired.take(); len(r) != 1 || r[0] != (removalRecord{b.key("a:b"), ReasonExpired}) {
+		t.Errorf("expected the cache's OnExpire to get the key in the cache, got %+v", r)
+	}
+
+	if r := evicted.take(); len(r) != 0 {
+		t.Errorf("expected expired items not to be passed to OnEvict, got %+v", r)
+	}
+
+	cache.Add("second", 3, time.Hour)
+	cache.Add("third", 4, time.Hour)
+	if !waitFor(func() bool {
+		evicted.mu.Lock()
+		defer evicted.mu.Unlock()
+		return len(evicted.records) == 1
+	}) {
+		t.Fatalf("expected OnEvict to be called for the evicted item")
+	}
+
+	if r := evicted.take(); r[0] != (removalRecord{"key", ReasonCapacity}) {
+		t.Errorf("expected the least recently used item to be evicted, got %+v", r)
+	}
+
+	b.Add("flushed", 5, time.Hour)
+	waitFor(func() bool {
+		evicted.mu.Lock()
+		defer evicted.mu.Unlock()
+		return len(evicted.records) == 1
+	})
+	evicted.take()
+
+	cache.Flush()
+	if r := bucketEvicted.take(); len(r) != 1 || r[0] != (removalRecord{"flushed", ReasonFlushed}) {
+		t.Errorf("expected the bucket's OnEvict to be called by Flush, got %+v", r)
+	}
+
+	if r := evicted.take(); len(r) != 2 || r[0].reason != ReasonFlushed {
+		t.Errorf("expected the cache's OnEvict to be called by Flush, got %+v", r)
+	}
+
+	if r := expired.take(); len(r) != 0 {
+		t.Errorf("expected OnExpire not to be called by Flush, got %+v", r)
+	}
+}
+
+func TestRemovalCallbacksShed(t *testing.T) {
+	var evicted removalLog
+	cache := NewCache(&CacheConfig{OnEvict: evicted.record})
+	defer cache.Close()
+
+	cache.Add("key", 1, time.Hour)
+	cache.shed(1)
+
+	if !waitFor(func() bool {
+		evicted.mu.Lock()
+		defer evicted.mu.Unlock()
+		return len(evicted.records) == 1
+	}) {
+		t.Fatalf("expected OnEvict to be called for the shed item")
+	}
+
+	if r := evicted.take(); r[0] != (removalRecord{"key", ReasonPressure}) {
+		t.Errorf("expected ReasonPressure, got %+v", r)
+	}
+}
+
+func TestRemovalReasonString(t *testing.T) {
+	if ReasonCapacity.String() != "capacity" || RemovalReason(-1).String() != "unknown" {
+		t.Errorf("unexpected reason names %q %q", ReasonCapacity, RemovalReason(-1))
+	}
+}
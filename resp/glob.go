@@ -0,0 +1,102 @@
+package resp
+
+// match reports whether the key matches the glob-style pattern used by
+// KEYS and SCAN: * matches any sequence, ? any single byte, [abc] and
+// [a-z] a set or range of bytes, [^a] a byte outside the set, and \
+// escapes the next byte.
+func match(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(key); i++ {
+				if match(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+
+			end := 1
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '\\' {
+					end++
+				}
+				end++
+			}
+
+			if end >= len(pattern) {
+				// an unterminated set matches the bracket literally
+				if key[0] != '[' {
+					return false
+				}
+				pattern, key = pattern[1:], key[1:]
+				continue
+			}
+
+			if !matchSet(pattern[1:end], key[0]) {
+				return false
+			}
+			pattern, key = pattern[end+1:], key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+
+	return len(key) == 0
+}
+
+// matchSet reports whether the byte is in the set, e.g. "a-z_" or "^0-9"
+func matchSet(set string, c byte) bool {
+	negate := len(set) > 0 && set[0] == '^'
+	if negate {
+		set = set[1:]
+	}
+
+	var found bool
+	for i := 0; i < len(set); i++ {
+		lo := set[i]
+		if lo == '\\' && i+1 < len(set) {
+			i++
+			lo = set[i]
+		}
+
+		hi := lo
+		if i+2 < len(set) && set[i+1] == '-' {
+			hi = set[i+2]
+			i += 2
+			if hi < lo {
+				lo, hi = hi, lo
+			}
+		}
+
+		if lo <= c && c <= hi {
+			found = true
+		}
+	}
+
+	return found != negate
+}
@@ -0,0 +1,109 @@
+package resp
+
+import "sort"
+
+// subscribedCommands are the commands allowed on a subscribed connection
+var subscribedCommands = map[string]bool{
+	"SUBSCRIBE":   true,
+	"UNSUBSCRIBE": true,
+	"PING":        true,
+	"QUIT":        true,
+}
+
+// publish will send the message to every subscriber of the
+// channel and return the number of subscribers it was sent to
+func (s *Server) publish(channel, message string) int64 {
+	s.mu.Lock()
+	subscribers := make([]*writer, 0, len(s.channels[channel]))
+	for w := range s.channels[channel] {
+		subscribers = append(subscribers, w)
+	}
+	s.mu.Unlock()
+
+	for _, w := range subscribers {
+		w.mu.Lock()
+		w.array(3)
+		w.bulk([]byte("message"))
+		w.bulk([]byte(channel))
+		w.bulk([]byte(message))
+		w.Flush()
+		w.mu.Unlock()
+	}
+
+	return int64(len(subscribers))
+}
+
+// subscribe will subscribe the connection to the channels.
+// The writer lock must be held.
+func (s *Server) subscribe(w *writer, channels []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, channel := range channels {
+		if s.channels[channel] == nil {
+			s.channels[channel] = make(map[*writer]struct{})
+		}
+		s.channels[channel][w] = struct{}{}
+		w.channels[channel] = struct{}{}
+
+		w.array(3)
+		w.bulk([]byte("subscribe"))
+		w.bulk([]byte(channel))
+		w.integer(int64(len(w.channels)))
+	}
+}
+
+// unsubscribe will unsubscribe the connection from the channels,
+// or from every channel when none are given.
+// The writer lock must be held.
+func (s *Server) unsubscribe(w *writer, channels []string) {
+	if len(channels) == 0 {
+		for channel := range w.channels {
+			channels = append(channels, channel)
+		}
+		sort.Strings(channels)
+
+		if len(channels) == 0 {
+			w.array(3)
+			w.bulk([]byte("unsubscribe"))
+			w.null()
+			w.integer(0)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, channel := range channels {
+		s.remove(w, channel)
+
+		w.array(3)
+		w.bulk([]byte("unsubscribe"))
+		w.bulk([]byte(channel))
+		w.integer(int64(len(w.channels)))
+	}
+}
+
+// unsubscribeAll will unsubscribe a closed connection from every channel
+func (s *Server) unsubscribeAll(w *writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for channel := range w.channels {
+		s.remove(w, channel)
+	}
+}
+
+// remove will remove the connection from the subscribers of the channel.
+// The writer and server locks must be held.
+func (s *Server) remove(w *writer, channel string) {
+	delete(w.channels, channel)
+	delete(s.channels[channel], w)
+	if len(s.channels[channel]) == 0 {
+		delete(s.channels, channel)
+	}
+}
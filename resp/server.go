@@ -0,0 +1,629 @@
+// Package resp serves a cache over the Redis serialization protocol, so
+// that redis-cli and Redis client libraries can talk to the cache for
+// debugging and lightweight deployments. A subset of commands is
+// supported: PING, ECHO, QUIT, GET, SET (with EX, PX, NX and XX), DEL,
+// EXISTS, EXPIRE, PEXPIRE, TTL, PTTL, INCR, DECR, INCRBY, DECRBY,
+// KEYS, SCAN, PUBLISH, SUBSCRIBE and UNSUBSCRIBE.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrServerClosed is returned by Serve after the server is closed
+	ErrServerClosed = errors.New("resp: server closed")
+
+	errProtocol = errors.New("protocol error")
+
+	defaultMaxBulkSize = 512 * 1024 * 1024
+	defaultScanCount   = 10
+)
+
+const maxArgs = 1024 * 1024
+
+// Server serves a cache over the Redis serialization protocol
+type Server struct {
+	cache       *cache.Cache
+	MaxBulkSize int // largest argument accepted in a command, defaults to 512MB
+
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	channels  map[string]map[*writer]struct{} // subscribers of each channel
+	closed    bool
+	mu        *sync.Mutex
+}
+
+// NewServer will create and return a pointer to a new Server for the cache
+func NewServer(c *cache.Cache) *Server {
+	return &Server{
+		cache:       c,
+		MaxBulkSize: defaultMaxBulkSize,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+		channels:    make(map[string]map[*writer]struct{}),
+		mu:          &sync.Mutex{},
+	}
+}
+
+// ListenAndServe will listen on the TCP address and serve connections
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve will accept connections on the listener and serve each of them
+// on its own goroutine. It always returns a non-nil error, which is
+// ErrServerClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close will close the listeners and every open connection
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := newWriter(conn)
+	defer s.unsubscribeAll(w)
+
+	for {
+		args, err := s.readCommand(r)
+		if err == errProtocol {
+			w.mu.Lock()
+			w.error("ERR Protocol error")
+			w.Flush()
+			w.mu.Unlock()
+			return
+		} else if err != nil {
+			return
+		}
+
+		// the lock keeps messages published to a subscribed
+		// connection from interleaving with its replies
+		w.mu.Lock()
+		open := len(args) == 0 || s.handle(w, args)
+		if !open || r.Buffered() == 0 {
+			err = w.Flush()
+		}
+		w.mu.Unlock()
+
+		if !open || err != nil {
+			return
+		}
+	}
+}
+
+// handle will run one command, returning false
+// when the connection should be closed
+func (s *Server) handle(w *writer, args []string) bool {
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+
+	if arity, ok := arities[name]; ok && (len(args) < arity.min || (arity.max >= 0 && len(args) > arity.max)) {
+		w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return true
+	}
+
+	if len(w.channels) > 0 && !subscribedCommands[name] {
+		w.error("ERR Can't execute '" + strings.ToLower(name) + "': only SUBSCRIBE / UNSUBSCRIBE / PING / QUIT are allowed in this context")
+		return true
+	}
+
+	switch name {
+	case "PING":
+		if len(args) == 1 {
+			w.bulk([]byte(args[0]))
+		} else {
+			w.status("PONG")
+		}
+	case "ECHO":
+		w.bulk([]byte(args[0]))
+	case "QUIT":
+		w.status("OK")
+		return false
+	case "GET":
+		s.get(w, args[0])
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		var n int64
+		for _, key := range args {
+			if s.cache.Delete(key) == nil {
+				n++
+			}
+		}
+		w.integer(n)
+	case "EXISTS":
+		var n int64
+		for _, key := range args {
+			if _, err := s.cache.TTL(key); err == nil {
+				n++
+			}
+		}
+		w.integer(n)
+	case "EXPIRE", "PEXPIRE":
+		s.expire(w, args, name == "PEXPIRE")
+	case "TTL", "PTTL":
+		s.ttl(w, args[0], name == "PTTL")
+	case "INCR", "DECR", "INCRBY", "DECRBY":
+		s.incr(w, name, args)
+	case "KEYS":
+		s.keys(w, args[0])
+	case "SCAN":
+		s.scan(w, args)
+	case "PUBLISH":
+		w.integer(s.publish(args[0], args[1]))
+	case "SUBSCRIBE":
+		s.subscribe(w, args)
+	case "UNSUBSCRIBE":
+		s.unsubscribe(w, args)
+	default:
+		w.error("ERR unknown command '" + strings.ToLower(name) + "'")
+	}
+
+	return true
+}
+
+type arity struct {
+	min, max int // max is -1 when unbounded
+}
+
+var arities = map[string]arity{
+	"PING":        {0, 1},
+	"ECHO":        {1, 1},
+	"QUIT":        {0, 0},
+	"GET":         {1, 1},
+	"SET":         {2, -1},
+	"DEL":         {1, -1},
+	"EXISTS":      {1, -1},
+	"EXPIRE":      {2, 2},
+	"PEXPIRE":     {2, 2},
+	"TTL":         {1, 1},
+	"PTTL":        {1, 1},
+	"INCR":        {1, 1},
+	"DECR":        {1, 1},
+	"INCRBY":      {2, 2},
+	"DECRBY":      {2, 2},
+	"KEYS":        {1, 1},
+	"SCAN":        {1, -1},
+	"PUBLISH":     {2, 2},
+	"SUBSCRIBE":   {1, -1},
+	"UNSUBSCRIBE": {0, -1},
+}
+
+func (s *Server) get(w *writer, key string) {
+	item, err := s.cache.Get(key)
+	if err == cache.ErrDNE {
+		w.null()
+		return
+	} else if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+
+	value, ok := encode(item)
+	if !ok {
+		w.error("WRONGTYPE Operation against a key holding the wrong kind of value")
+		return
+	}
+	w.bulk(value)
+}
+
+// set will run SET key value [EX seconds | PX milliseconds] [NX | XX]
+func (s *Server) set(w *writer, args []string) {
+	key, value := args[0], []byte(args[1])
+
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 == len(args) || ttl != 0 {
+				w.error("ERR syntax error")
+				return
+			}
+
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+
+			unit := time.Second
+			if strings.ToUpper(args[i]) == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	if nx && xx {
+		w.error("ERR syntax error")
+		return
+	}
+
+	var err error
+	switch {
+	case nx:
+		err = s.cache.Add(key, value, ttl)
+		if err == cache.ErrCollision {
+			w.null()
+			return
+		}
+	case xx:
+		if _, err = s.cache.TTL(key); err == cache.ErrDNE {
+			w.null()
+			return
+		}
+		err = s.cache.Set(key, value, ttl)
+	default:
+		err = s.cache.Set(key, value, ttl)
+	}
+
+	if err != nil {
+		w.error("ERR " + err.Error())
+		return
+	}
+	w.status("OK")
+}
+
+func (s *Server) expire(w *writer, args []string, millis bool) {
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	ttl := time.Duration(n) * time.Second
+	if millis {
+		ttl = time.Duration(n) * time.Millisecond
+	}
+
+	if ttl <= 0 {
+		err = s.cache.Delete(args[0])
+	} else {
+		err = s.cache.Touch(args[0], ttl)
+	}
+
+	if err != nil {
+		w.integer(0)
+		return
+	}
+	w.integer(1)
+}
+
+func (s *Server) ttl(w *writer, key string, millis bool) {
+	ttl, err := s.cache.TTL(key)
+	switch {
+	case err != nil:
+		w.integer(-2)
+	case ttl == 0:
+		w.integer(-1)
+	case millis:
+		w.integer(int64(ttl / time.Millisecond))
+	default:
+		// round up, so that a key with time left never reports 0
+		w.integer(int64((ttl + time.Second - 1) / time.Second))
+	}
+}
+
+// incr will run INCR, DECR, INCRBY, or DECRBY. A missing key is set to
+// the delta without an expiration, as in Redis.
+func (s *Server) incr(w *writer, name string, args []string) {
+	delta := int64(1)
+	if len(args) == 2 {
+		var err error
+		delta, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			w.error("ERR value is not an integer or out of range")
+			return
+		}
+	}
+
+	if name == "DECR" || name == "DECRBY" {
+		delta = -delta
+	}
+
+	for {
+		var result int64
+		var invalid string
+		err := s.cache.UpdateIf(args[0], func(cur interface{}) (interface{}, bool) {
+			value, ok := encode(cur)
+			if !ok {
+				invalid = "WRONGTYPE Operation against a key holding the wrong kind of value"
+				return nil, false
+			}
+
+			n, err := strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				invalid = "ERR value is not an integer or out of range"
+				return nil, false
+			}
+
+			result = n + delta
+			if (delta > 0 && result < n) || (delta < 0 && result > n) {
+				invalid = "ERR increment or decrement would overflow"
+				return nil, false
+			}
+
+			return []byte(strconv.FormatInt(result, 10)), true
+		})
+
+		if err == cache.ErrDNE {
+			err = s.cache.Add(args[0], []byte(strconv.FormatInt(delta, 10)), 0)
+			if err == cache.ErrCollision {
+				// added concurrently, so increment the new value
+				continue
+			}
+			result = delta
+		}
+
+		switch {
+		case err != nil:
+			w.error("ERR " + err.Error())
+		case invalid != "":
+			w.error(invalid)
+		default:
+			w.integer(result)
+		}
+		return
+	}
+}
+
+func (s *Server) keys(w *writer, pattern string) {
+	var matched []string
+	for _, key := range s.cache.Keys() {
+		if match(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	w.array(len(matched))
+	for _, key := range matched {
+		w.bulk([]byte(key))
+	}
+}
+
+// scan will run SCAN cursor [MATCH pattern] [COUNT count]. The cursor is
+// an offset into the ordered keys, so keys that exist for the whole scan
+// are returned unless keys ordered before them are deleted meanwhile.
+func (s *Server) scan(w *writer, args []string) {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		w.error("ERR invalid cursor")
+		return
+	}
+
+	pattern := "*"
+	count := defaultScanCount
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				w.error("ERR syntax error")
+				return
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	keys := s.cache.Keys()
+	if cursor > len(keys) {
+		cursor = len(keys)
+	}
+
+	end := cursor + count
+	if end >= len(keys) {
+		end = len(keys)
+	}
+
+	var matched []string
+	for _, key := range keys[cursor:end] {
+		if match(pattern, key) {
+			matched = append(matched, key)
+		}
+	}
+
+	next := end
+	if end == len(keys) {
+		next = 0
+	}
+
+	w.array(2)
+	w.bulk([]byte(strconv.Itoa(next)))
+	w.array(len(matched))
+	for _, key := range matched {
+		w.bulk([]byte(key))
+	}
+}
+
+// encode will return the bytes of an item in the cache
+func encode(item interface{}) ([]byte, bool) {
+	switch v := item.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	}
+
+	return nil, false
+}
+
+// readCommand will read a command as an array of bulk strings,
+// or as an inline command of space separated arguments
+func (s *Server) readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, errProtocol
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > s.MaxBulkSize {
+			return nil, errProtocol
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, errProtocol
+		}
+		args = append(args, string(data[:size]))
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// writer writes RESP replies
+type writer struct {
+	*bufio.Writer
+	channels map[string]struct{} // channels the connection is subscribed to
+	mu       *sync.Mutex
+}
+
+func newWriter(conn net.Conn) *writer {
+	return &writer{
+		Writer:   bufio.NewWriter(conn),
+		channels: make(map[string]struct{}),
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (w *writer) status(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w *writer) error(s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (w *writer) bulk(b []byte) {
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,248 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newTestServer(t *testing.T) (*cache.Cache, *client, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening: %+v", err)
+	}
+
+	c := cache.NewCache(nil)
+	s := NewServer(c)
+	go s.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("error connecting: %+v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	return c, &client{t: t, conn: conn, r: bufio.NewReader(conn)}, func() {
+		conn.Close()
+		s.Close()
+	}
+}
+
+// do will send the command as an array of bulk strings and return
+// the reply flattened into lines, e.g. ":1" or "*2" "$-1"
+func (c *client) do(args ...string) []string {
+	c.t.Helper()
+
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+
+	_, err := io.WriteString(c.conn, b.String())
+	if err != nil {
+		c.t.Fatalf("error writing command: %+v", err)
+	}
+
+	return c.read()
+}
+
+func (c *client) read() []string {
+	c.t.Helper()
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("error reading reply: %+v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return []string{line}
+		}
+
+		data := make([]byte, n+2)
+		io.ReadFull(c.r, data)
+		return []string{string(data[:n])}
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		reply := []string{line}
+		for i := 0; i < n; i++ {
+			reply = append(reply, c.read()...)
+		}
+		return reply
+	}
+
+	return []string{line}
+}
+
+func (c *client) expect(reply []string, expected ...string) {
+	c.t.Helper()
+
+	if strings.Join(reply, " ") != strings.Join(expected, " ") {
+		c.t.Errorf("reply was %q, expected %q", reply, expected)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	c, client, stop := newTestServer(t)
+	defer stop()
+
+	client.expect(client.do("PING"), "+PONG")
+	client.expect(client.do("SET", "a", "hello"), "+OK")
+	client.expect(client.do("GET", "a"), "hello")
+	client.expect(client.do("get", "missing"), "$-1")
+
+	client.expect(client.do("SET", "a", "x", "NX"), "$-1")
+	client.expect(client.do("SET", "b", "x", "XX"), "$-1")
+	client.expect(client.do("SET", "a", "world", "XX", "EX", "60"), "+OK")
+	client.expect(client.do("GET", "a"), "world")
+
+	client.expect(client.do("EXISTS", "a", "b"), ":1")
+	client.expect(client.do("DEL", "a", "b"), ":1")
+	client.expect(client.do("GET", "a"), "$-1")
+
+	c.Add("native", 42, time.Minute)
+	client.expect(client.do("GET", "native"), "-WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	client.expect(client.do("SET", "a"), "-ERR wrong number of arguments for 'set' command")
+	client.expect(client.do("SET", "a", "b", "EX", "0"), "-ERR invalid expire time in 'set' command")
+	client.expect(client.do("FLUSHALL"), "-ERR unknown command 'flushall'")
+}
+
+func TestExpiration(t *testing.T) {
+	_, client, stop := newTestServer(t)
+	defer stop()
+
+	client.expect(client.do("SET", "a", "1", "PX", "1500"), "+OK")
+	client.expect(client.do("TTL", "a"), ":2")
+
+	client.expect(client.do("SET", "b", "1"), "+OK")
+	client.expect(client.do("TTL", "b"), ":-1")
+	client.expect(client.do("TTL", "missing"), ":-2")
+
+	client.expect(client.do("EXPIRE", "b", "100"), ":1")
+	client.expect(client.do("TTL", "b"), ":100")
+	client.expect(client.do("EXPIRE", "missing", "100"), ":0")
+
+	client.expect(client.do("PEXPIRE", "b", "-1"), ":1")
+	client.expect(client.do("GET", "b"), "$-1")
+}
+
+func TestIncr(t *testing.T) {
+	_, client, stop := newTestServer(t)
+	defer stop()
+
+	client.expect(client.do("INCR", "n"), ":1")
+	client.expect(client.do("INCRBY", "n", "9"), ":10")
+	client.expect(client.do("DECR", "n"), ":9")
+	client.expect(client.do("DECRBY", "missing", "3"), ":-3")
+
+	client.expect(client.do("SET", "s", "abc"), "+OK")
+	client.expect(client.do("INCR", "s"), "-ERR value is not an integer or out of range")
+
+	client.expect(client.do("SET", "max", "9223372036854775807"), "+OK")
+	client.expect(client.do("INCR", "max"), "-ERR increment or decrement would overflow")
+}
+
+func TestKeysAndScan(t *testing.T) {
+	_, client, stop := newTestServer(t)
+	defer stop()
+
+	for _, key := range []string{"user:1", "user:2", "user:3", "session:1"} {
+		client.expect(client.do("SET", key, "x"), "+OK")
+	}
+
+	client.expect(client.do("KEYS", "user:*"), "*3", "user:1", "user:2", "user:3")
+	client.expect(client.do("KEYS", "*:[12]"), "*3", "session:1", "user:1", "user:2")
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply := client.do("SCAN", cursor, "COUNT", "3")
+		cursor = reply[1]
+		keys = append(keys, reply[3:]...)
+		if cursor == "0" {
+			break
+		}
+	}
+
+	if strings.Join(keys, " ") != "session:1 user:1 user:2 user:3" {
+		t.Errorf("scan returned %q", keys)
+	}
+
+	client.expect(client.do("SCAN", "0", "MATCH", "session:*"), "*2", "0", "*1", "session:1")
+}
+
+func TestInlineCommands(t *testing.T) {
+	_, client, stop := newTestServer(t)
+	defer stop()
+
+	io.WriteString(client.conn, "SET a 1\r\nGET a\r\n")
+	client.expect(client.read(), "+OK")
+	client.expect(client.read(), "1")
+}
+
+func TestPubSub(t *testing.T) {
+	_, sub, stop := newTestServer(t)
+	defer stop()
+
+	conn, err := net.Dial("tcp", sub.conn.RemoteAddr().String())
+	if err != nil {
+		t.Fatalf("error connecting: %+v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	pub := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	sub.expect(sub.do("SUBSCRIBE", "news"), "*3", "subscribe", "news", ":1")
+	sub.expect(sub.do("GET", "a"), "-ERR Can't execute 'get': only SUBSCRIBE / UNSUBSCRIBE / PING / QUIT are allowed in this context")
+
+	pub.expect(pub.do("PUBLISH", "news", "hello"), ":1")
+	sub.expect(sub.read(), "*3", "message", "news", "hello")
+
+	pub.expect(pub.do("PUBLISH", "other", "hello"), ":0")
+
+	sub.expect(sub.do("UNSUBSCRIBE"), "*3", "unsubscribe", "news", ":0")
+	pub.expect(pub.do("PUBLISH", "news", "hello"), ":0")
+	sub.expect(sub.do("GET", "a"), "$-1")
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		match        bool
+	}{
+		{"*", "", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"[a-c]x", "bx", true},
+		{"[^a-c]x", "bx", false},
+		{"[^a-c]x", "dx", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"a[", "a[", true},
+	}
+
+	for _, test := range tests {
+		if match(test.pattern, test.key) != test.match {
+			t.Errorf("match(%q, %q) should be %v", test.pattern, test.key, test.match)
+		}
+	}
+}
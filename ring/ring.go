@@ -0,0 +1,194 @@
+// Package ring shards keys across several remote cache nodes using
+// consistent hashing. Each node is placed on the ring at a number of
+// virtual points, so keys are spread evenly and adding or removing a
+// node only moves the keys of the points it owns. Nodes are any
+// cache.Backend, such as a redis.Backend connected to a resp.Server.
+package ring
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrNoNodes is returned when a key is looked up on an empty ring
+	ErrNoNodes = errors.New("ring: no nodes")
+
+	defaultReplicas = 100
+)
+
+// Ring is a cache.Backend that distributes keys across its nodes
+type Ring struct {
+	replicas int
+	nodes    map[string]cache.Backend
+	points   []point // sorted by hash
+	mu       *sync.RWMutex
+}
+
+// point is a virtual node on the ring
+type point struct {
+	hash uint64
+	node string
+}
+
+// New will create and return a pointer to an empty Ring placing
+// each node at replicas virtual points, defaulting to 100.
+func New(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+
+	return &Ring{
+		replicas: replicas,
+		nodes:    make(map[string]cache.Backend),
+		mu:       &sync.RWMutex{},
+	}
+}
+
+// AddNode will add a node to the ring, replacing any node with the same name.
+func (r *Ring) AddNode(name string, backend cache.Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[name]; ok {
+		r.removePoints(name)
+	}
+	r.nodes[name] = backend
+
+	for i := 0; i < r.replicas; i++ {
+		r.points = append(r.points, point{
+			hash: hash(name + "#" + strconv.Itoa(i)),
+			node: name,
+		})
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash == r.points[j].hash {
+			return r.points[i].node < r.points[j].node
+		}
+		return r.points[i].hash < r.points[j].hash
+	})
+}
+
+// RemoveNode will remove a node from the ring. Its keys move to the
+// nodes that follow its points, the keys of other nodes do not move.
+func (r *Ring) RemoveNode(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[name]; !ok {
+		return
+	}
+
+	delete(r.nodes, name)
+	r.removePoints(name)
+}
+
+// Nodes will return the sorted names of the nodes on the ring
+func (r *Ring) Nodes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.nodes))
+	for name := range r.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Node will return the name and backend of the node that owns the key
+func (r *Ring) Node(key string) (string, cache.Backend, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.points) == 0 {
+		return "", nil, ErrNoNodes
+	}
+
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+
+	name := r.points[i].node
+	return name, r.nodes[name], nil
+}
+
+// Get will get the value of the key from the node that owns it
+func (r *Ring) Get(key string) ([]byte, error) {
+	_, backend, err := r.Node(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.Get(key)
+}
+
+// Set will set the value of the key on the node that owns it
+func (r *Ring) Set(key string, value []byte, ttl time.Duration) error {
+	_, backend, err := r.Node(key)
+	if err != nil {
+		return err
+	}
+
+	return backend.Set(key, value, ttl)
+}
+
+// Delete will delete the key from the node that owns it
+func (r *Ring) Delete(key string) error {
+	_, backend, err := r.Node(key)
+	if err != nil {
+		return err
+	}
+
+	return backend.Delete(key)
+}
+
+// TTL will return the ttl of the key from the node that owns it
+func (r *Ring) TTL(key string) (time.Duration, error) {
+	_, backend, err := r.Node(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return backend.TTL(key)
+}
+
+// removePoints will remove the virtual points of the node.
+// The ring lock must be held.
+func (r *Ring) removePoints(name string) {
+	points := r.points[:0]
+	for _, p := range r.points {
+		if p.node != name {
+			points = append(points, p)
+		}
+	}
+	r.points = points
+}
+
+// hash will hash the key with FNV-1a, finalized so that
+// similar keys such as the virtual points of a node spread out
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
@@ -0,0 +1,148 @@
+package ring
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+	"github.com/JKhawaja/cache/redis"
+	"github.com/JKhawaja/cache/resp"
+)
+
+// owners will map each of count keys to the node that owns it
+func owners(t *testing.T, r *Ring, count int) map[string]string {
+	m := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		key := "key" + strconv.Itoa(i)
+		name, _, err := r.Node(key)
+		if err != nil {
+			t.Fatalf("Node error: %+v", err)
+		}
+		m[key] = name
+	}
+
+	return m
+}
+
+func TestRingDistribution(t *testing.T) {
+	r := New(0)
+	for i := 0; i < 4; i++ {
+		r.AddNode("node"+strconv.Itoa(i), nil)
+	}
+
+	counts := make(map[string]int)
+	for _, name := range owners(t, r, 10000) {
+		counts[name]++
+	}
+
+	for name, count := range counts {
+		if count < 1500 || count > 3500 {
+			t.Errorf("expected an even spread, %s owns %d of 10000 keys", name, count)
+		}
+	}
+}
+
+func TestRingMovement(t *testing.T) {
+	r := New(0)
+	for i := 0; i < 4; i++ {
+		r.AddNode("node"+strconv.Itoa(i), nil)
+	}
+	before := owners(t, r, 10000)
+
+	r.AddNode("node4", nil)
+	after := owners(t, r, 10000)
+
+	var moved int
+	for key, name := range after {
+		if name == before[key] {
+			continue
+		}
+
+		moved++
+		if name != "node4" {
+			t.Errorf("expected keys to only move to the new node, %s moved to %s", key, name)
+		}
+	}
+
+	if moved < 1000 || moved > 3000 {
+		t.Errorf("expected about a fifth of the keys to move, moved %d", moved)
+	}
+
+	r.RemoveNode("node4")
+	for key, name := range owners(t, r, 10000) {
+		if name != before[key] {
+			t.Errorf("expected removing the node to restore %s to %s, got %s", key, before[key], name)
+		}
+	}
+}
+
+func TestRingEmpty(t *testing.T) {
+	r := New(0)
+
+	_, err := r.Get("key")
+	if err != ErrNoNodes {
+		t.Errorf("expected ErrNoNodes, got %+v", err)
+	}
+}
+
+func TestRingBackends(t *testing.T) {
+	r := New(10)
+
+	var servers []*resp.Server
+	caches := make(map[string]*cache.Cache)
+	for i := 0; i < 3; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("error listening: %+v", err)
+		}
+
+		c := cache.NewCache(nil)
+		s := resp.NewServer(c)
+		go s.Serve(l)
+		servers = append(servers, s)
+
+		b := redis.NewBackend(l.Addr().String(), &redis.Config{Timeout: 5 * time.Second})
+		defer b.Close()
+
+		name := l.Addr().String()
+		caches[name] = c
+		r.AddNode(name, b)
+	}
+	defer func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}()
+
+	var _ cache.Backend = r
+
+	for i := 0; i < 30; i++ {
+		key := "key" + strconv.Itoa(i)
+		err := r.Set(key, []byte(key), time.Minute)
+		if err != nil {
+			t.Errorf("Set error: %+v", err)
+		}
+
+		name, _, _ := r.Node(key)
+		if _, err := caches[name].Get(key); err != nil {
+			t.Errorf("expected %s to be stored on %s: %+v", key, name, err)
+		}
+
+		value, err := r.Get(key)
+		if err != nil || string(value) != key {
+			t.Errorf("unexpected value %q: %+v", value, err)
+		}
+	}
+
+	err := r.Delete("key0")
+	if err != nil {
+		t.Errorf("Delete error: %+v", err)
+	}
+
+	_, err = r.Get("key0")
+	if err != cache.ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package cache
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrBadPattern is returned by ScanMatch when the glob pattern is malformed
+var ErrBadPattern = errors.New("malformed glob pattern")
+
+// scanBatch is the number of slots a Scanner examines each time it takes the lock
+const scanBatch = 256
+
+// Scanner iterates over the keys of a cache that match a pattern,
+// examining a batch of slots at a time so that large caches can be
+// scanned without holding the lock for long. Like Redis SCAN, a key
+// present for the whole scan is returned exactly once, while keys added
+// or deleted during the scan may or may not be returned. Buckets and
+// expired items are skipped.
+//
+// A scan can be paused and resumed later by passing the value of
+// Cursor to a new Scanner. A cursor of 0 starts a new scan.
+type Scanner struct {
+	cache  *Cache
+	match  func(name string) bool
+	next   int // next slot to examine
+	batch  []scanned
+	cur    scanned
+	cursor uint64
+	done   bool
+}
+
+type scanned struct {
+	idx  int
+	slot Slot
+}
+
+// ScanPrefix will return a Scanner over the keys starting with
+// the prefix, resuming from the cursor.
+func (t *Cache) ScanPrefix(prefix string, cursor uint64) *Scanner {
+	return t.scan(func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	}, cursor)
+}
+
+// ScanMatch will return a Scanner over the keys matching the glob pattern,
+// resuming from the cursor. Patterns use the Redis syntax: '*' matches any
+// sequence, '?' any single character, '[abc]', '[a-z]' and '[^a]' match
+// classes of characters, and '\' escapes the character following it.
+// It will return ErrBadPattern if the pattern is malformed.
+func (t *Cache) ScanMatch(pattern string, cursor uint64) (*Scanner, error) {
+	g, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.scan(g.match, cursor), nil
+}
+
+func (t *Cache) scan(match func(name string) bool, cursor uint64) *Scanner {
+	return &Scanner{
+		cache:  t,
+		match:  match,
+		next:   int(cursor),
+		cursor: cursor,
+	}
+}
+
+// Next will advance the scanner to the next matching key,
+// returning false once the scan is complete.
+func (s *Scanner) Next() bool {
+	for len(s.batch) == 0 {
+		if s.done {
+			return false
+		}
+		s.fill()
+	}
+
+	s.cur = s.batch[0]
+	s.batch = s.batch[1:]
+	s.cursor = uint64(s.cur.idx + 1)
+
+	return true
+}
+
+// Key will return the current key
+func (s *Scanner) Key() string {
+	return s.cur.slot.name
+}
+
+// Item will return the item of the current key
+func (s *Scanner) Item() interface{} {
+	return s.cur.slot.Item
+}
+
+// Cursor will return the cursor to resume the scan from after the
+// current key, or 0 once the scan is complete.
+func (s *Scanner) Cursor() uint64 {
+	if s.done && len(s.batch) == 0 && s.next == 0 {
+		return 0
+	}
+
+	return s.cursor
+}
+
+// fill will collect the matching keys of the next batch of slots
+func (s *Scanner) fill() {
+	t := s.cache
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+	end := s.next + scanBatch
+	if end >= len(t.slots) {
+		end = len(t.slots)
+		s.done = true
+	}
+
+	for i := s.next; i < end; i++ {
+		slot := t.slots[i]
+		if slot.empty || slot.deleted || now.After(slot.ExpiresAt) {
+			continue
+		}
+
+		if _, ok := slot.Item.(*Bucket); ok {
+			continue
+		}
+
+		if s.match(slot.name) {
+			s.batch = append(s.batch, scanned{idx: i, slot: slot})
+		}
+	}
+
+	if s.done {
+		s.next = 0
+	} else {
+		s.next = end
+		s.cursor = uint64(end)
+	}
+}
+
+// glob is a compiled glob pattern
+type glob []globToken
+
+type globToken struct {
+	kind   byte // '*', '?', '[' or 0 for a literal
+	r      rune
+	ranges [][2]rune
+	negate bool
+}
+
+func compileGlob(pattern string) (glob, error) {
+	var g glob
+	for len(pattern) > 0 {
+		r, n := utf8.DecodeRuneInString(pattern)
+		pattern = pattern[n:]
+
+		switch r {
+		case '*':
+			if len(g) == 0 || g[len(g)-1].kind != '*' {
+				g = append(g, globToken{kind: '*'})
+			}
+		case '?':
+			g = append(g, globToken{kind: '?'})
+		case '[':
+			tok, rest, err := compileClass(pattern)
+			if err != nil {
+				return nil, err
+			}
+			g = append(g, tok)
+			pattern = rest
+		case '\\':
+			if len(pattern) == 0 {
+				return nil, ErrBadPattern
+			}
+			r, n = utf8.DecodeRuneInString(pattern)
+			pattern = pattern[n:]
+			g = append(g, globToken{r: r})
+		default:
+			g = append(g, globToken{r: r})
+		}
+	}
+
+	return g, nil
+}
+
+// compileClass will compile a character class, following its
+// opening '[', and return the rest of the pattern after its ']'
+func compileClass(pattern string) (globToken, string, error) {
+	tok := globToken{kind: '['}
+	if strings.HasPrefix(pattern, "^") {
+		tok.negate = true
+		pattern = pattern[1:]
+	}
+
+	for {
+		if len(pattern) == 0 {
+			return tok, "", ErrBadPattern
+		}
+
+		if pattern[0] == ']' && len(tok.ranges) > 0 {
+			return tok, pattern[1:], nil
+		}
+
+		lo, rest, err := classChar(pattern)
+		if err != nil {
+			return tok, "", err
+		}
+		pattern = rest
+
+		hi := lo
+		if len(pattern) > 1 && pattern[0] == '-' && pattern[1] != ']' {
+			hi, pattern, err = classChar(pattern[1:])
+			if err != nil {
+				return tok, "", err
+			}
+
+			if hi < lo {
+				return tok, "", ErrBadPattern
+			}
+		}
+
+		tok.ranges = append(tok.ranges, [2]rune{lo, hi})
+	}
+}
+
+func classChar(pattern string) (rune, string, error) {
+	if pattern[0] == '\\' {
+		pattern = pattern[1:]
+		if len(pattern) == 0 {
+			return 0, "", ErrBadPattern
+		}
+	}
+
+	r, n := utf8.DecodeRuneInString(pattern)
+	return r, pattern[n:], nil
+}
+
+// match will report whether the name matches the whole pattern,
+// backtracking to the last '*' when the rest of the pattern fails.
+func (g glob) match(name string) bool {
+	var p, star int
+	var backtrack string
+	starred := false
+	for {
+		if p < len(g) && g[p].kind == '*' {
+			p++
+			if p == len(g) {
+				return true
+			}
+			star, backtrack, starred = p, name, true
+			continue
+		}
+
+		if len(name) == 0 {
+			if p == len(g) {
+				return true
+			}
+		} else if p < len(g) {
+			r, n := utf8.DecodeRuneInString(name)
+			if g[p].matches(r) {
+				p++
+				name = name[n:]
+				continue
+			}
+		}
+
+		if !starred || len(backtrack) == 0 {
+			return false
+		}
+
+		_, n := utf8.DecodeRuneInString(backtrack)
+		backtrack = backtrack[n:]
+		p, name = star, backtrack
+	}
+}
+
+func (tok globToken) matches(r rune) bool {
+	switch tok.kind {
+	case '?':
+		return true
+	case '[':
+		for _, rg := range tok.ranges {
+			if rg[0] <= r && r <= rg[1] {
+				return !tok.negate
+			}
+		}
+		return tok.negate
+	}
+
+	return tok.r == r
+}
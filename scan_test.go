@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestScanPrefix(t *testing.T) {
+	cache := NewCache(nil)
+
+	for i := 0; i < 1000; i++ {
+		cache.Add("user:"+strconv.Itoa(i), i, 10*time.Minute)
+		cache.Add("order:"+strconv.Itoa(i), i, 10*time.Minute)
+	}
+	cache.Bucket("user:bucket")
+
+	// scan a page at a time, resuming from the cursor
+	seen := make(map[string]int)
+	var cursor uint64
+	for pages := 0; ; pages++ {
+		s := cache.ScanPrefix("user:", cursor)
+		for n := 0; n < 100 && s.Next(); n++ {
+			seen[s.Key()]++
+
+			if s.Item() != mustAtoi(s.Key()[len("user:"):]) {
+				t.Errorf("unexpected item %+v for %s", s.Item(), s.Key())
+			}
+		}
+
+		cursor = s.Cursor()
+		if cursor == 0 {
+			break
+		}
+
+		if pages > 100 {
+			t.Fatalf("scan did not complete")
+		}
+	}
+
+	if len(seen) != 1000 {
+		t.Errorf("expected 1000 keys, got %d", len(seen))
+	}
+
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("expected %s to be returned once, got %d", key, n)
+		}
+	}
+}
+
+func TestScanMatch(t *testing.T) {
+	cache := NewCache(nil)
+
+	for _, key := range []string{"a/b", "a/bc", "abc", "a-c", "a]c", "xyz", "a*"} {
+		cache.Add(key, key, 10*time.Minute)
+	}
+	cache.Add("a/expired", 0, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	tests := []struct {
+		pattern string
+		keys    int
+	}{
+		{"a*", 6},
+		{"a/*", 2},
+		{"a?c", 3},
+		{"a[/b]*", 3},
+		{"a[^/]c", 3},
+		{"a[a-c]c", 1},
+		{"a\\*", 1},
+		{"*z", 1},
+		{"*", 7},
+		{"a[]]c", 1},
+	}
+
+	for _, test := range tests {
+		s, err := cache.ScanMatch(test.pattern, 0)
+		if err != nil {
+			t.Errorf("ScanMatch(%q) error: %+v", test.pattern, err)
+			continue
+		}
+
+		var keys int
+		for s.Next() {
+			keys++
+		}
+
+		if keys != test.keys {
+			t.Errorf("expected %q to match %d keys, got %d", test.pattern, test.keys, keys)
+		}
+	}
+
+	for _, pattern := range []string{"[abc", "a\\", "[z-a]", "[]"} {
+		if _, err := cache.ScanMatch(pattern, 0); err != ErrBadPattern {
+			t.Errorf("expected ErrBadPattern for %q, got %+v", pattern, err)
+		}
+	}
+}
+
+func mustAtoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,254 @@
+// Package sessions provides web session storage for net/http backed by
+// a cache bucket. Sessions expire after being idle for the configured
+// TTL, which every Get slides forward, and are identified by a random
+// id carried in a cookie.
+//
+// Sessions are held in memory as pointers, so changes to their values
+// are visible to every request without being saved back.
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrNoSession is returned when a session does not exist or has expired
+	ErrNoSession = errors.New("sessions: no such session")
+
+	defaultBucket     = "sessions"
+	defaultTTL        = 30 * time.Minute
+	defaultCookieName = "session"
+)
+
+const idBytes = 32
+
+// Config is used to configure a Manager
+type Config struct {
+	Bucket     string        // bucket the sessions are stored in, defaults to "sessions"
+	TTL        time.Duration // idle time after which a session expires, defaults to 30 minutes
+	CookieName string        // name of the session cookie, defaults to "session"
+	Path       string        // path of the session cookie, defaults to "/"
+	Domain     string        // domain of the session cookie
+	Secure     bool          // only sends the session cookie over HTTPS
+	SameSite   http.SameSite // SameSite attribute of the session cookie, defaults to Lax
+}
+
+// Manager creates, finds and destroys sessions
+type Manager struct {
+	bucket *cache.Bucket
+	config *Config
+}
+
+// Session is a set of values kept for a client between requests.
+// It is safe for concurrent use.
+type Session struct {
+	ID     string
+	values map[string]interface{}
+	mu     *sync.RWMutex
+}
+
+// NewManager will create and return a pointer to a
+// new Manager storing its sessions in the cache
+func NewManager(c *cache.Cache, config *Config) *Manager {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Bucket == "" {
+		config.Bucket = defaultBucket
+	}
+
+	if config.TTL == 0 {
+		config.TTL = defaultTTL
+	}
+
+	if config.CookieName == "" {
+		config.CookieName = defaultCookieName
+	}
+
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+
+	return &Manager{
+		bucket: c.Bucket(config.Bucket),
+		config: config,
+	}
+}
+
+// Create will start a new session with a random id
+func (m *Manager) Create() (*Session, error) {
+	id := make([]byte, idBytes)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		ID:     base64.RawURLEncoding.EncodeToString(id),
+		values: make(map[string]interface{}),
+		mu:     &sync.RWMutex{},
+	}
+
+	err = m.bucket.Add(s.ID, s, m.config.TTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get will return the session with the id and
+// reset its expiration to the TTL from now
+func (m *Manager) Get(id string) (*Session, error) {
+	item, err := m.bucket.Get(id)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+
+	s, ok := item.(*Session)
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	err = m.Refresh(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Refresh will reset the expiration of the session to the TTL from now
+func (m *Manager) Refresh(id string) error {
+	err := m.bucket.Touch(id, m.config.TTL)
+	if err == cache.ErrDNE {
+		return ErrNoSession
+	}
+
+	return err
+}
+
+// Destroy will delete the session
+func (m *Manager) Destroy(id string) error {
+	err := m.bucket.Delete(id)
+	if err == cache.ErrDNE {
+		return ErrNoSession
+	}
+
+	return err
+}
+
+// FromRequest will return the session named by the request's cookie
+func (m *Manager) FromRequest(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.config.CookieName)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+
+	return m.Get(cookie.Value)
+}
+
+// Start will return the session named by the request's cookie,
+// creating a new session and setting its cookie if there is none
+func (m *Manager) Start(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	s, err := m.FromRequest(r)
+	if err == nil {
+		return s, nil
+	}
+
+	s, err = m.Create()
+	if err != nil {
+		return nil, err
+	}
+	m.SetCookie(w, s)
+
+	return s, nil
+}
+
+// End will destroy the session named by the request's
+// cookie, if there is one, and clear the cookie
+func (m *Manager) End(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(m.config.CookieName); err == nil {
+		m.Destroy(cookie.Value)
+	}
+
+	m.ClearCookie(w)
+}
+
+// SetCookie will set the cookie carrying the session's id
+func (m *Manager) SetCookie(w http.ResponseWriter, s *Session) {
+	cookie := m.cookie(s.ID)
+	cookie.MaxAge = int(m.config.TTL / time.Second)
+	http.SetCookie(w, cookie)
+}
+
+// ClearCookie will tell the client to delete the session cookie
+func (m *Manager) ClearCookie(w http.ResponseWriter) {
+	cookie := m.cookie("")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+func (m *Manager) cookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     m.config.Path,
+		Domain:   m.config.Domain,
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+		SameSite: m.config.SameSite,
+	}
+}
+
+// Get will return the value stored in the session at the key
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Set will store the value in the session at the key
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = value
+}
+
+// Delete will remove the value stored in the session at the key
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+}
+
+// Keys will return the keys of the values stored in the session
+func (s *Session) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,112 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(cache.NewCache(nil), nil)
+
+	s, err := m.Create()
+	if err != nil {
+		t.Fatalf("Create error: %+v", err)
+	}
+	s.Set("user", "alice")
+
+	other, _ := m.Create()
+	if other.ID == s.ID || len(s.ID) < 40 {
+		t.Errorf("expected distinct random ids, got %q and %q", s.ID, other.ID)
+	}
+
+	got, err := m.Get(s.ID)
+	if err != nil || got != s {
+		t.Errorf("unexpected session %+v: %+v", got, err)
+	}
+
+	if user, ok := got.Get("user"); !ok || user != "alice" {
+		t.Errorf("unexpected value %v", user)
+	}
+
+	got.Set("cart", 3)
+	got.Delete("user")
+	if keys := s.Keys(); len(keys) != 1 || keys[0] != "cart" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if err := m.Destroy(s.ID); err != nil {
+		t.Errorf("Destroy error: %+v", err)
+	}
+
+	if _, err := m.Get(s.ID); err != ErrNoSession {
+		t.Errorf("expected ErrNoSession, got %+v", err)
+	}
+
+	if err := m.Destroy(s.ID); err != ErrNoSession {
+		t.Errorf("expected ErrNoSession, got %+v", err)
+	}
+
+	if err := m.Refresh("missing"); err != ErrNoSession {
+		t.Errorf("expected ErrNoSession, got %+v", err)
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	m := NewManager(cache.NewCache(nil), &Config{TTL: 50 * time.Millisecond})
+
+	s, _ := m.Create()
+	for i := 0; i < 4; i++ {
+		time.Sleep(25 * time.Millisecond)
+		if _, err := m.Get(s.ID); err != nil {
+			t.Fatalf("expected the session to be kept alive: %+v", err)
+		}
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if _, err := m.Get(s.ID); err != ErrNoSession {
+		t.Errorf("expected the idle session to expire, got %+v", err)
+	}
+}
+
+func TestCookies(t *testing.T) {
+	m := NewManager(cache.NewCache(nil), &Config{CookieName: "sid", Secure: true})
+
+	rec := httptest.NewRecorder()
+	s, err := m.Start(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("Start error: %+v", err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" || cookies[0].Value != s.ID || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("unexpected cookies %+v", cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+
+	rec = httptest.NewRecorder()
+	again, err := m.Start(rec, req)
+	if err != nil || again != s {
+		t.Errorf("expected the session from the cookie, got %+v: %+v", again, err)
+	}
+
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("expected no cookie for an existing session")
+	}
+
+	rec = httptest.NewRecorder()
+	m.End(rec, req)
+	cookies = rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge != -1 {
+		t.Errorf("expected the cookie to be cleared, got %+v", cookies)
+	}
+
+	if _, err := m.FromRequest(req); err != ErrNoSession {
+		t.Errorf("expected the session to be destroyed, got %+v", err)
+	}
+}
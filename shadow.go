@@ -0,0 +1,58 @@
+package cache
+
+// newShadow will create the shadow cache for a configuration.
+// The shadow never invokes callbacks, writes to a store or publishes
+// invalidations since it does not serve items.
+func newShadow(config *CacheConfig) *Cache {
+	if config == nil {
+		return nil
+	}
+
+	shadowConfig := *config
+	shadowConfig.OnExpires = nil
+	shadowConfig.OnExpiresBatch = nil
+	shadowConfig.OnExpire = nil
+	shadowConfig.OnEvict = nil
+	shadowConfig.OnHashFlood = nil
+	shadowConfig.OnError = nil
+	shadowConfig.Observer = nil
+	shadowConfig.AutoReseed = false
+	shadowConfig.Shadow = nil
+	shadowConfig.Store = nil
+	shadowConfig.Invalidator = nil
+	shadowConfig.SortedKeys = false
+	shadowConfig.CopyOnWrite = false
+	shadowConfig.BloomCapacity = 0
+	shadowConfig.SpillDir = ""
+	shadowConfig.SaveOnShutdown = ""
+	shadowConfig.AccessStatsFile = ""
+	shadowConfig.HeapLimit = 0
+	shadowConfig.HotKeySampleRate = 0
+	shadowConfig.Trace = nil
+
+	return NewCache(&shadowConfig)
+}
+
+// ShadowStats will return the statistics of the shadow cache, which
+// can be compared with Stats to evaluate the shadow's configuration.
+// It returns false if the cache has no shadow.
+func (t *Cache) ShadowStats() (Stats, bool) {
+	if t.shadow == nil {
+		return Stats{}, false
+	}
+
+	return t.shadow.Stats(), true
+}
+
+// mirror will call fn with the locked shadow cache, if there is one.
+// The cache lock must be held by the caller.
+func (t *Cache) mirror(fn func(shadow *Cache)) {
+	if t.shadow == nil {
+		return
+	}
+
+	t.shadow.mu.Lock()
+	defer t.shadow.mu.Unlock()
+
+	fn(t.shadow)
+}
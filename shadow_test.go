@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheShadow(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries: 2,
+		Shadow: &CacheConfig{
+			MaxEntries: 1,
+		},
+	})
+
+	_, ok := NewCache(nil).ShadowStats()
+	if ok {
+		t.Error("cache without a shadow returned shadow stats")
+	}
+
+	for _, key := range []string{"a", "b"} {
+		err := cache.Add(key, key, 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	}
+
+	for _, key := range []string{"a", "b"} {
+		value, err := cache.Get(key)
+		if err != nil {
+			t.Errorf("error while getting key: %+v", err)
+		}
+
+		if value.(string) != key {
+			t.Errorf("returned value was %s", value)
+		}
+	}
+
+	stats := cache.Stats()
+	shadow, ok := cache.ShadowStats()
+	if !ok {
+		t.Fatal("shadow stats were not returned")
+	}
+
+	if stats.HitRate() != 1 {
+		t.Errorf("hit rate was %f", stats.HitRate())
+	}
+
+	if shadow.HitRate() != 0.5 || shadow.Evictions != 1 {
+		t.Errorf("shadow did not mirror operations: %+v", shadow)
+	}
+}
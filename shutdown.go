@@ -0,0 +1,50 @@
+package cache
+
+import "context"
+
+// Shutdown will gracefully stop the cache. It expires the items that
+// are due and runs their expiration callbacks, saves the cache to
+// SaveOnShutdown if it is set, writes the AccessStats to AccessStatsFile
+// if it is set, writes back the pending changes to the Store, and then
+// closes the cache, waiting for the callbacks still queued or running to
+// return. It returns ctx.Err() if ctx is done before then, leaving the
+// remaining work to finish in the background, and otherwise the first
+// error saving the cache or its access statistics or writing it back.
+func (t *Cache) Shutdown(ctx context.Context) error {
+	expired, removed := t.clean()
+	t.expire(expired, false)
+	t.notify(removed, ReasonExpired, false)
+
+	var first error
+	if t.config.SaveOnShutdown != "" {
+		first = t.Save(t.config.SaveOnShutdown)
+	}
+
+	if t.config.AccessStatsFile != "" {
+		err := t.SaveAccessStats(t.config.AccessStatsFile)
+		if first == nil {
+			first = err
+		}
+	}
+
+	err := t.FlushWrites(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	} else if first == nil {
+		first = err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t.Close()
+		t.expirer.wait()
+	}()
+
+	select {
+	case <-done:
+		return first
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
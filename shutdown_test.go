@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	file := filepath.Join(t.TempDir(), "cache.gob")
+	store := newMapStore()
+
+	var expired int64
+	cache := NewCache(&CacheConfig{
+		Clock:          clock,
+		Store:          store,
+		WriteBack:      true,
+		WriteInterval:  time.Hour,
+		SaveOnShutdown: file,
+		OnExpires: func(item interface{}) {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt64(&expired, 1)
+		},
+	})
+
+	cache.Set("short", 1, time.Minute)
+	cache.Set("long", 2, time.Hour)
+	clock.Advance(2 * time.Minute)
+
+	if err := cache.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error: %+v", err)
+	}
+
+	if atomic.LoadInt64(&expired) != 1 {
+		t.Errorf("expected the expiration callbacks to have run, got %d", atomic.LoadInt64(&expired))
+	}
+
+	if _, ok := store.get("long"); !ok {
+		t.Errorf("expected the pending writes to be written back")
+	}
+
+	restored := NewCache(&CacheConfig{Clock: clock})
+	defer restored.Close()
+	if err := restored.Load(file); err != nil {
+		t.Fatalf("Load error: %+v", err)
+	}
+
+	if keys := restored.Keys(); len(keys) != 1 || keys[0] != "long" {
+		t.Errorf("expected the unexpired items to be saved, got %+v", keys)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	release := make(chan struct{})
+	cache := NewCache(&CacheConfig{
+		Clock: clock,
+		OnExpires: func(item interface{}) {
+			<-release
+		},
+	})
+	defer close(release)
+
+	cache.Add("key", 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := cache.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected Shutdown to stop waiting at the deadline, got %+v", err)
+	}
+}
+
+func TestShutdownAccessStats(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "access.bin")
+	cache := NewCache(&CacheConfig{
+		AccessSampleRate: 1,
+		AccessStatsFile:  file,
+	})
+
+	cache.Add("key", 1, time.Minute)
+	cache.Get("key")
+	cache.Get("key")
+
+	if err := cache.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error: %+v", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("expected the access statistics to be written: %+v", err)
+	}
+	defer f.Close()
+
+	stats, err := ReadAccessStats(f)
+	if err != nil {
+		t.Fatalf("ReadAccessStats error: %+v", err)
+	}
+
+	if len(stats) != 1 || stats[0].Hits != 2 {
+		t.Errorf("expected the hits of the key to be written, got %+v", stats)
+	}
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"encoding/gob"
+	"reflect"
+	"time"
+)
+
+// Sizer is a function that will return the size in bytes of an item
+type Sizer func(item interface{}) int64
+
+// AddSized will add a key, value, and expiration duration to the cache
+// like Add, and return the size in bytes of the item encoded with gob,
+// as it is written by Save, the spill log and a remote tier, so callers
+// can attribute the storage their own code paths use. An item that gob
+// cannot encode returns the encoding error and is not added. The running
+// total of the sizes measured by the Sizer is reported by Stats().Bytes.
+func (t *Cache) AddSized(key string, item interface{}, expiresIn time.Duration, opts ...AddOption) (int64, error) {
+	size, err := serializedSize(item)
+	if err != nil {
+		return 0, err
+	}
+
+	err = t.Add(key, item, expiresIn, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// serializedSize will return the size of the item encoded with gob
+func serializedSize(item interface{}) (int64, error) {
+	var n byteCounter
+	err := gob.NewEncoder(&n).Encode(&item)
+	return int64(n), err
+}
+
+// byteCounter counts the bytes written to it
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// measure will return the size of the item as measured by the Sizer,
+// or 0 if there is none because the cache has no MaxBytes to keep to
+func (t *Cache) measure(item interface{}) int64 {
+	if t.config.Sizer == nil {
+		return 0
+	}
+
+	return t.config.Sizer(item)
+}
+
+// defaultSizer measures strings and byte slices by their length and
+// any other item by walking the values it references, counting each
+// pointer, slice and map once. A bucket is measured as a pointer alone,
+// its items being sized as they are added. Caches holding large or
+// deeply linked items should set a Sizer that measures them cheaply.
+func defaultSizer(item interface{}) int64 {
+	switch v := item.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case *Bucket:
+		return int64(reflect.TypeOf(v).Size())
+	}
+
+	v := reflect.ValueOf(item)
+	return int64(v.Type().Size()) + referencedSize(v, make(map[uintptr]bool))
+}
+
+// referencedSize will return the size of the memory the value references
+// beyond its own, skipping pointers, slices and maps already seen
+func referencedSize(v reflect.Value, seen map[uintptr]bool) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+
+		return int64(v.Type().Elem().Size()) + referencedSize(v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+
+		return int64(v.Elem().Type().Size()) + referencedSize(v.Elem(), seen)
+	case reflect.Slice:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), seen)
+		}
+		return size
+	case reflect.Array:
+		var size int64
+		for i := 0; i < v.Len(); i++ {
+			size += referencedSize(v.Index(i), seen)
+		}
+		return size
+	case reflect.Map:
+		if v.IsNil() || seen[v.Pointer()] {
+			return 0
+		}
+		seen[v.Pointer()] = true
+
+		var size int64
+		iter := v.MapRange()
+		for iter.Next() {
+			key, value := iter.Key(), iter.Value()
+			size += int64(key.Type().Size()) + referencedSize(key, seen)
+			size += int64(value.Type().Size()) + referencedSize(value, seen)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += referencedSize(v.Field(i), seen)
+		}
+		return size
+	}
+
+	return 0
+}
+
+// defaultMaxBytes will return the given fraction of the container
+// memory limit, or 0 (unbounded) if there is no memory limit.
+func defaultMaxBytes(fraction float64) int64 {
+	limit, ok := memoryLimit()
+	if !ok {
+		return 0
+	}
+
+	return int64(float64(limit) * fraction)
+}
+
+// replace will replace the item in the slot at idx
+// and account for the change in its size.
+func (t *Cache) replace(idx int, item interface{}) {
+	size := t.measure(item)
+	t.bytes += size - t.slots[idx].size
+	t.slots[idx].Item = item
+	t.slots[idx].size = size
+	t.slots[idx].modified = t.now()
+
+	t.version++
+	t.slots[idx].version = t.version
+	t.gen++
+}
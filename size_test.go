@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheMaxBytes(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxBytes: 10,
+	})
+
+	err := cache.Add("soon", "12345", 1*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Add("later", "12345", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Add("new", "12345", 5*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	_, err = cache.Get("soon")
+	if err != ErrDNE {
+		t.Errorf("item closest to expiring was not evicted: %+v", err)
+	}
+
+	for _, key := range []string{"later", "new"} {
+		_, err = cache.Get(key)
+		if err != nil {
+			t.Errorf("error while getting key %s: %+v", key, err)
+		}
+	}
+
+	if cache.bytes != 10 {
+		t.Errorf("cache size was %d bytes", cache.bytes)
+	}
+
+	err = cache.Add("huge", "12345678901", 1*time.Minute)
+	if err != ErrTooLarge {
+		t.Errorf("should have returned ErrTooLarge but returned %+v", err)
+	}
+}
+
+func TestDefaultMaxBytes(t *testing.T) {
+	limit, ok := memoryLimit()
+	max := defaultMaxBytes(0.5)
+	if !ok && max != 0 {
+		t.Errorf("max bytes was %d without a memory limit", max)
+	}
+
+	if ok && max != limit/2 {
+		t.Errorf("max bytes was %d for memory limit %d", max, limit)
+	}
+
+	cache := NewCache(&CacheConfig{})
+	defer cache.Close()
+
+	if cache.config.MaxBytes != 0 {
+		t.Errorf("max bytes was %d without a MemoryFraction", cache.config.MaxBytes)
+	}
+}
+
+func TestCacheAddSized(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxBytes: 1 << 20,
+	})
+
+	want, _ := serializedSize("12345")
+	size, err := cache.AddSized("a", "12345", 1*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	if size != want || size <= 5 {
+		t.Errorf("size was %d bytes, expected the %d bytes of its encoding", size, want)
+	}
+
+	want, _ = serializedSize([]byte("123"))
+	size, err = cache.Bucket("bucket").AddSized("b", []byte("123"), 1*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	if size != want {
+		t.Errorf("size was %d bytes, expected the %d bytes of its encoding", size, want)
+	}
+
+	_, err = cache.AddSized("a", "12345", 1*time.Minute)
+	if err != ErrCollision {
+		t.Errorf("should have returned ErrCollision but returned %+v", err)
+	}
+
+	// gob cannot encode a channel
+	if _, err := cache.AddSized("c", make(chan int), time.Minute); err == nil || cache.Contains("c") {
+		t.Errorf("expected an item that cannot be encoded to be refused, got %+v", err)
+	}
+
+	bucketSize := defaultSizer(cache.Bucket("bucket"))
+	if bytes := cache.Stats().Bytes; bytes != 8+bucketSize {
+		t.Errorf("running total was %d bytes", bytes)
+	}
+}
+
+func TestCacheUnmeasured(t *testing.T) {
+	cache := NewCache(nil)
+	cache.Add("a", "12345", time.Minute)
+	cache.Set("a", []byte("123456"), time.Minute)
+
+	if bytes := cache.Stats().Bytes; bytes != 0 {
+		t.Errorf("expected items not to be measured without MaxBytes, got %d bytes", bytes)
+	}
+
+	sized := NewCache(&CacheConfig{Sizer: func(item interface{}) int64 { return 7 }})
+	sized.Add("a", "12345", time.Minute)
+	if bytes := sized.Stats().Bytes; bytes != 7 {
+		t.Errorf("expected a Sizer to be used without MaxBytes, got %d bytes", bytes)
+	}
+}
+
+func TestDefaultSizer(t *testing.T) {
+	type node struct {
+		name string
+		next *node
+		tags map[string][]byte
+	}
+
+	n := &node{name: "12345", tags: map[string][]byte{"a": []byte("123")}}
+	n.next = n
+	size := defaultSizer(n)
+	if size < int64(reflect.TypeOf(n).Size()+reflect.TypeOf(*n).Size())+5+1+3 {
+		t.Errorf("size of a linked item was %d bytes", size)
+	}
+
+	if size != defaultSizer(n) {
+		t.Errorf("size of a cyclic item changed between measures")
+	}
+
+	strings := []string{"12345", "123"}
+	want := int64(reflect.TypeOf(strings).Size()+2*reflect.TypeOf("").Size()) + 8
+	if size := defaultSizer(strings); size != want {
+		t.Errorf("size of a slice of strings was %d bytes", size)
+	}
+}
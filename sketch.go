@@ -0,0 +1,86 @@
+package cache
+
+// sketchDepth is the number of rows, and so hash functions, in a sketch
+const sketchDepth = 4
+
+var (
+	defaultSketchWidth = 4096
+
+	// odd multipliers used to derive a row index from a hashed key
+	sketchSeeds = [sketchDepth]uint64{
+		0x9e3779b97f4a7c15,
+		0xc2b2ae3d27d4eb4f,
+		0x165667b19e3779f9,
+		0xd6e8feb86659fd93,
+	}
+)
+
+// sketch is a count-min sketch of saturating 8-bit counters
+// that estimates how often keys have been seen. Counters are
+// halved periodically so that old popularity fades.
+type sketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// newSketch will create a sketch with at least width counters per row
+func newSketch(width int) *sketch {
+	size := 1
+	for size < width {
+		size <<= 1
+	}
+
+	s := &sketch{
+		mask:    uint64(size - 1),
+		resetAt: 10 * size,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, size)
+	}
+
+	return s
+}
+
+func (s *sketch) index(key uint64, row int) uint64 {
+	h := key * sketchSeeds[row]
+	return (h ^ h>>32) & s.mask
+}
+
+// increment will count an occurrence of the key
+func (s *sketch) increment(key uint64) {
+	for i := range s.rows {
+		idx := s.index(key, i)
+		if s.rows[i][idx] < 255 {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+// estimate will return the estimated number of occurrences of the key
+func (s *sketch) estimate(key uint64) uint8 {
+	min := uint8(255)
+	for i := range s.rows {
+		if count := s.rows[i][s.index(key, i)]; count < min {
+			min = count
+		}
+	}
+
+	return min
+}
+
+// age will halve every counter
+func (s *sketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
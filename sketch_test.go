@@ -0,0 +1,23 @@
+package cache
+
+import "testing"
+
+func TestSketch(t *testing.T) {
+	s := newSketch(16)
+	for i := 0; i < 5; i++ {
+		s.increment(42)
+	}
+
+	if count := s.estimate(42); count < 5 {
+		t.Errorf("estimate was %d", count)
+	}
+
+	if count := s.estimate(7); count > 5 {
+		t.Errorf("estimate of unseen key was %d", count)
+	}
+
+	s.age()
+	if count := s.estimate(42); count < 2 || count > 3 {
+		t.Errorf("estimate after aging was %d", count)
+	}
+}
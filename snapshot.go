@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// snapshots holds the entries of the last snapshot of a cache with
+// CopyOnWrite, which are shared until the cache is written
+type snapshots struct {
+	entries []Entry
+	gen     uint64    // generation of the cache the entries were copied at
+	until   time.Time // earliest expiration of the entries
+	mu      *sync.Mutex
+}
+
+// Snapshot will copy the keys and items in the cache, excluding buckets,
+// soft deleted and expired items, and return them in order of their
+// keys. Items in buckets are returned with their keys in the cache. The
+// copy is taken under the read lock, so it is consistent and does not
+// change as the cache is written, and iterating it never blocks writers.
+// The items themselves are not copied.
+//
+// With CopyOnWrite, successive calls share one copy until an item is
+// written, removed or expires, so the returned slice must not be
+// modified.
+func (t *Cache) Snapshot() []Entry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+	if t.snapshots == nil {
+		entries, _ := t.snapshot(now)
+		return entries
+	}
+
+	s := t.snapshots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries == nil || s.gen != t.gen || now.After(s.until) {
+		s.entries, s.until = t.snapshot(now)
+		s.gen = t.gen
+	}
+
+	return s.entries
+}
+
+// snapshot will copy the live items and return them with the earliest
+// of their expirations. The lock must be held.
+func (t *Cache) snapshot(now time.Time) ([]Entry, time.Time) {
+	entries := make([]Entry, 0, len(t.keys))
+	until := neverExpires
+	for _, slot := range t.slots {
+		if slot.empty || slot.deleted || slot.ExpiresAt.Before(now) {
+			continue
+		}
+
+		if _, ok := slot.Item.(*Bucket); ok {
+			continue
+		}
+
+		entries = append(entries, Entry{
+			Key:       slot.name,
+			Item:      slot.Item,
+			ExpiresAt: slot.ExpiresAt,
+		})
+		if slot.ExpiresAt.Before(until) {
+			until = slot.ExpiresAt
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return entries, until
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock})
+	defer cache.Close()
+
+	cache.Add("b", 2, time.Hour)
+	cache.Add("a", 1, time.Minute)
+	cache.Add("deleted", 3, time.Hour)
+	cache.SoftDelete("deleted")
+	cache.Bucket("bucket").Add("c", 4, time.Hour)
+
+	entries := cache.Snapshot()
+	if len(entries) != 3 || entries[0].Key != "a" || entries[1].Key != "b" || entries[2].Key != "bucket:c" {
+		t.Fatalf("expected the live items in order of their keys, got %+v", entries)
+	}
+
+	cache.Delete("b")
+	cache.Set("a", 10, time.Minute)
+	if entries[0].Item != 1 || entries[1].Item != 2 {
+		t.Errorf("expected the snapshot not to change with the cache, got %+v", entries)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if entries := cache.Snapshot(); len(entries) != 1 || entries[0].Key != "bucket:c" {
+		t.Errorf("expected expired items to be excluded, got %+v", entries)
+	}
+}
+
+func TestSnapshotCopyOnWrite(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{Clock: clock, CopyOnWrite: true})
+	defer cache.Close()
+
+	cache.Add("a", 1, time.Minute)
+	cache.Add("b", 2, time.Hour)
+
+	first := cache.Snapshot()
+	if second := cache.Snapshot(); &second[0] != &first[0] {
+		t.Errorf("expected snapshots to be shared until the cache is written")
+	}
+
+	cache.Get("a")
+	if second := cache.Snapshot(); &second[0] != &first[0] {
+		t.Errorf("expected reads not to copy the snapshot")
+	}
+
+	cache.Set("a", 10, time.Minute)
+	second := cache.Snapshot()
+	if &second[0] == &first[0] || second[0].Item != 10 || first[0].Item != 1 {
+		t.Errorf("expected a write to copy the snapshot, got %+v and %+v", first, second)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if third := cache.Snapshot(); len(third) != 1 || third[0].Key != "b" {
+		t.Errorf("expected an expiration to copy the snapshot, got %+v", third)
+	}
+
+	cache.Delete("b")
+	if third := cache.Snapshot(); len(third) != 0 {
+		t.Errorf("expected a delete to copy the snapshot, got %+v", third)
+	}
+}
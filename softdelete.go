@@ -0,0 +1,83 @@
+package cache
+
+// SoftDelete will hide the item at the key from the cache without
+// removing it, until it expires or is restored with Restore. Adding or
+// setting the key replaces the hidden item, and Delete removes it.
+// When a soft deleted item expires its Expired has Deleted set.
+func (t *Cache) SoftDelete(key string) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.softDelete(hashedKey, true)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
+
+// Restore will undo SoftDelete, making the item visible again.
+// It returns ErrDNE if the key is not soft deleted or has expired.
+func (t *Cache) Restore(key string) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.softDelete(hashedKey, false)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
+
+// live will return the index of the key's slot, unless the key
+// is missing, soft deleted or has expired. An item that can still
+// be served stale is live until its grace period has passed.
+func (t *Cache) live(key uint64) (int, bool) {
+	idx, ok := t.present(key)
+	if !ok || t.expired(t.slots[idx], t.now()) {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+// present will return the index of the key's slot, unless the key
+// is missing or soft deleted, whether or not the item has expired
+func (t *Cache) present(key uint64) (int, bool) {
+	idx, ok := t.keys[key]
+	if !ok || t.slots[idx].empty || t.slots[idx].deleted {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+// softDelete will hide or restore an unexpired item
+func (t *Cache) softDelete(key uint64, deleted bool) error {
+	idx, ok := t.keys[key]
+	if !ok || t.slots[idx].empty || t.slots[idx].deleted == deleted {
+		return ErrDNE
+	}
+
+	if t.now().After(t.slots[idx].ExpiresAt) {
+		return ErrDNE
+	}
+	t.slots[idx].deleted = deleted
+	t.slots[idx].modified = t.now()
+	t.gen++
+
+	t.mirror(func(shadow *Cache) {
+		shadow.softDelete(key, deleted)
+	})
+
+	return nil
+}
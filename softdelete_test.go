@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	c := NewCache(&CacheConfig{})
+	defer c.Close()
+
+	c.Add("key", "value", time.Minute)
+
+	err := c.SoftDelete("key")
+	if err != nil {
+		t.Errorf("SoftDelete error: %+v", err)
+	}
+
+	_, err = c.Get("key")
+	if err != ErrDNE {
+		t.Errorf("expected a soft deleted item to be hidden, got %+v", err)
+	}
+
+	_, err = c.TTL("key")
+	if err != ErrDNE {
+		t.Errorf("expected no ttl for a soft deleted item, got %+v", err)
+	}
+
+	err = c.Update("key", "other")
+	if err != ErrDNE {
+		t.Errorf("expected a soft deleted item not to be updated, got %+v", err)
+	}
+
+	if keys := c.Keys(); len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+
+	err = c.SoftDelete("key")
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE when soft deleting twice, got %+v", err)
+	}
+
+	err = c.Restore("key")
+	if err != nil {
+		t.Errorf("Restore error: %+v", err)
+	}
+
+	item, err := c.Get("key")
+	if err != nil || item != "value" {
+		t.Errorf("expected the item to be restored, got %v, %+v", item, err)
+	}
+
+	err = c.Restore("key")
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE when restoring a live item, got %+v", err)
+	}
+
+	c.SoftDelete("key")
+	err = c.Add("key", "new", time.Minute)
+	if err != nil {
+		t.Errorf("expected Add to replace a soft deleted item, got %+v", err)
+	}
+
+	err = c.Restore("key")
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE when restoring a replaced item, got %+v", err)
+	}
+
+	item, _ = c.Get("key")
+	if item != "new" {
+		t.Errorf("expected the new item, got %v", item)
+	}
+}
+
+func TestSoftDeleteExpires(t *testing.T) {
+	expired := make(chan []Expired, 1)
+	c := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+		OnExpiresBatch: func(items []Expired) {
+			expired <- items
+		},
+	})
+	defer c.Close()
+
+	c.Add("key", "value", 20*time.Millisecond)
+	c.SoftDelete("key")
+
+	select {
+	case items := <-expired:
+		if len(items) != 1 || items[0].Key != "key" || !items[0].Deleted {
+			t.Errorf("expected the soft deleted item to expire as deleted, got %+v", items)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the soft deleted item to expire")
+	}
+
+	err := c.Restore("key")
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE when restoring an expired item, got %+v", err)
+	}
+}
+
+func TestSoftDeleteStore(t *testing.T) {
+	store := newMapStore()
+	c := NewCache(&CacheConfig{Store: store})
+	defer c.Close()
+
+	c.Add("key", "value", time.Minute)
+	c.SoftDelete("key")
+
+	if _, ok := store.get("key"); ok {
+		t.Errorf("expected a soft delete to delete the key from the store")
+	}
+
+	c.Restore("key")
+
+	if item, ok := store.get("key"); !ok || item != "value" {
+		t.Errorf("expected a restore to write the key to the store, got %v", item)
+	}
+}
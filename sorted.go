@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const skipListLevels = 24
+
+// skipList keeps the keys of a cache in order, so that
+// RangeKeys does not have to sort every key on each call
+type skipList struct {
+	head  *skipNode
+	level int
+	rnd   *rand.Rand
+}
+
+type skipNode struct {
+	key  string
+	next []*skipNode
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head:  &skipNode{next: make([]*skipNode, skipListLevels)},
+		level: 1,
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// path will return the last node before the key on each level
+func (l *skipList) path(key string) []*skipNode {
+	update := make([]*skipNode, skipListLevels)
+	n := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].key < key {
+			n = n.next[i]
+		}
+		update[i] = n
+	}
+
+	return update
+}
+
+func (l *skipList) insert(key string) {
+	update := l.path(key)
+	if n := update[0].next[0]; n != nil && n.key == key {
+		return
+	}
+
+	level := 1
+	for level < skipListLevels && l.rnd.Intn(4) == 0 {
+		level++
+	}
+
+	for ; l.level < level; l.level++ {
+		update[l.level] = l.head
+	}
+
+	n := &skipNode{key: key, next: make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+}
+
+func (l *skipList) remove(key string) {
+	update := l.path(key)
+	n := update[0].next[0]
+	if n == nil || n.key != key {
+		return
+	}
+
+	for i := range n.next {
+		update[i].next[i] = n.next[i]
+	}
+
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+}
+
+// seek will return the first node at or after the key
+func (l *skipList) seek(key string) *skipNode {
+	return l.path(key)[0].next[0]
+}
+
+// RangeKeys will return the keys from `from` up to but excluding `to`
+// in order, excluding buckets and expired items. An empty `to` leaves the
+// range unbounded. With SortedKeys enabled the keys are read from an
+// index kept in order, otherwise every key is sorted on each call.
+func (t *Cache) RangeKeys(from, to string) []string {
+	if t.sorted == nil {
+		keys := t.Keys()
+		lo := sort.SearchStrings(keys, from)
+		hi := len(keys)
+		if to != "" {
+			hi = sort.SearchStrings(keys, to)
+		}
+
+		if hi < lo {
+			return keys[:0]
+		}
+
+		return keys[lo:hi]
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	now := t.now()
+	var keys []string
+	for n := t.sorted.seek(from); n != nil && (to == "" || n.key < to); n = n.next[0] {
+		hashedKey := t.hash(n.key)
+		idx, ok := t.live(hashedKey)
+		if !ok || t.slots[idx].name != n.key || now.After(t.slots[idx].ExpiresAt) {
+			continue
+		}
+
+		if _, ok := t.slots[idx].Item.(*Bucket); ok {
+			continue
+		}
+
+		keys = append(keys, n.key)
+	}
+
+	return keys
+}
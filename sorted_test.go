@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRangeKeys(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		cache := NewCache(&CacheConfig{SortedKeys: sorted})
+
+		for i := 99; i >= 0; i-- {
+			cache.Add(fmt.Sprintf("event:%03d", i), i, 10*time.Minute)
+		}
+		cache.Add("other", 0, 10*time.Minute)
+		cache.Add("event:expired", 0, time.Millisecond)
+		cache.Bucket("event:bucket")
+		cache.Delete("event:050")
+		time.Sleep(5 * time.Millisecond)
+
+		keys := cache.RangeKeys("event:048", "event:053")
+		expected := []string{"event:048", "event:049", "event:051", "event:052"}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("sorted %v: expected %v, got %v", sorted, expected, keys)
+		}
+
+		keys = cache.RangeKeys("event:098", "")
+		expected = []string{"event:098", "event:099", "other"}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("sorted %v: expected %v, got %v", sorted, expected, keys)
+		}
+
+		if keys := cache.RangeKeys("z", "a"); len(keys) != 0 {
+			t.Errorf("sorted %v: expected an empty range, got %v", sorted, keys)
+		}
+
+		if keys := cache.RangeKeys("", ""); len(keys) != 100 {
+			t.Errorf("sorted %v: expected 100 keys, got %d", sorted, len(keys))
+		}
+
+		cache.Flush()
+		if keys := cache.RangeKeys("", ""); len(keys) != 0 {
+			t.Errorf("sorted %v: expected no keys after Flush, got %v", sorted, keys)
+		}
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	l := newSkipList()
+	for i := 0; i < 1000; i++ {
+		l.insert(fmt.Sprintf("%04d", (i*7919)%1000))
+	}
+	l.insert("0500")
+
+	for i := 0; i < 1000; i += 2 {
+		l.remove(fmt.Sprintf("%04d", i))
+	}
+	l.remove("missing")
+
+	var keys []string
+	for n := l.seek(""); n != nil; n = n.next[0] {
+		keys = append(keys, n.key)
+	}
+
+	if len(keys) != 500 {
+		t.Fatalf("expected 500 keys, got %d", len(keys))
+	}
+
+	for i, key := range keys {
+		if key != fmt.Sprintf("%04d", i*2+1) {
+			t.Errorf("expected %04d at %d, got %s", i*2+1, i, key)
+			break
+		}
+	}
+}
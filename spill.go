@@ -0,0 +1,362 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// spillCompactBytes is the amount of space held by replaced and removed
+// records beyond which the spill log is compacted, once they also make
+// up half of the log
+const spillCompactBytes = 1 << 20
+
+// spill is an append-only log on disk holding the items evicted from
+// memory, so that Get can fault them back in. Each record is its length
+// followed by the gob encoded item.
+type spill struct {
+	file   *os.File
+	size   int64 // end of the log
+	dead   int64 // bytes of records that were replaced or removed
+	index  map[string]spillRef
+	hashes map[uint64]string // names of the spilled items by hashed key
+	mu     *sync.Mutex
+}
+
+type spillRef struct {
+	off  int64
+	size int64
+	key  uint64
+}
+
+type spillRecord struct {
+	Key       string
+	Item      interface{}
+	ExpiresAt time.Time
+	Meta      map[string]string
+}
+
+// newSpill will create a spill log in the directory
+func newSpill(dir string) (*spill, error) {
+	file, err := ioutil.TempFile(dir, "cache-spill-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &spill{
+		file:   file,
+		index:  make(map[string]spillRef),
+		hashes: make(map[uint64]string),
+		mu:     &sync.Mutex{},
+	}, nil
+}
+
+// put will append the record to the log, replacing any earlier record for its key
+func (s *spill) put(key uint64, rec spillRecord) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	err := gob.NewEncoder(&buf).Encode(&rec)
+	if err != nil {
+		return err
+	}
+
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	_, err = s.file.WriteAt(data, s.size)
+	if err != nil {
+		return err
+	}
+
+	s.drop(rec.Key)
+	s.index[rec.Key] = spillRef{off: s.size, size: int64(len(data)), key: key}
+	s.hashes[key] = rec.Key
+	s.size += int64(len(data))
+	s.compact()
+
+	return nil
+}
+
+// take will read and remove the record for the name
+func (s *spill) take(name string) (spillRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ref, ok := s.index[name]
+	if !ok || s.file == nil {
+		return spillRecord{}, false, nil
+	}
+
+	rec, err := s.read(ref)
+	if err != nil {
+		return spillRecord{}, false, err
+	}
+	s.drop(name)
+	s.compact()
+
+	return rec, true, nil
+}
+
+// get will read the record for the name, leaving it in the log
+func (s *spill) get(name string) (spillRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ref, ok := s.index[name]
+	if !ok || s.file == nil {
+		return spillRecord{}, false, nil
+	}
+
+	rec, err := s.read(ref)
+	if err != nil {
+		return spillRecord{}, false, err
+	}
+
+	return rec, true, nil
+}
+
+// owner will return the name of the record for the hashed key
+func (s *spill) owner(key uint64) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := s.hashes[key]
+	return name, ok
+}
+
+func (s *spill) has(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.index[name]
+	return ok
+}
+
+// remove will drop the record for the name, reporting whether there was one
+func (s *spill) remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.drop(name) {
+		return false
+	}
+	s.compact()
+
+	return true
+}
+
+// removeKey will drop the record for the hashed key,
+// reporting whether there was one
+func (s *spill) removeKey(key uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := s.hashes[key]
+	if !ok {
+		return false
+	}
+
+	s.drop(name)
+	s.compact()
+
+	return true
+}
+
+// rekey will replace the hashed keys of the records after the
+// cache is reseeded, returning the old keys mapped to the new ones
+func (s *spill) rekey(hash func(name string) uint64) map[uint64]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := make(map[uint64]uint64, len(s.index))
+	s.hashes = make(map[uint64]string, len(s.index))
+	for name, ref := range s.index {
+		hk := hash(name)
+		moved[ref.key] = hk
+		ref.key = hk
+		s.index[name] = ref
+		s.hashes[hk] = name
+	}
+
+	return moved
+}
+
+func (s *spill) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.index)
+}
+
+// reset will drop every record
+func (s *spill) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index = make(map[string]spillRef)
+	s.hashes = make(map[uint64]string)
+	s.size = 0
+	s.dead = 0
+	if s.file != nil {
+		s.file.Truncate(0)
+	}
+}
+
+// close will delete the log
+func (s *spill) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	s.index = make(map[string]spillRef)
+	s.hashes = make(map[uint64]string)
+	s.file.Close()
+	err := os.Remove(s.file.Name())
+	s.file = nil
+
+	return err
+}
+
+// drop will forget the record for the name. The lock must be held.
+func (s *spill) drop(name string) bool {
+	ref, ok := s.index[name]
+	if !ok {
+		return false
+	}
+
+	delete(s.index, name)
+	delete(s.hashes, ref.key)
+	s.dead += ref.size
+
+	return true
+}
+
+func (s *spill) read(ref spillRef) (spillRecord, error) {
+	data := make([]byte, ref.size)
+	_, err := s.file.ReadAt(data, ref.off)
+	if err != nil && err != io.EOF {
+		return spillRecord{}, err
+	}
+
+	var rec spillRecord
+	err = gob.NewDecoder(bytes.NewReader(data[4:])).Decode(&rec)
+	return rec, err
+}
+
+// compact will rewrite the log without its dead records once they
+// take up most of it. The lock must be held.
+func (s *spill) compact() {
+	if s.dead < spillCompactBytes || s.dead < s.size/2 {
+		return
+	}
+
+	names := make([]string, 0, len(s.index))
+	for name := range s.index {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return s.index[names[i]].off < s.index[names[j]].off
+	})
+
+	var off int64
+	for _, name := range names {
+		ref := s.index[name]
+		data := make([]byte, ref.size)
+		_, err := s.file.ReadAt(data, ref.off)
+		if err != nil && err != io.EOF {
+			return
+		}
+
+		// records are moved towards the start of the log in order,
+		// so a record is never overwritten before it has been moved
+		if ref.off != off {
+			_, err = s.file.WriteAt(data, off)
+			if err != nil {
+				return
+			}
+		}
+
+		ref.off = off
+		s.index[name] = ref
+		off += ref.size
+	}
+
+	s.file.Truncate(off)
+	s.size = off
+	s.dead = 0
+}
+
+// spillSlot will write the item in the slot, which is being evicted, to
+// the spill log. Expired items, buckets and soft deleted items are not
+// spilled, and an item that cannot be encoded is reported to OnError.
+func (t *Cache) spillSlot(idx int) {
+	if t.spill == nil {
+		return
+	}
+
+	slot := t.slots[idx]
+	if slot.deleted || t.now().After(slot.ExpiresAt) {
+		return
+	}
+
+	if _, ok := slot.Item.(*Bucket); ok {
+		return
+	}
+
+	err := t.spill.put(slot.key, spillRecord{
+		Key:       slot.name,
+		Item:      slot.Item,
+		ExpiresAt: slot.ExpiresAt,
+		Meta:      slot.meta,
+	})
+	if err != nil {
+		t.expirer.report(err)
+	}
+}
+
+// fault will move the item at the key from the spill log back
+// into memory, returning ErrDNE if it was not spilled or expired
+func (t *Cache) fault(name string) (interface{}, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := t.hash(name)
+
+	// another reader may have faulted the item in first
+	if idx, ok := t.live(key); ok && t.slots[idx].name == name && !t.now().After(t.slots[idx].ExpiresAt) {
+		return t.slots[idx].Item, nil
+	}
+
+	rec, ok, err := t.spill.take(name)
+	if err != nil {
+		return nil, err
+	} else if !ok || t.now().After(rec.ExpiresAt) {
+		return nil, ErrDNE
+	}
+
+	err = t.add(key, name, rec.Item, rec.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	t.slots[t.keys[key]].meta = rec.Meta
+	atomic.AddUint64(&t.counters.faults, 1)
+
+	return rec.Item, nil
+}
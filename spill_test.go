@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestSpill(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(&CacheConfig{
+		MaxEntries:     10,
+		EvictionPolicy: EvictLRU,
+		SpillDir:       dir,
+	})
+	defer cache.Close()
+
+	for i := 0; i < 100; i++ {
+		err := cache.Add(fmt.Sprintf("key-%d", i), i, time.Hour, WithMeta(map[string]string{"n": fmt.Sprint(i)}))
+		if err != nil {
+			t.Fatalf("Add error: %+v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 10 || stats.Spilled != 90 {
+		t.Fatalf("expected 10 items in memory and 90 spilled, got %+v", stats)
+	}
+
+	for i := 0; i < 100; i++ {
+		item, err := cache.Get(fmt.Sprintf("key-%d", i))
+		_, meta, _ := cache.GetWithMeta(fmt.Sprintf("key-%d", i))
+		if err != nil || item != i {
+			t.Errorf("expected key-%d to be faulted back in, got %v %+v", i, item, err)
+		}
+
+		if meta["n"] != fmt.Sprint(i) {
+			t.Errorf("expected the metadata of key-%d to be kept, got %v", i, meta)
+		}
+	}
+
+	stats = cache.Stats()
+	if stats.Faults == 0 || stats.Entries != 10 || stats.Spilled != 90 {
+		t.Errorf("expected faulted items to be swapped with memory, got %+v", stats)
+	}
+}
+
+func TestSpillDelete(t *testing.T) {
+	cache := NewCache(&CacheConfig{MaxEntries: 1, SpillDir: t.TempDir()})
+	defer cache.Close()
+
+	cache.Add("first", 1, time.Hour)
+	cache.Add("second", 2, time.Hour)
+
+	if err := cache.Delete("first"); err != nil {
+		t.Errorf("expected the spilled item to be deleted, got %+v", err)
+	}
+
+	if _, err := cache.Get("first"); err != ErrDNE {
+		t.Errorf("expected the deleted item to stay deleted, got %+v", err)
+	}
+
+	cache.Add("third", 3, time.Hour)
+	cache.Set("second", 20, time.Hour)
+	cache.Add("fourth", 4, time.Hour)
+	if item, err := cache.Get("second"); err != nil || item != 20 {
+		t.Errorf("expected the latest value, got %v %+v", item, err)
+	}
+}
+
+func TestSpillExpired(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{MaxEntries: 1, SpillDir: t.TempDir(), Clock: clock})
+	defer cache.Close()
+
+	cache.Add("first", 1, time.Minute)
+	cache.Add("second", 2, time.Hour)
+	clock.Advance(2 * time.Minute)
+
+	if _, err := cache.Get("first"); err != ErrDNE {
+		t.Errorf("expected the spilled item to expire, got %+v", err)
+	}
+}
+
+func TestSpillBucket(t *testing.T) {
+	cache := NewCache(&CacheConfig{MaxEntries: 3, SpillDir: t.TempDir()})
+	defer cache.Close()
+
+	b := cache.Bucket("bucket")
+	for i := 0; i < 5; i++ {
+		b.Add(fmt.Sprintf("key-%d", i), i, time.Hour)
+	}
+
+	for i := 0; i < 5; i++ {
+		if item, err := b.Get(fmt.Sprintf("key-%d", i)); err != nil || item != i {
+			t.Errorf("expected key-%d from the bucket, got %v %+v", i, item, err)
+		}
+	}
+}
+
+func TestSpillClose(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(&CacheConfig{MaxEntries: 1, SpillDir: dir})
+	cache.Add("first", 1, time.Hour)
+	cache.Add("second", 2, time.Hour)
+	cache.Close()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir error: %+v", err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected the spill log to be removed, found %d files", len(files))
+	}
+}
+
+func TestSpillCompact(t *testing.T) {
+	s, err := newSpill(t.TempDir())
+	if err != nil {
+		t.Fatalf("newSpill error: %+v", err)
+	}
+	defer s.close()
+
+	value := make([]byte, 1024)
+	for i := 0; i < 4096; i++ {
+		name := fmt.Sprintf("key-%d", i%16)
+		s.put(uint64(i%16), spillRecord{Key: name, Item: value, ExpiresAt: neverExpires})
+	}
+
+	if s.size > 2*spillCompactBytes {
+		t.Errorf("expected the log to be compacted, got %d bytes", s.size)
+	}
+
+	for i := 0; i < 16; i++ {
+		rec, ok, err := s.take(fmt.Sprintf("key-%d", i))
+		if !ok || err != nil || len(rec.Item.([]byte)) != 1024 {
+			t.Errorf("expected key-%d to survive compaction, got %v %+v", i, ok, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Refresher is a function that will reload the item for a key
+// along with the expiration duration of the reloaded item.
+type Refresher func(key string) (interface{}, time.Duration, error)
+
+// revalidator tracks the refresh functions of keys that may be served
+// stale, and the refreshes that are in flight. Refresh functions are
+// registered under the cache lock, while refreshes are started by
+// readers holding the read lock.
+type revalidator struct {
+	refreshers map[string]func()
+	inflight   map[string]bool
+	mu         *sync.Mutex
+}
+
+func newRevalidator() *revalidator {
+	return &revalidator{
+		refreshers: make(map[string]func()),
+		inflight:   make(map[string]bool),
+		mu:         &sync.Mutex{},
+	}
+}
+
+// SetRefresher will register the refresher for the key. When StaleGrace
+// is configured, the item is still returned by Get for up to StaleGrace
+// after it expires while the refresher reloads it in the background.
+// The refresher is dropped when the item is removed from the cache.
+// It will return ErrDNE if the key does not exist.
+func (t *Cache) SetRefresher(key string, refresher Refresher) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hashedKey := t.hash(key)
+
+	if _, ok := t.keys[hashedKey]; !ok {
+		return ErrDNE
+	}
+
+	t.revalidate.refreshers[key] = func() {
+		item, expiresIn, err := refresher(key)
+		if err != nil {
+			return
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		hashedKey := t.hash(key)
+		t.set(hashedKey, key, item, t.expiration(expiresIn))
+	}
+
+	return nil
+}
+
+// revalidateBucketItem will register the bucket's Loader, if it has one,
+// as the refresher for an item in the bucket. The cache lock must be
+// held by the caller.
+func (b *Bucket) revalidateBucketItem(key string) {
+	b.loadMu.Lock()
+	loader := b.config.Loader
+	b.loadMu.Unlock()
+
+	if b.cache.config.StaleGrace <= 0 || loader == nil {
+		return
+	}
+
+	b.cache.revalidate.refreshers[b.key(key)] = func() {
+		b.load(key)
+	}
+}
+
+// stale reports whether the expired item in the slot can still be
+// served, starting a background refresh of it if one is not in flight.
+func (t *Cache) stale(slot Slot, now time.Time) bool {
+	if t.config.StaleGrace <= 0 || now.Sub(slot.ExpiresAt) > t.config.StaleGrace {
+		return false
+	}
+
+	refresh, ok := t.revalidate.refreshers[slot.name]
+	if !ok {
+		return false
+	}
+
+	r := t.revalidate
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.inflight[slot.name] {
+		r.inflight[slot.name] = true
+		go func() {
+			refresh()
+
+			r.mu.Lock()
+			delete(r.inflight, slot.name)
+			r.mu.Unlock()
+		}()
+	}
+
+	return true
+}
+
+// expired reports whether the item in the slot should be removed by
+// the cleaner, holding items that can be served stale until their
+// grace period has passed.
+func (t *Cache) expired(slot Slot, now time.Time) bool {
+	if !now.After(slot.ExpiresAt) {
+		return false
+	}
+
+	if _, ok := t.revalidate.refreshers[slot.name]; ok {
+		return now.Sub(slot.ExpiresAt) > t.config.StaleGrace
+	}
+
+	return true
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheExpiredItemsAreMissing(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: time.Hour,
+	})
+
+	err := cache.Add("a", 1, 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = cache.Get("a")
+	if err != ErrDNE {
+		t.Errorf("expired item was returned before being cleaned: %+v", err)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+		StaleGrace:    time.Hour,
+	})
+
+	err := cache.Add("a", 1, 20*time.Millisecond)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	var calls int32
+	release := make(chan struct{})
+	err = cache.SetRefresher("a", func(key string) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 2, time.Hour, nil
+	})
+	if err != nil {
+		t.Errorf("error setting refresher: %+v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		item, err := cache.Get("a")
+		if err != nil || item != 1 {
+			t.Errorf("stale item was not served: %v %+v", item, err)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		item, _ := cache.Get("a")
+		if item == 2 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("item was not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("refresher was called %d times", n)
+	}
+
+	err = cache.SetRefresher("missing", nil)
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE, got: %+v", err)
+	}
+}
+
+func TestStaleGraceExpires(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+		StaleGrace:    20 * time.Millisecond,
+	})
+
+	cache.Add("a", 1, 10*time.Millisecond)
+	cache.SetRefresher("a", func(key string) (interface{}, time.Duration, error) {
+		return nil, 0, ErrDNE
+	})
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err := cache.Get("a")
+	if err != ErrDNE {
+		t.Errorf("item was served after its grace period: %+v", err)
+	}
+}
+
+func TestBucketStaleWhileRevalidate(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		CleanDuration: 10 * time.Millisecond,
+		StaleGrace:    time.Hour,
+	})
+
+	var loads int32
+	bucket := cache.BucketWithConfig("users", &BucketConfig{
+		Loader: func(key string) (interface{}, error) {
+			return atomic.AddInt32(&loads, 1), nil
+		},
+		LoadTTL: time.Hour,
+	})
+
+	err := bucket.Add("1", int32(0), 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	item, err := bucket.Get("1")
+	if err != nil || item != int32(0) {
+		t.Errorf("stale item was not served: %v %+v", item, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		item, _ := bucket.Get("1")
+		if item == int32(1) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("item was not reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,120 @@
+package cache
+
+import "sync/atomic"
+
+// Stats describes the usage of a cache
+type Stats struct {
+	Hits        uint64 // gets that found the key
+	Misses      uint64 // gets that did not find the key
+	Evictions   uint64 // items removed to stay within MaxEntries or MaxBytes
+	Rejections  uint64 // items not added because the Admission policy preferred the item they would evict
+	Expirations uint64 // items removed by the cleaner after expiring
+	Collisions  uint64 // hash collisions between distinct keys
+	Faults      uint64 // evicted items moved back into memory from the spill log
+	Spilled     int    // evicted items held in the spill log
+	Pinned      int    // items kept from eviction by Pin
+	Entries     int    // keys in the cache, including buckets
+	Bytes       int64  // total size of the items in the cache, measured with MaxBytes or a Sizer set
+
+	// with Histograms set, the time since each item was added and
+	// the time left until each item with a ttl expires
+	AgeHistogram *Histogram
+	TTLHistogram *Histogram
+}
+
+// HitRate returns the fraction of gets that found the key
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// counters are updated atomically, since hits
+// and misses are counted under the read lock
+type counters struct {
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	rejections  uint64
+	expirations uint64
+	faults      uint64
+}
+
+// Stats will return the current statistics of the cache
+func (t *Cache) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	stats := Stats{
+		Hits:        atomic.LoadUint64(&t.counters.hits),
+		Misses:      atomic.LoadUint64(&t.counters.misses),
+		Evictions:   atomic.LoadUint64(&t.counters.evictions),
+		Rejections:  atomic.LoadUint64(&t.counters.rejections),
+		Expirations: atomic.LoadUint64(&t.counters.expirations),
+		Collisions:  t.collisions,
+		Faults:      atomic.LoadUint64(&t.counters.faults),
+		Pinned:      t.pins,
+		Entries:     len(t.keys),
+		Bytes:       t.bytes,
+	}
+	if t.spill != nil {
+		stats.Spilled = t.spill.len()
+	}
+	if t.config.Histograms {
+		stats.AgeHistogram, stats.TTLHistogram = t.histograms()
+	}
+
+	return stats
+}
+
+// BucketStats are the statistics of the items in a bucket
+type BucketStats struct {
+	Hits      uint64 // gets through the bucket that found the key
+	Misses    uint64 // gets through the bucket that did not find the key
+	Evictions uint64 // items of the bucket removed to stay within MaxEntries or MaxBytes
+	Entries   int    // items in the bucket
+	Bytes     int64  // total size of the items in the bucket
+}
+
+// HitRate returns the fraction of gets that found the key
+func (s BucketStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// Stats will return the current statistics of the bucket. Only gets
+// made through the bucket are counted as its hits and misses.
+func (b *Bucket) Stats() BucketStats {
+	b.cache.mu.RLock()
+	defer b.cache.mu.RUnlock()
+
+	stats := BucketStats{
+		Hits:      atomic.LoadUint64(&b.counters.hits),
+		Misses:    atomic.LoadUint64(&b.counters.misses),
+		Evictions: atomic.LoadUint64(&b.counters.evictions),
+		Entries:   len(b.list),
+	}
+	for _, key := range b.list {
+		if idx, ok := b.cache.keys[key]; ok {
+			stats.Bytes += b.cache.slots[idx].size
+		}
+	}
+
+	return stats
+}
+
+// countGet will count a get made through the bucket as a hit or a miss
+func (b *Bucket) countGet(err error) {
+	if err == nil {
+		atomic.AddUint64(&b.counters.hits, 1)
+	} else if err == ErrDNE {
+		atomic.AddUint64(&b.counters.misses, 1)
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheStats(t *testing.T) {
+	cache := NewCache(&CacheConfig{
+		MaxEntries: 1,
+		MaxBytes:   1 << 20,
+	})
+
+	err := cache.Add("key", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	_, err = cache.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	_, err = cache.Get("dne")
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	err = cache.Add("other", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("stats were not counted: %+v", stats)
+	}
+
+	if stats.Entries != 1 || stats.Bytes != 5 {
+		t.Errorf("stats did not describe contents: %+v", stats)
+	}
+
+	if stats.HitRate() != 0.5 {
+		t.Errorf("hit rate was %f", stats.HitRate())
+	}
+}
+
+func TestBucketStats(t *testing.T) {
+	cache := NewCache(&CacheConfig{MaxBytes: 26})
+	defer cache.Close()
+
+	users := cache.Bucket("users")
+	pages := cache.Bucket("pages")
+
+	users.Add("alice", "value", NoExpiration)
+	users.Get("alice")
+	users.Get("bob")
+	pages.Get("home")
+
+	// with the two buckets, pushes alice out of the cache
+	pages.Add("home", "value", NoExpiration)
+	pages.Add("about", "value", NoExpiration)
+
+	stats := users.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 0 {
+		t.Errorf("users stats were not counted: %+v", stats)
+	}
+
+	stats = pages.Stats()
+	if stats.Hits != 0 || stats.Misses != 1 || stats.Evictions != 0 {
+		t.Errorf("pages stats were not counted: %+v", stats)
+	}
+
+	if stats.Entries != 2 || stats.Bytes != 10 {
+		t.Errorf("pages stats did not describe contents: %+v", stats)
+	}
+
+	if users.Stats().HitRate() != 0.5 {
+		t.Errorf("hit rate was %f", users.Stats().HitRate())
+	}
+}
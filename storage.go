@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMmapUnsupported is returned by NewMmapStorage on platforms without mmap
+var ErrMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// Storage provides the memory of the pages a BytesCache packs its keys
+// and values into. The default keeps pages on the Go heap, while
+// MmapStorage keeps them outside of it, so that neither the size of
+// the values nor their number adds to the work of the garbage collector.
+type Storage interface {
+	// Alloc will return a page of size bytes
+	Alloc(size int) ([]byte, error)
+	// Free will release a page returned by Alloc
+	Free(page []byte)
+	// Close will release the memory held by the storage
+	Close() error
+}
+
+// heapStorage allocates pages on the Go heap, pooling
+// pages of the default size for reuse
+type heapStorage struct {
+	pageSize int
+	pool     *sync.Pool
+}
+
+func newHeapStorage(pageSize int) *heapStorage {
+	return &heapStorage{
+		pageSize: pageSize,
+		pool: &sync.Pool{
+			New: func() interface{} {
+				return make([]byte, pageSize)
+			},
+		},
+	}
+}
+
+func (s *heapStorage) Alloc(size int) ([]byte, error) {
+	if size == s.pageSize {
+		return s.pool.Get().([]byte), nil
+	}
+
+	return make([]byte, size), nil
+}
+
+func (s *heapStorage) Free(page []byte) {
+	if len(page) == s.pageSize {
+		s.pool.Put(page)
+	}
+}
+
+func (s *heapStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMmapStorage(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		storage, err := NewMmapStorage(dir)
+		if err == ErrMmapUnsupported {
+			t.Skip("mmap is not supported on this platform")
+		} else if err != nil {
+			t.Fatalf("NewMmapStorage error: %+v", err)
+		}
+
+		c := NewBytesCache(&BytesConfig{PageSize: 4096, Storage: storage})
+		for i := 0; i < 1000; i++ {
+			err := c.Add(fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i)), 0)
+			if err != nil {
+				t.Fatalf("Add error: %+v", err)
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+			value, err := c.Get(fmt.Sprintf("key-%d", i))
+			if err != nil || string(value) != fmt.Sprintf("value-%d", i) {
+				t.Errorf("expected value-%d, got %q %+v", i, value, err)
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+			c.Delete(fmt.Sprintf("key-%d", i))
+		}
+
+		if c.Bytes() > 4096 {
+			t.Errorf("expected the emptied pages to be freed, %d bytes held", c.Bytes())
+		}
+
+		err = c.Close()
+		if err != nil {
+			t.Errorf("Close error: %+v", err)
+		}
+	}
+}
+
+func TestMmapStorageReuse(t *testing.T) {
+	storage, err := NewMmapStorage("")
+	if err == ErrMmapUnsupported {
+		t.Skip("mmap is not supported on this platform")
+	} else if err != nil {
+		t.Fatalf("NewMmapStorage error: %+v", err)
+	}
+	defer storage.Close()
+
+	page, err := storage.Alloc(4096)
+	if err != nil {
+		t.Fatalf("Alloc error: %+v", err)
+	}
+	page[0] = 1
+	storage.Free(page)
+
+	reused, err := storage.Alloc(4096)
+	if err != nil {
+		t.Fatalf("Alloc error: %+v", err)
+	}
+
+	if &reused[0] != &page[0] {
+		t.Errorf("expected the freed page to be reused")
+	}
+}
+
+func TestMmapStorageNotDir(t *testing.T) {
+	if _, err := NewMmapStorage("storage_test.go"); err == nil {
+		t.Errorf("expected an error for a path that is not a directory")
+	}
+}
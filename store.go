@@ -0,0 +1,384 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWriteInterval = 1 * time.Second
+	defaultWriteRetries  = 3
+)
+
+// Store is a backing store that changes made through Add, Set,
+// Update and Delete are propagated to. Keys of bucket items are
+// the composite keys built by the bucket, e.g. "bucket:key".
+type Store interface {
+	Write(key string, item interface{}) error
+	Delete(key string) error
+}
+
+// StoreError is reported to OnError when a write-back to
+// the store is dropped after exhausting its retries.
+type StoreError struct {
+	Key string
+	Err error
+}
+
+func (e *StoreError) Error() string {
+	return "cache: write-back of " + e.Key + " failed: " + e.Err.Error()
+}
+
+// storeOp is a change waiting to be written back to the store
+type storeOp struct {
+	item     interface{}
+	deleted  bool
+	attempts int
+}
+
+// writeBack batches the changes made to the cache
+// and flushes them to the store on an interval.
+type writeBack struct {
+	store    Store
+	retries  int
+	pending  map[string]*storeOp
+	flushing chan struct{} // held while flushing, so that changes are written in order
+	mu       sync.Mutex
+}
+
+func newWriteBack(store Store, retries int) *writeBack {
+	return &writeBack{
+		store:    store,
+		retries:  retries,
+		pending:  make(map[string]*storeOp),
+		flushing: make(chan struct{}, 1),
+	}
+}
+
+// queue will record the latest change to the key, replacing
+// any change to it that has not been flushed yet.
+func (w *writeBack) queue(name string, op *storeOp) {
+	w.mu.Lock()
+	w.pending[name] = op
+	w.mu.Unlock()
+}
+
+func (w *writeBack) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.pending)
+}
+
+// flush will write every pending change to the store. Changes that
+// fail are retried on the next flush unless the key was changed again
+// in the meantime, and are dropped once they exhaust their retries.
+// If ctx is done first, the changes not yet written stay pending.
+func (w *writeBack) flush(ctx context.Context, report func(error)) error {
+	select {
+	case w.flushing <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		<-w.flushing
+	}()
+
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[string]*storeOp)
+	w.mu.Unlock()
+
+	for name, op := range pending {
+		if ctx.Err() != nil {
+			w.requeue(name, op)
+			continue
+		}
+
+		var err error
+		if op.deleted {
+			err = w.store.Delete(name)
+		} else {
+			err = w.store.Write(name, op.item)
+		}
+
+		if err == nil {
+			continue
+		}
+
+		op.attempts++
+		if op.attempts > w.retries {
+			report(&StoreError{Key: name, Err: err})
+			continue
+		}
+		w.requeue(name, op)
+	}
+
+	return ctx.Err()
+}
+
+// requeue will put back a change that was not written,
+// unless the key was changed again in the meantime
+func (w *writeBack) requeue(name string, op *storeOp) {
+	w.mu.Lock()
+	if _, ok := w.pending[name]; !ok {
+		w.pending[name] = op
+	}
+	w.mu.Unlock()
+}
+
+// drain will flush until no changes are pending or ctx is done,
+// retrying failed changes until they exhaust their retries
+func (w *writeBack) drain(ctx context.Context, report func(error)) error {
+	for w.len() > 0 {
+		err := w.flush(ctx, report)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PendingWrites will return the number of changes waiting to be
+// written back to the Store, which is always 0 without WriteBack
+func (t *Cache) PendingWrites() int {
+	if t.writeBack == nil {
+		return 0
+	}
+
+	return t.writeBack.len()
+}
+
+// FlushWrites will write the pending changes back to the Store without
+// waiting for the WriteInterval, retrying failed changes until they
+// exhaust WriteRetries, and return once none are pending or ctx is
+// done. Changes that are dropped are reported to OnError, and the first
+// is returned as a *StoreError. Close drains the pending changes in the
+// same way, so FlushWrites is only needed to bound the time it takes.
+func (t *Cache) FlushWrites(ctx context.Context) error {
+	if t.writeBack == nil {
+		return nil
+	}
+
+	var first error
+	err := t.writeBack.drain(ctx, func(err error) {
+		if first == nil {
+			first = err
+		}
+		t.expirer.report(err)
+	})
+	if err != nil {
+		return err
+	}
+
+	return first
+}
+
+// writeBacker will flush the pending changes every WriteInterval
+// until the cache is closed, draining them before returning.
+func (t *Cache) writeBacker() {
+	ticker := time.NewTicker(t.config.WriteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.writeBack.flush(context.Background(), t.expirer.report)
+		case <-t.done:
+			t.writeBack.drain(context.Background(), t.expirer.report)
+			close(t.writeBackDone)
+			return
+		}
+	}
+}
+
+// pendingWrite is a change written through to the store once the
+// cache lock is released, with the transaction that undoes it
+type pendingWrite struct {
+	name string
+	op   *storeOp
+	tx   *Txn
+}
+
+// write will run fn while holding the cache lock. The changes fn
+// persists are written through to the store once the lock has been
+// released, so that reads are not held up by the store, and a change
+// the store rejects is rolled back and its error returned. Readers can
+// see a change from when it is made until it is rolled back. Changes
+// are written through one at a time, in the order they were made.
+func (t *Cache) write(fn func() error) error {
+	if t.config.Store != nil && t.writeBack == nil {
+		t.storeMu.Lock()
+		defer t.storeMu.Unlock()
+	}
+
+	t.mu.Lock()
+	err := fn()
+	pending := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(pending) > 0 {
+		werr := t.writeThrough(pending)
+		if err == nil {
+			err = werr
+		}
+	}
+
+	return err
+}
+
+// writeThrough will write the pending changes to the store. The changes
+// of a transaction the store rejects are rolled back, and those of them
+// already written are written again in their restored state. The first
+// error is returned, and any others are reported to OnError.
+func (t *Cache) writeThrough(pending []pendingWrite) error {
+	var first error
+	var rejected []*Txn
+	failed := make(map[*Txn]bool)
+	for _, w := range pending {
+		if w.tx != nil && failed[w.tx] {
+			continue
+		}
+
+		err := t.storeWrite(w.name, w.op)
+		if err == nil {
+			continue
+		}
+
+		if first == nil {
+			first = err
+		} else {
+			t.expirer.report(err)
+		}
+
+		if w.tx != nil {
+			failed[w.tx] = true
+			rejected = append(rejected, w.tx)
+		}
+	}
+
+	var restored []pendingWrite
+	if len(rejected) > 0 {
+		t.mu.Lock()
+		for i := len(rejected) - 1; i >= 0; i-- {
+			rejected[i].rollback()
+		}
+
+		for _, w := range pending {
+			if failed[w.tx] {
+				restored = append(restored, pendingWrite{name: w.name, op: t.storeState(t.hash(w.name), w.name)})
+			}
+		}
+		t.mu.Unlock()
+	}
+
+	// rewriting a key the store rejected is harmless,
+	// and restores the ones written before the rejection
+	for _, w := range restored {
+		err := t.storeWrite(w.name, w.op)
+		if err != nil {
+			t.expirer.report(err)
+		}
+	}
+
+	if t.invalidate != nil {
+		for _, w := range pending {
+			if !failed[w.tx] {
+				t.invalidate.queue(w.name)
+			}
+		}
+	}
+
+	return first
+}
+
+// storeWrite will apply the change to the store
+func (t *Cache) storeWrite(name string, op *storeOp) error {
+	if op.deleted {
+		return t.config.Store.Delete(name)
+	}
+
+	return t.config.Store.Write(name, op.item)
+}
+
+// storeState will return the change that writes the current
+// state of the key to the store. The lock must be held.
+func (t *Cache) storeState(key uint64, name string) *storeOp {
+	if idx, ok := t.live(key); ok && t.slots[idx].name == name {
+		return &storeOp{item: t.slots[idx].Item}
+	}
+
+	// an item placed on disk is still in the cache
+	if t.spill != nil {
+		if rec, ok, _ := t.spill.get(name); ok && !t.now().After(rec.ExpiresAt) {
+			return &storeOp{item: rec.Item}
+		}
+	}
+
+	return &storeOp{deleted: true}
+}
+
+// beginStore will return a transaction recording the state of the key,
+// and of the keys depending on it, when a change to it is propagated
+// to the store or other caches, so that the keys a deletion removes
+// can be propagated too and a rejected write-through undone.
+func (t *Cache) beginStore(key uint64, name string) *Txn {
+	if t.config.Store == nil && t.invalidate == nil {
+		return nil
+	}
+
+	tx := &Txn{cache: t}
+	tx.save(key, name)
+
+	return tx
+}
+
+// persist will propagate the current state of the key to the store
+// and invalidate the key in other caches, along with the keys that
+// were removed because they depended on it if it was deleted. With
+// write-through the changes are written once the lock is released,
+// see write, and with write-back they are queued for the next flush.
+// The lock must be held.
+func (t *Cache) persist(tx *Txn, key uint64, name string) {
+	if t.config.Store == nil && t.invalidate == nil {
+		return
+	}
+
+	op := t.storeState(key, name)
+	t.propagate(tx, name, op)
+	if tx == nil || !op.deleted {
+		return
+	}
+
+	for _, record := range tx.undo {
+		if record.parent != name || !record.existed {
+			continue
+		}
+
+		op := t.storeState(t.hash(record.name), record.name)
+		if op.deleted {
+			t.propagate(tx, record.name, op)
+		}
+	}
+}
+
+// propagate will write the change to the store, or queue it, and
+// invalidate the key in other caches. The lock must be held.
+func (t *Cache) propagate(tx *Txn, name string, op *storeOp) {
+	switch {
+	case t.config.Store == nil:
+	case t.writeBack != nil:
+		t.writeBack.queue(name, op)
+	default:
+		// invalidated once the store has accepted the change
+		t.pending = append(t.pending, pendingWrite{name: name, op: op, tx: tx})
+		return
+	}
+
+	if t.invalidate != nil {
+		t.invalidate.queue(name)
+	}
+}
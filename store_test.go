@@ -0,0 +1,409 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errStoreDown = errors.New("store down")
+
+// mapStore is an in-memory Store that can be made to fail
+type mapStore struct {
+	items  map[string]interface{}
+	writes int
+	fail   bool
+	mu     *sync.Mutex
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{
+		items: make(map[string]interface{}),
+		mu:    &sync.Mutex{},
+	}
+}
+
+func (m *mapStore) Write(key string, item interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writes++
+	if m.fail {
+		return errStoreDown
+	}
+	m.items[key] = item
+
+	return nil
+}
+
+func (m *mapStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return errStoreDown
+	}
+	delete(m.items, key)
+
+	return nil
+}
+
+func (m *mapStore) get(key string) (interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	return item, ok
+}
+
+func (m *mapStore) writeCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.writes
+}
+
+func (m *mapStore) setFail(fail bool) {
+	m.mu.Lock()
+	m.fail = fail
+	m.mu.Unlock()
+}
+
+func TestStoreWriteThrough(t *testing.T) {
+	store := newMapStore()
+	c := NewCache(&CacheConfig{Store: store})
+	defer c.Close()
+
+	err := c.Add("key", "one", time.Minute)
+	if err != nil {
+		t.Errorf("Add error: %+v", err)
+	}
+
+	err = c.Update("key", "two")
+	if err != nil {
+		t.Errorf("Update error: %+v", err)
+	}
+
+	item, ok := store.get("key")
+	if !ok || item != "two" {
+		t.Errorf("expected the store to hold two, got %v", item)
+	}
+
+	err = c.Bucket("b").Add("key", "three", time.Minute)
+	if err != nil {
+		t.Errorf("Bucket Add error: %+v", err)
+	}
+
+	item, ok = store.get("b:key")
+	if !ok || item != "three" {
+		t.Errorf("expected the store to hold the bucket item, got %v", item)
+	}
+
+	err = c.Delete("key")
+	if err != nil {
+		t.Errorf("Delete error: %+v", err)
+	}
+
+	_, ok = store.get("key")
+	if ok {
+		t.Errorf("expected the key to be deleted from the store")
+	}
+}
+
+func TestStoreWriteThroughRollback(t *testing.T) {
+	store := newMapStore()
+	c := NewCache(&CacheConfig{Store: store})
+	defer c.Close()
+
+	err := c.Add("key", "one", time.Minute)
+	if err != nil {
+		t.Errorf("Add error: %+v", err)
+	}
+
+	store.setFail(true)
+
+	err = c.Update("key", "two")
+	if err != errStoreDown {
+		t.Errorf("expected the store error, got %+v", err)
+	}
+
+	item, err := c.Get("key")
+	if err != nil || item != "one" {
+		t.Errorf("expected the update to be rolled back, got %v, %+v", item, err)
+	}
+
+	err = c.Add("other", "one", time.Minute)
+	if err != errStoreDown {
+		t.Errorf("expected the store error, got %+v", err)
+	}
+
+	_, err = c.Get("other")
+	if err != ErrDNE {
+		t.Errorf("expected the add to be rolled back, got %+v", err)
+	}
+
+	err = c.Delete("key")
+	if err != errStoreDown {
+		t.Errorf("expected the store error, got %+v", err)
+	}
+
+	_, err = c.Get("key")
+	if err != nil {
+		t.Errorf("expected the delete to be rolled back, got %+v", err)
+	}
+}
+
+func TestStoreWriteBack(t *testing.T) {
+	store := newMapStore()
+	c := NewCache(&CacheConfig{
+		Store:         store,
+		WriteBack:     true,
+		WriteInterval: 20 * time.Millisecond,
+	})
+
+	for i := 0; i < 10; i++ {
+		err := c.Set("key", i, time.Minute)
+		if err != nil {
+			t.Errorf("Set error: %+v", err)
+		}
+	}
+
+	_, ok := store.get("key")
+	if ok {
+		t.Errorf("expected the write to be deferred")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	item, ok := store.get("key")
+	if !ok || item != 9 {
+		t.Errorf("expected the latest write to be flushed, got %v", item)
+	}
+
+	if writes := store.writeCount(); writes != 1 {
+		t.Errorf("expected the writes to be coalesced, got %d", writes)
+	}
+
+	err := c.Add("other", "one", time.Minute)
+	if err != nil {
+		t.Errorf("Add error: %+v", err)
+	}
+
+	c.Close()
+
+	_, ok = store.get("other")
+	if !ok {
+		t.Errorf("expected Close to flush pending writes")
+	}
+}
+
+func TestStoreWriteBackRetry(t *testing.T) {
+	store := newMapStore()
+	store.setFail(true)
+
+	errs := make(chan error, 1)
+	c := NewCache(&CacheConfig{
+		Store:         store,
+		WriteBack:     true,
+		WriteInterval: 10 * time.Millisecond,
+		WriteRetries:  2,
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	defer c.Close()
+
+	err := c.Add("key", "one", time.Minute)
+	if err != nil {
+		t.Errorf("Add error: %+v", err)
+	}
+
+	select {
+	case err := <-errs:
+		storeErr, ok := err.(*StoreError)
+		if !ok || storeErr.Key != "key" || storeErr.Err != errStoreDown {
+			t.Errorf("expected a StoreError for key, got %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected the failed write to be reported")
+	}
+
+	if writes := store.writeCount(); writes != 3 {
+		t.Errorf("expected 3 attempts, got %d", writes)
+	}
+}
+
+func TestStoreFlushWrites(t *testing.T) {
+	store := newMapStore()
+	c := NewCache(&CacheConfig{
+		Store:         store,
+		WriteBack:     true,
+		WriteInterval: time.Hour,
+		WriteRetries:  1,
+	})
+	defer c.Close()
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	c.Delete("b")
+	if n := c.PendingWrites(); n != 2 {
+		t.Errorf("expected 2 pending writes, got %d", n)
+	}
+
+	if err := c.FlushWrites(context.Background()); err != nil {
+		t.Errorf("FlushWrites error: %+v", err)
+	}
+
+	if item, ok := store.get("a"); !ok || item != 1 || c.PendingWrites() != 0 {
+		t.Errorf("expected the pending writes to be flushed, got %v", item)
+	}
+
+	store.setFail(true)
+	c.Set("c", 3, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.FlushWrites(ctx); err != context.Canceled || c.PendingWrites() != 1 {
+		t.Errorf("expected the write to stay pending once ctx is done, got %+v", err)
+	}
+
+	err := c.FlushWrites(context.Background())
+	if storeErr, ok := err.(*StoreError); !ok || storeErr.Key != "c" {
+		t.Errorf("expected the dropped write to be returned, got %+v", err)
+	}
+
+	if writes := store.writeCount(); writes != 3 {
+		t.Errorf("expected the failed write to be retried once, got %d writes", writes)
+	}
+
+	if n := NewCache(nil).PendingWrites(); n != 0 {
+		t.Errorf("expected no pending writes without write-back, got %d", n)
+	}
+}
+
+// flakyStore is a mapStore whose first writes fail
+type flakyStore struct {
+	*mapStore
+	failures int
+}
+
+func (f *flakyStore) Write(key string, item interface{}) error {
+	if f.writeCount() < f.failures {
+		f.mu.Lock()
+		f.writes++
+		f.mu.Unlock()
+		return errStoreDown
+	}
+
+	return f.mapStore.Write(key, item)
+}
+
+func TestStoreWriteBackCloseDrains(t *testing.T) {
+	store := &flakyStore{mapStore: newMapStore(), failures: 2}
+	c := NewCache(&CacheConfig{
+		Store:         store,
+		WriteBack:     true,
+		WriteInterval: time.Hour,
+		WriteRetries:  2,
+	})
+
+	c.Set("key", 1, time.Minute)
+	c.Close()
+
+	if item, ok := store.get("key"); !ok || item != 1 {
+		t.Errorf("expected Close to retry the failed write, got %v", item)
+	}
+}
+
+// blockingStore is a mapStore whose writes wait until they are released
+type blockingStore struct {
+	*mapStore
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingStore) Write(key string, item interface{}) error {
+	b.writing <- struct{}{}
+	<-b.release
+	return b.mapStore.Write(key, item)
+}
+
+func TestStoreWriteThroughUnlocked(t *testing.T) {
+	store := &blockingStore{
+		mapStore: newMapStore(),
+		writing:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	c := NewCache(&CacheConfig{Store: store})
+	defer c.Close()
+
+	go func() {
+		<-store.writing
+		close(store.release)
+	}()
+	c.Add("other", "one", time.Minute)
+	store.release = make(chan struct{})
+
+	done := make(chan error)
+	go func() {
+		done <- c.Set("key", "one", time.Minute)
+	}()
+	<-store.writing
+
+	read := make(chan struct{})
+	go func() {
+		c.Get("other")
+		close(read)
+	}()
+
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("Get was held up by a write to the store")
+	}
+
+	close(store.release)
+	if err := <-done; err != nil {
+		t.Errorf("Set error: %+v", err)
+	}
+}
+
+func TestStoreWriteThroughDependents(t *testing.T) {
+	store := newMapStore()
+	c := NewCache(&CacheConfig{Store: store})
+	defer c.Close()
+
+	c.Add("parent", "one", time.Minute)
+	c.Add("child", "two", time.Minute, WithTags("tag"))
+	err := c.AddDependency("child", "parent")
+	if err != nil {
+		t.Errorf("AddDependency error: %+v", err)
+	}
+
+	store.setFail(true)
+	err = c.Delete("parent")
+	if err != errStoreDown {
+		t.Errorf("expected the store error, got %+v", err)
+	}
+
+	item, err := c.Get("child")
+	if err != nil || item != "two" {
+		t.Errorf("expected the removed dependent to be rolled back, got %v, %+v", item, err)
+	}
+
+	store.setFail(false)
+	err = c.Delete("parent")
+	if err != nil {
+		t.Errorf("Delete error: %+v", err)
+	}
+
+	if _, ok := store.get("child"); ok {
+		t.Error("expected the dependent to be deleted from the store")
+	}
+
+	if _, err := c.Get("child"); err != ErrDNE {
+		t.Errorf("expected the dependent to be removed, got %+v", err)
+	}
+}
@@ -0,0 +1,211 @@
+// Package sync provides coordination primitives built on the atomic
+// operations of a cache, for deduplicating and limiting work across
+// request handlers: counters that reset after a ttl, bounded semaphores
+// whose permits expire if never released, and flags that the first
+// writer wins.
+package sync
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+var (
+	// ErrNotCounter is returned when a counter's key holds an item that is not a counter
+	ErrNotCounter = errors.New("sync: key does not hold a counter")
+
+	// ErrNoPermit is returned by TryAcquire when every permit of the semaphore is held
+	ErrNoPermit = errors.New("sync: no permit available")
+
+	acquireRetry = 5 * time.Millisecond
+)
+
+// Counter is an integer stored at a key that is reset once its
+// ttl has passed since the first increment, e.g. to count the
+// requests made by a client within a window.
+type Counter struct {
+	cache *cache.Cache
+	key   string
+	ttl   time.Duration
+}
+
+// NewCounter will create and return a pointer to a new
+// Counter stored at the key, resetting every ttl
+func NewCounter(c *cache.Cache, key string, ttl time.Duration) *Counter {
+	return &Counter{
+		cache: c,
+		key:   key,
+		ttl:   ttl,
+	}
+}
+
+// Add will add delta to the counter and return its new value.
+// An expired or missing counter starts again from zero.
+func (c *Counter) Add(delta int64) (int64, error) {
+	var n int64
+	err := c.cache.Txn(func(tx *cache.Txn) error {
+		cur, err := tx.Get(c.key)
+		if err == cache.ErrDNE {
+			n = delta
+			return tx.Set(c.key, n, c.ttl)
+		} else if err != nil {
+			return err
+		}
+
+		v, ok := cur.(int64)
+		if !ok {
+			return ErrNotCounter
+		}
+		n = v + delta
+
+		return tx.Update(c.key, n)
+	})
+
+	return n, err
+}
+
+// Value will return the value of the counter
+func (c *Counter) Value() (int64, error) {
+	cur, err := c.cache.Get(c.key)
+	if err == cache.ErrDNE {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	v, ok := cur.(int64)
+	if !ok {
+		return 0, ErrNotCounter
+	}
+
+	return v, nil
+}
+
+// Reset will set the counter back to zero
+func (c *Counter) Reset() error {
+	err := c.cache.Delete(c.key)
+	if err == cache.ErrDNE {
+		return nil
+	}
+
+	return err
+}
+
+// Semaphore limits the holders of a resource to a number of permits.
+// Each permit is a lease on its own key, so a permit that is never
+// released is freed once its ttl passes.
+type Semaphore struct {
+	cache *cache.Cache
+	keys  []string
+	ttl   time.Duration
+}
+
+// NewSemaphore will create and return a pointer to a new Semaphore
+// with size permits stored under the key, each held for at most the ttl
+func NewSemaphore(c *cache.Cache, key string, size int, ttl time.Duration) *Semaphore {
+	keys := make([]string, size)
+	for i := range keys {
+		var k cache.KeyBuilder
+		keys[i] = k.Add(key).Add(strconv.Itoa(i)).String()
+	}
+
+	return &Semaphore{
+		cache: c,
+		keys:  keys,
+		ttl:   ttl,
+	}
+}
+
+// TryAcquire will acquire a permit, or return ErrNoPermit if
+// every permit is held. The permit is released with Release.
+func (s *Semaphore) TryAcquire() (cache.Lease, error) {
+	for _, key := range s.keys {
+		lease, err := s.cache.TryLock(key, s.ttl)
+		if err == cache.ErrLocked {
+			continue
+		}
+
+		return lease, err
+	}
+
+	return cache.Lease{}, ErrNoPermit
+}
+
+// Acquire will acquire a permit, waiting until one
+// is free or the context is done
+func (s *Semaphore) Acquire(ctx context.Context) (cache.Lease, error) {
+	for {
+		lease, err := s.TryAcquire()
+		if err != ErrNoPermit {
+			return lease, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return cache.Lease{}, ctx.Err()
+		case <-time.After(acquireRetry):
+		}
+	}
+}
+
+// Flag is a value stored at a key that only the first writer
+// sets, e.g. to claim a job so that it is only run once.
+type Flag struct {
+	cache *cache.Cache
+	key   string
+	ttl   time.Duration
+}
+
+// NewFlag will create and return a pointer to a new Flag
+// stored at the key, which can be set again after the ttl
+func NewFlag(c *cache.Cache, key string, ttl time.Duration) *Flag {
+	return &Flag{
+		cache: c,
+		key:   key,
+		ttl:   ttl,
+	}
+}
+
+// Set will set the flag to the value if it is not set, returning true
+// if this call set it, or false and the value of the earlier writer.
+func (f *Flag) Set(value interface{}) (bool, interface{}, error) {
+	won := false
+	cur := value
+	err := f.cache.Txn(func(tx *cache.Txn) error {
+		v, err := tx.Get(f.key)
+		if err == nil {
+			cur = v
+			return nil
+		} else if err != cache.ErrDNE {
+			return err
+		}
+
+		won = true
+		return tx.Set(f.key, value, f.ttl)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	return won, cur, nil
+}
+
+// Get will return the value of the flag, or
+// cache.ErrDNE if it has not been set
+func (f *Flag) Get() (interface{}, error) {
+	return f.cache.Get(f.key)
+}
+
+// Clear will unset the flag so that it can be set again
+func (f *Flag) Clear() error {
+	err := f.cache.Delete(f.key)
+	if err == cache.ErrDNE {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+func TestCounter(t *testing.T) {
+	c := cache.NewCache(nil)
+	counter := NewCounter(c, "requests", 50*time.Millisecond)
+
+	var wg gosync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	if n, err := counter.Value(); err != nil || n != 100 {
+		t.Errorf("expected 100, got %d: %+v", n, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n, err := counter.Add(2); err != nil || n != 2 {
+		t.Errorf("expected the counter to restart after its ttl, got %d: %+v", n, err)
+	}
+
+	counter.Reset()
+	if n, _ := counter.Value(); n != 0 {
+		t.Errorf("expected 0 after Reset, got %d", n)
+	}
+
+	c.Set("requests", "text", 0)
+	if _, err := counter.Add(1); err != ErrNotCounter {
+		t.Errorf("expected ErrNotCounter, got %+v", err)
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	c := cache.NewCache(nil)
+	sem := NewSemaphore(c, "workers", 2, time.Minute)
+
+	a, err := sem.TryAcquire()
+	if err != nil {
+		t.Fatalf("TryAcquire error: %+v", err)
+	}
+
+	if _, err := sem.TryAcquire(); err != nil {
+		t.Fatalf("TryAcquire error: %+v", err)
+	}
+
+	if _, err := sem.TryAcquire(); err != ErrNoPermit {
+		t.Errorf("expected ErrNoPermit, got %+v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := sem.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the context to end the wait, got %+v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		a.Release()
+	}()
+
+	if _, err := sem.Acquire(context.Background()); err != nil {
+		t.Errorf("expected a released permit to be acquired, got %+v", err)
+	}
+}
+
+func TestSemaphoreExpires(t *testing.T) {
+	c := cache.NewCache(nil)
+	sem := NewSemaphore(c, "workers", 1, 20*time.Millisecond)
+
+	sem.TryAcquire()
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := sem.TryAcquire(); err != nil {
+		t.Errorf("expected an unreleased permit to expire, got %+v", err)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	c := cache.NewCache(nil)
+	flag := NewFlag(c, "job:42", time.Minute)
+
+	var wg gosync.WaitGroup
+	var mu gosync.Mutex
+	var winners []interface{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			won, cur, err := flag.Set(i)
+			if err != nil {
+				t.Errorf("Set error: %+v", err)
+			}
+
+			if won {
+				mu.Lock()
+				winners = append(winners, cur)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(winners) != 1 {
+		t.Fatalf("expected one winner, got %v", winners)
+	}
+
+	won, cur, _ := flag.Set(100)
+	if won || cur != winners[0] {
+		t.Errorf("expected the first value %v, got %v", winners[0], cur)
+	}
+
+	flag.Clear()
+	if won, _, _ := flag.Set(100); !won {
+		t.Errorf("expected the cleared flag to be set again")
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import "time"
+
+// AddTagged will add a key, value, and expiration duration to the cache,
+// attaching tags that InvalidateTag can later remove the item by, e.g.
+// the database rows the item was derived from.
+func (t *Cache) AddTagged(key string, item interface{}, expiresIn time.Duration, tags ...string) error {
+	return t.Add(key, item, expiresIn, WithTags(tags...))
+}
+
+// WithTags will attach tags to the added item, see AddTagged
+func WithTags(tags ...string) AddOption {
+	return addOptionFunc(func(o *addOptions) {
+		o.tags = append(o.tags, tags...)
+	})
+}
+
+// InvalidateTag will delete every item carrying the tag
+// and return the number of items deleted. A deletion the
+// Store rejects is rolled back and reported to OnError.
+func (t *Cache) InvalidateTag(tag string) int {
+	var n int
+	err := t.write(func() error {
+		names := make([]string, 0, len(t.tags[tag]))
+		for name := range t.tags[tag] {
+			names = append(names, name)
+		}
+
+		for _, name := range names {
+			hashedKey := t.hash(name)
+			tx := t.beginStore(hashedKey, name)
+			if t.delete(hashedKey) != nil {
+				continue
+			}
+			n++
+
+			t.persist(tx, hashedKey, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.expirer.report(err)
+	}
+
+	return n
+}
+
+// tag will attach the tags to the item at the key.
+// The cache lock must be held.
+func (t *Cache) tag(key uint64, tags []string) {
+	idx, ok := t.keys[key]
+	if !ok || len(tags) == 0 {
+		return
+	}
+
+	if t.tags == nil {
+		t.tags = make(map[string]map[string]struct{})
+	}
+
+	slot := &t.slots[idx]
+	for _, tag := range tags {
+		if t.tags[tag] == nil {
+			t.tags[tag] = make(map[string]struct{})
+		}
+
+		if _, ok := t.tags[tag][slot.name]; !ok {
+			t.tags[tag][slot.name] = struct{}{}
+			slot.tags = append(slot.tags, tag)
+		}
+	}
+}
+
+// untag will remove the item in the slot from the tag index.
+// The cache lock must be held.
+func (t *Cache) untag(idx int) {
+	name := t.slots[idx].name
+	for _, tag := range t.slots[idx].tags {
+		delete(t.tags[tag], name)
+		if len(t.tags[tag]) == 0 {
+			delete(t.tags, tag)
+		}
+	}
+}
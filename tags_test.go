@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c := NewCache(&CacheConfig{})
+	defer c.Close()
+
+	c.AddTagged("user:1", "alice", time.Minute, "users", "row:1")
+	c.AddTagged("user:2", "bob", time.Minute, "users", "row:2")
+	c.AddTagged("profile:1", "alice's profile", time.Minute, "row:1")
+	c.Bucket("pages").Add("home", "page", time.Minute, WithTags("row:1"))
+	c.Add("untagged", "value", time.Minute)
+
+	n := c.InvalidateTag("row:1")
+	if n != 3 {
+		t.Errorf("expected 3 items to be invalidated, got %d", n)
+	}
+
+	for _, key := range []string{"user:1", "profile:1"} {
+		if _, err := c.Get(key); err != ErrDNE {
+			t.Errorf("expected %s to be invalidated, got %+v", key, err)
+		}
+	}
+
+	if _, err := c.Bucket("pages").Get("home"); err != ErrDNE {
+		t.Errorf("expected the bucket item to be invalidated, got %+v", err)
+	}
+
+	for _, key := range []string{"user:2", "untagged"} {
+		if _, err := c.Get(key); err != nil {
+			t.Errorf("expected %s to remain, got %+v", key, err)
+		}
+	}
+
+	n = c.InvalidateTag("row:1")
+	if n != 0 {
+		t.Errorf("expected nothing left to invalidate, got %d", n)
+	}
+
+	// deleted items leave the index, so a re-added key is not invalidated by old tags
+	c.Delete("user:2")
+	c.Add("user:2", "bob", time.Minute)
+
+	n = c.InvalidateTag("users")
+	if n != 0 {
+		t.Errorf("expected the re-added key not to carry its old tags, got %d", n)
+	}
+
+	if len(c.tags) != 0 {
+		t.Errorf("expected the tag index to be empty, got %v", c.tags)
+	}
+}
@@ -0,0 +1,100 @@
+// Package telemetry instruments a cache with metrics and traces. It
+// defines the small Provider interface rather than importing the
+// OpenTelemetry SDK, so that the cache stays free of dependencies:
+// a Provider is a thin adapter over an OpenTelemetry MeterProvider
+// and TracerProvider, or over any other metrics and tracing library.
+//
+// The instrumented cache counts hits, misses, evictions and expirations
+// on the counters "cache.hits", "cache.misses", "cache.evictions" and
+// "cache.expirations", and wraps each load by a bucket's Loader or a
+// refresher in a "cache.load" span.
+package telemetry
+
+import "github.com/JKhawaja/cache"
+
+// Names of the counters and the span
+const (
+	HitsCounter        = "cache.hits"
+	MissesCounter      = "cache.misses"
+	EvictionsCounter   = "cache.evictions"
+	ExpirationsCounter = "cache.expirations"
+	LoadSpan           = "cache.load"
+)
+
+// Provider creates the instruments of a cache, e.g. by adapting
+// an OpenTelemetry Meter and Tracer
+type Provider interface {
+	// Counter will return the counter by the name
+	Counter(name string) Counter
+	// StartSpan will start a span by the name with the attributes
+	StartSpan(name string, attributes map[string]string) Span
+}
+
+// Counter is a monotonic counter, such as an OpenTelemetry Int64Counter
+type Counter interface {
+	Add(n int64)
+}
+
+// Span is an operation being traced, such as an OpenTelemetry Span
+type Span interface {
+	// End will end the span, recording the error if it is not nil
+	End(err error)
+}
+
+// WithTelemetry will instrument the cache with the instruments
+// created by the provider
+func WithTelemetry(provider Provider) cache.Option {
+	return func(c *cache.CacheConfig) error {
+		if provider == nil {
+			return &cache.ConfigError{Field: "Observer", Reason: "is nil"}
+		}
+
+		c.Observer = NewObserver(provider)
+		return nil
+	}
+}
+
+// NewObserver will return a cache.Observer recording the
+// events of a cache with the instruments of the provider
+func NewObserver(provider Provider) cache.Observer {
+	return &observer{
+		provider:    provider,
+		hits:        provider.Counter(HitsCounter),
+		misses:      provider.Counter(MissesCounter),
+		evictions:   provider.Counter(EvictionsCounter),
+		expirations: provider.Counter(ExpirationsCounter),
+	}
+}
+
+type observer struct {
+	provider    Provider
+	hits        Counter
+	misses      Counter
+	evictions   Counter
+	expirations Counter
+}
+
+func (o *observer) Hit() {
+	o.hits.Add(1)
+}
+
+func (o *observer) Miss() {
+	o.misses.Add(1)
+}
+
+func (o *observer) Evict() {
+	o.evictions.Add(1)
+}
+
+func (o *observer) Expire() {
+	o.expirations.Add(1)
+}
+
+func (o *observer) Load(bucket, key string) func(err error) {
+	span := o.provider.StartSpan(LoadSpan, map[string]string{
+		"cache.bucket": bucket,
+		"cache.key":    key,
+	})
+
+	return span.End
+}
@@ -0,0 +1,114 @@
+package telemetry
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JKhawaja/cache"
+)
+
+// recorder is a Provider that keeps the counts and spans in memory
+type recorder struct {
+	counts map[string]int64
+	spans  []*span
+	mu     *sync.Mutex
+}
+
+type recorderCounter struct {
+	name     string
+	recorder *recorder
+}
+
+type span struct {
+	name       string
+	attributes map[string]string
+	err        error
+	ended      bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		counts: make(map[string]int64),
+		mu:     &sync.Mutex{},
+	}
+}
+
+func (r *recorder) Counter(name string) Counter {
+	return &recorderCounter{name: name, recorder: r}
+}
+
+func (r *recorder) StartSpan(name string, attributes map[string]string) Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &span{name: name, attributes: attributes}
+	r.spans = append(r.spans, s)
+	return s
+}
+
+func (r *recorder) count(name string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.counts[name]
+}
+
+func (c *recorderCounter) Add(n int64) {
+	c.recorder.mu.Lock()
+	c.recorder.counts[c.name] += n
+	c.recorder.mu.Unlock()
+}
+
+func (s *span) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+func TestWithTelemetry(t *testing.T) {
+	r := newRecorder()
+	c, err := cache.NewCacheWithOptions(
+		WithTelemetry(r),
+		cache.WithMaxEntries(2),
+	)
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer c.Close()
+
+	c.Add("a", 1, time.Minute)
+	c.Get("a")
+	c.Get("missing")
+	c.Add("b", 2, time.Minute)
+	c.Add("c", 3, time.Minute)
+
+	if r.count(HitsCounter) != 1 || r.count(MissesCounter) != 1 || r.count(EvictionsCounter) != 1 {
+		t.Errorf("unexpected counts %+v", r.counts)
+	}
+
+	errLoad := errors.New("load failed")
+	b := c.BucketWithConfig("users", &cache.BucketConfig{
+		Loader: func(key string) (interface{}, error) {
+			return nil, errLoad
+		},
+	})
+	b.GetOrLoad("42")
+
+	if len(r.spans) != 1 {
+		t.Fatalf("expected a span for the load, got %d", len(r.spans))
+	}
+
+	s := r.spans[0]
+	if s.name != LoadSpan || s.attributes["cache.bucket"] != "users" || s.attributes["cache.key"] != "42" {
+		t.Errorf("unexpected span %+v", s)
+	}
+
+	if !s.ended || s.err != errLoad {
+		t.Errorf("expected the span to end with the load error, got %+v", s)
+	}
+
+	if _, err := cache.NewCacheWithOptions(WithTelemetry(nil)); err == nil {
+		t.Errorf("expected a nil provider to be rejected")
+	}
+}
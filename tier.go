@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTierUnavailable is returned when an item is steered to a storage tier
+// that the cache has not been configured with
+var ErrTierUnavailable = errors.New("storage tier unavailable")
+
+// Tier is a storage tier that an item can be placed in
+type Tier int
+
+const (
+	// TierMemory keeps the item in the in-memory slots
+	TierMemory Tier = iota
+	// TierDisk places the item in the disk tier
+	TierDisk
+	// TierRemote places the item in the remote tier
+	TierRemote
+)
+
+// String returns the name of the tier
+func (t Tier) String() string {
+	switch t {
+	case TierMemory:
+		return "memory"
+	case TierDisk:
+		return "disk"
+	case TierRemote:
+		return "remote"
+	}
+
+	return "unknown"
+}
+
+// AddWithHint will add a key, value, and expiration duration to the cache,
+// placing the item directly in the given storage tier rather than leaving
+// placement to eviction. TierDisk writes the item to the spill log, to be
+// faulted into memory by the first Get, and is available when SpillDir is
+// set. The remote tier belongs to a TieredCache, see its SetWithHint.
+// ErrTierUnavailable is returned for a tier the cache does not have.
+func (t *Cache) AddWithHint(key string, item interface{}, expiresIn time.Duration, tier Tier) error {
+	return t.Add(key, item, expiresIn, WithTier(tier))
+}
+
+// place will add the item to the tier of the add options.
+// The lock must be held.
+func (t *Cache) place(key uint64, name string, item interface{}, expiresAt time.Time, o addOptions) error {
+	if o.tier != TierDisk {
+		return t.add(key, name, item, expiresAt)
+	}
+
+	if idx, ok := t.keys[key]; ok && t.expired(t.slots[idx], t.now()) {
+		t.dropExpired(idx)
+	}
+
+	if idx, ok := t.keys[key]; ok {
+		if t.slots[idx].name != name {
+			return t.collision()
+		} else if !t.slots[idx].deleted {
+			return ErrCollision
+		}
+		t.remove(idx)
+	}
+
+	if owner, ok := t.spill.owner(key); ok && owner != name {
+		return t.collision()
+	}
+
+	rec, ok, err := t.spill.get(name)
+	if err != nil {
+		return err
+	} else if ok && !t.now().After(rec.ExpiresAt) {
+		return ErrCollision
+	}
+
+	return t.spill.put(key, spillRecord{
+		Key:       name,
+		Item:      item,
+		ExpiresAt: expiresAt,
+		Meta:      o.meta,
+	})
+}
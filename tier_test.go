@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheAddWithHint(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.AddWithHint("key", "value", 10*time.Minute, TierMemory)
+	if err != nil {
+		t.Errorf("error while adding k/v to cache: %+v", err)
+	}
+
+	value, err := cache.Get("key")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "value" {
+		t.Errorf("returned value was %s", value)
+	}
+
+	err = cache.AddWithHint("cold", "value", 10*time.Minute, TierDisk)
+	if err != ErrTierUnavailable {
+		t.Errorf("should have returned ErrTierUnavailable but returned %+v", err)
+	}
+}
+
+func TestCacheAddWithHintDisk(t *testing.T) {
+	cache := NewCache(&CacheConfig{SpillDir: t.TempDir()})
+	defer cache.Close()
+
+	err := cache.AddWithHint("cold", "value", 10*time.Minute, TierDisk)
+	if err != nil {
+		t.Errorf("error while adding k/v to disk: %+v", err)
+	}
+
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Spilled != 1 {
+		t.Errorf("expected the item to be placed on disk alone, got %+v", stats)
+	}
+
+	if err := cache.AddWithHint("cold", "other", 10*time.Minute, TierDisk); err != ErrCollision {
+		t.Errorf("should have returned ErrCollision but returned %+v", err)
+	}
+
+	value, err := cache.Get("cold")
+	if err != nil || value != "value" {
+		t.Errorf("expected the item to be faulted in from disk, got %v: %+v", value, err)
+	}
+
+	if stats := cache.Stats(); stats.Entries != 1 || stats.Spilled != 0 || stats.Faults != 1 {
+		t.Errorf("expected the item to be moved into memory, got %+v", stats)
+	}
+
+	if err := cache.AddWithHint("remote", "value", 10*time.Minute, TierRemote); err != ErrTierUnavailable {
+		t.Errorf("should have returned ErrTierUnavailable but returned %+v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package cache
+
+import "time"
+
+// Backend is a remote store, such as Redis, memcached, or disk,
+// that a TieredCache falls back to when an item is not in memory.
+// Get and TTL return ErrDNE for keys that do not exist.
+type Backend interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration) error // a ttl of 0 never expires
+	Delete(key string) error
+	TTL(key string) (time.Duration, error) // 0 if the key never expires
+}
+
+// TieredCache checks an in-memory cache first and falls back to a remote
+// Backend, writing the values it fetches back to memory. Writes go to
+// both tiers.
+type TieredCache struct {
+	l1      *Cache
+	backend Backend
+	config  *TieredConfig
+}
+
+// TieredConfig is used to configure a tiered cache
+type TieredConfig struct {
+	MemoryTTL time.Duration // caps how long fetched values stay in memory, 0 uses the backend's ttl
+	Chunker   *Chunker      // splits large values for backends with item size limits
+}
+
+// NewTieredCache will create and return a pointer to a new TieredCache
+// object in front of the backend, using the cache as the memory tier.
+func NewTieredCache(l1 *Cache, backend Backend, config *TieredConfig) *TieredCache {
+	if config == nil {
+		config = &TieredConfig{}
+	}
+
+	return &TieredCache{
+		l1:      l1,
+		backend: backend,
+		config:  config,
+	}
+}
+
+// Get will return the value stored at the key, fetching it from the
+// backend and storing it in memory if it is not already there.
+// It will return an ErrDNE value if the key is in neither tier.
+func (t *TieredCache) Get(key string) ([]byte, error) {
+	item, err := t.l1.Get(key)
+	if err == nil {
+		if value, ok := item.([]byte); ok {
+			return value, nil
+		}
+	}
+
+	value, err := t.fetch(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := t.backend.TTL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.config.MemoryTTL > 0 && (ttl == 0 || ttl > t.config.MemoryTTL) {
+		ttl = t.config.MemoryTTL
+	}
+
+	return value, t.l1.Set(key, value, ttl)
+}
+
+// Set will store the value at the key in the backend and in memory
+func (t *TieredCache) Set(key string, value []byte, expiresIn time.Duration) error {
+	err := t.store(key, value, expiresIn)
+	if err != nil {
+		return err
+	}
+
+	ttl := expiresIn
+	if t.config.MemoryTTL > 0 && (ttl == 0 || ttl > t.config.MemoryTTL) {
+		ttl = t.config.MemoryTTL
+	}
+
+	return t.l1.Set(key, value, ttl)
+}
+
+// SetWithHint will store the value at the key in the given tier alone.
+// TierRemote writes it to the backend and drops any copy held in memory,
+// so that the next Get fetches it. TierMemory and TierDisk place it in
+// the memory tier's cache as its AddWithHint does, replacing the value
+// there, without writing it to the backend.
+func (t *TieredCache) SetWithHint(key string, value []byte, expiresIn time.Duration, tier Tier) error {
+	if tier == TierRemote {
+		err := t.store(key, value, expiresIn)
+		if err != nil {
+			return err
+		}
+
+		err = t.l1.Delete(key)
+		if err == ErrDNE {
+			return nil
+		}
+		return err
+	}
+
+	err := t.l1.Delete(key)
+	if err != nil && err != ErrDNE {
+		return err
+	}
+
+	ttl := expiresIn
+	if t.config.MemoryTTL > 0 && (ttl == 0 || ttl > t.config.MemoryTTL) {
+		ttl = t.config.MemoryTTL
+	}
+
+	return t.l1.AddWithHint(key, value, ttl, tier)
+}
+
+// Delete will remove the key from both tiers.
+// It will return ErrDNE if the key is in neither tier.
+func (t *TieredCache) Delete(key string) error {
+	l1Err := t.l1.Delete(key)
+
+	err := t.remove(key)
+	if err == ErrDNE && l1Err == nil {
+		return nil
+	}
+
+	return err
+}
+
+// fetch will get the value from the backend,
+// joining it from its chunks if it was split
+func (t *TieredCache) fetch(key string) ([]byte, error) {
+	head, err := t.backend.Get(key)
+	if err != nil || t.config.Chunker == nil {
+		return head, err
+	}
+
+	return t.config.Chunker.Join(key, head, t.backend.Get)
+}
+
+// store will set the value in the backend,
+// splitting it into chunks if a Chunker is configured
+func (t *TieredCache) store(key string, value []byte, expiresIn time.Duration) error {
+	if t.config.Chunker == nil {
+		return t.backend.Set(key, value, expiresIn)
+	}
+
+	chunks := t.config.Chunker.Split(key, value)
+
+	// the head is written last, so readers never find a manifest
+	// for chunks that have not been written yet
+	for i := len(chunks) - 1; i >= 0; i-- {
+		err := t.backend.Set(chunks[i].Key, chunks[i].Data, expiresIn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove will delete the value from the backend, along with its chunks
+func (t *TieredCache) remove(key string) error {
+	if t.config.Chunker != nil {
+		if head, err := t.backend.Get(key); err == nil {
+			for _, chunkKey := range t.config.Chunker.Keys(key, head) {
+				t.backend.Delete(chunkKey)
+			}
+		}
+	}
+
+	return t.backend.Delete(key)
+}
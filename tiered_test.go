@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapBackend is an in-memory Backend that counts its gets
+type mapBackend struct {
+	values  map[string][]byte
+	expires map[string]time.Time
+	gets    int
+	mu      *sync.Mutex
+}
+
+func newMapBackend() *mapBackend {
+	return &mapBackend{
+		values:  make(map[string][]byte),
+		expires: make(map[string]time.Time),
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (m *mapBackend) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gets++
+	value, ok := m.values[key]
+	if !ok {
+		return nil, ErrDNE
+	}
+
+	return value, nil
+}
+
+func (m *mapBackend) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = value
+	if ttl > 0 {
+		m.expires[key] = time.Now().Add(ttl)
+	} else {
+		delete(m.expires, key)
+	}
+
+	return nil
+}
+
+func (m *mapBackend) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.values[key]; !ok {
+		return ErrDNE
+	}
+	delete(m.values, key)
+	delete(m.expires, key)
+
+	return nil
+}
+
+func (m *mapBackend) TTL(key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.values[key]; !ok {
+		return 0, ErrDNE
+	}
+
+	if expiresAt, ok := m.expires[key]; ok {
+		return time.Until(expiresAt), nil
+	}
+
+	return 0, nil
+}
+
+func TestTieredCache(t *testing.T) {
+	backend := newMapBackend()
+	l1 := NewCache(nil)
+	tiered := NewTieredCache(l1, backend, &TieredConfig{
+		MemoryTTL: time.Minute,
+	})
+
+	backend.Set("remote", []byte("value"), time.Hour)
+
+	for i := 0; i < 3; i++ {
+		value, err := tiered.Get("remote")
+		if err != nil || string(value) != "value" {
+			t.Errorf("unexpected value %q: %+v", value, err)
+		}
+	}
+
+	if backend.gets != 1 {
+		t.Errorf("backend was read %d times", backend.gets)
+	}
+
+	if ttl, err := l1.TTL("remote"); err != nil || ttl > time.Minute {
+		t.Errorf("memory ttl was not capped: %v %+v", ttl, err)
+	}
+
+	err := tiered.Set("local", []byte("written"), time.Hour)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	if value, err := backend.Get("local"); err != nil || string(value) != "written" {
+		t.Errorf("value was not written to the backend: %q %+v", value, err)
+	}
+
+	err = tiered.Delete("local")
+	if err != nil {
+		t.Errorf("error deleting key: %+v", err)
+	}
+
+	if _, err := tiered.Get("local"); err != ErrDNE {
+		t.Errorf("deleted key was returned: %+v", err)
+	}
+
+	if err := tiered.Delete("missing"); err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+}
+
+func TestTieredCacheSetWithHint(t *testing.T) {
+	backend := newMapBackend()
+	l1 := NewCache(&CacheConfig{SpillDir: t.TempDir()})
+	defer l1.Close()
+	tiered := NewTieredCache(l1, backend, nil)
+
+	tiered.Set("key", []byte("old"), time.Hour)
+	err := tiered.SetWithHint("key", []byte("remote"), time.Hour, TierRemote)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	if l1.Contains("key") {
+		t.Errorf("expected the old value to be dropped from memory")
+	}
+
+	if value, err := tiered.Get("key"); err != nil || string(value) != "remote" {
+		t.Errorf("expected the value to be fetched from the backend, got %q: %+v", value, err)
+	}
+
+	err = tiered.SetWithHint("cold", []byte("disk"), time.Hour, TierDisk)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	if _, err := backend.Get("cold"); err != ErrDNE {
+		t.Errorf("expected the value to be kept out of the backend, got %+v", err)
+	}
+
+	if stats := l1.Stats(); stats.Spilled != 1 {
+		t.Errorf("expected the value to be placed on disk, got %+v", stats)
+	}
+
+	if value, err := tiered.Get("cold"); err != nil || string(value) != "disk" {
+		t.Errorf("unexpected value %q: %+v", value, err)
+	}
+}
+
+func TestTieredCacheChunks(t *testing.T) {
+	backend := newMapBackend()
+	tiered := NewTieredCache(NewCache(nil), backend, &TieredConfig{
+		Chunker: &Chunker{Size: 32},
+	})
+
+	value := bytes.Repeat([]byte("0123456789"), 10)
+	err := tiered.Set("big", value, time.Hour)
+	if err != nil {
+		t.Errorf("error setting key: %+v", err)
+	}
+
+	if len(backend.values) != 5 {
+		t.Errorf("value was stored in %d backend keys", len(backend.values))
+	}
+
+	fresh := NewTieredCache(NewCache(nil), backend, &TieredConfig{
+		Chunker: &Chunker{Size: 32},
+	})
+
+	got, err := fresh.Get("big")
+	if err != nil || !bytes.Equal(got, value) {
+		t.Errorf("chunked value was not joined: %q %+v", got, err)
+	}
+
+	err = fresh.Delete("big")
+	if err != nil {
+		t.Errorf("error deleting key: %+v", err)
+	}
+
+	if len(backend.values) != 0 {
+		t.Errorf("%d chunks were left in the backend", len(backend.values))
+	}
+}
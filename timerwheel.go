@@ -0,0 +1,302 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelMask   = wheelSlots - 1
+	wheelLevels = 4
+)
+
+var (
+	defaultWheelTick = 1 * time.Second
+
+	sharedWheel     *TimerWheel
+	sharedWheelOnce = &sync.Once{}
+)
+
+// TimerWheelConfig is used to configure a TimerWheel
+type TimerWheelConfig struct {
+	Tick  time.Duration // resolution of the timers, defaults to 1 second
+	Clock Clock         // source of time for the timers, defaults to the system clock
+}
+
+// TimerWheel is a hierarchical timer wheel running the timers of any
+// number of caches from one goroutine. A cache with a TimerWheel runs
+// its clean cycles as timers of the wheel instead of starting a cleaner
+// goroutine of its own, so an application creating many caches does not
+// create a goroutine and a sleeping timer for each. The wheel only wakes
+// when a timer is due or its lowest level wraps, at most once per Tick.
+//
+// Each of the 4 levels of the wheel has 64 slots, the slots of a level
+// spanning 64 times the time of those below it. Timers further away
+// than the wheel spans, about 194 days with a 1 second Tick, are held
+// in its top level until they come within range.
+type TimerWheel struct {
+	config   *TimerWheelConfig
+	start    time.Time
+	tick     uint64 // ticks since start that have been run
+	slots    [wheelLevels][wheelSlots][]*wheelTimer
+	count    int
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce *sync.Once
+	mu       *sync.Mutex
+}
+
+type wheelTimer struct {
+	wheel *TimerWheel
+	at    uint64 // tick the timer is due at
+	fn    func()
+	level int
+	slot  int
+}
+
+// SharedTimerWheel will return the process-wide TimerWheel
+// on the system clock, starting it on first use
+func SharedTimerWheel() *TimerWheel {
+	sharedWheelOnce.Do(func() {
+		sharedWheel = NewTimerWheel(nil)
+	})
+
+	return sharedWheel
+}
+
+// NewTimerWheel will create a TimerWheel and start its goroutine
+func NewTimerWheel(config *TimerWheelConfig) *TimerWheel {
+	if config == nil {
+		config = &TimerWheelConfig{}
+	}
+
+	if config.Tick <= 0 {
+		config.Tick = defaultWheelTick
+	}
+
+	if config.Clock == nil {
+		config.Clock = systemClock{}
+	}
+
+	w := &TimerWheel{
+		config:   config,
+		start:    config.Clock.Now(),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		mu:       &sync.Mutex{},
+	}
+	go w.run()
+
+	return w
+}
+
+// AfterFunc will call f from the wheel's goroutine once d has passed,
+// rounded up to the wheel's Tick. The wheel runs one timer at a time,
+// so f should return quickly.
+func (w *TimerWheel) AfterFunc(d time.Duration, f func()) Timer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ticks := uint64((d + w.config.Tick - 1) / w.config.Tick)
+	at := w.elapsed() + ticks
+	if at <= w.tick {
+		at = w.tick + 1
+	}
+
+	t := &wheelTimer{wheel: w, at: at, fn: f}
+	w.insert(t)
+	w.count++
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+
+	return t
+}
+
+// Len will return the number of timers that have not run
+func (w *TimerWheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.count
+}
+
+// Stop will stop the wheel's goroutine, after which no timers run
+func (w *TimerWheel) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// Stop will remove the timer from the wheel, returning
+// false if it has already run or been stopped
+func (t *wheelTimer) Stop() bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slot := w.slots[t.level][t.slot]
+	for i, pending := range slot {
+		if pending == t {
+			w.slots[t.level][t.slot] = append(slot[:i], slot[i+1:]...)
+			w.count--
+			return true
+		}
+	}
+
+	return false
+}
+
+// elapsed will return the ticks since the wheel started. The lock must be held.
+func (w *TimerWheel) elapsed() uint64 {
+	d := w.config.Clock.Now().Sub(w.start)
+	if d < 0 {
+		return 0
+	}
+
+	return uint64(d / w.config.Tick)
+}
+
+// insert will place the timer in the level spanning the time until
+// it is due, in the slot of its tick. The lock must be held.
+func (w *TimerWheel) insert(t *wheelTimer) {
+	delta := t.at - w.tick
+	for level := 0; level < wheelLevels; level++ {
+		if delta < 1<<(wheelBits*(level+1)) {
+			t.level = level
+			t.slot = int(t.at>>(wheelBits*level)) & wheelMask
+			w.slots[t.level][t.slot] = append(w.slots[t.level][t.slot], t)
+			return
+		}
+	}
+
+	// beyond the span of the wheel, the timer is held in the slot of the
+	// top level cascaded last, which is cascaded again after a full turn
+	t.level = wheelLevels - 1
+	t.slot = int(w.tick>>(wheelBits*t.level)) & wheelMask
+	w.slots[t.level][t.slot] = append(w.slots[t.level][t.slot], t)
+}
+
+// next will return the ticks until the wheel next has work to do, when
+// a slot of the lowest level holds timers or the level wraps and the
+// levels above it are cascaded. The lock must be held.
+func (w *TimerWheel) next() uint64 {
+	for i := uint64(1); i < wheelSlots; i++ {
+		tick := w.tick + i
+		if tick&wheelMask == 0 || len(w.slots[0][tick&wheelMask]) > 0 {
+			return i
+		}
+	}
+
+	return wheelSlots
+}
+
+func (w *TimerWheel) run() {
+	fire := make(chan struct{}, 1)
+	for {
+		var timer Timer
+		w.mu.Lock()
+		if w.count > 0 {
+			deadline := w.start.Add(time.Duration(w.tick+w.next()) * w.config.Tick)
+			d := deadline.Sub(w.config.Clock.Now())
+			if d <= 0 {
+				w.mu.Unlock()
+				w.advance()
+				continue
+			}
+
+			timer = w.config.Clock.AfterFunc(d, func() {
+				select {
+				case fire <- struct{}{}:
+				default:
+				}
+			})
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-w.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-fire:
+			w.advance()
+		}
+	}
+}
+
+// advance will run the ticks up to the current time, cascading
+// the timers of the upper levels into the lower ones as they come
+// within range and running the timers that are due
+func (w *TimerWheel) advance() {
+	w.mu.Lock()
+	var due []*wheelTimer
+	for now := w.elapsed(); w.tick < now; {
+		if w.count == len(due) {
+			w.tick = now
+			break
+		}
+
+		w.tick++
+		for level := wheelLevels - 1; level > 0; level-- {
+			if w.tick&(1<<(wheelBits*level)-1) != 0 {
+				continue
+			}
+
+			slot := int(w.tick>>(wheelBits*level)) & wheelMask
+			cascaded := w.slots[level][slot]
+			w.slots[level][slot] = nil
+			for _, t := range cascaded {
+				w.insert(t)
+			}
+		}
+
+		slot := int(w.tick & wheelMask)
+		due = append(due, w.slots[0][slot]...)
+		w.slots[0][slot] = nil
+	}
+	w.count -= len(due)
+	w.mu.Unlock()
+
+	for _, t := range due {
+		t.fn()
+	}
+}
+
+// scheduleClean will run a clean cycle on the cache's TimerWheel
+// once CleanDuration has passed, and again after each cycle until
+// the cache is closed
+func (t *Cache) scheduleClean() {
+	t.cleanMu.Lock()
+	defer t.cleanMu.Unlock()
+
+	if t.closed() {
+		return
+	}
+
+	t.cleanTimer = t.config.TimerWheel.AfterFunc(t.config.CleanDuration, func() {
+		t.cleanCycle()
+		t.scheduleClean()
+	})
+}
+
+// stopClean will remove the pending clean cycle from the TimerWheel
+func (t *Cache) stopClean() {
+	t.cleanMu.Lock()
+	defer t.cleanMu.Unlock()
+
+	if t.cleanTimer != nil {
+		t.cleanTimer.Stop()
+		t.cleanTimer = nil
+	}
+}
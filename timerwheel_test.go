@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimerWheel(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	w := NewTimerWheel(&TimerWheelConfig{Tick: time.Second, Clock: clock})
+	defer w.Stop()
+
+	var mu sync.Mutex
+	var fired []string
+	fire := func(name string) func() {
+		return func() {
+			mu.Lock()
+			fired = append(fired, name)
+			mu.Unlock()
+		}
+	}
+	firedCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(fired)
+	}
+
+	w.AfterFunc(5*time.Second, fire("seconds"))
+	w.AfterFunc(100*time.Second, fire("minutes"))
+	w.AfterFunc(5000*time.Second, fire("hours"))
+	stopped := w.AfterFunc(10*time.Second, fire("stopped"))
+
+	if !stopped.Stop() || stopped.Stop() {
+		t.Errorf("expected the timer to be stopped once")
+	}
+
+	clock.Advance(5 * time.Second)
+	if !waitFor(func() bool { return firedCount() == 1 }) {
+		t.Fatalf("expected the first timer to fire, got %v", fired)
+	}
+
+	clock.Advance(94 * time.Second)
+	clock.Advance(time.Second)
+	if !waitFor(func() bool { return firedCount() == 2 }) {
+		t.Fatalf("expected the timer of the second level to fire, got %v", fired)
+	}
+
+	clock.Advance(4899 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if firedCount() != 2 {
+		t.Errorf("expected the timer of the third level not to fire early, got %v", fired)
+	}
+
+	clock.Advance(time.Second)
+	if !waitFor(func() bool { return firedCount() == 3 }) {
+		t.Fatalf("expected the timer of the third level to fire, got %v", fired)
+	}
+
+	mu.Lock()
+	if fired[0] != "seconds" || fired[1] != "minutes" || fired[2] != "hours" {
+		t.Errorf("expected the timers in order, got %v", fired)
+	}
+	mu.Unlock()
+
+	if w.Len() != 0 {
+		t.Errorf("expected no pending timers, got %d", w.Len())
+	}
+}
+
+func TestTimerWheelCleaner(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	w := NewTimerWheel(&TimerWheelConfig{Tick: time.Second, Clock: clock})
+	defer w.Stop()
+
+	var caches []*Cache
+	for i := 0; i < 10; i++ {
+		cache := NewCache(&CacheConfig{Clock: clock, CleanDuration: 10 * time.Second, TimerWheel: w})
+		cache.Add("key", "value", 5*time.Second)
+		caches = append(caches, cache)
+	}
+
+	if w.Len() != 10 {
+		t.Errorf("expected a clean cycle of each cache on the wheel, got %d", w.Len())
+	}
+
+	if !waitFor(func() bool { return clock.Waiters() == 1 }) {
+		t.Errorf("expected only the wheel to wait on the clock, got %d", clock.Waiters())
+	}
+
+	clock.Advance(10 * time.Second)
+	for _, cache := range caches {
+		if !waitFor(func() bool { return cache.Stats().Entries == 0 }) {
+			t.Fatalf("expected the wheel to clean every cache")
+		}
+	}
+
+	if !waitFor(func() bool { return w.Len() == 10 }) {
+		t.Errorf("expected the next clean cycles to be scheduled, got %d", w.Len())
+	}
+
+	for _, cache := range caches {
+		cache.Close()
+	}
+
+	if w.Len() != 0 {
+		t.Errorf("expected Close to remove the clean cycles, got %d", w.Len())
+	}
+}
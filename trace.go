@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/maphash"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// traceMagic begins a trace written by a TraceRecorder
+const traceMagic = "CACHETRC"
+
+// traceRecordSize is the size of an encoded TraceEvent: its time, op,
+// key, size and ttl
+const traceRecordSize = 8 + 1 + 8 + 8 + 8
+
+// ErrNotTrace is returned when reading a file that is not a trace
+var ErrNotTrace = errors.New("not a trace")
+
+// TraceOp is the operation of a TraceEvent
+type TraceOp uint8
+
+const (
+	// TraceGet is a get of a key
+	TraceGet TraceOp = iota + 1
+	// TraceAdd is an item added to the cache
+	TraceAdd
+	// TraceDelete is a key deleted from the cache
+	TraceDelete
+)
+
+func (o TraceOp) String() string {
+	switch o {
+	case TraceGet:
+		return "get"
+	case TraceAdd:
+		return "add"
+	case TraceDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// TraceEvent is an operation on a cache recorded by a TraceRecorder.
+// The key is hashed under a seed chosen by the recorder, so the keys
+// of a trace can be told apart but not recovered.
+type TraceEvent struct {
+	Time time.Time
+	Op   TraceOp
+	Key  uint64
+	Size int64         // size of an added item
+	TTL  time.Duration // expiration an item was added with
+}
+
+// TraceRecorder records the operations on the caches it is set as the
+// Trace of, either to a ring buffer of the latest events or to a writer.
+// It is safe for concurrent use.
+type TraceRecorder struct {
+	seed   maphash.Seed
+	ring   []TraceEvent
+	next   int
+	full   bool
+	w      *bufio.Writer
+	header bool
+	err    error
+	mu     *sync.Mutex
+}
+
+// NewTraceRecorder will create a TraceRecorder keeping
+// the latest size events in memory for Events
+func NewTraceRecorder(size int) *TraceRecorder {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &TraceRecorder{
+		seed: maphash.MakeSeed(),
+		ring: make([]TraceEvent, size),
+		mu:   &sync.Mutex{},
+	}
+}
+
+// NewTraceWriter will create a TraceRecorder writing every event to w,
+// to be read back by ReadTrace. Events are buffered until Flush.
+func NewTraceWriter(w io.Writer) *TraceRecorder {
+	return &TraceRecorder{
+		seed: maphash.MakeSeed(),
+		w:    bufio.NewWriter(w),
+		mu:   &sync.Mutex{},
+	}
+}
+
+// Events will return the events held in memory, oldest first.
+// It returns nil for a recorder writing its events to a writer.
+func (r *TraceRecorder) Events() []TraceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ring == nil {
+		return nil
+	}
+
+	if !r.full {
+		return append([]TraceEvent(nil), r.ring[:r.next]...)
+	}
+
+	events := make([]TraceEvent, 0, len(r.ring))
+	events = append(events, r.ring[r.next:]...)
+	return append(events, r.ring[:r.next]...)
+}
+
+// Flush will write any buffered events, returning
+// the first error from writing the trace
+func (r *TraceRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.w == nil {
+		return nil
+	}
+
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+
+	return r.err
+}
+
+// record will hash the name and record the event
+func (r *TraceRecorder) record(op TraceOp, name string, now time.Time, size int64, ttl time.Duration) {
+	var h maphash.Hash
+	h.SetSeed(r.seed)
+	h.WriteString(name)
+
+	e := TraceEvent{Time: now, Op: op, Key: h.Sum64(), Size: size, TTL: ttl}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ring != nil {
+		r.ring[r.next] = e
+		r.next = (r.next + 1) % len(r.ring)
+		r.full = r.full || r.next == 0
+		return
+	}
+
+	if r.err != nil {
+		return
+	}
+
+	if !r.header {
+		_, r.err = r.w.WriteString(traceMagic)
+		r.header = true
+	}
+
+	if r.err == nil {
+		_, r.err = r.w.Write(encodeTraceEvent(e))
+	}
+}
+
+// WriteTrace will write the events to w in the format read by ReadTrace
+func WriteTrace(w io.Writer, events []TraceEvent) error {
+	bw := bufio.NewWriter(w)
+	_, err := bw.WriteString(traceMagic)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		_, err = bw.Write(encodeTraceEvent(e))
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadTrace will read the events written by a TraceRecorder or WriteTrace.
+// It returns ErrNotTrace if r does not hold a trace and ErrCorrupt if
+// the trace ends within an event.
+func ReadTrace(r io.Reader) ([]TraceEvent, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(traceMagic))
+	_, err := io.ReadFull(br, magic)
+	if err != nil || string(magic) != traceMagic {
+		return nil, ErrNotTrace
+	}
+
+	var events []TraceEvent
+	buf := make([]byte, traceRecordSize)
+	for {
+		_, err := io.ReadFull(br, buf)
+		if err == io.EOF {
+			return events, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return events, ErrCorrupt
+		} else if err != nil {
+			return events, err
+		}
+
+		events = append(events, decodeTraceEvent(buf))
+	}
+}
+
+func encodeTraceEvent(e TraceEvent) []byte {
+	buf := make([]byte, traceRecordSize)
+	binary.BigEndian.PutUint64(buf[0:], uint64(e.Time.UnixNano()))
+	buf[8] = byte(e.Op)
+	binary.BigEndian.PutUint64(buf[9:], e.Key)
+	binary.BigEndian.PutUint64(buf[17:], uint64(e.Size))
+	binary.BigEndian.PutUint64(buf[25:], uint64(e.TTL))
+	return buf
+}
+
+func decodeTraceEvent(buf []byte) TraceEvent {
+	return TraceEvent{
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(buf[0:]))).UTC(),
+		Op:   TraceOp(buf[8]),
+		Key:  binary.BigEndian.Uint64(buf[9:]),
+		Size: int64(binary.BigEndian.Uint64(buf[17:])),
+		TTL:  time.Duration(binary.BigEndian.Uint64(buf[25:])),
+	}
+}
+
+// ReplayResult is the outcome of replaying a trace
+type ReplayResult struct {
+	Gets   uint64
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the fraction of gets that found the key
+func (r ReplayResult) HitRate() float64 {
+	if r.Gets == 0 {
+		return 0
+	}
+
+	return float64(r.Hits) / float64(r.Gets)
+}
+
+// Replay will apply the events of a trace to the cache, so that
+// a recorded workload can be tried against other configurations.
+// Items are added as byte slices of their recorded size. If the
+// cache's Clock is a ManualClock it is advanced by the time between
+// events, so that items expire as they did when the trace was recorded.
+func Replay(c *Cache, events []TraceEvent) ReplayResult {
+	clock, _ := c.config.Clock.(*ManualClock)
+
+	var result ReplayResult
+	var last time.Time
+	for _, e := range events {
+		if clock != nil && !last.IsZero() && e.Time.After(last) {
+			clock.Advance(e.Time.Sub(last))
+		}
+		last = e.Time
+
+		key := traceKey(e.Key)
+		switch e.Op {
+		case TraceGet:
+			result.Gets++
+			if _, err := c.Get(key); err == nil {
+				result.Hits++
+			} else {
+				result.Misses++
+			}
+		case TraceAdd:
+			c.Add(key, make([]byte, e.Size), e.TTL)
+		case TraceDelete:
+			c.Delete(key)
+		}
+	}
+
+	return result
+}
+
+// traceKey will return the key an item of a trace is replayed under
+func traceKey(key uint64) string {
+	return strconv.FormatUint(key, 16)
+}
+
+// trace will record the operation on the item at the name,
+// if the cache has a Trace
+func (t *Cache) trace(op TraceOp, name string, size int64, ttl time.Duration) {
+	if t.config.Trace == nil {
+		return
+	}
+
+	t.config.Trace.record(op, name, t.now(), size, ttl)
+}
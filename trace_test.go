@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	trace := NewTraceRecorder(3)
+	cache := NewCache(&CacheConfig{Clock: clock, Trace: trace})
+	defer cache.Close()
+
+	cache.Add("key", "value", time.Minute)
+	clock.Advance(time.Second)
+	cache.Get("key")
+	cache.Bucket("bucket").Get("key")
+	cache.Delete("key")
+
+	events := trace.Events()
+	if len(events) != 3 {
+		t.Fatalf("expected the latest 3 events, got %v", events)
+	}
+
+	if events[0].Op != TraceGet || events[1].Op != TraceGet || events[2].Op != TraceDelete {
+		t.Errorf("expected the events in order, got %v", events)
+	}
+
+	if events[0].Key != events[2].Key || events[0].Key == events[1].Key {
+		t.Errorf("expected the same keys to hash alike, got %v", events)
+	}
+
+	if !events[0].Time.Equal(clock.Now()) {
+		t.Errorf("expected the time of the event, got %v", events[0].Time)
+	}
+
+	var buf bytes.Buffer
+	w := NewTraceWriter(&buf)
+	cache = NewCache(&CacheConfig{Clock: clock, Trace: w, MaxBytes: 1 << 20})
+	defer cache.Close()
+
+	cache.Add("key", "value", time.Minute)
+	cache.Get("key")
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush error: %+v", err)
+	}
+
+	events, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrace error: %+v", err)
+	}
+
+	if len(events) != 2 || events[0].Op != TraceAdd || events[0].Size != 5 || events[0].TTL != time.Minute {
+		t.Errorf("expected the written events, got %v", events)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("key")) {
+		t.Errorf("expected the trace not to hold the keys")
+	}
+
+	if _, err := ReadTrace(bytes.NewReader([]byte("not a trace"))); err != ErrNotTrace {
+		t.Errorf("expected ErrNotTrace, got %+v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	trace := NewTraceRecorder(1000)
+	cache := NewCache(&CacheConfig{Clock: clock, Trace: trace})
+	defer cache.Close()
+
+	// a loop over 4 keys, with each key read twice after it is added
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i%4)
+		if _, err := cache.Get(key); err == ErrDNE {
+			cache.Add(key, "value", time.Minute)
+		}
+		cache.Get(key)
+		clock.Advance(time.Second)
+	}
+
+	var buf bytes.Buffer
+	err := WriteTrace(&buf, trace.Events())
+	if err != nil {
+		t.Fatalf("WriteTrace error: %+v", err)
+	}
+
+	events, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrace error: %+v", err)
+	}
+
+	large := NewCache(&CacheConfig{Clock: NewManualClock(start)})
+	defer large.Close()
+	result := Replay(large, events)
+	if result.Gets != 40 || result.Hits != 36 {
+		t.Errorf("expected only the first reads of each key to miss, got %+v", result)
+	}
+
+	small := NewCache(&CacheConfig{Clock: NewManualClock(start), MaxEntries: 2})
+	defer small.Close()
+	result = Replay(small, events)
+	if result.Hits != 20 || result.HitRate() != 0.5 {
+		t.Errorf("expected the loop to miss every first read, got %+v", result)
+	}
+}
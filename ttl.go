@@ -0,0 +1,34 @@
+package cache
+
+import "time"
+
+// Sentinel expiration durations accepted wherever an item is added
+const (
+	// DefaultExpiration expires an item after the DefaultTTL of its
+	// bucket or cache, or never if neither has one
+	DefaultExpiration time.Duration = 0
+	// NoExpiration never expires an item, regardless of the
+	// DefaultTTL of its bucket or cache
+	NoExpiration time.Duration = -1
+)
+
+// ttl will resolve DefaultExpiration to the cache's DefaultTTL.
+// An item without a default ttl never expires.
+func (t *Cache) ttl(expiresIn time.Duration) time.Duration {
+	if expiresIn == DefaultExpiration {
+		return t.config.DefaultTTL
+	}
+
+	return expiresIn
+}
+
+// ttl will resolve DefaultExpiration to the bucket's DefaultTTL,
+// falling back to the DefaultTTL of the cache.
+// The cache lock must be held by the caller.
+func (b *Bucket) ttl(expiresIn time.Duration) time.Duration {
+	if expiresIn == DefaultExpiration && b.config.DefaultTTL != 0 {
+		return b.config.DefaultTTL
+	}
+
+	return b.cache.ttl(expiresIn)
+}
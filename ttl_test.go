@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultTTL(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache, err := NewCacheWithOptions(WithClock(clock), WithDefaultTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	cache.Add("default", 1, 0)
+	cache.Add("never", 2, NoExpiration)
+	cache.Add("explicit", 3, time.Hour)
+
+	expected := map[string]time.Duration{
+		"default":  time.Minute,
+		"never":    0,
+		"explicit": time.Hour,
+	}
+	for key, ttl := range expected {
+		got, err := cache.TTL(key)
+		if err != nil {
+			t.Errorf("TTL error for %s: %+v", key, err)
+		}
+
+		if got != ttl {
+			t.Errorf("expected %s to have a ttl of %v, got %v", key, ttl, got)
+		}
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := cache.Get("default"); err != ErrDNE {
+		t.Errorf("expected the item to expire after the default ttl, got %+v", err)
+	}
+
+	if _, err := cache.Get("never"); err != nil {
+		t.Errorf("expected the item to never expire, got %+v", err)
+	}
+}
+
+func TestBucketDefaultTTL(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache, err := NewCacheWithOptions(WithClock(clock), WithDefaultTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	inherited := cache.Bucket("inherited")
+	own := cache.BucketWithConfig("own", &BucketConfig{DefaultTTL: time.Hour})
+
+	inherited.Add("key", 1, 0)
+	own.Add("key", 2, 0)
+	own.Add("never", 3, NoExpiration)
+
+	clock.Advance(2 * time.Minute)
+	if _, err := inherited.Get("key"); err != ErrDNE {
+		t.Errorf("expected the item to expire after the cache's default ttl, got %+v", err)
+	}
+
+	if _, err := own.Get("key"); err != nil {
+		t.Errorf("expected the bucket's default ttl to be used, got %+v", err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if _, err := own.Get("key"); err != ErrDNE {
+		t.Errorf("expected the item to expire after the bucket's default ttl, got %+v", err)
+	}
+
+	if _, err := own.Get("never"); err != nil {
+		t.Errorf("expected the item to never expire, got %+v", err)
+	}
+}
+
+func TestBucketZeroTTL(t *testing.T) {
+	cache := NewCache(nil)
+	defer cache.Close()
+
+	b := cache.Bucket("bucket")
+	b.Add("key", 1, 0)
+	if _, err := b.Get("key"); err != nil {
+		t.Errorf("expected an item added with a ttl of 0 to never expire, got %+v", err)
+	}
+}
+
+func TestExpirationSentinels(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache, err := NewCacheWithOptions(WithClock(clock), WithDefaultTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	b := cache.BucketWithConfig("loaded", &BucketConfig{
+		Loader: func(key string) (interface{}, error) {
+			return key, nil
+		},
+	})
+
+	cache.Set("set-default", 1, DefaultExpiration)
+	cache.Set("set-never", 2, NoExpiration)
+	cache.AddNX("addnx-default", 3, DefaultExpiration)
+	cache.AddNX("addnx-never", 4, NoExpiration)
+	cache.WithLock(func(tx *Txn) {
+		tx.Add("txn-default", 5, DefaultExpiration)
+		tx.Set("txn-never", 6, NoExpiration)
+	})
+	b.GetOrLoad("load-default")
+
+	clock.Advance(2 * time.Minute)
+	for _, key := range []string{"set-default", "addnx-default", "txn-default"} {
+		if _, err := cache.Get(key); err != ErrDNE {
+			t.Errorf("expected %s to expire after the default ttl, got %+v", key, err)
+		}
+	}
+
+	for _, key := range []string{"set-never", "addnx-never", "txn-never"} {
+		if _, err := cache.Get(key); err != nil {
+			t.Errorf("expected %s to never expire, got %+v", key, err)
+		}
+	}
+
+	if _, err := b.Get("load-default"); err != ErrDNE {
+		t.Errorf("expected the loaded item to expire after the default ttl, got %+v", err)
+	}
+}
+
+func TestTouchSentinels(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache, err := NewCacheWithOptions(WithClock(clock), WithDefaultTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewCacheWithOptions error: %+v", err)
+	}
+	defer cache.Close()
+
+	b := cache.BucketWithConfig("bucket", &BucketConfig{DefaultTTL: time.Hour})
+	for _, key := range []string{"touch", "get", "txn"} {
+		cache.Add(key+"-default", 1, time.Second)
+		cache.Add(key+"-never", 2, time.Second)
+	}
+	b.Add("default", 3, time.Second)
+	b.Add("never", 4, time.Second)
+
+	touches := []error{
+		cache.Touch("touch-default", DefaultExpiration),
+		cache.Touch("touch-never", NoExpiration),
+		b.Touch("default", DefaultExpiration),
+		b.Touch("never", NoExpiration),
+		cache.Txn(func(tx *Txn) error {
+			err := tx.Touch("txn-default", DefaultExpiration)
+			if err != nil {
+				return err
+			}
+			return tx.Touch("txn-never", NoExpiration)
+		}),
+	}
+	_, err = cache.GetAndTouch("get-default", DefaultExpiration)
+	touches = append(touches, err)
+	_, err = cache.GetAndTouch("get-never", NoExpiration)
+	touches = append(touches, err)
+
+	for i, err := range touches {
+		if err != nil {
+			t.Errorf("touch %d error: %+v", i, err)
+		}
+	}
+
+	expected := map[string]time.Duration{
+		"touch-default":  time.Minute,
+		"touch-never":    0,
+		"get-default":    time.Minute,
+		"get-never":      0,
+		"txn-default":    time.Minute,
+		"txn-never":      0,
+		b.key("default"): time.Hour,
+		b.key("never"):   0,
+	}
+	for key, ttl := range expected {
+		got, err := cache.TTL(key)
+		if err != nil {
+			t.Errorf("TTL error for %s: %+v", key, err)
+		}
+
+		if got != ttl {
+			t.Errorf("expected %s to have a ttl of %v after touching, got %v", key, ttl, got)
+		}
+	}
+}
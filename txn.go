@@ -0,0 +1,267 @@
+package cache
+
+import "time"
+
+// Txn gives access to the cache while the cache lock is held,
+// so that several operations can be performed atomically.
+// A Txn must not be used after the function it was passed to returns.
+type Txn struct {
+	cache  *Cache
+	undo   []undoRecord
+	shadow *Txn // records the changes mirrored to the shadow cache
+}
+
+// undoRecord is the state of a key before a transaction changed it:
+// the whole slot, with its tags, metadata, pin, priority and version,
+// and the keys it depended on and that depended on it
+type undoRecord struct {
+	name      string
+	existed   bool
+	slot      Slot
+	parents   []string
+	children  []string
+	refresher func()
+	reload    *reload
+	parent    string // key whose change may remove it, if it was saved as a dependent
+}
+
+// Txn will call fn with a transaction while holding the cache lock.
+// If fn returns an error then every change made through the transaction
+// is rolled back, along with the removal of the items that depended on
+// the keys it deleted, and the error is returned. Items evicted to make
+// room for the transaction's changes are not restored by a rollback.
+// Otherwise the changed keys are written to the Store and invalidated
+// in other caches, and if the Store rejects a write-through the whole
+// transaction is rolled back and the Store's error returned.
+// Methods of the Cache itself must not be called from within fn.
+func (t *Cache) Txn(fn func(tx *Txn) error) error {
+	return t.write(func() error {
+		tx := &Txn{cache: t}
+		err := fn(tx)
+		if err != nil {
+			tx.rollback()
+			return err
+		}
+
+		tx.commit()
+		return nil
+	})
+}
+
+// WithLock will call fn with a transaction while holding the cache lock.
+// The changed keys are written to the Store and invalidated in other
+// caches once fn returns, as with Txn, and an error from the Store is
+// reported to OnError.
+// Methods of the Cache itself must not be called from within fn.
+func (t *Cache) WithLock(fn func(tx *Txn)) {
+	err := t.write(func() error {
+		tx := &Txn{cache: t}
+		fn(tx)
+		tx.commit()
+		return nil
+	})
+	if err != nil {
+		t.expirer.report(err)
+	}
+}
+
+// Add will add a key, value, and expiration duration to the cache.
+func (tx *Txn) Add(key string, item interface{}, expiresIn time.Duration) error {
+	hashedKey := tx.cache.hash(key)
+
+	tx.save(hashedKey, key)
+	return tx.cache.add(hashedKey, key, item, tx.cache.expiration(tx.cache.jitter(tx.cache.ttl(expiresIn))))
+}
+
+// Delete will delete a key from the cache.
+func (tx *Txn) Delete(key string) error {
+	hashedKey := tx.cache.hash(key)
+
+	tx.save(hashedKey, key)
+	return tx.cache.delete(hashedKey)
+}
+
+// Extend will extend the time until expiration for the specified key.
+func (tx *Txn) Extend(key string, extend time.Duration) error {
+	hashedKey := tx.cache.hash(key)
+
+	tx.save(hashedKey, key)
+	return tx.cache.extend(hashedKey, extend)
+}
+
+// Get will return the value stored at the key.
+func (tx *Txn) Get(key string) (interface{}, error) {
+	hashedKey := tx.cache.hash(key)
+
+	return tx.cache.get(hashedKey)
+}
+
+// Touch will reset the time until expiration for the specified key.
+func (tx *Txn) Touch(key string, newTTL time.Duration) error {
+	hashedKey := tx.cache.hash(key)
+
+	tx.save(hashedKey, key)
+	return tx.cache.touch(hashedKey, tx.cache.expiration(tx.cache.ttl(newTTL)))
+}
+
+// Update updates the value at the key to the new supplied value
+func (tx *Txn) Update(key string, item interface{}) error {
+	hashedKey := tx.cache.hash(key)
+
+	tx.save(hashedKey, key)
+	return tx.cache.update(hashedKey, item)
+}
+
+// Set will add the key to the cache, or replace
+// its value and expiration if it already exists.
+func (tx *Txn) Set(key string, item interface{}, expiresIn time.Duration) error {
+	hashedKey := tx.cache.hash(key)
+
+	tx.save(hashedKey, key)
+	return tx.cache.set(hashedKey, key, item, tx.cache.expiration(tx.cache.jitter(tx.cache.ttl(expiresIn))))
+}
+
+// commit will persist every key changed by the transaction,
+// in the order they were first changed, see write
+func (tx *Txn) commit() {
+	t := tx.cache
+	for _, name := range tx.changed() {
+		t.persist(tx, t.hash(name), name)
+	}
+}
+
+// changed will return the keys changed by the transaction
+func (tx *Txn) changed() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, record := range tx.undo {
+		if record.parent == "" && !seen[record.name] {
+			seen[record.name] = true
+			names = append(names, record.name)
+		}
+	}
+
+	return names
+}
+
+// rollback will restore every key changed by the
+// transaction to its state before the transaction,
+// in the shadow cache too.
+func (tx *Txn) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.restore(tx.undo[i])
+	}
+	tx.undo = nil
+
+	if tx.shadow != nil {
+		tx.cache.mirror(func(shadow *Cache) {
+			tx.shadow.rollback()
+		})
+	}
+}
+
+// restore will return the key to the state saved in the record,
+// replacing the item now at the key
+func (tx *Txn) restore(record undoRecord) {
+	t := tx.cache
+	key := t.hash(record.name)
+	if idx, ok := t.keys[key]; ok {
+		// a dependent that is still present was not removed, and
+		// any change made to it directly has a record of its own
+		if t.slots[idx].name != record.name || record.parent != "" {
+			return
+		}
+		t.remove(idx)
+	}
+
+	if !record.existed {
+		// an item added straight to disk has no slot to remove
+		if t.spill != nil && record.parent == "" {
+			t.spill.remove(record.name)
+		}
+		return
+	}
+
+	slot := record.slot
+	slot.key = t.hash(record.name)
+	slot.tags = nil
+	idx := t.insert(slot)
+	t.expiry.set(idx, slot.ExpiresAt)
+	if t.nextExp.IsZero() || t.nextExp.After(slot.ExpiresAt) {
+		t.nextExp = slot.ExpiresAt
+	}
+	if slot.pinned {
+		t.pins++
+	}
+	if slot.priority != PriorityNormal {
+		t.prioritized++
+	}
+	t.tag(slot.key, record.slot.tags)
+
+	for _, parent := range record.parents {
+		t.deps.link(record.name, parent)
+	}
+	for _, child := range record.children {
+		t.deps.link(child, record.name)
+	}
+
+	if record.refresher != nil {
+		t.revalidate.refreshers[record.name] = record.refresher
+	}
+	if record.reload != nil {
+		t.scheduleReload(record.name, record.reload)
+	}
+	t.evict(slot.key)
+}
+
+// save will record the state of the key, and of the keys depending on
+// it that a change to it may remove, so that they can be restored if
+// the transaction is rolled back. The key is saved in the shadow cache
+// too, since the changes to it are mirrored there.
+func (tx *Txn) save(key uint64, name string) {
+	tx.record(key, name, "")
+	for _, dependent := range tx.cache.dependents(name) {
+		tx.record(tx.cache.hash(dependent), dependent, name)
+	}
+
+	tx.cache.mirror(func(shadow *Cache) {
+		if tx.shadow == nil {
+			tx.shadow = &Txn{cache: shadow}
+		}
+		tx.shadow.save(key, name)
+	})
+}
+
+// record will append the state of the key to the undo log
+func (tx *Txn) record(key uint64, name, parent string) {
+	t := tx.cache
+	record := undoRecord{
+		name:   name,
+		parent: parent,
+	}
+
+	if idx, ok := t.keys[key]; ok && t.slots[idx].name == name && !t.slots[idx].empty {
+		record.existed = true
+		record.slot = t.slots[idx]
+		record.slot.tags = append([]string(nil), t.slots[idx].tags...)
+		record.parents = setNames(t.deps.parents[name])
+		record.children = setNames(t.deps.children[name])
+		record.refresher = t.revalidate.refreshers[name]
+
+		t.reload.mu.Lock()
+		record.reload = t.reload.timers[name]
+		t.reload.mu.Unlock()
+	}
+
+	tx.undo = append(tx.undo, record)
+}
+
+// setNames will return the names in the set
+func setNames(set map[string]struct{}) []string {
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+
+	return names
+}
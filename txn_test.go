@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheWithLock(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Add("count", 1, 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	cache.WithLock(func(tx *Txn) {
+		value, err := tx.Get("count")
+		if err != nil {
+			t.Errorf("error while getting key: %+v", err)
+		}
+
+		err = tx.Update("count", value.(int)+1)
+		if err != nil {
+			t.Errorf("error while updating key: %+v", err)
+		}
+
+		err = tx.Add("other", "value", 10*time.Minute)
+		if err != nil {
+			t.Errorf("error adding key: %+v", err)
+		}
+	})
+
+	value, err := cache.Get("count")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(int) != 2 {
+		t.Errorf("value was not updated in transaction: %d", value)
+	}
+
+	_, err = cache.Get("other")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+}
+
+func TestCacheTxn(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Add("forward", "a", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Txn(func(tx *Txn) error {
+		err := tx.Set("forward", "b", 10*time.Minute)
+		if err != nil {
+			return err
+		}
+
+		return tx.Set("reverse", "b", 10*time.Minute)
+	})
+	if err != nil {
+		t.Errorf("error while committing transaction: %+v", err)
+	}
+
+	value, err := cache.Get("reverse")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "b" {
+		t.Errorf("returned value was %s", value)
+	}
+}
+
+func TestCacheTxnRollback(t *testing.T) {
+	cache := NewCache(nil)
+	err := cache.Add("forward", "a", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Add("deleted", "value", 10*time.Minute)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+
+	err = cache.Txn(func(tx *Txn) error {
+		err := tx.Set("forward", "b", 1*time.Minute)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Add("reverse", "b", 10*time.Minute)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Delete("deleted")
+		if err != nil {
+			return err
+		}
+
+		return tx.Update("dne", "value")
+	})
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	value, err := cache.Get("forward")
+	if err != nil {
+		t.Errorf("error while getting key: %+v", err)
+	}
+
+	if value.(string) != "a" {
+		t.Errorf("value was not rolled back: %s", value)
+	}
+
+	_, err = cache.Get("reverse")
+	if err != ErrDNE {
+		t.Errorf("added key was not rolled back: %+v", err)
+	}
+
+	_, err = cache.Get("deleted")
+	if err != nil {
+		t.Errorf("deleted key was not rolled back: %+v", err)
+	}
+}
+
+func TestCacheTxnRollbackState(t *testing.T) {
+	cache := NewCache(nil)
+	defer cache.Close()
+
+	err := cache.Add("parent", "a", 10*time.Minute,
+		WithTags("tag"),
+		WithMeta(map[string]string{"etag": "1"}),
+		WithPriority(PriorityHigh),
+	)
+	if err != nil {
+		t.Errorf("error adding key: %+v", err)
+	}
+	cache.Pin("parent", false)
+	_, version, _ := cache.GetVersioned("parent")
+
+	cache.Add("child", "b", 10*time.Minute, WithTags("tag"))
+	err = cache.AddDependency("child", "parent")
+	if err != nil {
+		t.Errorf("error adding dependency: %+v", err)
+	}
+
+	err = cache.Txn(func(tx *Txn) error {
+		err := tx.Delete("parent")
+		if err != nil {
+			return err
+		}
+
+		return ErrDNE
+	})
+	if err != ErrDNE {
+		t.Errorf("should have returned ErrDNE but returned %+v", err)
+	}
+
+	_, meta, err := cache.GetWithMeta("parent")
+	if err != nil || meta["etag"] != "1" {
+		t.Errorf("metadata was not rolled back: %v, %+v", meta, err)
+	}
+
+	_, restored, _ := cache.GetVersioned("parent")
+	if restored != version {
+		t.Errorf("version was not rolled back: %d, expected %d", restored, version)
+	}
+
+	if !cache.Pinned("parent") {
+		t.Error("pin was not rolled back")
+	}
+
+	if p := cache.slots[cache.keys[cache.hash("parent")]].priority; p != PriorityHigh || cache.prioritized != 1 {
+		t.Errorf("priority was not rolled back: %v", p)
+	}
+
+	_, err = cache.Get("child")
+	if err != nil {
+		t.Errorf("dependent removed by the deleted key was not rolled back: %+v", err)
+	}
+
+	err = cache.Unpin("parent")
+	if err != nil {
+		t.Errorf("error unpinning key: %+v", err)
+	}
+
+	cache.Delete("parent")
+	if _, err := cache.Get("child"); err != ErrDNE {
+		t.Errorf("dependency was not rolled back: %+v", err)
+	}
+
+	if n := cache.InvalidateTag("tag"); n != 0 {
+		t.Errorf("tags were not cleaned up after the rollback: %d", n)
+	}
+}
+
+func TestCacheTxnPersist(t *testing.T) {
+	store := newMapStore()
+	bus := newMemoryBus()
+	cache := NewCache(&CacheConfig{Store: store, Invalidator: bus})
+	defer cache.Close()
+
+	cache.Add("a", "one", 10*time.Minute)
+	err := cache.Txn(func(tx *Txn) error {
+		err := tx.Update("a", "two")
+		if err != nil {
+			return err
+		}
+
+		return tx.Add("b", "one", 10*time.Minute)
+	})
+	if err != nil {
+		t.Errorf("error while committing transaction: %+v", err)
+	}
+
+	for key, expected := range map[string]string{"a": "two", "b": "one"} {
+		if item, ok := store.get(key); !ok || item != expected {
+			t.Errorf("expected the store to hold %s at %s, got %v", expected, key, item)
+		}
+	}
+
+	if !waitFor(func() bool { return len(bus.keys()) == 3 }) {
+		t.Errorf("transaction was not invalidated: %v", bus.keys())
+	}
+
+	store.setFail(true)
+	err = cache.Txn(func(tx *Txn) error {
+		err := tx.Delete("a")
+		if err != nil {
+			return err
+		}
+
+		return tx.Set("c", "one", 10*time.Minute)
+	})
+	if err != errStoreDown {
+		t.Errorf("expected the store error, got %+v", err)
+	}
+
+	if _, err := cache.Get("a"); err != nil {
+		t.Errorf("deleted key was not rolled back: %+v", err)
+	}
+
+	if _, err := cache.Get("c"); err != ErrDNE {
+		t.Errorf("added key was not rolled back: %+v", err)
+	}
+}
+
+func TestCacheTxnShadowAndJitter(t *testing.T) {
+	clock := NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewCache(&CacheConfig{
+		Clock:     clock,
+		TTLJitter: 0.1,
+		Shadow:    &CacheConfig{Clock: clock},
+	})
+	defer cache.Close()
+
+	cache.Add("kept", "a", time.Hour)
+
+	err := cache.Txn(func(tx *Txn) error {
+		tx.Set("kept", "b", time.Hour)
+		tx.Add("added", "b", time.Hour)
+		return ErrDNE
+	})
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+
+	// the writes mirrored to the shadow are rolled back with the cache
+	if item, err := cache.shadow.Get("kept"); err != nil || item != "a" {
+		t.Errorf("expected the shadow item to be rolled back, got %v: %+v", item, err)
+	}
+
+	if _, err := cache.shadow.Get("added"); err != ErrDNE {
+		t.Errorf("expected the shadow add to be rolled back, got %+v", err)
+	}
+
+	distinct := make(map[time.Time]bool)
+	cache.Txn(func(tx *Txn) error {
+		for i := 0; i < 20; i++ {
+			key := string(rune('a' + i))
+			tx.Set(key, i, time.Hour)
+			distinct[cache.slots[cache.keys[cache.hash(key)]].ExpiresAt] = true
+		}
+		return nil
+	})
+
+	if len(distinct) < 10 {
+		t.Errorf("expected the ttl of Set to be jittered, got %d distinct expirations", len(distinct))
+	}
+}
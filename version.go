@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrVersionMismatch is returned by UpdateVersioned and SetVersioned when
+// the item was written since its version was read
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// GetVersioned will get an item from the cache along with its version.
+// Versions increase every time an item is written, including when a key
+// is deleted and added again, so passing the version to UpdateVersioned
+// only succeeds if nothing wrote the key in between.
+func (t *Cache) GetVersioned(key string, opts ...GetOption) (interface{}, uint64, error) {
+	o := newGetOptions(opts)
+	t.lockGet(&o)
+	defer t.unlockGet(&o)
+
+	hashedKey := t.hash(key)
+
+	item, err := t.getWithOptions(hashedKey, o)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return item, t.slots[t.keys[hashedKey]].version, nil
+}
+
+// UpdateVersioned will update the item at the key if its version
+// still matches the version returned by GetVersioned, or return
+// ErrVersionMismatch when the item was written in the meantime.
+func (t *Cache) UpdateVersioned(key string, item interface{}, version uint64) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		idx, ok := t.live(hashedKey)
+		if !ok {
+			return ErrDNE
+		}
+
+		if t.slots[idx].version != version {
+			return ErrVersionMismatch
+		}
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.update(hashedKey, item)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
+
+// SetVersioned will replace the item at the key and reset its expiration
+// to expiresIn from now, with DefaultExpiration and NoExpiration as in Add,
+// if its version still matches the version returned by GetVersioned. The
+// version check and both changes are made as one write, so no other write
+// can come between them. It returns ErrVersionMismatch when the item was
+// written in the meantime.
+func (t *Cache) SetVersioned(key string, item interface{}, expiresIn time.Duration, version uint64) error {
+	return t.write(func() error {
+		hashedKey := t.hash(key)
+
+		idx, ok := t.live(hashedKey)
+		if !ok {
+			return ErrDNE
+		}
+
+		if t.slots[idx].version != version {
+			return ErrVersionMismatch
+		}
+
+		tx := t.beginStore(hashedKey, key)
+		err := t.touch(hashedKey, t.expiration(t.jitter(t.ttl(expiresIn))))
+		if err != nil {
+			return err
+		}
+
+		err = t.update(hashedKey, item)
+		if err != nil {
+			return err
+		}
+
+		t.persist(tx, hashedKey, key)
+
+		return nil
+	})
+}
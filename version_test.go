@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateVersioned(t *testing.T) {
+	c := NewCache(&CacheConfig{})
+	defer c.Close()
+
+	c.Add("key", 1, time.Minute)
+
+	item, version, err := c.GetVersioned("key")
+	if err != nil || item != 1 {
+		t.Errorf("unexpected item %v: %+v", item, err)
+	}
+
+	err = c.UpdateVersioned("key", 2, version)
+	if err != nil {
+		t.Errorf("UpdateVersioned error: %+v", err)
+	}
+
+	err = c.UpdateVersioned("key", 3, version)
+	if err != ErrVersionMismatch {
+		t.Errorf("expected ErrVersionMismatch for a stale version, got %+v", err)
+	}
+
+	_, next, _ := c.GetVersioned("key")
+	if next <= version {
+		t.Errorf("expected the version to increase, got %d after %d", next, version)
+	}
+
+	c.Delete("key")
+	c.Add("key", 1, time.Minute)
+
+	err = c.UpdateVersioned("key", 4, next)
+	if err != ErrVersionMismatch {
+		t.Errorf("expected a re-added key to have a new version, got %+v", err)
+	}
+
+	err = c.UpdateVersioned("missing", 1, 1)
+	if err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
+
+func TestUpdateVersionedConcurrent(t *testing.T) {
+	c := NewCache(&CacheConfig{})
+	defer c.Close()
+
+	c.Add("counter", 0, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for {
+					item, version, _ := c.GetVersioned("counter")
+					if c.UpdateVersioned("counter", item.(int)+1, version) == nil {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	item, _ := c.Get("counter")
+	if item != 1000 {
+		t.Errorf("expected every increment to apply, got %v", item)
+	}
+}
+
+func TestSetVersioned(t *testing.T) {
+	c := NewCache(&CacheConfig{
+		Clock:          NewManualClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		DisableCleaner: true,
+	})
+	defer c.Close()
+
+	c.Add("key", 1, time.Minute)
+	_, version, _ := c.GetVersioned("key")
+
+	err := c.SetVersioned("key", 2, time.Hour, version)
+	if err != nil {
+		t.Errorf("SetVersioned error: %+v", err)
+	}
+
+	if item, _ := c.Get("key"); item != 2 {
+		t.Errorf("expected the item to be replaced, got %v", item)
+	}
+
+	if ttl, err := c.TTL("key"); err != nil || ttl != time.Hour {
+		t.Errorf("expected the expiration to be reset, got %s: %+v", ttl, err)
+	}
+
+	err = c.SetVersioned("key", 3, time.Second, version)
+	if err != ErrVersionMismatch {
+		t.Errorf("expected ErrVersionMismatch for a stale version, got %+v", err)
+	}
+
+	if ttl, _ := c.TTL("key"); ttl != time.Hour {
+		t.Errorf("expected a rejected write to keep the expiration, got %s", ttl)
+	}
+
+	if err := c.SetVersioned("missing", 1, time.Minute, 1); err != ErrDNE {
+		t.Errorf("expected ErrDNE, got %+v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// warmBatch is the number of items added under each acquisition of the lock
+const warmBatch = 1024
+
+// WarmSource yields the items to load into the cache, see Warm.
+// It should stop and return the error when yield returns one.
+type WarmSource func(yield func(key string, item interface{}, ttl time.Duration) error) error
+
+// WarmOption changes the behavior of Warm
+type WarmOption func(o *warmOptions)
+
+type warmOptions struct {
+	size     int
+	progress func(loaded int)
+}
+
+// WithWarmSize will size the cache's index for the number of items
+// the source is expected to yield, so that it does not grow as they
+// are added
+func WithWarmSize(n int) WarmOption {
+	return func(o *warmOptions) {
+		o.size = n
+	}
+}
+
+// WithWarmProgress will call fn with the number of items loaded
+// so far after each batch of items is added to the cache
+func WithWarmProgress(fn func(loaded int)) WarmOption {
+	return func(o *warmOptions) {
+		o.progress = fn
+	}
+}
+
+// Warm will load the items yielded by the source into the cache, for
+// services that need a hit rate before serving traffic. Items are added
+// in batches, taking the lock once per batch rather than once per item,
+// and replace existing items with the same keys. They are not written
+// to the Store, nor published to the Invalidator, as the source is
+// usually the Store itself.
+//
+// Yield returns ctx.Err() once ctx is done. Items that cannot be added,
+// such as those returning ErrCollision or ErrTooLarge, are skipped, and
+// Warm returns the error returned by the source or otherwise the first
+// error adding an item.
+func (t *Cache) Warm(ctx context.Context, source WarmSource, opts ...WarmOption) error {
+	var o warmOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.size > 0 {
+		t.mu.Lock()
+		t.reserve(o.size)
+		t.mu.Unlock()
+	}
+
+	type warmItem struct {
+		key  string
+		item interface{}
+		ttl  time.Duration
+	}
+
+	var loaded int
+	var first error
+	batch := make([]warmItem, 0, warmBatch)
+	flush := func() {
+		t.mu.Lock()
+		for _, w := range batch {
+			hk := t.hash(w.key)
+			err := t.set(hk, w.key, w.item, t.expiration(t.jitter(t.ttl(w.ttl))))
+			if err != nil {
+				if first == nil {
+					first = err
+				}
+				continue
+			}
+			loaded++
+		}
+		t.mu.Unlock()
+		batch = batch[:0]
+
+		if o.progress != nil {
+			o.progress(loaded)
+		}
+	}
+
+	err := source(func(key string, item interface{}, ttl time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch = append(batch, warmItem{key: key, item: item, ttl: ttl})
+		if len(batch) == warmBatch {
+			flush()
+		}
+
+		return nil
+	})
+	flush()
+
+	if err != nil {
+		return err
+	}
+
+	return first
+}
+
+// reserve will grow the index of the cache to hold n more items
+// without being resized. The lock must be held.
+func (t *Cache) reserve(n int) {
+	keys := make(map[uint64]int, len(t.keys)+n)
+	for k, idx := range t.keys {
+		keys[k] = idx
+	}
+	t.keys = keys
+
+	if cap(t.slots)-len(t.slots) < n {
+		slots := make([]Slot, len(t.slots), len(t.slots)+n)
+		copy(slots, t.slots)
+		t.slots = slots
+	}
+}
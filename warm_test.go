@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWarm(t *testing.T) {
+	cache := NewCache(&CacheConfig{MaxBytes: 1 << 20})
+	defer cache.Close()
+
+	var progress []int
+	source := func(yield func(key string, item interface{}, ttl time.Duration) error) error {
+		for i := 0; i < 2*warmBatch+1; i++ {
+			if err := yield(fmt.Sprintf("key-%d", i), i, time.Hour); err != nil {
+				return err
+			}
+		}
+
+		return yield("large", make([]byte, 2<<20), time.Hour)
+	}
+
+	err := cache.Warm(context.Background(), source, WithWarmSize(2*warmBatch+2), WithWarmProgress(func(loaded int) {
+		progress = append(progress, loaded)
+	}))
+	if err != ErrTooLarge {
+		t.Errorf("expected the error adding the large item, got %+v", err)
+	}
+
+	if n := len(cache.Keys()); n != 2*warmBatch+1 {
+		t.Errorf("expected every item but the large one to be loaded, got %d", n)
+	}
+
+	if fmt.Sprint(progress) != fmt.Sprint([]int{warmBatch, 2 * warmBatch, 2*warmBatch + 1}) {
+		t.Errorf("expected progress after each batch, got %v", progress)
+	}
+
+	if err := cache.CheckInvariants(); err != nil {
+		t.Errorf("CheckInvariants error: %+v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var yielded int
+	err = cache.Warm(ctx, func(yield func(key string, item interface{}, ttl time.Duration) error) error {
+		for i := 0; ; i++ {
+			if i == 10 {
+				cancel()
+			}
+
+			if err := yield(fmt.Sprintf("other-%d", i), i, NoExpiration); err != nil {
+				return err
+			}
+			yielded++
+		}
+	})
+	if err != context.Canceled || yielded != 10 {
+		t.Errorf("expected warming to stop once the context is done, got %+v after %d items", err, yielded)
+	}
+}